@@ -0,0 +1,36 @@
+package apikey
+
+import (
+	"errors"
+
+	"github.com/goava/di"
+	"github.com/spf13/cobra"
+
+	"gitlab.com/olaris/olaris-server/cmd/root"
+	"gitlab.com/olaris/olaris-server/pkg/cmd"
+)
+
+type APIKeyCommand cmd.Command
+
+func New() di.Option {
+	return di.Options(
+		di.Provide(NewAPIKeyCommand, di.As(new(APIKeyCommand))),
+		di.Invoke(RegisterAPIKeyCommand),
+	)
+}
+
+func RegisterAPIKeyCommand(rootCommand root.RootCommand, apiKeyCommand APIKeyCommand) {
+	rootCommand.GetCobraCommand().AddCommand(apiKeyCommand.GetCobraCommand())
+}
+
+func NewAPIKeyCommand() *cmd.CobraCommand {
+	c := &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage API keys",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return errors.New("Subcommand required")
+		},
+	}
+
+	return &cmd.CobraCommand{Command: c}
+}
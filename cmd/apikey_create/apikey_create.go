@@ -0,0 +1,65 @@
+package apikey_create
+
+import (
+	"fmt"
+
+	"github.com/goava/di"
+	"github.com/spf13/cobra"
+
+	"gitlab.com/olaris/olaris-server/cmd/apikey"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/pkg/cmd"
+)
+
+type APIKeyCreateCommand cmd.Command
+
+func New() di.Option {
+	return di.Options(
+		di.Provide(NewAPIKeyCreateCommand, di.As(new(APIKeyCreateCommand))),
+		di.Invoke(RegisterAPIKeyCreateCommand),
+	)
+}
+
+func RegisterAPIKeyCreateCommand(apiKeyCommand apikey.APIKeyCommand, apiKeyCreateCommand APIKeyCreateCommand) {
+	apiKeyCommand.GetCobraCommand().AddCommand(apiKeyCreateCommand.GetCobraCommand())
+}
+
+func NewAPIKeyCreateCommand() *cmd.CobraCommand {
+	var name string
+	var username string
+	var scopes []string
+
+	c := &cobra.Command{
+		Use:   "create",
+		Short: "Create a new API key",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mctx := app.NewDefaultMDContext()
+			defer mctx.Db.Close()
+
+			user, err := db.FindUserByUsername(username)
+			if err != nil {
+				return fmt.Errorf("user '%s' could not be found", username)
+			}
+
+			_, key, err := db.CreateAPIKey(name, user.ID, 0, scopes)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(key)
+			return nil
+		},
+	}
+
+	c.Flags().StringVar(&name, "name", "", "A name to recognise this key by")
+	c.MarkFlagRequired("name")
+
+	c.Flags().StringVar(&username, "username", "", "User the key acts on behalf of")
+	c.MarkFlagRequired("username")
+
+	c.Flags().StringSliceVar(&scopes, "scopes", []string{db.APIKeyScopeMetadataRead},
+		"Comma-separated list of scopes (metadata:read, library:rescan, playstate:write, admin)")
+
+	return &cmd.CobraCommand{Command: c}
+}
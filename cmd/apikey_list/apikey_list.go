@@ -0,0 +1,59 @@
+package apikey_list
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/goava/di"
+	"github.com/spf13/cobra"
+
+	"gitlab.com/olaris/olaris-server/cmd/apikey"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/pkg/cmd"
+)
+
+type APIKeyListCommand cmd.Command
+
+func New() di.Option {
+	return di.Options(
+		di.Provide(NewAPIKeyListCommand, di.As(new(APIKeyListCommand))),
+		di.Invoke(RegisterAPIKeyListCommand),
+	)
+}
+
+func RegisterAPIKeyListCommand(apiKeyCommand apikey.APIKeyCommand, apiKeyListCommand APIKeyListCommand) {
+	apiKeyCommand.GetCobraCommand().AddCommand(apiKeyListCommand.GetCobraCommand())
+}
+
+func NewAPIKeyListCommand() *cmd.CobraCommand {
+	c := &cobra.Command{
+		Use:   "list",
+		Short: "List all API keys",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mctx := app.NewDefaultMDContext()
+			defer mctx.Db.Close()
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tNAME\tUSER\tSCOPES\tLAST USED\tREVOKED")
+			for _, key := range db.AllAPIKeys() {
+				username := ""
+				if user, err := db.FindUser(key.UserID); err == nil {
+					username = user.Username
+				}
+				lastUsed := "never"
+				if key.LastUsedAt != nil {
+					lastUsed = key.LastUsedAt.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%t\n",
+					key.ID, key.Name, username, strings.Join(key.ScopeList(), ","), lastUsed, key.Revoked())
+			}
+			return w.Flush()
+		},
+	}
+
+	return &cmd.CobraCommand{Command: c}
+}
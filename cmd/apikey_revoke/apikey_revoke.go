@@ -0,0 +1,45 @@
+package apikey_revoke
+
+import (
+	"github.com/goava/di"
+	"github.com/spf13/cobra"
+
+	"gitlab.com/olaris/olaris-server/cmd/apikey"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/pkg/cmd"
+)
+
+type APIKeyRevokeCommand cmd.Command
+
+func New() di.Option {
+	return di.Options(
+		di.Provide(NewAPIKeyRevokeCommand, di.As(new(APIKeyRevokeCommand))),
+		di.Invoke(RegisterAPIKeyRevokeCommand),
+	)
+}
+
+func RegisterAPIKeyRevokeCommand(apiKeyCommand apikey.APIKeyCommand, apiKeyRevokeCommand APIKeyRevokeCommand) {
+	apiKeyCommand.GetCobraCommand().AddCommand(apiKeyRevokeCommand.GetCobraCommand())
+}
+
+func NewAPIKeyRevokeCommand() *cmd.CobraCommand {
+	var id uint
+
+	c := &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke an API key",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mctx := app.NewDefaultMDContext()
+			defer mctx.Db.Close()
+
+			_, err := db.RevokeAPIKey(id)
+			return err
+		},
+	}
+
+	c.Flags().UintVar(&id, "id", 0, "ID of the API key, see 'apikey list'")
+	c.MarkFlagRequired("id")
+
+	return &cmd.CobraCommand{Command: c}
+}
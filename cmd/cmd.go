@@ -3,6 +3,10 @@ package cmd
 import (
 	"github.com/goava/di"
 
+	"gitlab.com/olaris/olaris-server/cmd/apikey"
+	"gitlab.com/olaris/olaris-server/cmd/apikey_create"
+	"gitlab.com/olaris/olaris-server/cmd/apikey_list"
+	"gitlab.com/olaris/olaris-server/cmd/apikey_revoke"
 	"gitlab.com/olaris/olaris-server/cmd/dumpdebug"
 	"gitlab.com/olaris/olaris-server/cmd/identify"
 	"gitlab.com/olaris/olaris-server/cmd/identify_movie"
@@ -12,6 +16,10 @@ import (
 	"gitlab.com/olaris/olaris-server/cmd/serve"
 	"gitlab.com/olaris/olaris-server/cmd/user"
 	"gitlab.com/olaris/olaris-server/cmd/user_create"
+	"gitlab.com/olaris/olaris-server/cmd/user_delete"
+	"gitlab.com/olaris/olaris-server/cmd/user_list"
+	"gitlab.com/olaris/olaris-server/cmd/user_passwd"
+	"gitlab.com/olaris/olaris-server/cmd/user_promote"
 )
 
 func New() di.Option {
@@ -19,6 +27,14 @@ func New() di.Option {
 		root.New(),
 		user.New(),
 		user_create.New(),
+		user_list.New(),
+		user_passwd.New(),
+		user_delete.New(),
+		user_promote.New(),
+		apikey.New(),
+		apikey_create.New(),
+		apikey_list.New(),
+		apikey_revoke.New(),
 		serve.New(),
 		identify.New(),
 		identify_movie.New(),
@@ -3,6 +3,8 @@ package main
 import (
 	"flag"
 	"fmt"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/app"
 	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"os"
 )
@@ -18,10 +20,18 @@ func main() {
 
 	}
 
-	token, err := auth.CreateStreamingJWT(0, *filepath)
+	mctx := app.NewDefaultMDContext()
+	defer mctx.Db.Close()
+
+	sessionID := helpers.RandAlphaString(16)
+	token, _, err := auth.CreateStreamingTicket(auth.StreamingTicketOptions{
+		FilePath:  *filepath,
+		SessionID: sessionID,
+	})
 	if err != nil {
 		fmt.Printf("Failed to create streaming token: %s", err.Error())
 		os.Exit(1)
 	}
 	fmt.Println(token)
+	fmt.Fprintf(os.Stderr, "Valid for playback session session:%s\n", sessionID)
 }
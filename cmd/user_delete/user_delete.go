@@ -0,0 +1,55 @@
+package user_delete
+
+import (
+	"fmt"
+
+	"github.com/goava/di"
+	"github.com/spf13/cobra"
+
+	"gitlab.com/olaris/olaris-server/cmd/user"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/pkg/cmd"
+)
+
+type UserDeleteCommand cmd.Command
+
+func New() di.Option {
+	return di.Options(
+		di.Provide(NewUserDeleteCommand, di.As(new(UserDeleteCommand))),
+		di.Invoke(RegisterUserDeleteCommand),
+	)
+}
+
+func RegisterUserDeleteCommand(userCommand user.UserCommand, userDeleteCommand UserDeleteCommand) {
+	userCommand.GetCobraCommand().AddCommand(userDeleteCommand.GetCobraCommand())
+}
+
+func NewUserDeleteCommand() *cmd.CobraCommand {
+	var username string
+
+	c := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete a user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mctx := app.NewDefaultMDContext()
+			defer mctx.Db.Close()
+
+			u, err := db.FindUserByUsername(username)
+			if err != nil {
+				return fmt.Errorf("user '%s' could not be found", username)
+			}
+			if u.Admin && db.AdminCount() <= 1 {
+				return fmt.Errorf("the last admin can't be deleted")
+			}
+
+			_, err = db.DeleteUser(u.ID)
+			return err
+		},
+	}
+
+	c.Flags().StringVar(&username, "username", "", "")
+	c.MarkFlagRequired("username")
+
+	return &cmd.CobraCommand{Command: c}
+}
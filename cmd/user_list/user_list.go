@@ -0,0 +1,50 @@
+package user_list
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/goava/di"
+	"github.com/spf13/cobra"
+
+	"gitlab.com/olaris/olaris-server/cmd/user"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/pkg/cmd"
+)
+
+type UserListCommand cmd.Command
+
+func New() di.Option {
+	return di.Options(
+		di.Provide(NewUserListCommand, di.As(new(UserListCommand))),
+		di.Invoke(RegisterUserListCommand),
+	)
+}
+
+func RegisterUserListCommand(userCommand user.UserCommand, userListCommand UserListCommand) {
+	userCommand.GetCobraCommand().AddCommand(userListCommand.GetCobraCommand())
+}
+
+func NewUserListCommand() *cmd.CobraCommand {
+	c := &cobra.Command{
+		Use:   "list",
+		Short: "List all users",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mctx := app.NewDefaultMDContext()
+			defer mctx.Db.Close()
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "ID\tUSERNAME\tADMIN\tTWO-FACTOR\tCREATED")
+			for _, u := range db.AllUsers() {
+				fmt.Fprintf(w, "%d\t%s\t%t\t%t\t%s\n",
+					u.ID, u.Username, u.Admin, u.TOTPEnabled, u.CreatedAt.Format(time.RFC3339))
+			}
+			return w.Flush()
+		},
+	}
+
+	return &cmd.CobraCommand{Command: c}
+}
@@ -0,0 +1,55 @@
+package user_passwd
+
+import (
+	"fmt"
+
+	"github.com/goava/di"
+	"github.com/spf13/cobra"
+
+	"gitlab.com/olaris/olaris-server/cmd/user"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/pkg/cmd"
+)
+
+type UserPasswdCommand cmd.Command
+
+func New() di.Option {
+	return di.Options(
+		di.Provide(NewUserPasswdCommand, di.As(new(UserPasswdCommand))),
+		di.Invoke(RegisterUserPasswdCommand),
+	)
+}
+
+func RegisterUserPasswdCommand(userCommand user.UserCommand, userPasswdCommand UserPasswdCommand) {
+	userCommand.GetCobraCommand().AddCommand(userPasswdCommand.GetCobraCommand())
+}
+
+func NewUserPasswdCommand() *cmd.CobraCommand {
+	var username string
+	var password string
+
+	c := &cobra.Command{
+		Use:   "passwd",
+		Short: "Set a new password for a user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mctx := app.NewDefaultMDContext()
+			defer mctx.Db.Close()
+
+			u, err := db.FindUserByUsername(username)
+			if err != nil {
+				return fmt.Errorf("user '%s' could not be found", username)
+			}
+
+			return db.UpdatePassword(u.ID, password)
+		},
+	}
+
+	c.Flags().StringVar(&username, "username", "", "")
+	c.MarkFlagRequired("username")
+
+	c.Flags().StringVar(&password, "password", "", "")
+	c.MarkFlagRequired("password")
+
+	return &cmd.CobraCommand{Command: c}
+}
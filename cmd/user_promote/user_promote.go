@@ -0,0 +1,55 @@
+package user_promote
+
+import (
+	"fmt"
+
+	"github.com/goava/di"
+	"github.com/spf13/cobra"
+
+	"gitlab.com/olaris/olaris-server/cmd/user"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/pkg/cmd"
+)
+
+type UserPromoteCommand cmd.Command
+
+func New() di.Option {
+	return di.Options(
+		di.Provide(NewUserPromoteCommand, di.As(new(UserPromoteCommand))),
+		di.Invoke(RegisterUserPromoteCommand),
+	)
+}
+
+func RegisterUserPromoteCommand(userCommand user.UserCommand, userPromoteCommand UserPromoteCommand) {
+	userCommand.GetCobraCommand().AddCommand(userPromoteCommand.GetCobraCommand())
+}
+
+func NewUserPromoteCommand() *cmd.CobraCommand {
+	var username string
+	var demote bool
+
+	c := &cobra.Command{
+		Use:   "promote",
+		Short: "Make a user admin, or revoke admin rights with --demote",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mctx := app.NewDefaultMDContext()
+			defer mctx.Db.Close()
+
+			u, err := db.FindUserByUsername(username)
+			if err != nil {
+				return fmt.Errorf("user '%s' could not be found", username)
+			}
+
+			_, err = db.SetUserAdmin(u.ID, !demote)
+			return err
+		},
+	}
+
+	c.Flags().StringVar(&username, "username", "", "")
+	c.MarkFlagRequired("username")
+
+	c.Flags().BoolVar(&demote, "demote", false, "Revoke admin rights instead")
+
+	return &cmd.CobraCommand{Command: c}
+}
@@ -26,6 +26,9 @@ const dashManifestTemplate = `<?xml version="1.0" encoding="utf-8"?>
 					mimeType="video/mp4"
 					codecs="{{$s.Representation.Codecs}}"
 					height="{{$s.Representation.Height}}" bandwidth="{{$s.Representation.BitRate}}">
+				{{ if $s.Representation.IsHDR -}}
+				<SupplementalProperty schemeIdUri="urn:mpeg:mpegB:cicp:TransferCharacteristics" value="{{$s.Representation.TransferCharacteristics}}"/>
+				{{ end -}}
 				<SegmentTemplate timescale="1000" duration="{{$.segmentDurationMs}}" initialization="{{$s.Stream.StreamId}}/$RepresentationID$/init.mp4" media="{{$s.Stream.StreamId}}/$RepresentationID$/$Number$.m4s" startNumber="0">
 				</SegmentTemplate>
 			</Representation>
@@ -38,6 +41,7 @@ const dashManifestTemplate = `<?xml version="1.0" encoding="utf-8"?>
 					id="{{ $s.Representation.RepresentationId }}"
 					mimeType="audio/mp4" codecs="{{ $s.Representation.Codecs }}"
 					bandwidth="{{$s.Representation.BitRate}}">
+				<AudioChannelConfiguration schemeIdUri="urn:mpeg:dash:23003:3:audio_channel_configuration:2011" value="{{$s.Representation.Channels}}"/>
 				<SegmentTemplate timescale="1000" duration="{{$.segmentDurationMs}}" initialization="{{$s.Stream.StreamId}}/$RepresentationID$/init.mp4" media="{{$s.Stream.StreamId}}/$RepresentationID$/$Number$.m4s" startNumber="0">
 				</SegmentTemplate>
 			</Representation>
@@ -0,0 +1,126 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+)
+
+// TimeRange is a part of a media file.
+type TimeRange struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Duration returns the length of the range.
+func (r TimeRange) Duration() time.Duration {
+	return r.End - r.Start
+}
+
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
+
+// ExtractAudioPCM returns the first audio stream of the file from start on for the given duration,
+// downmixed to mono 16-bit samples at the given sample rate.
+func ExtractAudioPCM(fileLocator filesystem.FileLocator, start time.Duration, duration time.Duration, sampleRate int) ([]int16, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error",
+		"-ss", formatSeconds(start),
+		"-i", buildFfmpegUrlFromFileLocator(fileLocator),
+		"-t", formatSeconds(duration),
+		"-map", "0:a:0",
+		"-ac", "1", "-ar", strconv.Itoa(sampleRate),
+		"-f", "s16le", "pipe:1")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	log.Debugf("Starting %s with args %s", cmd.Path, cmd.Args)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %s: %s", err, stderr.String())
+	}
+
+	samples := make([]int16, stdout.Len()/2)
+	if err := binary.Read(&stdout, binary.LittleEndian, samples); err != nil {
+		return nil, err
+	}
+	return samples, nil
+}
+
+var (
+	blackDetectRegex  = regexp.MustCompile(`black_start:\s*([0-9.]+)\s+black_end:\s*([0-9.]+)`)
+	silenceStartRegex = regexp.MustCompile(`silence_start:\s*(-?[0-9.]+)`)
+	silenceEndRegex   = regexp.MustCompile(`silence_end:\s*([0-9.]+)`)
+	detectedLineRegex = regexp.MustCompile(`(?m)^.*(black_start|silence_start|silence_end).*$`)
+)
+
+// DetectBlackAndSilence returns the parts of the file between start and end that have black video and
+// silent audio. Only keyframes are checked for black, which is precise enough for finding
+// scene transitions and much faster than decoding everything.
+func DetectBlackAndSilence(fileLocator filesystem.FileLocator, start time.Duration, end time.Duration) (black []TimeRange, silence []TimeRange, err error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-nostats", "-loglevel", "info",
+		"-ss", formatSeconds(start),
+		"-skip_frame", "nokey",
+		"-i", buildFfmpegUrlFromFileLocator(fileLocator),
+		"-t", formatSeconds(end-start),
+		"-map", "0:v:0", "-map", "0:a:0",
+		"-vf", "blackdetect=d=0.1:pix_th=0.10",
+		"-af", "silencedetect=n=-45dB:d=0.5",
+		"-f", "null", "-")
+	cmd.Stderr = &stderr
+
+	log.Debugf("Starting %s with args %s", cmd.Path, cmd.Args)
+	if err := cmd.Run(); err != nil {
+		return nil, nil, fmt.Errorf("ffmpeg failed: %s: %s", err, stderr.String())
+	}
+
+	black, silence = parseBlackAndSilence(stderr.String(), end-start)
+	for i := range black {
+		black[i].Start += start
+		black[i].End += start
+	}
+	for i := range silence {
+		silence[i].Start += start
+		silence[i].End += start
+	}
+	return black, silence, nil
+}
+
+// parseBlackAndSilence reads the output of the blackdetect and silencedetect filters. Timestamps
+// are relative to the start of the analysed part, which is length long.
+func parseBlackAndSilence(output string, length time.Duration) (black []TimeRange, silence []TimeRange) {
+	parseSeconds := func(s string) time.Duration {
+		f, _ := strconv.ParseFloat(s, 64)
+		if f < 0 {
+			f = 0
+		}
+		return time.Duration(math.Round(f*1000)) * time.Millisecond
+	}
+
+	var silenceStart *time.Duration
+	for _, line := range detectedLineRegex.FindAllString(output, -1) {
+		if m := blackDetectRegex.FindStringSubmatch(line); m != nil {
+			black = append(black, TimeRange{Start: parseSeconds(m[1]), End: parseSeconds(m[2])})
+		} else if m := silenceStartRegex.FindStringSubmatch(line); m != nil {
+			s := parseSeconds(m[1])
+			silenceStart = &s
+		} else if m := silenceEndRegex.FindStringSubmatch(line); m != nil && silenceStart != nil {
+			silence = append(silence, TimeRange{Start: *silenceStart, End: parseSeconds(m[1])})
+			silenceStart = nil
+		}
+	}
+	// Silence until the end isn't closed by the filter
+	if silenceStart != nil {
+		silence = append(silence, TimeRange{Start: *silenceStart, End: length})
+	}
+	return black, silence
+}
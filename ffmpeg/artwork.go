@@ -0,0 +1,126 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+)
+
+// artworkFramePosition is how far into the video frames are grabbed for artwork, to skip
+// black intros and studio logos.
+const artworkFramePosition = 0.1
+
+// ExtractArtwork returns a JPEG image to use as artwork for the file. Embedded cover art is
+// preferred, either as attached picture stream or as Matroska attachment. Otherwise a
+// representative frame of the video is used.
+func ExtractArtwork(fileLocator filesystem.FileLocator) ([]byte, error) {
+	probe, err := Probe(fileLocator)
+	if err != nil {
+		return nil, err
+	}
+	url := buildFfmpegUrlFromFileLocator(fileLocator)
+
+	for _, s := range probe.Streams {
+		if s.Disposition["attached_pic"] == 1 {
+			data, err := extractAttachedPicture(url, s.Index)
+			if err == nil {
+				return data, nil
+			}
+			log.WithError(err).WithField("fileLocator", fileLocator).Warnln("Could not extract attached picture")
+		}
+	}
+
+	if s, ok := coverAttachment(probe.Streams); ok {
+		data, err := extractAttachment(url, s.Index)
+		if err == nil {
+			return data, nil
+		}
+		log.WithError(err).WithField("fileLocator", fileLocator).Warnln("Could not extract cover attachment")
+	}
+
+	return grabFrame(url, probe.Format.DurationSeconds*artworkFramePosition)
+}
+
+// coverAttachment picks the image attachment that is most likely the cover. Matroska
+// conventionally names it cover.jpg or cover.png.
+func coverAttachment(streams []ProbeStream) (ProbeStream, bool) {
+	var found *ProbeStream
+	for i, s := range streams {
+		if s.CodecType != "attachment" || !strings.HasPrefix(s.Tags["mimetype"], "image/") {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(s.Tags["filename"]), "cover") {
+			return s, true
+		}
+		if found == nil {
+			found = &streams[i]
+		}
+	}
+	if found == nil {
+		return ProbeStream{}, false
+	}
+	return *found, true
+}
+
+func runImageCommand(args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	log.Debugf("Starting %s with args %s", cmd.Path, cmd.Args)
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %s: %s", err, stderr.String())
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg produced no image")
+	}
+	return stdout.Bytes(), nil
+}
+
+// toJPEG are the ffmpeg output arguments to write a single JPEG image to stdout.
+var toJPEG = []string{"-frames:v", "1", "-c:v", "mjpeg", "-q:v", "2", "-f", "image2pipe", "pipe:1"}
+
+func extractAttachedPicture(url string, streamIndex int) ([]byte, error) {
+	args := []string{"-hide_banner", "-loglevel", "error",
+		"-i", url, "-map", fmt.Sprintf("0:%d", streamIndex)}
+	return runImageCommand(append(args, toJPEG...)...)
+}
+
+func extractAttachment(url string, streamIndex int) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "olaris-artwork")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// ffmpeg dumps attachments while opening the input and then complains about the missing
+	// output, so its exit status tells us nothing.
+	attachment := filepath.Join(dir, "attachment")
+	exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error",
+		fmt.Sprintf("-dump_attachment:%d", streamIndex), attachment, "-i", url).Run()
+	if _, err := os.Stat(attachment); err != nil {
+		return nil, fmt.Errorf("attachment %d could not be dumped", streamIndex)
+	}
+
+	// Convert to JPEG in case the attachment is in another format.
+	args := []string{"-hide_banner", "-loglevel", "error", "-i", attachment}
+	return runImageCommand(append(args, toJPEG...)...)
+}
+
+func grabFrame(url string, positionSeconds float64) ([]byte, error) {
+	args := []string{"-hide_banner", "-loglevel", "error",
+		"-ss", strconv.FormatFloat(positionSeconds, 'f', 3, 64),
+		"-i", url, "-map", "0:v:0",
+		// Pick the most representative of the next frames, which avoids black or blurry ones.
+		"-vf", "thumbnail=50,scale=w='min(1280,iw)':h=-2"}
+	return runImageCommand(append(args, toJPEG...)...)
+}
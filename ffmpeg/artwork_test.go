@@ -0,0 +1,27 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoverAttachment(t *testing.T) {
+	streams := []ProbeStream{
+		{Index: 0, CodecType: "video"},
+		{Index: 1, CodecType: "attachment", Tags: map[string]string{"mimetype": "application/x-truetype-font", "filename": "font.ttf"}},
+		{Index: 2, CodecType: "attachment", Tags: map[string]string{"mimetype": "image/png", "filename": "small_cover.png"}},
+		{Index: 3, CodecType: "attachment", Tags: map[string]string{"mimetype": "image/jpeg", "filename": "Cover.jpg"}},
+	}
+
+	s, ok := coverAttachment(streams)
+	assert.True(t, ok)
+	assert.Equal(t, 3, s.Index)
+
+	s, ok = coverAttachment(streams[:3])
+	assert.True(t, ok)
+	assert.Equal(t, 2, s.Index)
+
+	_, ok = coverAttachment(streams[:2])
+	assert.False(t, ok)
+}
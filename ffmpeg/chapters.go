@@ -0,0 +1,44 @@
+package ffmpeg
+
+import (
+	"time"
+
+	"gitlab.com/olaris/olaris-server/filesystem"
+)
+
+type ProbeChapter struct {
+	ID               int64             `json:"id"`
+	TimeBase         string            `json:"time_base"`
+	StartTimeSeconds float64           `json:"start_time,string"`
+	EndTimeSeconds   float64           `json:"end_time,string"`
+	Tags             map[string]string `json:"tags"`
+}
+
+// Chapter is a chapter marker of a media file.
+type Chapter struct {
+	Start time.Duration
+	End   time.Duration
+	// Title is empty if the file doesn't name its chapters.
+	Title string
+}
+
+// GetChapters returns the chapters of the file in order.
+func GetChapters(fileLocator filesystem.FileLocator) ([]Chapter, error) {
+	probe, err := Probe(fileLocator)
+	if err != nil {
+		return nil, err
+	}
+	return chaptersFromProbe(probe.Chapters), nil
+}
+
+func chaptersFromProbe(probeChapters []ProbeChapter) []Chapter {
+	chapters := []Chapter{}
+	for _, c := range probeChapters {
+		chapters = append(chapters, Chapter{
+			Start: time.Duration(c.StartTimeSeconds * float64(time.Second)),
+			End:   time.Duration(c.EndTimeSeconds * float64(time.Second)),
+			Title: c.Tags["title"],
+		})
+	}
+	return chapters
+}
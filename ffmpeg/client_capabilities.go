@@ -2,6 +2,11 @@ package ffmpeg
 
 type ClientCodecCapabilities struct {
 	PlayableCodecs []string `json:"playableCodecs"`
+	// VideoRanges are the HDR video ranges the client can display, e.g. VideoRangePQ.
+	VideoRanges []string `json:"videoRanges"`
+	// CanDeinterlace is set by clients that deinterlace video themselves, interlaced video is
+	// only transmuxed to them.
+	CanDeinterlace bool `json:"canDeinterlace"`
 }
 
 func (c *ClientCodecCapabilities) Filter(
@@ -19,8 +24,26 @@ func (c *ClientCodecCapabilities) Filter(
 }
 
 func (c *ClientCodecCapabilities) CanPlay(sr StreamRepresentation) bool {
+	return c.canPlayCodecs(sr.Representation.Codecs)
+}
+
+func (c *ClientCodecCapabilities) canPlayCodecs(codecs string) bool {
 	for _, playableCodec := range c.PlayableCodecs {
-		if playableCodec == sr.Representation.Codecs {
+		if playableCodec == codecs {
+			return true
+		}
+	}
+	return false
+}
+
+// CanDisplay returns whether the client can display video with the given range. SDR can be
+// displayed by all clients, HDR only by clients that declare it.
+func (c *ClientCodecCapabilities) CanDisplay(videoRange string) bool {
+	if videoRange == "" || videoRange == VideoRangeSDR {
+		return true
+	}
+	for _, r := range c.VideoRanges {
+		if r == videoRange {
 			return true
 		}
 	}
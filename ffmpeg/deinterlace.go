@@ -0,0 +1,68 @@
+package ffmpeg
+
+const (
+	// DeinterlaceBwdif deinterlaces video with ffmpeg's bwdif filter.
+	DeinterlaceBwdif = "bwdif"
+	// DeinterlaceYadif deinterlaces video with ffmpeg's yadif filter, which is faster than bwdif.
+	DeinterlaceYadif = "yadif"
+	// DeinterlaceIVTC restores the progressive frames of film that was telecined to interlaced
+	// video for broadcast, e.g. 24 fps film in 29.97 fps NTSC, and deinterlaces the rest.
+	DeinterlaceIVTC = "ivtc"
+	// DeinterlaceOff keeps interlaced video as is.
+	DeinterlaceOff = "off"
+)
+
+// DefaultDeinterlacing is used for interlaced video if the library doesn't set a mode.
+const DefaultDeinterlacing = DeinterlaceBwdif
+
+// DeinterlacingModes are the modes that can be selected for libraries.
+var DeinterlacingModes = []string{DeinterlaceBwdif, DeinterlaceYadif, DeinterlaceIVTC, DeinterlaceOff}
+
+// IsInterlaced returns whether the video consists of interlaced fields. Video with an unknown
+// field order is assumed to be progressive.
+func (s Stream) IsInterlaced() bool {
+	switch s.FieldOrder {
+	case "tt", "bb", "tb", "bt":
+		return true
+	}
+	return false
+}
+
+// ValidDeinterlacing returns whether the mode can be used, the empty mode selects
+// DefaultDeinterlacing.
+func ValidDeinterlacing(mode string) bool {
+	if mode == "" {
+		return true
+	}
+	for _, m := range DeinterlacingModes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// WithDeinterlacing returns the representation with interlaced video deinterlaced using the
+// mode when it is transcoded.
+func WithDeinterlacing(sr StreamRepresentation, mode string) StreamRepresentation {
+	sr.Representation.encoderParams.deinterlace = mode
+	return sr
+}
+
+// deinterlaceFilter returns the filter chain that deinterlaces the stream, empty if it is
+// progressive. Deinterlacing outputs one frame per frame so that the frame rate stays the same,
+// except for inverse telecine which drops the duplicated frames.
+func (p EncoderParams) deinterlaceFilter(stream Stream) string {
+	if !stream.IsInterlaced() {
+		return ""
+	}
+	switch p.deinterlace {
+	case DeinterlaceOff:
+		return ""
+	case DeinterlaceYadif:
+		return "yadif=mode=send_frame:deint=all"
+	case DeinterlaceIVTC:
+		return "fieldmatch,yadif=deint=interlaced,decimate"
+	}
+	return "bwdif=mode=send_frame:deint=all"
+}
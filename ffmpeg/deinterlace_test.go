@@ -0,0 +1,60 @@
+package ffmpeg
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func interlacedTestStream() Stream {
+	return Stream{
+		StreamKey: StreamKey{StreamId: 0}, StreamType: "video", CodecName: "h264", Codecs: "avc1.640028",
+		Width: 1920, Height: 1080, BitRate: 12000000, FrameRate: big.NewRat(30000, 1001), FieldOrder: "tt",
+	}
+}
+
+func TestIsInterlaced(t *testing.T) {
+	assert.True(t, interlacedTestStream().IsInterlaced())
+	assert.True(t, Stream{FieldOrder: "bb"}.IsInterlaced())
+	assert.False(t, Stream{FieldOrder: "progressive"}.IsInterlaced())
+	assert.False(t, Stream{FieldOrder: "unknown"}.IsInterlaced())
+	assert.False(t, Stream{}.IsInterlaced())
+
+	assert.True(t, ValidDeinterlacing(""))
+	assert.True(t, ValidDeinterlacing(DeinterlaceIVTC))
+	assert.False(t, ValidDeinterlacing("kerndeint"))
+}
+
+func TestDeinterlacing(t *testing.T) {
+	profile := GetTranscodingProfile(DefaultTranscodingProfile)
+	stream := interlacedTestStream()
+
+	// Interlaced video is only transmuxed to clients that deinterlace it themselves
+	r, err := GetTransmuxedOrTranscodedRepresentation(stream, ClientCodecCapabilities{
+		PlayableCodecs: []string{stream.Codecs}}, profile)
+	require.NoError(t, err)
+	assert.True(t, r.Representation.Transcoded)
+	r, err = GetTransmuxedOrTranscodedRepresentation(stream, ClientCodecCapabilities{
+		PlayableCodecs: []string{stream.Codecs}, CanDeinterlace: true}, profile)
+	require.NoError(t, err)
+	assert.True(t, r.Representation.Transmuxed)
+
+	r, err = StreamRepresentationFromRepresentationId(stream, "preset:default:720-5000k-video")
+	require.NoError(t, err)
+	assert.Equal(t, "bwdif=mode=send_frame:deint=all,scale=-2:720", r.Representation.encoderParams.videoFilter(stream))
+	assert.Equal(t, "fieldmatch,yadif=deint=interlaced,decimate,scale=-2:720",
+		WithDeinterlacing(r, DeinterlaceIVTC).Representation.encoderParams.videoFilter(stream))
+	assert.Equal(t, "scale=-2:720", WithDeinterlacing(r, DeinterlaceOff).Representation.encoderParams.videoFilter(stream))
+
+	// Deinterlacing is kept when falling back to a lower quality
+	lower := LowerQualityRepresentations(WithDeinterlacing(r, DeinterlaceYadif))
+	require.Len(t, lower, 1)
+	assert.Equal(t, "yadif=mode=send_frame:deint=all,scale=-2:480", lower[0].Representation.encoderParams.videoFilter(stream))
+
+	// Fields are deinterlaced before subtitles are overlaid
+	burnIn := withBurnIn(r, 3)
+	assert.Equal(t, "[0:0]bwdif=mode=send_frame:deint=all[base];[base][0:3]overlay=eof_action=pass,scale=-2:720[v]",
+		burnIn.Representation.encoderParams.burnInFilterComplex(stream))
+}
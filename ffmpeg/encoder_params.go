@@ -14,6 +14,30 @@ type EncoderParams struct {
 	videoBitrate int
 	audioBitrate int
 
+	// Video encoder settings from the transcoding profile. crf is only used if it's non-zero.
+	profile      string
+	videoCodec   string
+	encoder      string
+	preset       string
+	tune         string
+	crf          int
+	maxFrameRate float64
+
+	// hdr keeps the HDR of the source, otherwise HDR is tone mapped to SDR with toneMapping.
+	hdr         bool
+	toneMapping string
+
+	// deinterlace is the mode used for interlaced video, see deinterlaceFilter.
+	deinterlace string
+
+	// burnIn overlays the image subtitle stream with the ID burnInSubtitleStreamId on the video.
+	burnIn                 bool
+	burnInSubtitleStreamId int64
+
+	// Audio filters from the transcoding profile.
+	dialogueBoost     bool
+	normalizeLoudness bool
+
 	// The codecs (https://tools.ietf.org/html/rfc6381#section-3.3) that these params will produce.
 	Codecs string
 }
@@ -0,0 +1,195 @@
+package ffmpeg
+
+import (
+	"path"
+	"strings"
+	"time"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+)
+
+// externalSubtitleCodecs maps the extensions of subtitle files next to media files to the codec
+// ffprobe reports for them. VobSub subtitles are found by their .idx file, ffmpeg reads the
+// bitmaps from the .sub file next to it.
+var externalSubtitleCodecs = map[string]string{
+	".srt": "subrip",
+	".ass": "ass",
+	".ssa": "ass",
+	".vtt": "webvtt",
+	".idx": "dvd_subtitle",
+}
+
+// subtitleDirNames are the (lowercase) names of folders next to media files that are searched
+// for subtitles.
+var subtitleDirNames = map[string]bool{
+	"subs":      true,
+	"subtitles": true,
+}
+
+// buildExternalSubtitleStreams finds subtitle files belonging to the media file. These are files
+// named like the media file, e.g. "Movie.en.forced.srt" for "Movie.mkv", in the same folder or in
+// a "Subs" folder next to it, and all subtitle files in a folder named like the media file inside
+// the "Subs" folder, as found in release packs of series.
+func buildExternalSubtitleStreams(
+	fileLocator filesystem.FileLocator,
+	duration time.Duration) ([]Stream, error) {
+
+	dir := path.Dir(fileLocator.Path)
+	base := strings.TrimSuffix(path.Base(fileLocator.Path), path.Ext(fileLocator.Path))
+
+	dirLocator := filesystem.FileLocator{Backend: fileLocator.Backend, Path: dir}
+	streams, err := findSubtitleFiles(dirLocator, base, duration)
+	if err != nil {
+		return []Stream{}, err
+	}
+
+	dirNode, err := filesystem.GetNodeFromFileLocator(dirLocator)
+	if err != nil {
+		return streams, err
+	}
+	subDirs, _ := dirNode.ListDir()
+	for _, subDir := range subDirs {
+		if !subtitleDirNames[strings.ToLower(subDir)] {
+			continue
+		}
+		subsLocator := filesystem.FileLocator{Backend: fileLocator.Backend, Path: path.Join(dir, subDir)}
+		found, err := findSubtitleFiles(subsLocator, base, duration)
+		if err != nil {
+			log.WithError(err).WithField("dir", subsLocator.String()).Warnln("Failed to list subtitles")
+			continue
+		}
+		streams = append(streams, found...)
+
+		subsNode, err := filesystem.GetNodeFromFileLocator(subsLocator)
+		if err != nil {
+			continue
+		}
+		episodeDirs, _ := subsNode.ListDir()
+		for _, episodeDir := range episodeDirs {
+			if episodeDir != base {
+				continue
+			}
+			episodeLocator := filesystem.FileLocator{
+				Backend: fileLocator.Backend, Path: path.Join(subsLocator.Path, episodeDir)}
+			found, _ := findSubtitleFiles(episodeLocator, "", duration)
+			streams = append(streams, found...)
+		}
+	}
+
+	return streams, nil
+}
+
+// findSubtitleFiles returns the subtitle files in the folder whose name starts with the base name
+// of the media file, or all subtitle files if base is empty.
+func findSubtitleFiles(dir filesystem.FileLocator, base string, duration time.Duration) ([]Stream, error) {
+	node, err := filesystem.GetNodeFromFileLocator(dir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := node.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	streams := []Stream{}
+	for _, file := range files {
+		ext := path.Ext(file)
+		codec, ok := externalSubtitleCodecs[strings.ToLower(ext)]
+		if !ok {
+			continue
+		}
+
+		name := strings.TrimSuffix(file, ext)
+		if base != "" {
+			// "Movie.en.srt" belongs to "Movie.mkv", "Movie 2.srt" does not
+			if name != base && !strings.HasPrefix(name, base+".") {
+				continue
+			}
+			name = strings.TrimPrefix(name, base)
+		}
+
+		lang, forced, hearingImpaired := parseSubtitleFileTag(name)
+		streams = append(streams,
+			Stream{
+				StreamKey: StreamKey{
+					FileLocator: filesystem.FileLocator{
+						Backend: dir.Backend,
+						Path:    path.Join(dir.Path, file),
+					},
+					StreamId: 0,
+				},
+				TotalDuration:    duration,
+				StreamType:       "subtitle",
+				CodecName:        codec,
+				Language:         lang,
+				Title:            subtitleTitle(lang, forced, hearingImpaired),
+				EnabledByDefault: false,
+				Forced:           forced,
+				HearingImpaired:  hearingImpaired,
+			})
+	}
+	return streams, nil
+}
+
+// parseSubtitleFileTag parses the part of a subtitle filename after the name of the media file,
+// e.g. ".en.forced" or ".English.SDH", into a language code and flags.
+func parseSubtitleFileTag(tag string) (string, bool, bool) {
+	lang := "unk"
+	forced, hearingImpaired := false, false
+
+	tokens := strings.FieldsFunc(tag, func(r rune) bool {
+		return r == '.' || r == '_' || r == ' '
+	})
+	for _, token := range tokens {
+		switch strings.ToLower(token) {
+		case "forced":
+			forced = true
+			continue
+		case "sdh", "cc", "hi":
+			hearingImpaired = true
+			continue
+		case "default":
+			continue
+		}
+		if l, ok := languageFromToken(token); ok && lang == "unk" {
+			lang = l
+		}
+	}
+	return lang, forced, hearingImpaired
+}
+
+// languageFromToken returns the language code of a part of a filename, which may be a language
+// name like "English" or a code like "en" or "eng".
+func languageFromToken(token string) (string, bool) {
+	for humanized, tag := range humanizedToLangTag {
+		if strings.EqualFold(humanized, token) {
+			return tag, true
+		}
+	}
+	if len(token) < 2 || len(token) > 3 {
+		return "", false
+	}
+	for _, r := range token {
+		if !unicode.IsLetter(r) {
+			return "", false
+		}
+	}
+	return strings.ToLower(token), true
+}
+
+// subtitleTitle returns the user-visible title of an external subtitle.
+func subtitleTitle(lang string, forced bool, hearingImpaired bool) string {
+	title := lang
+	if humanized, ok := langTagToHumanized[lang]; ok {
+		title = humanized
+	}
+	if forced {
+		title += " (Forced)"
+	}
+	if hearingImpaired {
+		title += " (SDH)"
+	}
+	return title
+}
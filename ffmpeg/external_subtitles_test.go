@@ -0,0 +1,72 @@
+package ffmpeg
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/filesystem"
+)
+
+func TestParseSubtitleFileTag(t *testing.T) {
+	tests := []struct {
+		tag             string
+		lang            string
+		forced          bool
+		hearingImpaired bool
+	}{
+		{"", "unk", false, false},
+		{".en", "en", false, false},
+		{".English.SDH", "eng", false, true},
+		{".ger.forced", "ger", true, false},
+		{"2_English", "eng", false, false},
+		{".Polski.default", "pol", false, false},
+		{".some.release", "unk", false, false},
+	}
+	for _, tt := range tests {
+		lang, forced, hearingImpaired := parseSubtitleFileTag(tt.tag)
+		assert.Equal(t, tt.lang, lang, tt.tag)
+		assert.Equal(t, tt.forced, forced, tt.tag)
+		assert.Equal(t, tt.hearingImpaired, hearingImpaired, tt.tag)
+	}
+}
+
+func TestBuildExternalSubtitleStreams(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{
+		"Show S01E01.mkv",
+		"Show S01E01.en.srt",
+		"Show S01E01.English.forced.ass",
+		"Show S01E01.nfo",
+		"Show S01E02.en.srt",
+		"Subs/Show S01E01.ger.vtt",
+		"Subs/Show S01E01/2_English.srt",
+		"Subs/Show S01E01/3_Dutch.idx",
+		"Subs/Show S01E01/3_Dutch.sub",
+		"Subs/Show S01E02/2_English.srt",
+	} {
+		require.NoError(t, os.MkdirAll(path.Dir(path.Join(dir, f)), 0755))
+		require.NoError(t, ioutil.WriteFile(path.Join(dir, f), []byte{}, 0644))
+	}
+
+	streams, err := buildExternalSubtitleStreams(
+		filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: path.Join(dir, "Show S01E01.mkv")}, 0)
+	require.NoError(t, err)
+
+	found := map[string]Stream{}
+	for _, s := range streams {
+		rel := s.FileLocator.Path[len(dir)+1:]
+		found[rel] = s
+	}
+	assert.Len(t, found, 5)
+	assert.Equal(t, "subrip", found["Show S01E01.en.srt"].CodecName)
+	assert.True(t, found["Show S01E01.English.forced.ass"].Forced)
+	assert.True(t, found["Show S01E01.English.forced.ass"].IsStyledSubtitle())
+	assert.Equal(t, "English (Forced)", found["Show S01E01.English.forced.ass"].Title)
+	assert.Equal(t, "ger", found["Subs/Show S01E01.ger.vtt"].Language)
+	assert.Equal(t, "eng", found["Subs/Show S01E01/2_English.srt"].Language)
+	assert.True(t, found["Subs/Show S01E01/3_Dutch.idx"].IsImageSubtitle())
+}
@@ -25,6 +25,10 @@ type Representation struct {
 	Container string
 	// codecs string ready for DASH/HLS serving
 	Codecs string
+	// Number of audio channels, only set for audio
+	Channels int
+	// VideoRangeSDR, VideoRangePQ or VideoRangeHLG, only set for video
+	VideoRange string
 
 	// Mutually exclusive
 	Transcoded bool
@@ -61,35 +65,73 @@ func ComputeSegmentDurations(sessions [][]Segment) []time.Duration {
 
 func GetTransmuxedOrTranscodedRepresentation(
 	stream Stream,
-	capabilities ClientCodecCapabilities) (StreamRepresentation, error) {
+	capabilities ClientCodecCapabilities,
+	profile TranscodingProfile) (StreamRepresentation, error) {
 
 	transmuxed := GetTransmuxedRepresentation(stream)
-	// We interpret empty PlayableCodecs as no preference
-	if len(capabilities.PlayableCodecs) == 0 || capabilities.CanPlay(transmuxed) {
+	// We interpret empty PlayableCodecs and VideoRanges as no preference
+	canDisplay := len(capabilities.VideoRanges) == 0 ||
+		stream.StreamType != "video" ||
+		capabilities.CanDisplay(stream.VideoRange())
+	canDeinterlace := capabilities.CanDeinterlace || !stream.IsInterlaced()
+	if (len(capabilities.PlayableCodecs) == 0 || capabilities.CanPlay(transmuxed)) && canDisplay && canDeinterlace {
 		return transmuxed, nil
 	}
-	return GetSimilarTranscodedRepresentation(stream), nil
+	if stream.StreamType == "video" {
+		return GetSimilarTranscodedVideoRepresentation(
+			stream, profile, profile.VideoCodecFor(stream, capabilities)), nil
+	}
+	if stream.StreamType == "audio" {
+		return GetSimilarTranscodedAudioRepresentation(
+			stream, profile, AudioCodecFor(stream, capabilities)), nil
+	}
+	return GetSimilarTranscodedRepresentation(stream, profile), nil
 }
 
-func GetSimilarTranscodedRepresentation(stream Stream) StreamRepresentation {
-	similarEncoderParams, _ := GetSimilarEncoderParams(stream)
+// GetSimilarTranscodedRepresentation returns a transcoded representation with about the quality
+// of the stream, video is transcoded to H.264 and audio to stereo AAC.
+func GetSimilarTranscodedRepresentation(stream Stream, profile TranscodingProfile) StreamRepresentation {
+	if stream.StreamType == "video" {
+		return GetSimilarTranscodedVideoRepresentation(stream, profile, VideoCodecH264)
+	}
 	if stream.StreamType == "audio" {
-		return GetTranscodedAudioRepresentation(
-			stream,
-			// TODO(Leon Handreke): Make a util method for this prefix.
-			"transcode:"+EncoderParamsToString(similarEncoderParams),
-			similarEncoderParams)
+		return GetSimilarTranscodedAudioRepresentation(stream, profile, AudioCodecAAC)
 	}
-	if stream.StreamType == "video" {
-		return GetTranscodedVideoRepresentation(
-			stream,
-			// TODO(Leon Handreke): Make a util method for this prefix.
-			"transcode:"+EncoderParamsToString(similarEncoderParams),
-			similarEncoderParams)
 
+	panic("GetSimliarTranscodedRepresentation for stream that is not audio/video")
+}
+
+// GetSimilarTranscodedAudioRepresentation returns a representation of the audio stream transcoded
+// to the codec with about the quality of the stream.
+func GetSimilarTranscodedAudioRepresentation(stream Stream, profile TranscodingProfile, codec string) StreamRepresentation {
+	similarEncoderParams, _ := GetSimilarEncoderParams(stream)
+	similarEncoderParams.Codecs = audioCodecStrings[codec]
+	if max := maxAudioBitrates[codec]; similarEncoderParams.audioBitrate <= 0 || similarEncoderParams.audioBitrate > max {
+		similarEncoderParams.audioBitrate = max
 	}
+	profile.applyAudioTo(&similarEncoderParams)
 
-	panic("GetSimliarTranscodedRepresentation for stream that is not audio/video")
+	return GetTranscodedAudioRepresentation(
+		stream,
+		// TODO(Leon Handreke): Make a util method for this prefix.
+		fmt.Sprintf("transcode:%s:%s", profile.Name, EncoderParamsToString(similarEncoderParams)),
+		similarEncoderParams)
+}
+
+// GetSimilarTranscodedVideoRepresentation returns a representation of the video stream transcoded
+// to the format with about the quality of the stream. The format is a codec, optionally keeping
+// HDR, see HDRVideoFormat.
+func GetSimilarTranscodedVideoRepresentation(stream Stream, profile TranscodingProfile, format string) StreamRepresentation {
+	similarEncoderParams, _ := GetSimilarEncoderParams(stream)
+	profile.applyTo(&similarEncoderParams, format)
+	similarEncoderParams.Codecs = videoCodecTag(format, stream.Width, stream.Height, stream.BitRate,
+		similarEncoderParams.outputFrameRate(stream.FrameRate))
+
+	return GetTranscodedVideoRepresentation(
+		stream,
+		// TODO(Leon Handreke): Make a util method for this prefix.
+		fmt.Sprintf("transcode:%s:%s", profile.Name, EncoderParamsToString(similarEncoderParams)),
+		similarEncoderParams)
 }
 
 // TODO(Leon Handreke): Should this really return an error?
@@ -101,27 +143,40 @@ func StreamRepresentationFromRepresentationId(
 		return GetSubtitleStreamRepresentation(s), nil
 	}
 
+	if subtitleStreamId, videoRepresentationId, ok := splitBurnIn(representationId); ok {
+		r, err := StreamRepresentationFromRepresentationId(s, videoRepresentationId)
+		if err != nil || !r.Representation.Transcoded {
+			return StreamRepresentation{},
+				fmt.Errorf("Subtitles can only be burned into transcoded video, not %s", videoRepresentationId)
+		}
+		return withBurnIn(r, subtitleStreamId), nil
+	}
+
 	if representationId == "direct" {
 		return GetTransmuxedRepresentation(s), nil
 	} else if strings.HasPrefix(representationId, "preset:") {
-		presetId := representationId[7:]
+		profileName, presetId := splitProfileName(representationId[7:])
+		profile := GetTranscodingProfile(profileName)
+		presetId, format := splitVideoCodec(presetId)
 
-		encoderParams, err := GetVideoEncoderPreset(s, presetId)
+		encoderParams, err := GetVideoEncoderPreset(s, profile, presetId, format)
 		if err == nil {
 			return GetTranscodedVideoRepresentation(s, representationId, encoderParams), nil
 		}
-		if encoderParams, ok := AudioEncoderPresets[presetId]; ok {
+		if encoderParams, err := GetAudioEncoderPreset(profile, presetId); err == nil {
 			return GetTranscodedAudioRepresentation(s, representationId, encoderParams), nil
 		}
 	} else if strings.HasPrefix(representationId, "transcode:") {
-		encoderParamsStr := representationId[10:]
+		profileName, encoderParamsStr := splitProfileName(representationId[10:])
 		encoderParams, err := EncoderParamsFromString(encoderParamsStr)
 		if err != nil {
 			return StreamRepresentation{}, err
 		}
 		if s.StreamType == "video" {
+			GetTranscodingProfile(profileName).applyTo(&encoderParams, videoCodecOf(encoderParams.Codecs))
 			return GetTranscodedVideoRepresentation(s, representationId, encoderParams), nil
 		} else if s.StreamType == "audio" {
+			GetTranscodingProfile(profileName).applyAudioTo(&encoderParams)
 			return GetTranscodedAudioRepresentation(s, representationId, encoderParams), nil
 		}
 	}
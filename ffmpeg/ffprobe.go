@@ -20,29 +20,42 @@ import (
 var extraDataRegex = regexp.MustCompile(`0{8}: \d{2}(.{2})\s(.{4})`)
 
 type ProbeContainer struct {
-	Streams []ProbeStream `json:"streams"`
-	Format  ProbeFormat   `json:"format"`
+	Streams  []ProbeStream  `json:"streams"`
+	Format   ProbeFormat    `json:"format"`
+	Chapters []ProbeChapter `json:"chapters"`
 }
 
 type ProbeStream struct {
-	Index         int               `json:"index"`
-	CodecName     string            `json:"codec_name"`
-	CodecLongName string            `json:"codec_long_name"`
-	CodecTag      string            `json:"codec_tag"`
-	Profile       string            `json:"profile"`
-	Level         int               `json:"level"`
-	Channels      int               `json:"channels"`
-	ChannelLayout string            `json:"channel_layout"`
-	CodecType     string            `json:"codec_type"`
-	BitRate       string            `json:"bit_rate"`
-	Width         int               `json:"width"`
-	Height        int               `json:"height"`
-	Extradata     string            `json:"extradata"`
-	Tags          map[string]string `json:"tags"`
-	Disposition   map[string]int    `json:"disposition"`
-	TimeBase      string            `json:"time_base"`
-	DurationTs    int               `json:"duration_ts"`
-	RFrameRate    string            `json:"r_frame_rate"`
+	Index          int               `json:"index"`
+	CodecName      string            `json:"codec_name"`
+	CodecLongName  string            `json:"codec_long_name"`
+	CodecTag       string            `json:"codec_tag"`
+	Profile        string            `json:"profile"`
+	Level          int               `json:"level"`
+	Channels       int               `json:"channels"`
+	ChannelLayout  string            `json:"channel_layout"`
+	CodecType      string            `json:"codec_type"`
+	BitRate        string            `json:"bit_rate"`
+	Width          int               `json:"width"`
+	Height         int               `json:"height"`
+	Extradata      string            `json:"extradata"`
+	Tags           map[string]string `json:"tags"`
+	Disposition    map[string]int    `json:"disposition"`
+	TimeBase       string            `json:"time_base"`
+	DurationTs     int               `json:"duration_ts"`
+	RFrameRate     string            `json:"r_frame_rate"`
+	PixFmt         string            `json:"pix_fmt"`
+	FieldOrder     string            `json:"field_order"`
+	ColorSpace     string            `json:"color_space"`
+	ColorTransfer  string            `json:"color_transfer"`
+	ColorPrimaries string            `json:"color_primaries"`
+	SideDataList   []ProbeSideData   `json:"side_data_list"`
+}
+
+// ProbeSideData is side data of a stream, such as the Dolby Vision configuration of HDR video.
+type ProbeSideData struct {
+	SideDataType string `json:"side_data_type"`
+	DVProfile    int    `json:"dv_profile"`
 }
 
 func (ps *ProbeStream) String() string {
@@ -59,6 +72,16 @@ func FilterProbeStreamByCodecType(streams []ProbeStream, codecType string) []Pro
 	return res
 }
 
+// dolbyVisionProfile returns the Dolby Vision profile from the side data, 0 if there is none.
+func (ps *ProbeStream) dolbyVisionProfile() int {
+	for _, d := range ps.SideDataList {
+		if d.SideDataType == "DOVI configuration record" {
+			return d.DVProfile
+		}
+	}
+	return 0
+}
+
 func (self *ProbeStream) GetMime() string {
 	if self.CodecName == "h264" {
 		res := extraDataRegex.FindAllStringSubmatch(self.Extradata, -1)
@@ -70,6 +93,12 @@ func (self *ProbeStream) GetMime() string {
 	if self.CodecName == "aac" {
 		return fmt.Sprintf("mp4a.40.2")
 	}
+	if self.CodecName == "ac3" {
+		return "ac-3"
+	}
+	if self.CodecName == "eac3" {
+		return "ec-3"
+	}
 	return self.CodecName
 }
 
@@ -121,6 +150,7 @@ func Probe(fileLocator filesystem.FileLocator) (*ProbeContainer, error) {
 		"ffprobe",
 		"-show_data",
 		"-show_format",
+		"-show_chapters",
 		"-show_streams", ffmpegUrl, "-print_format", "json", "-v", "quiet")
 	cmd.Stderr = os.Stderr
 
@@ -2,9 +2,11 @@ package ffmpeg
 
 import (
 	_ "bytes"
+	"encoding/json"
 	"math/big"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func Test_parseRational(t *testing.T) {
@@ -48,3 +50,48 @@ func Test_parseRational(t *testing.T) {
 		})
 	}
 }
+
+func Test_chaptersFromProbe(t *testing.T) {
+	probe := `{"chapters": [
+		{"id": 0, "time_base": "1/1000000000", "start": 0, "start_time": "0.000000", "end": 90500000000, "end_time": "90.500000", "tags": {"title": "Opening"}},
+		{"id": 1, "time_base": "1/1000000000", "start": 90500000000, "start_time": "90.500000", "end": 600000000000, "end_time": "600.000000"}
+	]}`
+	var v ProbeContainer
+	if err := json.Unmarshal([]byte(probe), &v); err != nil {
+		t.Fatal(err)
+	}
+
+	chapters := chaptersFromProbe(v.Chapters)
+	expected := []Chapter{
+		{Start: 0, End: 90500 * time.Millisecond, Title: "Opening"},
+		{Start: 90500 * time.Millisecond, End: 600 * time.Second},
+	}
+	if !reflect.DeepEqual(chapters, expected) {
+		t.Errorf("chaptersFromProbe() = %v, want %v", chapters, expected)
+	}
+}
+
+func Test_parseBlackAndSilence(t *testing.T) {
+	output := `[blackdetect @ 0x55d1] black_start:12.5 black_end:14 black_duration:1.5
+[silencedetect @ 0x55d2] silence_start: 12.8
+[silencedetect @ 0x55d2] silence_end: 13.9 | silence_duration: 1.1
+[blackdetect @ 0x55d1] black_start:100 black_end:120 black_duration:20
+[silencedetect @ 0x55d2] silence_start: 290.25
+`
+	black, silence := parseBlackAndSilence(output, 300*time.Second)
+
+	expectedBlack := []TimeRange{
+		{Start: 12500 * time.Millisecond, End: 14 * time.Second},
+		{Start: 100 * time.Second, End: 120 * time.Second},
+	}
+	expectedSilence := []TimeRange{
+		{Start: 12800 * time.Millisecond, End: 13900 * time.Millisecond},
+		{Start: 290250 * time.Millisecond, End: 300 * time.Second},
+	}
+	if !reflect.DeepEqual(black, expectedBlack) {
+		t.Errorf("black = %v, want %v", black, expectedBlack)
+	}
+	if !reflect.DeepEqual(silence, expectedSilence) {
+		t.Errorf("silence = %v, want %v", silence, expectedSilence)
+	}
+}
@@ -0,0 +1,100 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// VideoRangeSDR is standard dynamic range video, it's also assumed if we don't know better.
+	VideoRangeSDR = "SDR"
+	// VideoRangePQ is HDR video with the perceptual quantizer transfer function, e.g. HDR10.
+	VideoRangePQ = "PQ"
+	// VideoRangeHLG is HDR video with the hybrid log-gamma transfer function.
+	VideoRangeHLG = "HLG"
+)
+
+// DefaultToneMapping is the tonemap algorithm used if the profile doesn't set one.
+const DefaultToneMapping = "hable"
+
+// toneMappings are the algorithms of ffmpeg's tonemap filter that can be used in profiles.
+var toneMappings = []string{"hable", "mobius", "reinhard", "clip", "linear", "gamma"}
+
+// hdrSuffix marks video formats that keep the HDR of the source, e.g. "hevc:hdr". Only HEVC and
+// AV1 can be transcoded to HDR because we encode H.264 with 8 bits.
+const hdrSuffix = ":hdr"
+
+// VideoRange returns the dynamic range of the video as used in HLS manifests: VideoRangePQ,
+// VideoRangeHLG or VideoRangeSDR.
+func (s Stream) VideoRange() string {
+	switch s.ColorTransfer {
+	case "smpte2084":
+		return VideoRangePQ
+	case "arib-std-b67":
+		return VideoRangeHLG
+	}
+	return VideoRangeSDR
+}
+
+// IsHDR returns whether the video has a high dynamic range.
+func (s Stream) IsHDR() bool {
+	return s.VideoRange() != VideoRangeSDR
+}
+
+// HDRVideoFormat returns the format of video in the codec that keeps the HDR of the source.
+func HDRVideoFormat(codec string) string {
+	return codec + hdrSuffix
+}
+
+// splitHDR splits a video format into its codec and whether it keeps HDR.
+func splitHDR(format string) (string, bool) {
+	if strings.HasSuffix(format, hdrSuffix) {
+		return strings.TrimSuffix(format, hdrSuffix), true
+	}
+	return format, false
+}
+
+// IsHDR returns whether the representation is HDR video.
+func (r Representation) IsHDR() bool {
+	return r.VideoRange != "" && r.VideoRange != VideoRangeSDR
+}
+
+// TransferCharacteristics returns the code point of the transfer function of the video as
+// defined in ITU-T H.273, used to signal HDR in DASH manifests.
+func (r Representation) TransferCharacteristics() int {
+	switch r.VideoRange {
+	case VideoRangePQ:
+		return 16
+	case VideoRangeHLG:
+		return 18
+	}
+	return 1
+}
+
+// toneMappingFilter returns the filter chain converting HDR video to SDR BT.709 with the given
+// tonemap algorithm. zscale linearizes the video so that the tone mapping works on actual light
+// levels, see https://ffmpeg.org/ffmpeg-filters.html#tonemap-1
+func toneMappingFilter(algorithm string) string {
+	if algorithm == "" {
+		algorithm = DefaultToneMapping
+	}
+	return strings.Join([]string{
+		"zscale=t=linear:npl=100",
+		"format=gbrpf32le",
+		"zscale=p=bt709",
+		fmt.Sprintf("tonemap=tonemap=%s:desat=0", algorithm),
+		"zscale=t=bt709:m=bt709:r=tv",
+		"format=yuv420p",
+	}, ",")
+}
+
+// hdrColorArgs returns the ffmpeg arguments that tag the first output stream with the colour
+// properties of the HDR source, so players know how to display it.
+func hdrColorArgs(stream Stream) []string {
+	return []string{
+		"-pix_fmt:0", "yuv420p10le",
+		"-color_primaries:0", "bt2020",
+		"-color_trc:0", stream.ColorTransfer,
+		"-colorspace:0", "bt2020nc",
+	}
+}
@@ -0,0 +1,83 @@
+package ffmpeg
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hdrTestStream() Stream {
+	return Stream{
+		StreamType: "video", Codecs: "hvc1.2.4.L150.B0", Width: 3840, Height: 2160,
+		BitRate: 30000000, FrameRate: big.NewRat(24, 1),
+		ColorTransfer: "smpte2084", ColorPrimaries: "bt2020", ColorSpace: "bt2020nc",
+	}
+}
+
+func TestVideoRange(t *testing.T) {
+	assert.Equal(t, VideoRangePQ, hdrTestStream().VideoRange())
+	assert.Equal(t, VideoRangeHLG, Stream{ColorTransfer: "arib-std-b67"}.VideoRange())
+	assert.Equal(t, VideoRangeSDR, Stream{ColorTransfer: "bt709"}.VideoRange())
+	assert.Equal(t, VideoRangeSDR, Stream{}.VideoRange())
+
+	assert.Equal(t, VideoRangePQ, GetTransmuxedRepresentation(hdrTestStream()).Representation.VideoRange)
+}
+
+func TestHDRTranscoding(t *testing.T) {
+	offerAllVideoCodecs(t)
+	profile := GetTranscodingProfile(DefaultTranscodingProfile)
+	stream := hdrTestStream()
+
+	hevcHDR := GetSimilarTranscodedVideoRepresentation(stream, profile, HDRVideoFormat(VideoCodecHEVC))
+	hevcSDR := GetSimilarTranscodedVideoRepresentation(stream, profile, VideoCodecHEVC)
+	assert.Equal(t, "hvc1.2.4.L153.B0", hevcHDR.Representation.Codecs)
+	assert.Equal(t, VideoRangePQ, hevcHDR.Representation.VideoRange)
+	assert.Equal(t, VideoRangeSDR, hevcSDR.Representation.VideoRange)
+
+	// Clients that don't declare HDR support get tone mapped video
+	playsAll := ClientCodecCapabilities{PlayableCodecs: []string{
+		"avc1.640028", hevcSDR.Representation.Codecs, hevcHDR.Representation.Codecs}}
+	assert.Equal(t, VideoCodecHEVC, profile.VideoCodecFor(stream, playsAll))
+	playsAll.VideoRanges = []string{VideoRangePQ}
+	assert.Equal(t, HDRVideoFormat(VideoCodecHEVC), profile.VideoCodecFor(stream, playsAll))
+
+	// HDR sources are not transmuxed to clients that declare they can only display SDR
+	r, err := GetTransmuxedOrTranscodedRepresentation(stream, ClientCodecCapabilities{
+		PlayableCodecs: []string{stream.Codecs}, VideoRanges: []string{VideoRangeSDR}}, profile)
+	require.NoError(t, err)
+	assert.True(t, r.Representation.Transcoded)
+	r, err = GetTransmuxedOrTranscodedRepresentation(stream, ClientCodecCapabilities{
+		PlayableCodecs: []string{stream.Codecs}}, profile)
+	require.NoError(t, err)
+	assert.True(t, r.Representation.Transmuxed)
+
+	r, err = StreamRepresentationFromRepresentationId(stream, "preset:default:720-5000k-video")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-c:0", "libx264", "-b:v", "5000000", "-preset:0", "veryfast"},
+		r.Representation.encoderParams.videoEncoderArgs(stream))
+	assert.Equal(t, "scale=-2:720,"+toneMappingFilter("hable"), r.Representation.encoderParams.videoFilter(stream))
+
+	r, err = StreamRepresentationFromRepresentationId(stream, "preset:default:720-5000k-video:hevc:hdr")
+	require.NoError(t, err)
+	assert.Equal(t, "hvc1.2.4.L93.B0", r.Representation.Codecs)
+	assert.Equal(t, []string{
+		"-c:0", "libx265", "-b:v", "5000000", "-x265-params:0", "log-level=error", "-preset:0", "veryfast",
+		"-pix_fmt:0", "yuv420p10le", "-color_primaries:0", "bt2020", "-color_trc:0", "smpte2084",
+		"-colorspace:0", "bt2020nc", "-tag:0", "hvc1"},
+		r.Representation.encoderParams.videoEncoderArgs(stream))
+	assert.Equal(t, "scale=-2:720", r.Representation.encoderParams.videoFilter(stream))
+
+	// Transcoded representation IDs only carry the codecs string, HDR is derived from it
+	r, err = StreamRepresentationFromRepresentationId(stream, hevcHDR.Representation.RepresentationId)
+	require.NoError(t, err)
+	assert.True(t, r.Representation.encoderParams.hdr)
+}
+
+func TestToneMappingFilter(t *testing.T) {
+	assert.Equal(t,
+		"zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709,tonemap=tonemap=mobius:desat=0,"+
+			"zscale=t=bt709:m=bt709:r=tv,format=yuv420p",
+		toneMappingFilter("mobius"))
+}
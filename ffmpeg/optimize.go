@@ -0,0 +1,244 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OptimizeOptions describe how an optimized version of a file is encoded.
+type OptimizeOptions struct {
+	Profile TranscodingProfile
+	// VideoFormat is one of the formats the profile offers for the video, see
+	// TranscodingProfile.OfferedVideoFormats. H.264 is used if it is empty.
+	VideoFormat string
+	// Deinterlace is the mode used if the video is interlaced.
+	Deinterlace string
+	// AudioStreamIDs and SubtitleStreamIDs select the streams of the file to keep. All audio
+	// streams and text subtitles are kept if they are nil.
+	AudioStreamIDs    []int64
+	SubtitleStreamIDs []int64
+}
+
+// selected returns whether the stream is kept given the stream IDs selected, nil selects all.
+func selected(stream Stream, streamIDs []int64) bool {
+	if streamIDs == nil {
+		return true
+	}
+	for _, id := range streamIDs {
+		if id == stream.StreamId {
+			return true
+		}
+	}
+	return false
+}
+
+// Optimize encodes the file the streams belong to as a progressive MP4 at outputPath that most
+// clients can play without transcoding. The video is encoded with the best rung of the profile
+// that doesn't upscale it, the selected audio streams with the best audio rung and the selected
+// text subtitles of the file are kept. progress is called with the share of the file encoded so far.
+func Optimize(
+	ctx context.Context,
+	streams *Streams,
+	opts OptimizeOptions,
+	outputPath string,
+	progress func(float64)) error {
+
+	partPath := outputPath + ".part"
+	args, err := optimizeArgs(streams, opts, partPath)
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	log.Infoln("ffmpeg started with", cmd.Args)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	readOptimizeProgress(stdout, streams.GetVideoStream().TotalDuration, progress)
+
+	if err := cmd.Wait(); err != nil {
+		os.Remove(partPath)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return fmt.Errorf("ffmpeg failed: %s: %s", err, stderr.String())
+	}
+	return os.Rename(partPath, outputPath)
+}
+
+// readOptimizeProgress reports the progress ffmpeg writes with -progress until it exits.
+func readOptimizeProgress(r io.Reader, duration time.Duration, progress func(float64)) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// Despite its name, out_time_ms is in microseconds
+		value := strings.TrimPrefix(scanner.Text(), "out_time_ms=")
+		if value == scanner.Text() || duration <= 0 {
+			continue
+		}
+		if us, err := strconv.ParseInt(value, 10, 64); err == nil && progress != nil {
+			progress(float64(time.Duration(us)*time.Microsecond) / float64(duration))
+		}
+	}
+}
+
+// optimizeVideoRung returns the best rung of the profile that doesn't upscale the stream, or the
+// lowest one if all of them would.
+func optimizeVideoRung(profile TranscodingProfile, stream Stream) VideoRung {
+	best, lowest := VideoRung{}, profile.Video[0]
+	for _, r := range profile.Video {
+		if r.BitRate < lowest.BitRate {
+			lowest = r
+		}
+		if r.Height <= stream.Height && r.BitRate > best.BitRate {
+			best = r
+		}
+	}
+	if best.BitRate == 0 {
+		return lowest
+	}
+	return best
+}
+
+// optimizeArgs returns the ffmpeg arguments that create the optimized version of the file the
+// streams belong to at outputPath.
+func optimizeArgs(streams *Streams, opts OptimizeOptions, outputPath string) ([]string, error) {
+	if len(streams.VideoStreams) == 0 {
+		return nil, fmt.Errorf("file has no video stream")
+	}
+	video := streams.GetVideoStream()
+
+	format := opts.VideoFormat
+	if format == "" {
+		format = VideoCodecH264
+	}
+	offered := false
+	for _, f := range opts.Profile.OfferedVideoFormats(video) {
+		offered = offered || f == format
+	}
+	if !offered {
+		return nil, fmt.Errorf("profile \"%s\" doesn't offer %s video", opts.Profile.Name, format)
+	}
+
+	videoParams, err := GetVideoEncoderPreset(video, opts.Profile, optimizeVideoRung(opts.Profile, video).Name(), format)
+	if err != nil {
+		return nil, err
+	}
+	videoParams.deinterlace = opts.Deinterlace
+	audioRung := opts.Profile.Audio[0]
+	for _, r := range opts.Profile.Audio {
+		if r.BitRate > audioRung.BitRate {
+			audioRung = r
+		}
+	}
+	audioParams, err := GetAudioEncoderPreset(opts.Profile, audioRung.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", buildFfmpegUrlFromFileLocator(video.FileLocator),
+		"-map_chapters", "0",
+	}
+
+	output := 0
+	args = append(args, "-map", fmt.Sprintf("0:%d", video.StreamId))
+	args = append(args, outputStreamArgs(videoParams.videoEncoderArgs(video), output)...)
+	if filter := videoParams.videoFilter(video); filter != "" {
+		args = append(args, fmt.Sprintf("-filter:%d", output), filter)
+	}
+
+	if err := checkSelectedStreams(streams, opts); err != nil {
+		return nil, err
+	}
+
+	for _, s := range streams.AudioStreams {
+		if !selected(s, opts.AudioStreamIDs) {
+			continue
+		}
+		output++
+		args = append(args, "-map", fmt.Sprintf("0:%d", s.StreamId))
+		args = append(args, outputStreamArgs(audioParams.audioEncoderArgs(s), output)...)
+	}
+
+	for _, s := range streams.SubtitleStreams {
+		// Subtitle files next to the source are found next to it, not the optimized version
+		if s.IsImageSubtitle() || s.FileLocator != video.FileLocator || !selected(s, opts.SubtitleStreamIDs) {
+			continue
+		}
+		output++
+		args = append(args,
+			"-map", fmt.Sprintf("0:%d", s.StreamId),
+			fmt.Sprintf("-c:%d", output), "mov_text")
+	}
+
+	return append(args,
+		"-movflags", "+faststart",
+		"-progress", "pipe:1", "-nostats",
+		"-f", "mp4", "-y", outputPath), nil
+}
+
+// checkSelectedStreams returns an error if a selected stream doesn't exist or can't be stored in
+// the MP4 file.
+func checkSelectedStreams(streams *Streams, opts OptimizeOptions) error {
+	for _, id := range opts.AudioStreamIDs {
+		found := false
+		for _, s := range streams.AudioStreams {
+			found = found || s.StreamId == id
+		}
+		if !found {
+			return fmt.Errorf("no audio stream %d", id)
+		}
+	}
+
+	video := streams.GetVideoStream()
+	for _, id := range opts.SubtitleStreamIDs {
+		var subtitle *Stream
+		for i, s := range streams.SubtitleStreams {
+			if s.StreamId == id && s.FileLocator == video.FileLocator {
+				subtitle = &streams.SubtitleStreams[i]
+			}
+		}
+		if subtitle == nil {
+			return fmt.Errorf("no embedded subtitle stream %d", id)
+		}
+		if subtitle.IsImageSubtitle() {
+			return fmt.Errorf("subtitle stream %d is image-based and can't be stored in MP4", id)
+		}
+	}
+	return nil
+}
+
+// outputStreamArgs rewrites encoder arguments for the first output stream to apply to the output
+// stream with the given index instead. Every option of encoder arguments takes a value.
+func outputStreamArgs(args []string, index int) []string {
+	rewritten := append([]string{}, args...)
+	for i := 0; i < len(rewritten); i += 2 {
+		switch option := rewritten[i]; {
+		case strings.HasSuffix(option, ":0"):
+			rewritten[i] = fmt.Sprintf("%s:%d", strings.TrimSuffix(option, ":0"), index)
+		case option == "-b:v" || option == "-b:a":
+			rewritten[i] = fmt.Sprintf("-b:%d", index)
+		case option == "-ac":
+			rewritten[i] = fmt.Sprintf("-ac:%d", index)
+		}
+	}
+	return rewritten
+}
@@ -0,0 +1,89 @@
+package ffmpeg
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/filesystem"
+)
+
+func TestOptimizeArgs(t *testing.T) {
+	offerAllVideoCodecs(t)
+	locator := filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: "/movies/a.mkv"}
+	subtitleLocator := filesystem.FileLocator{Backend: filesystem.BackendLocal, Path: "/movies/a.en.srt"}
+	streams := &Streams{
+		VideoStreams: []Stream{{
+			StreamKey: StreamKey{FileLocator: locator, StreamId: 0}, StreamType: "video", CodecName: "h264",
+			Width: 1280, Height: 720, BitRate: 8000000, FrameRate: big.NewRat(24, 1),
+		}},
+		AudioStreams: []Stream{
+			{StreamKey: StreamKey{FileLocator: locator, StreamId: 1}, StreamType: "audio", Channels: 6, ChannelLayout: "5.1"},
+			{StreamKey: StreamKey{FileLocator: locator, StreamId: 2}, StreamType: "audio", Channels: 2},
+		},
+		SubtitleStreams: []Stream{
+			{StreamKey: StreamKey{FileLocator: locator, StreamId: 3}, StreamType: "subtitle", CodecName: "subrip"},
+			{StreamKey: StreamKey{FileLocator: locator, StreamId: 4}, StreamType: "subtitle", CodecName: "hdmv_pgs_subtitle"},
+			{StreamKey: StreamKey{FileLocator: subtitleLocator, StreamId: 0}, StreamType: "subtitle", CodecName: "subrip"},
+		},
+	}
+	profile := GetTranscodingProfile(DefaultTranscodingProfile)
+
+	args, err := optimizeArgs(streams, OptimizeOptions{Profile: profile}, "/optimized/a.mp4")
+	require.NoError(t, err)
+	cmdline := strings.Join(args, " ")
+
+	// 720p video isn't upscaled to the 1080p rung
+	assert.Contains(t, cmdline, "-map 0:0 -c:0 libx264 -b:0 5000000")
+	assert.Contains(t, cmdline, "-filter:0 scale=-2:720")
+	// Every audio stream is encoded with the best audio rung and surround is downmixed
+	assert.Contains(t, cmdline, "-map 0:1 -c:1 aac -b:1 128000")
+	assert.Contains(t, cmdline, "-ac:1 2 -filter:1 pan=stereo")
+	assert.Contains(t, cmdline, "-map 0:2 -c:2 aac -b:2 128000")
+	assert.NotContains(t, cmdline, "-ac:2")
+	// Only text subtitles of the file itself are kept
+	assert.Contains(t, cmdline, "-map 0:3 -c:3 mov_text")
+	assert.NotContains(t, cmdline, "0:4")
+	assert.NotContains(t, cmdline, "-c:4")
+	assert.True(t, strings.HasSuffix(cmdline, "-movflags +faststart -progress pipe:1 -nostats -f mp4 -y /optimized/a.mp4"))
+
+	args, err = optimizeArgs(streams, OptimizeOptions{Profile: profile, VideoFormat: VideoCodecHEVC}, "/optimized/a.mp4")
+	require.NoError(t, err)
+	assert.Contains(t, strings.Join(args, " "), "-c:0 libx265")
+
+	// Only the selected streams are kept, numbered in output order
+	args, err = optimizeArgs(streams, OptimizeOptions{
+		Profile: profile, AudioStreamIDs: []int64{2}, SubtitleStreamIDs: []int64{}}, "/downloads/a.mp4")
+	require.NoError(t, err)
+	cmdline = strings.Join(args, " ")
+	assert.Contains(t, cmdline, "-map 0:2 -c:1 aac -b:1 128000")
+	assert.NotContains(t, cmdline, "0:1 ")
+	assert.NotContains(t, cmdline, "mov_text")
+
+	_, err = optimizeArgs(streams, OptimizeOptions{Profile: profile, AudioStreamIDs: []int64{5}}, "/downloads/a.mp4")
+	assert.Error(t, err)
+	_, err = optimizeArgs(streams, OptimizeOptions{Profile: profile, SubtitleStreamIDs: []int64{4}}, "/downloads/a.mp4")
+	assert.Error(t, err, "image subtitles can't be stored in MP4")
+
+	_, err = optimizeArgs(streams, OptimizeOptions{Profile: profile, VideoFormat: "vp9"}, "/optimized/a.mp4")
+	assert.Error(t, err)
+	_, err = optimizeArgs(&Streams{}, OptimizeOptions{Profile: profile}, "/optimized/a.mp4")
+	assert.Error(t, err)
+}
+
+func TestOptimizeVideoRung(t *testing.T) {
+	profile := GetTranscodingProfile(DefaultTranscodingProfile)
+	assert.Equal(t, 1080, optimizeVideoRung(profile, Stream{Height: 2160}).Height)
+	assert.Equal(t, 720, optimizeVideoRung(profile, Stream{Height: 720}).Height)
+	assert.Equal(t, 480, optimizeVideoRung(profile, Stream{Height: 360}).Height)
+}
+
+func TestReadOptimizeProgress(t *testing.T) {
+	var reported []float64
+	readOptimizeProgress(strings.NewReader("frame=10\nout_time_ms=30000000\nprogress=continue\nout_time_ms=60000000\n"),
+		time.Minute, func(p float64) { reported = append(reported, p) })
+	assert.Equal(t, []float64{0.5, 1}, reported)
+}
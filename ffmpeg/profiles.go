@@ -0,0 +1,487 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"math/big"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// DefaultTranscodingProfile is the name of the built-in profile. It is used if no other profile is
+// selected and can be replaced by configuring a profile with the same name.
+const DefaultTranscodingProfile = "default"
+
+const (
+	// RateControlBitrate encodes with an average bitrate.
+	RateControlBitrate = "bitrate"
+	// RateControlCRF encodes with a constant quality, capped at the bitrate of the rung.
+	RateControlCRF = "crf"
+)
+
+const (
+	// VideoCodecH264 is offered to all clients.
+	VideoCodecH264 = "h264"
+	// VideoCodecHEVC and VideoCodecAV1 are only offered to clients that can play them.
+	VideoCodecHEVC = "hevc"
+	VideoCodecAV1  = "av1"
+)
+
+// videoEncoders are the ffmpeg encoders that can be used in profiles for each video codec.
+var videoEncoders = map[string][]string{
+	VideoCodecH264: {"libx264", "h264_nvenc", "h264_qsv", "h264_videotoolbox", "h264_amf"},
+	VideoCodecHEVC: {"libx265", "hevc_nvenc", "hevc_qsv", "hevc_videotoolbox", "hevc_amf"},
+	VideoCodecAV1:  {"libsvtav1", "libaom-av1", "av1_nvenc", "av1_qsv", "av1_amf"},
+}
+
+var ffmpegEncoders struct {
+	once     sync.Once
+	encoders map[string]bool
+	// warned remembers the missing encoders that were logged, profiles are read on every request.
+	warned sync.Map
+}
+
+// encoderAvailable returns true if the ffmpeg binary has the given encoder. It's a variable so
+// tests don't depend on how ffmpeg was built.
+var encoderAvailable = func(encoder string) bool {
+	ffmpegEncoders.once.Do(func() {
+		ffmpegEncoders.encoders = map[string]bool{}
+		out, err := exec.Command("ffmpeg", "-hide_banner", "-encoders").Output()
+		if err != nil {
+			log.WithError(err).Warnln("Could not list the encoders of ffmpeg")
+		}
+		// Encoders are listed like " V....D libx264    libx264 H.264 / AVC / MPEG-4 AVC"
+		for _, line := range strings.Split(string(out), "\n") {
+			if fields := strings.Fields(line); len(fields) >= 2 {
+				ffmpegEncoders.encoders[fields[1]] = true
+			}
+		}
+	})
+	return ffmpegEncoders.encoders[encoder]
+}
+
+var profileNameRegexp = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// VideoRung is one quality of the video ladder of a profile.
+type VideoRung struct {
+	Height  int
+	BitRate int
+}
+
+// Name returns the name of the rung as used in representation IDs, e.g. "720-5000k-video".
+func (r VideoRung) Name() string {
+	return fmt.Sprintf("%d-%dk-video", r.Height, r.BitRate/1000)
+}
+
+// AudioRung is one quality of the audio ladder of a profile.
+type AudioRung struct {
+	BitRate int
+}
+
+// Name returns the name of the rung as used in representation IDs, e.g. "128k-audio".
+func (r AudioRung) Name() string {
+	return fmt.Sprintf("%dk-audio", r.BitRate/1000)
+}
+
+// TranscodingProfile describes which qualities are offered when transcoding and how ffmpeg
+// encodes them. Profiles are configured in the "transcoding.profiles" list of the config file.
+type TranscodingProfile struct {
+	Name string
+
+	// VideoEncoder is the ffmpeg encoder used for H.264 video, e.g. "libx264".
+	VideoEncoder string
+	// HEVCEncoder and AV1Encoder are used for clients that can play these codecs.
+	HEVCEncoder string
+	AV1Encoder  string
+	// VideoCodecs are the codecs offered to clients that can play them, most preferred first.
+	// H.264 is used for all other clients.
+	VideoCodecs []string
+	// Preset and Tune are passed to the encoder if set, e.g. "veryfast" and "film" for libx264.
+	Preset string
+	Tune   string
+	// RateControl is either RateControlBitrate or RateControlCRF.
+	RateControl string
+	// CRF is the constant rate factor used with RateControlCRF.
+	CRF int
+	// MaxFrameRate limits the frame rate of transcoded video, 0 keeps the original frame rate.
+	MaxFrameRate float64
+	// ToneMapping is the algorithm of ffmpeg's tonemap filter used to convert HDR video to SDR
+	// for clients that can't display HDR, e.g. "hable" or "mobius".
+	ToneMapping string
+
+	Video []VideoRung
+	Audio []AudioRung
+
+	// DialogueBoost favours the centre channel when surround audio is downmixed to stereo.
+	DialogueBoost bool
+	// NormalizeLoudness evens out the volume of transcoded audio.
+	NormalizeLoudness bool
+}
+
+// builtinTranscodingProfile only offers H.264. HEVC and AV1 cost a lot more CPU, admins can opt in
+// to them in a configured profile.
+var builtinTranscodingProfile = TranscodingProfile{
+	Name:         DefaultTranscodingProfile,
+	VideoEncoder: "libx264",
+	HEVCEncoder:  "libx265",
+	AV1Encoder:   "libsvtav1",
+	VideoCodecs:  []string{VideoCodecH264},
+	Preset:       "veryfast",
+	RateControl:  RateControlBitrate,
+	ToneMapping:  DefaultToneMapping,
+	Video: []VideoRung{
+		{Height: 480, BitRate: 1000000},
+		{Height: 720, BitRate: 5000000},
+		{Height: 1080, BitRate: 10000000},
+	},
+	Audio: []AudioRung{
+		{BitRate: 64000},
+		{BitRate: 128000},
+	},
+}
+
+// Validate checks whether the profile can be used for transcoding.
+func (p TranscodingProfile) Validate() error {
+	if !profileNameRegexp.MatchString(p.Name) {
+		return fmt.Errorf("profile name \"%s\" may only contain a-z, 0-9, '-' and '_'", p.Name)
+	}
+
+	for _, codec := range []string{VideoCodecH264, VideoCodecHEVC, VideoCodecAV1} {
+		encoder := p.encoderFor(codec)
+		knownEncoder := false
+		for _, e := range videoEncoders[codec] {
+			knownEncoder = knownEncoder || e == encoder
+		}
+		if !knownEncoder {
+			return fmt.Errorf("unsupported %s encoder \"%s\", must be one of %s",
+				codec, encoder, strings.Join(videoEncoders[codec], ", "))
+		}
+	}
+	for _, codec := range p.VideoCodecs {
+		if _, ok := videoEncoders[codec]; !ok {
+			return fmt.Errorf("unsupported video codec \"%s\"", codec)
+		}
+	}
+
+	switch p.RateControl {
+	case RateControlBitrate:
+	case RateControlCRF:
+		if p.CRF < 1 || p.CRF > 51 {
+			return fmt.Errorf("crf must be between 1 and 51")
+		}
+	default:
+		return fmt.Errorf("rate control must be \"%s\" or \"%s\"", RateControlBitrate, RateControlCRF)
+	}
+
+	knownToneMapping := false
+	for _, t := range toneMappings {
+		knownToneMapping = knownToneMapping || t == p.ToneMapping
+	}
+	if !knownToneMapping {
+		return fmt.Errorf("unsupported tone mapping \"%s\", must be one of %s",
+			p.ToneMapping, strings.Join(toneMappings, ", "))
+	}
+
+	if p.MaxFrameRate < 0 {
+		return fmt.Errorf("max frame rate must not be negative")
+	}
+	if len(p.Video) == 0 || len(p.Audio) == 0 {
+		return fmt.Errorf("at least one video and one audio rung are required")
+	}
+	for _, r := range p.Video {
+		if r.Height <= 0 || r.BitRate <= 0 {
+			return fmt.Errorf("video rung %s needs a height and a bitrate", r.Name())
+		}
+	}
+	for _, r := range p.Audio {
+		if r.BitRate <= 0 {
+			return fmt.Errorf("audio rung %s needs a bitrate", r.Name())
+		}
+	}
+	return nil
+}
+
+// encoderFor returns the ffmpeg encoder of the profile for the video codec.
+func (p TranscodingProfile) encoderFor(codec string) string {
+	switch codec {
+	case VideoCodecHEVC:
+		return p.HEVCEncoder
+	case VideoCodecAV1:
+		return p.AV1Encoder
+	}
+	return p.VideoEncoder
+}
+
+// withAvailableVideoCodecs drops the codecs whose encoder is missing from the ffmpeg binary, clients
+// would otherwise get transcodes that fail. H.264 is kept as it's the fallback for all clients.
+func (p TranscodingProfile) withAvailableVideoCodecs() TranscodingProfile {
+	codecs := []string{}
+	for _, codec := range p.VideoCodecs {
+		encoder := p.encoderFor(codec)
+		if codec == VideoCodecH264 || encoderAvailable(encoder) {
+			codecs = append(codecs, codec)
+			continue
+		}
+		if _, warned := ffmpegEncoders.warned.LoadOrStore(encoder, true); !warned {
+			log.WithFields(log.Fields{"profile": p.Name, "encoder": encoder}).
+				Warnf("ffmpeg has no %s encoder, not offering %s", encoder, codec)
+		}
+	}
+	p.VideoCodecs = codecs
+	return p
+}
+
+// OfferedVideoCodecs returns all codecs transcoded video may have with this profile.
+func (p TranscodingProfile) OfferedVideoCodecs() []string {
+	codecs := []string{}
+	for _, codec := range p.VideoCodecs {
+		if codec != VideoCodecH264 {
+			codecs = append(codecs, codec)
+		}
+	}
+	return append(codecs, VideoCodecH264)
+}
+
+// OfferedVideoFormats returns all formats video of the stream may be transcoded to with this
+// profile, most preferred first. HDR video in HEVC or AV1 can keep its HDR, see HDRVideoFormat.
+func (p TranscodingProfile) OfferedVideoFormats(stream Stream) []string {
+	formats := []string{}
+	for _, codec := range p.OfferedVideoCodecs() {
+		if stream.IsHDR() && codec != VideoCodecH264 {
+			formats = append(formats, HDRVideoFormat(codec))
+		}
+		formats = append(formats, codec)
+	}
+	return formats
+}
+
+// VideoCodecFor returns the format video is transcoded to for a client: the most preferred codec
+// of the profile the client can play, keeping HDR only if the client can display it. Clients
+// without preferences get H.264.
+func (p TranscodingProfile) VideoCodecFor(stream Stream, capabilities ClientCodecCapabilities) string {
+	for _, format := range p.OfferedVideoFormats(stream) {
+		if _, hdr := splitHDR(format); hdr && !capabilities.CanDisplay(stream.VideoRange()) {
+			continue
+		}
+		if capabilities.CanPlay(GetSimilarTranscodedVideoRepresentation(stream, p, format)) {
+			return format
+		}
+	}
+	return VideoCodecH264
+}
+
+// VideoPresets returns the representation IDs of the video ladder in the given format, lowest
+// quality first.
+func (p TranscodingProfile) VideoPresets(format string) []string {
+	presets := []string{}
+	for _, r := range p.Video {
+		preset := fmt.Sprintf("preset:%s:%s", p.Name, r.Name())
+		// H.264 presets don't name the codec to stay compatible with older IDs
+		if format != VideoCodecH264 {
+			preset += ":" + format
+		}
+		presets = append(presets, preset)
+	}
+	return presets
+}
+
+// AudioPresets returns the representation IDs of the audio ladder, lowest quality first.
+func (p TranscodingProfile) AudioPresets() []string {
+	presets := []string{}
+	for _, r := range p.Audio {
+		presets = append(presets, fmt.Sprintf("preset:%s:%s", p.Name, r.Name()))
+	}
+	return presets
+}
+
+// VideoRepresentations returns the transcoded representations of the video ladder for the stream.
+func (p TranscodingProfile) VideoRepresentations(stream Stream, format string) []StreamRepresentation {
+	representations := []StreamRepresentation{}
+	for _, preset := range p.VideoPresets(format) {
+		r, _ := StreamRepresentationFromRepresentationId(stream, preset)
+		representations = append(representations, r)
+	}
+	return representations
+}
+
+// LowerQualityRepresentations returns the representations of the video ladder of the profile the
+// transcoded video was created with that have a lower bitrate, in the same format and best first.
+// Burned in subtitles and deinterlacing are kept.
+func LowerQualityRepresentations(r StreamRepresentation) []StreamRepresentation {
+	if r.Stream.StreamType != "video" || !r.Representation.Transcoded {
+		return nil
+	}
+	params := r.Representation.encoderParams
+	format := params.videoCodec
+	if params.hdr {
+		format = HDRVideoFormat(format)
+	}
+	// Transcoded representation IDs don't carry the bitrate, it's similar to the source
+	bitRate := r.Representation.BitRate
+	if bitRate == 0 {
+		bitRate = int(r.Stream.BitRate)
+	}
+
+	lower := []StreamRepresentation{}
+	for _, l := range GetTranscodingProfile(params.profile).VideoRepresentations(r.Stream, format) {
+		if l.Representation.BitRate == 0 || l.Representation.BitRate >= bitRate {
+			continue
+		}
+		l = WithDeinterlacing(l, params.deinterlace)
+		if params.burnIn {
+			l = withBurnIn(l, params.burnInSubtitleStreamId)
+		}
+		lower = append(lower, l)
+	}
+	sort.Slice(lower, func(i, j int) bool {
+		return lower[i].Representation.BitRate > lower[j].Representation.BitRate
+	})
+	return lower
+}
+
+// applyTo copies the encoder settings of the profile for the format to the params.
+func (p TranscodingProfile) applyTo(params *EncoderParams, format string) {
+	codec, hdr := splitHDR(format)
+	params.profile = p.Name
+	params.videoCodec = codec
+	// We encode H.264 with 8 bits only
+	params.hdr = hdr && codec != VideoCodecH264
+	params.toneMapping = p.ToneMapping
+	params.encoder = p.encoderFor(codec)
+	params.preset = p.Preset
+	params.tune = p.Tune
+	if p.RateControl == RateControlCRF {
+		params.crf = p.CRF
+	}
+	params.maxFrameRate = p.MaxFrameRate
+}
+
+// applyAudioTo copies the audio settings of the profile to the params.
+func (p TranscodingProfile) applyAudioTo(params *EncoderParams) {
+	params.dialogueBoost = p.DialogueBoost
+	params.normalizeLoudness = p.NormalizeLoudness
+}
+
+// TranscodingProfiles returns all valid profiles, the built-in one first unless it is replaced
+// in the config file. Video codecs ffmpeg can't encode are left out.
+func TranscodingProfiles() []TranscodingProfile {
+	configured := []TranscodingProfile{}
+	if err := viper.UnmarshalKey("transcoding.profiles", &configured); err != nil {
+		log.WithError(err).Warnln("Failed to read transcoding profiles from config")
+	}
+
+	profiles := []TranscodingProfile{builtinTranscodingProfile.withAvailableVideoCodecs()}
+	for _, p := range configured {
+		if p.VideoEncoder == "" {
+			p.VideoEncoder = builtinTranscodingProfile.VideoEncoder
+		}
+		if p.HEVCEncoder == "" {
+			p.HEVCEncoder = builtinTranscodingProfile.HEVCEncoder
+		}
+		if p.AV1Encoder == "" {
+			p.AV1Encoder = builtinTranscodingProfile.AV1Encoder
+		}
+		if p.VideoCodecs == nil {
+			p.VideoCodecs = builtinTranscodingProfile.VideoCodecs
+		}
+		if p.RateControl == "" {
+			p.RateControl = RateControlBitrate
+		}
+		if p.ToneMapping == "" {
+			p.ToneMapping = DefaultToneMapping
+		}
+		if err := p.Validate(); err != nil {
+			log.WithError(err).WithField("profile", p.Name).Warnln("Ignoring invalid transcoding profile")
+			continue
+		}
+
+		p = p.withAvailableVideoCodecs()
+
+		if p.Name == DefaultTranscodingProfile {
+			profiles[0] = p
+		} else {
+			profiles = append(profiles, p)
+		}
+	}
+	return profiles
+}
+
+// FindTranscodingProfile returns the profile with the given name.
+func FindTranscodingProfile(name string) (TranscodingProfile, bool) {
+	for _, p := range TranscodingProfiles() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return TranscodingProfile{}, false
+}
+
+// GetTranscodingProfile returns the profile with the given name. Unknown or empty names fall back
+// to the profile set as "transcoding.defaultProfile" and then to the built-in profile.
+func GetTranscodingProfile(name string) TranscodingProfile {
+	for _, n := range []string{name, viper.GetString("transcoding.defaultProfile")} {
+		if n == "" {
+			continue
+		}
+		if p, ok := FindTranscodingProfile(n); ok {
+			return p
+		}
+		log.WithField("profile", n).Warnln("Unknown transcoding profile, falling back to default")
+	}
+	p, _ := FindTranscodingProfile(DefaultTranscodingProfile)
+	return p
+}
+
+// splitProfileName splits IDs of the form "<profile>:<rest>". IDs without a profile, as
+// generated by older versions, belong to the default profile.
+func splitProfileName(id string) (string, string) {
+	if i := strings.Index(id, ":"); i >= 0 {
+		return id[:i], id[i+1:]
+	}
+	return DefaultTranscodingProfile, id
+}
+
+// splitVideoCodec splits preset names of the form "<rung>:<format>", presets without a format
+// are H.264.
+func splitVideoCodec(name string) (string, string) {
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, VideoCodecH264
+}
+
+// videoCodecOf returns the video format of a codecs string. 10-bit HEVC and AV1 is only produced
+// for HDR.
+func videoCodecOf(codecs string) string {
+	switch {
+	case strings.HasPrefix(codecs, "hvc1.2."), strings.HasPrefix(codecs, "hev1.2."):
+		return HDRVideoFormat(VideoCodecHEVC)
+	case strings.HasPrefix(codecs, "hvc1"), strings.HasPrefix(codecs, "hev1"):
+		return VideoCodecHEVC
+	case strings.HasPrefix(codecs, "av01") && strings.HasSuffix(codecs, ".10"):
+		return HDRVideoFormat(VideoCodecAV1)
+	case strings.HasPrefix(codecs, "av01"):
+		return VideoCodecAV1
+	}
+	return VideoCodecH264
+}
+
+// videoCodecTag returns the codecs string of video in the given format with the given properties.
+func videoCodecTag(format string, width int, height int, bitRate int64, frameRate *big.Rat) string {
+	switch format {
+	case VideoCodecHEVC:
+		return GetHVC1Tag(width, height, bitRate, frameRate)
+	case HDRVideoFormat(VideoCodecHEVC):
+		return GetHVC1Main10Tag(width, height, bitRate, frameRate)
+	case VideoCodecAV1:
+		return GetAV01Tag(width, height, bitRate, frameRate)
+	case HDRVideoFormat(VideoCodecAV1):
+		return GetAV0110BitTag(width, height, bitRate, frameRate)
+	}
+	return GetAVC1Tag(width, height, bitRate, frameRate)
+}
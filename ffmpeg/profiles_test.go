@@ -0,0 +1,191 @@
+package ffmpeg
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setTestProfiles(t *testing.T, profiles []map[string]interface{}) {
+	viper.Set("transcoding.profiles", profiles)
+	t.Cleanup(func() {
+		viper.Set("transcoding.profiles", nil)
+		viper.Set("transcoding.defaultProfile", "")
+	})
+}
+
+// offerAllVideoCodecs makes the built-in profile offer AV1 and HEVC and pretends ffmpeg has all
+// encoders.
+func offerAllVideoCodecs(t *testing.T) {
+	builtin, available := builtinTranscodingProfile, encoderAvailable
+	builtinTranscodingProfile.VideoCodecs = []string{VideoCodecAV1, VideoCodecHEVC, VideoCodecH264}
+	encoderAvailable = func(string) bool { return true }
+	t.Cleanup(func() {
+		builtinTranscodingProfile, encoderAvailable = builtin, available
+	})
+}
+
+func TestTranscodingProfiles(t *testing.T) {
+	setTestProfiles(t, []map[string]interface{}{
+		{
+			"name":         "mobile",
+			"rateControl":  "crf",
+			"crf":          26,
+			"tune":         "film",
+			"maxFrameRate": 30,
+			"video":        []map[string]interface{}{{"height": 360, "bitRate": 600000}},
+			"audio":        []map[string]interface{}{{"bitRate": 96000}},
+		},
+		{
+			"name":         "broken",
+			"videoEncoder": "libvpx",
+			"video":        []map[string]interface{}{{"height": 360, "bitRate": 600000}},
+			"audio":        []map[string]interface{}{{"bitRate": 96000}},
+		},
+	})
+
+	profiles := TranscodingProfiles()
+	require.Len(t, profiles, 2)
+	assert.Equal(t, DefaultTranscodingProfile, profiles[0].Name)
+
+	mobile := profiles[1]
+	assert.Equal(t, "libx264", mobile.VideoEncoder)
+	assert.Equal(t, RateControlCRF, mobile.RateControl)
+	assert.Equal(t, []string{"preset:mobile:360-600k-video"}, mobile.VideoPresets(VideoCodecH264))
+	assert.Equal(t, []string{"preset:mobile:96k-audio"}, mobile.AudioPresets())
+
+	assert.Equal(t, "mobile", GetTranscodingProfile("mobile").Name)
+	assert.Equal(t, DefaultTranscodingProfile, GetTranscodingProfile("broken").Name)
+
+	viper.Set("transcoding.defaultProfile", "mobile")
+	assert.Equal(t, "mobile", GetTranscodingProfile("").Name)
+}
+
+func TestStreamRepresentationFromPreset(t *testing.T) {
+	setTestProfiles(t, []map[string]interface{}{
+		{
+			"name":         "mobile",
+			"rateControl":  "crf",
+			"crf":          26,
+			"maxFrameRate": 30,
+			"video":        []map[string]interface{}{{"height": 360, "bitRate": 600000}},
+			"audio":        []map[string]interface{}{{"bitRate": 96000}},
+		},
+	})
+
+	stream := Stream{StreamType: "video", Width: 1920, Height: 1080, FrameRate: big.NewRat(60, 1)}
+
+	// Representation IDs without a profile belong to the default profile
+	r, err := StreamRepresentationFromRepresentationId(stream, "preset:720-5000k-video")
+	require.NoError(t, err)
+	assert.Equal(t, 720, r.Representation.Height)
+	assert.Equal(t, []string{"-c:0", "libx264", "-b:v", "5000000", "-preset:0", "veryfast"},
+		r.Representation.encoderParams.videoEncoderArgs(stream))
+
+	r, err = StreamRepresentationFromRepresentationId(stream, "preset:mobile:360-600k-video")
+	require.NoError(t, err)
+	assert.Equal(t, 600000, r.Representation.BitRate)
+	assert.Equal(t, []string{
+		"-c:0", "libx264", "-crf:0", "26", "-maxrate:0", "600000", "-bufsize:0", "1200000", "-r:0", "30.000"},
+		r.Representation.encoderParams.videoEncoderArgs(stream))
+
+	_, err = StreamRepresentationFromRepresentationId(stream, "preset:mobile:720-5000k-video")
+	assert.Error(t, err)
+
+	audio := Stream{StreamType: "audio"}
+	r, err = StreamRepresentationFromRepresentationId(audio, "preset:mobile:96k-audio")
+	require.NoError(t, err)
+	assert.Equal(t, 96000, r.Representation.BitRate)
+}
+
+func TestVideoCodecTags(t *testing.T) {
+	frameRate := big.NewRat(30, 1)
+	assert.Equal(t, "hvc1.1.6.L120.B0", GetHVC1Tag(1920, 1080, 10000000, frameRate))
+	assert.Equal(t, "hvc1.1.6.L93.B0", GetHVC1Tag(1280, 720, 5000000, frameRate))
+	assert.Equal(t, "hvc1.1.6.L153.B0", GetHVC1Tag(3840, 2160, 30000000, big.NewRat(60, 1)))
+	assert.Equal(t, "av01.0.08M.08", GetAV01Tag(1920, 1080, 10000000, frameRate))
+	assert.Equal(t, "av01.0.05M.08", GetAV01Tag(1280, 720, 5000000, frameRate))
+	assert.Equal(t, "av01.0.13M.08", GetAV01Tag(3840, 2160, 30000000, big.NewRat(60, 1)))
+}
+
+func TestVideoCodecFor(t *testing.T) {
+	offerAllVideoCodecs(t)
+	profile := GetTranscodingProfile(DefaultTranscodingProfile)
+	stream := Stream{StreamType: "video", Width: 1920, Height: 1080, BitRate: 8000000, FrameRate: big.NewRat(24, 1)}
+
+	assert.Equal(t, VideoCodecH264, profile.VideoCodecFor(stream, ClientCodecCapabilities{}))
+
+	hevc := GetSimilarTranscodedVideoRepresentation(stream, profile, VideoCodecHEVC)
+	assert.Equal(t, "hvc1.1.6.L120.B0", hevc.Representation.Codecs)
+	capabilities := ClientCodecCapabilities{PlayableCodecs: []string{"avc1.640028", hevc.Representation.Codecs}}
+	assert.Equal(t, VideoCodecHEVC, profile.VideoCodecFor(stream, capabilities))
+
+	r, err := StreamRepresentationFromRepresentationId(stream, "preset:default:720-5000k-video:hevc")
+	require.NoError(t, err)
+	assert.Equal(t, "hvc1.1.6.L93.B0", r.Representation.Codecs)
+	assert.Equal(t, []string{
+		"-c:0", "libx265", "-b:v", "5000000", "-x265-params:0", "log-level=error", "-preset:0", "veryfast",
+		"-pix_fmt:0", "yuv420p", "-tag:0", "hvc1"},
+		r.Representation.encoderParams.videoEncoderArgs(stream))
+
+	// Transcoded representation IDs keep their codec
+	r, err = StreamRepresentationFromRepresentationId(stream, hevc.Representation.RepresentationId)
+	require.NoError(t, err)
+	assert.Equal(t, "libx265", r.Representation.encoderParams.encoder)
+
+	r, err = StreamRepresentationFromRepresentationId(stream, "preset:default:480-1000k-video:av1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"-c:0", "libsvtav1", "-b:v", "1000000", "-preset:0", "11", "-pix_fmt:0", "yuv420p"},
+		r.Representation.encoderParams.videoEncoderArgs(stream))
+}
+
+func TestLowerQualityRepresentations(t *testing.T) {
+	profile := GetTranscodingProfile(DefaultTranscodingProfile)
+	stream := Stream{StreamType: "video", Codecs: "avc1.640028", Width: 1920, Height: 1080,
+		BitRate: 8000000, FrameRate: big.NewRat(24, 1)}
+
+	ids := func(representations []StreamRepresentation) []string {
+		ids := []string{}
+		for _, r := range representations {
+			ids = append(ids, r.Representation.RepresentationId)
+		}
+		return ids
+	}
+
+	// Similar transcodes fall back to the rungs below the bitrate of the source
+	similar := GetSimilarTranscodedVideoRepresentation(stream, profile, VideoCodecHEVC)
+	assert.Equal(t, []string{"preset:default:720-5000k-video:hevc", "preset:default:480-1000k-video:hevc"},
+		ids(LowerQualityRepresentations(similar)))
+	r, err := StreamRepresentationFromRepresentationId(stream, similar.Representation.RepresentationId)
+	require.NoError(t, err)
+	assert.Len(t, LowerQualityRepresentations(r), 2)
+
+	r, err = StreamRepresentationFromRepresentationId(stream, "burnin:3:preset:default:720-5000k-video")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"burnin:3:preset:default:480-1000k-video"}, ids(LowerQualityRepresentations(r)))
+
+	assert.Empty(t, LowerQualityRepresentations(GetTransmuxedRepresentation(stream)))
+}
+
+func TestTranscodingProfiles_VideoCodecs(t *testing.T) {
+	setTestProfiles(t, []map[string]interface{}{
+		{
+			"name":        "efficient",
+			"videoCodecs": []string{VideoCodecAV1, VideoCodecHEVC},
+			"video":       []map[string]interface{}{{"height": 720, "bitRate": 3000000}},
+			"audio":       []map[string]interface{}{{"bitRate": 128000}},
+		},
+	})
+	available := encoderAvailable
+	encoderAvailable = func(encoder string) bool { return encoder == "libx265" }
+	t.Cleanup(func() { encoderAvailable = available })
+
+	// Only H.264 unless admins opt in to the more expensive codecs
+	assert.Equal(t, []string{VideoCodecH264}, GetTranscodingProfile(DefaultTranscodingProfile).OfferedVideoCodecs())
+	// ffmpeg has no libsvtav1
+	assert.Equal(t, []string{VideoCodecHEVC, VideoCodecH264}, GetTranscodingProfile("efficient").OfferedVideoCodecs())
+}
@@ -6,10 +6,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"gitlab.com/olaris/olaris-server/filesystem"
 	"math/big"
-	"path/filepath"
-	"regexp"
 	"strconv"
-	"strings"
 	"time"
 )
 
@@ -42,6 +39,22 @@ type Stream struct {
 	Width  int
 	Height int
 
+	// Only relevant for audio, Channels is 0 if unknown.
+	Channels      int
+	ChannelLayout string
+
+	// Only relevant for video, as reported by ffprobe, e.g. "smpte2084", "bt2020" and "bt2020nc"
+	// for HDR10. See VideoRange.
+	ColorTransfer  string
+	ColorPrimaries string
+	ColorSpace     string
+	PixelFormat    string
+	// DolbyVisionProfile is the Dolby Vision profile of the video, 0 if it has none.
+	DolbyVisionProfile int
+	// FieldOrder is the field order of the video as reported by ffprobe, e.g. "progressive" or
+	// "tt" for interlaced video with the top field first. See IsInterlaced.
+	FieldOrder string
+
 	// "audio", "video", "subtitle"
 	StreamType string
 	// Only relevant for audio and subtitles. Language code.
@@ -49,6 +62,10 @@ type Stream struct {
 	// User-visible string for this audio or subtitle track
 	Title            string
 	EnabledByDefault bool
+
+	// Only relevant for subtitles. Forced subtitles only cover foreign-language parts.
+	Forced          bool
+	HearingImpaired bool
 }
 
 type Streams struct {
@@ -102,6 +119,8 @@ func GetStreams(fileLocator filesystem.FileLocator) (*Streams, error) {
 					},
 					Codecs:           stream.GetMime(),
 					BitRate:          int64(bitrate),
+					Channels:         stream.Channels,
+					ChannelLayout:    stream.ChannelLayout,
 					TotalDuration:    time.Duration(totalDurationSeconds * float64(time.Second)),
 					TotalDurationDts: totalDurationTs,
 					StreamType:       stream.CodecType,
@@ -148,6 +167,13 @@ func GetStreams(fileLocator filesystem.FileLocator) (*Streams, error) {
 				StreamType:       stream.CodecType,
 				CodecName:        stream.CodecName,
 				Profile:          stream.Profile,
+				ColorTransfer:    stream.ColorTransfer,
+				ColorPrimaries:   stream.ColorPrimaries,
+				ColorSpace:       stream.ColorSpace,
+				PixelFormat:      stream.PixFmt,
+
+				DolbyVisionProfile: stream.dolbyVisionProfile(),
+				FieldOrder:         stream.FieldOrder,
 			})
 		} else if stream.CodecType == "subtitle" {
 			// TODO(Leon Handreke): This usually happens for next-to-the-file .srt files, ffprobe doesn't return
@@ -165,9 +191,12 @@ func GetStreams(fileLocator filesystem.FileLocator) (*Streams, error) {
 				TotalDurationDts: DtsTimestamp(totalDurationSeconds * 1000),
 				TimeBase:         big.NewRat(1, 1000),
 				StreamType:       "subtitle",
+				CodecName:        stream.CodecName,
 				Language:         GetLanguageTag(stream),
 				Title:            GetTitleOrHumanizedLanguage(stream),
 				EnabledByDefault: stream.Disposition["default"] != 0,
+				Forced:           stream.Disposition["forced"] != 0,
+				HearingImpaired:  stream.Disposition["hearing_impaired"] != 0,
 			})
 
 		}
@@ -192,63 +221,6 @@ func (s *Streams) GetVideoStream() Stream {
 
 }
 
-func buildExternalSubtitleStreams(
-	fileLocator filesystem.FileLocator,
-	duration time.Duration) ([]Stream, error) {
-
-	streams := []Stream{}
-
-	// TODO(Leon Handreke): We should be able to support other backends, but it will be another
-	// point where the abstraction will be a bit leaky
-	if fileLocator.Backend != filesystem.BackendLocal {
-		return []Stream{}, nil
-	}
-
-	mediaFilePath := fileLocator.Path
-	mediaFilePathWithoutExt := strings.TrimSuffix(mediaFilePath, filepath.Ext(mediaFilePath))
-	r := regexp.MustCompile(regexp.QuoteMeta(mediaFilePathWithoutExt) + "\\.?(?P<lang>.*).srt")
-
-	subtitleFiles, _ := filepath.Glob(mediaFilePathWithoutExt + "*.srt")
-	for _, subtitleFile := range subtitleFiles {
-		match := r.FindStringSubmatch(subtitleFile)
-		if match == nil {
-			log.Error("Failed to parse subtitle filename: ", subtitleFile)
-			continue
-		}
-
-		lang := "unk"
-
-		// TODO(Leon Handreke): This is a case of aggressive programming, can this ever fail?
-		tag := match[1]
-		if tag != "" {
-			// If the language tag is "English" or "Polish" instead of a code
-			if _, ok := humanizedToLangTag[tag]; ok {
-				lang = humanizedToLangTag[tag]
-			} else {
-				lang = tag
-			}
-		}
-
-		streams = append(streams,
-			Stream{
-				StreamKey: StreamKey{
-					FileLocator: filesystem.FileLocator{
-						Backend: fileLocator.Backend,
-						Path:    subtitleFile,
-					},
-					StreamId: 0,
-				},
-				TotalDuration:    duration,
-				StreamType:       "subtitle",
-				Language:         lang,
-				Title:            lang,
-				EnabledByDefault: false,
-			})
-	}
-
-	return streams, nil
-}
-
 func GetStream(streamKey StreamKey) (Stream, error) {
 	// TODO(Leon Handreke): Error handling
 	c, err := GetStreams(streamKey.FileLocator)
@@ -1,17 +1,69 @@
 package ffmpeg
 
 import (
+	"context"
 	"fmt"
 	log "github.com/sirupsen/logrus"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
+	"strconv"
+	"strings"
 )
 
+// imageSubtitleCodecs are the subtitle codecs that store bitmaps rather than text. They can't be
+// converted to WebVTT and have to be burned into the video instead.
+var imageSubtitleCodecs = map[string]bool{
+	"hdmv_pgs_subtitle": true,
+	"dvd_subtitle":      true,
+	"dvb_subtitle":      true,
+	"xsub":              true,
+}
+
+// burnInPrefix marks video representation IDs that have a subtitle stream burned in, e.g.
+// "burnin:3:preset:default:720-5000k-video".
+const burnInPrefix = "burnin:"
+
+// IsImageSubtitle returns whether the stream is a bitmap subtitle such as PGS, VobSub or DVB.
+func (s Stream) IsImageSubtitle() bool {
+	return s.StreamType == "subtitle" && imageSubtitleCodecs[s.CodecName]
+}
+
+// IsStyledSubtitle returns whether the stream is an ASS/SSA subtitle, whose styling is lost when
+// it is converted to WebVTT.
+func (s Stream) IsStyledSubtitle() bool {
+	return s.StreamType == "subtitle" && (s.CodecName == "ass" || s.CodecName == "ssa")
+}
+
+// ExtractASSSubtitle writes the ASS/SSA subtitle stream with its styling to w, for clients that
+// can render it themselves.
+func ExtractASSSubtitle(ctx context.Context, stream Stream, w io.Writer) error {
+	if !stream.IsStyledSubtitle() {
+		return fmt.Errorf("%s subtitles are not ASS/SSA", stream.CodecName)
+	}
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-i", buildFfmpegUrlFromFileLocator(stream.FileLocator),
+		"-map", fmt.Sprintf("0:%d", stream.StreamId),
+		"-c:s", "copy",
+		"-f", "ass",
+		"pipe:1")
+	cmd.Stdout = w
+	cmd.Stderr, _ = os.Open(os.DevNull)
+
+	log.WithFields(log.Fields{"args": cmd.Args}).Debugln("ffmpeg extracting ASS subtitles")
+	return cmd.Run()
+}
+
 func NewSubtitleSession(
 	stream StreamRepresentation,
 	outputDirBase string) (*TranscodingSession, error) {
 
+	if stream.Stream.IsImageSubtitle() {
+		return nil, fmt.Errorf("%s subtitles can't be converted to WebVTT, they can only be burned in",
+			stream.Stream.CodecName)
+	}
+
 	outputDir, err := ioutil.TempDir(outputDirBase, "subtitle-session-")
 	if err != nil {
 		return nil, err
@@ -44,11 +96,80 @@ func GetSubtitleStreamRepresentation(stream Stream) StreamRepresentation {
 	}
 }
 
+// GetSubtitleStreamRepresentations returns the WebVTT representations of all text subtitles,
+// image subtitles are skipped.
 func GetSubtitleStreamRepresentations(streams []Stream) []StreamRepresentation {
 	subtitleRepresentations := []StreamRepresentation{}
 	for _, s := range streams {
+		if s.IsImageSubtitle() {
+			continue
+		}
 		subtitleRepresentations = append(subtitleRepresentations,
 			GetSubtitleStreamRepresentation(s))
 	}
 	return subtitleRepresentations
 }
+
+// GetBurnInRepresentation returns the video representation with the image subtitle burned in.
+// Transmuxed representations are replaced with a similar transcoded one in the format.
+func GetBurnInRepresentation(
+	video StreamRepresentation,
+	subtitle Stream,
+	profile TranscodingProfile,
+	format string) StreamRepresentation {
+
+	if !video.Representation.Transcoded {
+		video = GetSimilarTranscodedVideoRepresentation(video.Stream, profile, format)
+	}
+	return withBurnIn(video, subtitle.StreamId)
+}
+
+// withBurnIn returns the transcoded video representation with the subtitle stream burned in.
+func withBurnIn(video StreamRepresentation, subtitleStreamId int64) StreamRepresentation {
+	video.Representation.RepresentationId = fmt.Sprintf("%s%d:%s",
+		burnInPrefix, subtitleStreamId, video.Representation.RepresentationId)
+	video.Representation.encoderParams.burnIn = true
+	video.Representation.encoderParams.burnInSubtitleStreamId = subtitleStreamId
+	return video
+}
+
+// splitBurnIn splits representation IDs with a burned in subtitle into the ID of the subtitle
+// stream and the ID of the video representation.
+func splitBurnIn(representationId string) (int64, string, bool) {
+	if !strings.HasPrefix(representationId, burnInPrefix) {
+		return 0, representationId, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(representationId, burnInPrefix), ":", 2)
+	if len(parts) != 2 {
+		return 0, representationId, false
+	}
+	streamId, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, representationId, false
+	}
+	return streamId, parts[1], true
+}
+
+// burnInFilterComplex returns the filter graph that overlays the subtitle stream on the video
+// stream and applies the video filter, with the result labelled "[v]". Interlaced video is
+// deinterlaced and HDR video is tone mapped before the overlay so the subtitles stay intact.
+func (p EncoderParams) burnInFilterComplex(stream Stream) string {
+	video := fmt.Sprintf("[0:%d]", stream.StreamId)
+	filters := []string{}
+	if deinterlace := p.deinterlaceFilter(stream); deinterlace != "" {
+		filters = append(filters, deinterlace)
+	}
+	if stream.IsHDR() && !p.keepsHDR(stream) {
+		filters = append(filters, toneMappingFilter(p.toneMapping))
+	}
+	graph := ""
+	if len(filters) > 0 {
+		graph = fmt.Sprintf("%s%s[base];", video, strings.Join(filters, ","))
+		video = "[base]"
+	}
+	graph += fmt.Sprintf("%s[0:%d]overlay=eof_action=pass", video, p.burnInSubtitleStreamId)
+	if p.width != 0 || p.height != 0 {
+		graph += fmt.Sprintf(",scale=%d:%d", p.width, p.height)
+	}
+	return graph + "[v]"
+}
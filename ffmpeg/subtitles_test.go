@@ -0,0 +1,50 @@
+package ffmpeg
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSubtitleStreamRepresentations(t *testing.T) {
+	srt := Stream{StreamType: "subtitle", CodecName: "subrip"}
+	pgs := Stream{StreamType: "subtitle", CodecName: "hdmv_pgs_subtitle"}
+	assert.False(t, srt.IsImageSubtitle())
+	assert.True(t, pgs.IsImageSubtitle())
+
+	representations := GetSubtitleStreamRepresentations([]Stream{srt, pgs})
+	require.Len(t, representations, 1)
+	assert.Equal(t, "subrip", representations[0].Stream.CodecName)
+
+	_, err := NewSubtitleSession(GetSubtitleStreamRepresentation(pgs), t.TempDir())
+	assert.Error(t, err)
+}
+
+func TestBurnInRepresentation(t *testing.T) {
+	profile := GetTranscodingProfile(DefaultTranscodingProfile)
+	video := Stream{StreamKey: StreamKey{StreamId: 0}, StreamType: "video", Codecs: "avc1.640028",
+		Width: 1920, Height: 1080, BitRate: 8000000, FrameRate: big.NewRat(24, 1)}
+	pgs := Stream{StreamKey: StreamKey{StreamId: 3}, StreamType: "subtitle", CodecName: "hdmv_pgs_subtitle"}
+
+	// Transmuxed video has to be transcoded to burn in subtitles
+	r := GetBurnInRepresentation(GetTransmuxedRepresentation(video), pgs, profile, VideoCodecH264)
+	assert.True(t, r.Representation.Transcoded)
+	assert.Regexp(t, "^burnin:3:transcode:default:", r.Representation.RepresentationId)
+	assert.Equal(t, "[0:0][0:3]overlay=eof_action=pass,scale=-2:1080[v]", r.Representation.encoderParams.burnInFilterComplex(video))
+
+	r, err := StreamRepresentationFromRepresentationId(video, "burnin:3:preset:default:720-5000k-video")
+	require.NoError(t, err)
+	assert.Equal(t, 720, r.Representation.Height)
+	assert.Equal(t, "[0:0][0:3]overlay=eof_action=pass,scale=-2:720[v]",
+		r.Representation.encoderParams.burnInFilterComplex(video))
+
+	// HDR is tone mapped before the subtitles are overlaid
+	video.ColorTransfer = "smpte2084"
+	assert.Equal(t, "[0:0]"+toneMappingFilter("hable")+"[base];[base][0:3]overlay=eof_action=pass,scale=-2:720[v]",
+		r.Representation.encoderParams.burnInFilterComplex(video))
+
+	_, err = StreamRepresentationFromRepresentationId(video, "burnin:3:direct")
+	assert.Error(t, err)
+}
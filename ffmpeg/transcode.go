@@ -97,3 +97,90 @@ var avc1Levels = []avc1Level{
 	{61, 8355840, 139264, 480000000},
 	{62, 16711680, 139264, 800000000},
 }
+
+// GetHVC1Tag returns the codecs string of HEVC Main profile, Main tier video with the given properties.
+func GetHVC1Tag(width int, height int, bitRate int64, frameRate *big.Rat) string {
+	// Profile space 0, Main profile (1) with compatibility flags for Main and Main 10,
+	// Main tier ("L"), level_idc and only the progressive source flag set.
+	return fmt.Sprintf("hvc1.1.6.L%d.B0", findVideoLevel(hvc1Levels, width, height, bitRate, frameRate))
+}
+
+// GetHVC1Main10Tag returns the codecs string of HEVC Main 10 profile video with the given
+// properties, as used for HDR.
+func GetHVC1Main10Tag(width int, height int, bitRate int64, frameRate *big.Rat) string {
+	// Main 10 profile (2) with the compatibility flag for Main 10 only.
+	return fmt.Sprintf("hvc1.2.4.L%d.B0", findVideoLevel(hvc1Levels, width, height, bitRate, frameRate))
+}
+
+// GetAV01Tag returns the codecs string of AV1 Main profile, Main tier 8-bit video with the given
+// properties, see https://aomediacodec.github.io/av1-isobmff/#codecsparam
+func GetAV01Tag(width int, height int, bitRate int64, frameRate *big.Rat) string {
+	return fmt.Sprintf("av01.0.%02dM.08", findVideoLevel(av01Levels, width, height, bitRate, frameRate))
+}
+
+// GetAV0110BitTag returns the codecs string of AV1 Main profile, Main tier 10-bit video with the
+// given properties, as used for HDR.
+func GetAV0110BitTag(width int, height int, bitRate int64, frameRate *big.Rat) string {
+	return fmt.Sprintf("av01.0.%02dM.10", findVideoLevel(av01Levels, width, height, bitRate, frameRate))
+}
+
+// findVideoLevel returns the lowest of the levels that allows video with the given properties.
+func findVideoLevel(levels []videoLevel, width int, height int, bitRate int64, frameRate *big.Rat) uint {
+	pictureSize := int64(width) * int64(height)
+	frameRateFloat, _ := frameRate.Float64()
+	sampleRate := float64(pictureSize) * frameRateFloat
+
+	for _, l := range levels {
+		if bitRate < l.MaxBitrate &&
+			pictureSize <= l.MaxPictureSize &&
+			sampleRate <= float64(l.MaxSampleRate) {
+			return l.Level
+		}
+	}
+	return levels[len(levels)-1].Level
+}
+
+// videoLevel describes the limits of a HEVC or AV1 level.
+type videoLevel struct {
+	// level_idc for HEVC (30 times the level number), seq_level_idx for AV1
+	Level uint
+	// max luma picture size (samples)
+	MaxPictureSize int64
+	// max luma sample rate (samples/sec)
+	MaxSampleRate int64
+	// max bitrate of the Main tier (bits/sec)
+	MaxBitrate int64
+}
+
+// From Table A.8 and A.9 of ITU-T H.265
+var hvc1Levels = []videoLevel{
+	{30, 36864, 552960, 128000},
+	{60, 122880, 3686400, 1500000},
+	{63, 245760, 7372800, 3000000},
+	{90, 552960, 16588800, 6000000},
+	{93, 983040, 33177600, 10000000},
+	{120, 2228224, 66846720, 12000000},
+	{123, 2228224, 133693440, 20000000},
+	{150, 8912896, 267386880, 25000000},
+	{153, 8912896, 534773760, 40000000},
+	{156, 8912896, 1069547520, 60000000},
+	{180, 35651584, 1069547520, 60000000},
+	{183, 35651584, 2139095040, 120000000},
+	{186, 35651584, 4278190080, 240000000},
+}
+
+// From Annex A.3 of the AV1 specification, using the max display rate as the sample rate
+var av01Levels = []videoLevel{
+	{0, 147456, 4423680, 1500000},
+	{1, 278784, 8363520, 3000000},
+	{4, 665856, 19975680, 6000000},
+	{5, 1065024, 31950720, 10000000},
+	{8, 2359296, 70778880, 12000000},
+	{9, 2359296, 141557760, 20000000},
+	{12, 8912896, 267386880, 30000000},
+	{13, 8912896, 534773760, 40000000},
+	{14, 8912896, 1069547520, 60000000},
+	{16, 35651584, 1069547520, 60000000},
+	{17, 35651584, 2139095040, 100000000},
+	{18, 35651584, 4278190080, 160000000},
+}
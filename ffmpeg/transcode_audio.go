@@ -8,12 +8,180 @@ import (
 	"os/exec"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 )
 
-var AudioEncoderPresets = map[string]EncoderParams{
-	"64k-audio":  {audioBitrate: 64000, Codecs: "mp4a.40.2"},
-	"128k-audio": {audioBitrate: 128000, Codecs: "mp4a.40.2"},
+const (
+	// AudioCodecAAC is offered to all clients, in stereo.
+	AudioCodecAAC = "aac"
+	// AudioCodecAC3 and AudioCodecEAC3 keep surround sound for clients that can play them.
+	AudioCodecAC3  = "ac3"
+	AudioCodecEAC3 = "eac3"
+)
+
+// audioCodecStrings are the codecs strings (https://tools.ietf.org/html/rfc6381#section-3.3)
+// of the audio codecs we encode to.
+var audioCodecStrings = map[string]string{
+	AudioCodecAAC:  "mp4a.40.2",
+	AudioCodecAC3:  "ac-3",
+	AudioCodecEAC3: "ec-3",
+}
+
+// surroundAudioCodecs are used for audio with more than two channels if the client can play
+// them, most preferred first.
+var surroundAudioCodecs = []string{AudioCodecEAC3, AudioCodecAC3}
+
+// maxAudioChannels is the number of channels we encode in each codec at most. The AC-3 and
+// E-AC-3 encoders of ffmpeg support up to 5.1, so 7.1 is downmixed to 5.1.
+var maxAudioChannels = map[string]int{
+	AudioCodecAAC:  2,
+	AudioCodecAC3:  6,
+	AudioCodecEAC3: 6,
+}
+
+// maxAudioBitrates caps the bitrate of audio transcoded with the bitrate of the original stream,
+// which may be lossless.
+var maxAudioBitrates = map[string]int{
+	AudioCodecAAC:  256000,
+	AudioCodecAC3:  640000,
+	AudioCodecEAC3: 768000,
+}
+
+// downmixLayout describes which channels of a surround layout are mixed into stereo.
+type downmixLayout struct {
+	center         bool
+	surroundsLeft  []string
+	surroundsRight []string
+}
+
+// downmixLayouts are the layouts, as named by ffprobe, we know how to downmix to stereo.
+// The LFE channel is left out, as recommended by ITU-R BS.775.
+var downmixLayouts = map[string]downmixLayout{
+	"quad":       {false, []string{"BL"}, []string{"BR"}},
+	"quad(side)": {false, []string{"SL"}, []string{"SR"}},
+	"5.0":        {true, []string{"BL"}, []string{"BR"}},
+	"5.0(side)":  {true, []string{"SL"}, []string{"SR"}},
+	"5.1":        {true, []string{"BL"}, []string{"BR"}},
+	"5.1(side)":  {true, []string{"SL"}, []string{"SR"}},
+	"6.1":        {true, []string{"SL", "BC"}, []string{"SR", "BC"}},
+	"7.1":        {true, []string{"SL", "BL"}, []string{"SR", "BR"}},
+}
+
+// audioCodecOf returns the audio codec of a codecs string.
+func audioCodecOf(codecs string) string {
+	for codec, s := range audioCodecStrings {
+		if s == codecs {
+			return codec
+		}
+	}
+	return AudioCodecAAC
+}
+
+// channelCount returns the number of channels of the audio stream, assuming stereo if unknown.
+func channelCount(stream Stream) int {
+	if stream.Channels > 0 {
+		return stream.Channels
+	}
+	return 2
+}
+
+// outputChannels returns the number of channels of the audio stream after transcoding to the codec.
+func outputChannels(stream Stream, codec string) int {
+	channels := channelCount(stream)
+	if max := maxAudioChannels[codec]; channels > max {
+		return max
+	}
+	return channels
+}
+
+// AudioCodecFor returns the codec audio is transcoded to for a client. Surround audio keeps its
+// channels if the client can play AC-3 or E-AC-3, everything else is transcoded to stereo AAC.
+func AudioCodecFor(stream Stream, capabilities ClientCodecCapabilities) string {
+	if channelCount(stream) > 2 {
+		for _, codec := range surroundAudioCodecs {
+			if capabilities.canPlayCodecs(audioCodecStrings[codec]) {
+				return codec
+			}
+		}
+	}
+	return AudioCodecAAC
+}
+
+// OfferedAudioCodecs returns all codecs the audio stream may be transcoded to.
+func OfferedAudioCodecs(stream Stream) []string {
+	if channelCount(stream) > 2 {
+		return append(append([]string{}, surroundAudioCodecs...), AudioCodecAAC)
+	}
+	return []string{AudioCodecAAC}
+}
+
+// GetAudioEncoderPreset returns the params for the audio rung with the given name of the profile.
+// Presets are always stereo AAC.
+func GetAudioEncoderPreset(profile TranscodingProfile, name string) (EncoderParams, error) {
+	for _, rung := range profile.Audio {
+		if rung.Name() == name {
+			encoderParams := EncoderParams{audioBitrate: rung.BitRate, Codecs: audioCodecStrings[AudioCodecAAC]}
+			profile.applyAudioTo(&encoderParams)
+			return encoderParams, nil
+		}
+	}
+	return EncoderParams{}, fmt.Errorf("no preset \"%s\" in profile \"%s\"", name, profile.Name)
+}
+
+// stereoDownmixFilter returns a pan filter that downmixes the layout to stereo, or an empty
+// string if the layout is unknown.
+func stereoDownmixFilter(layout string, dialogueBoost bool) string {
+	l, ok := downmixLayouts[layout]
+	if !ok {
+		return ""
+	}
+
+	front, center, surround := "1", "0.707", "0.707"
+	if dialogueBoost {
+		front, center, surround = "0.707", "1", "0.5"
+	}
+
+	channel := func(side string, surrounds []string) string {
+		mix := fmt.Sprintf("F%s<%s*F%s", side, front, side)
+		if l.center {
+			mix += fmt.Sprintf("+%s*FC", center)
+		}
+		for _, s := range surrounds {
+			mix += fmt.Sprintf("+%s*%s", surround, s)
+		}
+		return mix
+	}
+	return fmt.Sprintf("pan=stereo|%s|%s", channel("L", l.surroundsLeft), channel("R", l.surroundsRight))
+}
+
+// audioEncoderArgs returns the ffmpeg arguments to encode the first output stream with the params.
+// Audio is only downmixed if the codec can't hold all channels of the stream.
+func (p EncoderParams) audioEncoderArgs(stream Stream) []string {
+	codec := audioCodecOf(p.Codecs)
+	args := []string{"-c:0", codec}
+	if p.audioBitrate > 0 {
+		args = append(args, "-b:a", strconv.Itoa(p.audioBitrate))
+	}
+
+	filters := []string{}
+	channels := outputChannels(stream, codec)
+	if stream.Channels == 0 || channels < stream.Channels {
+		if channels == 2 {
+			if downmix := stereoDownmixFilter(stream.ChannelLayout, p.dialogueBoost); downmix != "" {
+				filters = append(filters, downmix)
+			}
+		}
+		args = append(args, "-ac", strconv.Itoa(channels))
+	}
+	if p.normalizeLoudness {
+		// loudnorm upsamples to 192 kHz, so resample back afterwards
+		filters = append(filters, "loudnorm=I=-16:TP=-1.5:LRA=11", "aresample=48000")
+	}
+	if len(filters) > 0 {
+		args = append(args, "-filter:0", strings.Join(filters, ","))
+	}
+	return args
 }
 
 func NewAudioTranscodingSession(
@@ -42,7 +210,9 @@ func NewAudioTranscodingSession(
 		"-copyts",
 		"-start_at_zero",
 		"-map", fmt.Sprintf("0:%d", stream.Stream.StreamId),
-		"-c:0", "aac", "-ac", "2", "-ab", strconv.Itoa(encoderParams.audioBitrate),
+	}...)
+	args = append(args, encoderParams.audioEncoderArgs(stream.Stream)...)
+	args = append(args, []string{
 		"-f", "hls",
 		"-start_number", fmt.Sprintf("%d", segmentStartIndex),
 		"-hls_time", fmt.Sprintf("%.3f", SegmentDuration.Seconds()),
@@ -90,7 +260,9 @@ func GetTranscodedAudioRepresentation(stream Stream, representationId string, en
 			BitRate:          encoderParams.audioBitrate,
 			Container:        "audio/mp4",
 			Codecs:           encoderParams.Codecs,
+			Channels:         outputChannels(stream, audioCodecOf(encoderParams.Codecs)),
 			Transcoded:       true,
+			encoderParams:    encoderParams,
 		},
 	}
 }
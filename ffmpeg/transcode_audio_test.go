@@ -0,0 +1,66 @@
+package ffmpeg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAudioCodecFor(t *testing.T) {
+	stereo := Stream{StreamType: "audio", Channels: 2, ChannelLayout: "stereo"}
+	surround := Stream{StreamType: "audio", Channels: 6, ChannelLayout: "5.1(side)"}
+
+	capabilities := ClientCodecCapabilities{PlayableCodecs: []string{"mp4a.40.2", "ac-3"}}
+	assert.Equal(t, AudioCodecAAC, AudioCodecFor(stereo, capabilities))
+	assert.Equal(t, AudioCodecAC3, AudioCodecFor(surround, capabilities))
+	assert.Equal(t, AudioCodecAAC, AudioCodecFor(surround, ClientCodecCapabilities{}))
+
+	capabilities.PlayableCodecs = append(capabilities.PlayableCodecs, "ec-3")
+	assert.Equal(t, AudioCodecEAC3, AudioCodecFor(surround, capabilities))
+
+	assert.Equal(t, []string{AudioCodecAAC}, OfferedAudioCodecs(stereo))
+	assert.Equal(t, []string{AudioCodecEAC3, AudioCodecAC3, AudioCodecAAC}, OfferedAudioCodecs(surround))
+}
+
+func TestAudioEncoderArgs(t *testing.T) {
+	profile := GetTranscodingProfile(DefaultTranscodingProfile)
+	mono := Stream{StreamType: "audio", Channels: 1, ChannelLayout: "mono", BitRate: 96000}
+	surround := Stream{StreamType: "audio", Channels: 8, ChannelLayout: "7.1", BitRate: 3000000}
+
+	// Audio that fits into the codec is not remixed
+	r := GetSimilarTranscodedAudioRepresentation(mono, profile, AudioCodecAAC)
+	assert.Equal(t, 1, r.Representation.Channels)
+	assert.Equal(t, []string{"-c:0", "aac", "-b:a", "96000"},
+		r.Representation.encoderParams.audioEncoderArgs(mono))
+
+	r = GetSimilarTranscodedAudioRepresentation(surround, profile, AudioCodecEAC3)
+	assert.Equal(t, "ec-3", r.Representation.Codecs)
+	assert.Equal(t, 6, r.Representation.Channels)
+	assert.Equal(t, []string{"-c:0", "eac3", "-b:a", "768000", "-ac", "6"},
+		r.Representation.encoderParams.audioEncoderArgs(surround))
+
+	r = GetSimilarTranscodedAudioRepresentation(surround, profile, AudioCodecAAC)
+	assert.Equal(t, 2, r.Representation.Channels)
+	assert.Equal(t, []string{"-c:0", "aac", "-b:a", "256000", "-ac", "2",
+		"-filter:0", "pan=stereo|FL<1*FL+0.707*FC+0.707*SL+0.707*BL|FR<1*FR+0.707*FC+0.707*SR+0.707*BR"},
+		r.Representation.encoderParams.audioEncoderArgs(surround))
+
+	// Unknown layouts are left to ffmpeg, loudness normalization is applied after the downmix
+	profile.DialogueBoost = true
+	profile.NormalizeLoudness = true
+	unknown := Stream{StreamType: "audio", Channels: 6, ChannelLayout: "hexagonal"}
+	r = GetSimilarTranscodedAudioRepresentation(unknown, profile, AudioCodecAAC)
+	assert.Equal(t, []string{"-c:0", "aac", "-b:a", "256000", "-ac", "2",
+		"-filter:0", "loudnorm=I=-16:TP=-1.5:LRA=11,aresample=48000"},
+		r.Representation.encoderParams.audioEncoderArgs(unknown))
+}
+
+func TestStereoDownmixFilter(t *testing.T) {
+	assert.Equal(t, "pan=stereo|FL<1*FL+0.707*FC+0.707*BL|FR<1*FR+0.707*FC+0.707*BR",
+		stereoDownmixFilter("5.1", false))
+	assert.Equal(t, "pan=stereo|FL<0.707*FL+1*FC+0.5*BL|FR<0.707*FR+1*FC+0.5*BR",
+		stereoDownmixFilter("5.1", true))
+	assert.Equal(t, "pan=stereo|FL<1*FL+0.707*SL|FR<1*FR+0.707*SR",
+		stereoDownmixFilter("quad(side)", false))
+	assert.Equal(t, "", stereoDownmixFilter("22.2", false))
+}
@@ -4,53 +4,148 @@ import (
 	"fmt"
 	log "github.com/sirupsen/logrus"
 	"io/ioutil"
+	"math"
+	"math/big"
 	"os"
 	"os/exec"
 	"path"
 	"strconv"
+	"strings"
 	"time"
 )
 
-func GetVideoEncoderPreset(stream Stream, name string) (EncoderParams, error) {
-	encoderParams, exists := map[string]EncoderParams{
-		"480-1000k-video": {
-			height: 480, width: -2,
-			videoBitrate: 1000000},
-		"720-5000k-video": {
-			height: 720, width: -2,
-			videoBitrate: 5000000},
-		"1080-10000k-video": {
-			height: 1080, width: -2,
-			videoBitrate: 10000000},
-	}[name]
-
-	if !exists {
-		return EncoderParams{}, fmt.Errorf("no preset \"%s\"", name)
-	}
-	scaledWidth, scaledHeight := scalePreserveAspectRatio(
-		stream.Width, stream.Height,
-		-2, encoderParams.height)
-	encoderParams.Codecs = GetAVC1Tag(
-		scaledWidth, scaledHeight,
-		int64(encoderParams.videoBitrate),
-		stream.FrameRate)
-
-	return encoderParams, nil
+// GetVideoEncoderPreset returns the params for the rung with the given name of the profile in the format.
+func GetVideoEncoderPreset(stream Stream, profile TranscodingProfile, name string, format string) (EncoderParams, error) {
+	for _, rung := range profile.Video {
+		if rung.Name() != name {
+			continue
+		}
+
+		encoderParams := EncoderParams{
+			height: rung.Height, width: -2,
+			videoBitrate: rung.BitRate}
+		profile.applyTo(&encoderParams, format)
+
+		scaledWidth, scaledHeight := scalePreserveAspectRatio(
+			stream.Width, stream.Height,
+			-2, encoderParams.height)
+		encoderParams.Codecs = videoCodecTag(
+			format,
+			scaledWidth, scaledHeight,
+			int64(encoderParams.videoBitrate),
+			encoderParams.outputFrameRate(stream.FrameRate))
+
+		return encoderParams, nil
+	}
+
+	return EncoderParams{}, fmt.Errorf("no preset \"%s\" in profile \"%s\"", name, profile.Name)
+}
+
+// outputFrameRate returns the frame rate of the transcoded video for the given input frame rate.
+func (p EncoderParams) outputFrameRate(frameRate *big.Rat) *big.Rat {
+	if frameRate == nil || p.maxFrameRate <= 0 {
+		return frameRate
+	}
+	maxFrameRate := new(big.Rat).SetFloat64(p.maxFrameRate)
+	if frameRate.Cmp(maxFrameRate) > 0 {
+		return maxFrameRate
+	}
+	return frameRate
 }
 
-// List of standard presets that are offered by default
-var standardPresets = []string{
-	"preset:480-1000k-video",
-	"preset:720-5000k-video",
-	"preset:1080-10000k-video"}
+// svtAV1Presets maps x264 preset names to roughly equally fast SVT-AV1 presets.
+var svtAV1Presets = map[string]string{
+	"ultrafast": "12", "superfast": "12", "veryfast": "11", "faster": "10", "fast": "9",
+	"medium": "8", "slow": "6", "slower": "4", "veryslow": "2",
+}
+
+// videoEncoderArgs returns the ffmpeg arguments to encode the stream as the first output stream
+// with the params.
+func (p EncoderParams) videoEncoderArgs(stream Stream) []string {
+	encoder := p.encoder
+	if encoder == "" {
+		encoder = builtinTranscodingProfile.VideoEncoder
+	}
+	args := []string{"-c:0", encoder}
+
+	if p.crf != 0 {
+		crf := p.crf
+		if p.videoCodec == VideoCodecAV1 {
+			// AV1 encoders use a scale of 0-63 instead of 0-51
+			crf = int(math.Round(float64(crf) * 63 / 51))
+		}
+		args = append(args, "-crf:0", strconv.Itoa(crf))
+		if p.videoBitrate > 0 {
+			args = append(args,
+				"-maxrate:0", strconv.Itoa(p.videoBitrate),
+				"-bufsize:0", strconv.Itoa(2*p.videoBitrate))
+		}
+	} else if p.videoBitrate > 0 {
+		args = append(args, "-b:v", strconv.Itoa(p.videoBitrate))
+	}
 
-func GetStandardPresetVideoRepresentations(stream Stream) []StreamRepresentation {
-	representations := []StreamRepresentation{}
-	for _, preset := range standardPresets {
-		r, _ := StreamRepresentationFromRepresentationId(stream, preset)
-		representations = append(representations, r)
+	switch encoder {
+	case "libsvtav1":
+		// SVT-AV1 presets are numbers, the x264 names of the profile are translated
+		if preset, ok := svtAV1Presets[p.preset]; ok {
+			args = append(args, "-preset:0", preset)
+		}
+	case "libaom-av1":
+		args = append(args, "-usage:0", "realtime", "-cpu-used:0", "8", "-row-mt:0", "1")
+	case "libx265":
+		args = append(args, "-x265-params:0", "log-level=error")
+		if p.preset != "" {
+			args = append(args, "-preset:0", p.preset)
+		}
+	default:
+		if p.preset != "" {
+			args = append(args, "-preset:0", p.preset)
+		}
+		// Tunes are specific to x264
+		if p.tune != "" && encoder == "libx264" {
+			args = append(args, "-tune:0", p.tune)
+		}
 	}
-	return representations
+
+	if p.keepsHDR(stream) {
+		// The codecs strings we advertise for HDR are for 10-bit video
+		args = append(args, hdrColorArgs(stream)...)
+	} else if p.videoCodec == VideoCodecHEVC || p.videoCodec == VideoCodecAV1 {
+		// The codecs strings we advertise are for 8-bit video
+		args = append(args, "-pix_fmt:0", "yuv420p")
+	}
+	if p.videoCodec == VideoCodecHEVC {
+		// Apple devices only play HEVC in fMP4 with the hvc1 tag
+		args = append(args, "-tag:0", "hvc1")
+	}
+
+	if outputFrameRate := p.outputFrameRate(stream.FrameRate); outputFrameRate != stream.FrameRate {
+		args = append(args, "-r:0", outputFrameRate.FloatString(3))
+	}
+	return args
+}
+
+// keepsHDR returns whether the HDR of the stream is kept rather than tone mapped to SDR.
+func (p EncoderParams) keepsHDR(stream Stream) bool {
+	return p.hdr && stream.IsHDR()
+}
+
+// videoFilter returns the filter chain that deinterlaces and scales the stream and tone maps HDR
+// to SDR if needed.
+func (p EncoderParams) videoFilter(stream Stream) string {
+	filters := []string{}
+	// Deinterlacing works on fields, so it has to come before scaling
+	if deinterlace := p.deinterlaceFilter(stream); deinterlace != "" {
+		filters = append(filters, deinterlace)
+	}
+	// Scale first, tone mapping works on floats and is expensive for large frames
+	if p.width != 0 || p.height != 0 {
+		filters = append(filters, fmt.Sprintf("scale=%d:%d", p.width, p.height))
+	}
+	if stream.IsHDR() && !p.keepsHDR(stream) {
+		filters = append(filters, toneMappingFilter(p.toneMapping))
+	}
+	return strings.Join(filters, ",")
 }
 
 func NewVideoTranscodingSession(
@@ -78,9 +173,17 @@ func NewVideoTranscodingSession(
 		"-i", buildFfmpegUrlFromFileLocator(stream.Stream.FileLocator),
 		"-copyts",
 		"-start_at_zero",
-		"-map", fmt.Sprintf("0:%d", stream.Stream.StreamId),
-		"-c:0", "libx264", "-b:v", strconv.Itoa(encoderParams.videoBitrate),
-		"-preset:0", "veryfast",
+	}...)
+	if encoderParams.burnIn {
+		args = append(args, []string{
+			"-filter_complex", encoderParams.burnInFilterComplex(stream.Stream),
+			"-map", "[v]",
+		}...)
+	} else {
+		args = append(args, "-map", fmt.Sprintf("0:%d", stream.Stream.StreamId))
+	}
+	args = append(args, encoderParams.videoEncoderArgs(stream.Stream)...)
+	args = append(args, []string{
 		"-force_key_frames", fmt.Sprintf("expr:gte(t,n_forced*%.3f)", SegmentDuration.Seconds()),
 		"-f", "hls",
 		"-start_number", fmt.Sprintf("%d", segmentStartIndex),
@@ -101,10 +204,8 @@ func NewVideoTranscodingSession(
 		}...)
 	}
 
-	if encoderParams.width != 0 || encoderParams.height != 0 {
-		args = append(args, []string{
-			"-filter:0", fmt.Sprintf("scale=%d:%d", encoderParams.width, encoderParams.height),
-		}...)
+	if filter := encoderParams.videoFilter(stream.Stream); filter != "" && !encoderParams.burnIn {
+		args = append(args, "-filter:0", filter)
 	}
 	// We serve our own manifest, so we don't really care about this.
 	args = append(args, path.Join(outputDir, "generated_by_ffmpeg.m3u"))
@@ -136,7 +237,7 @@ func GetTranscodedVideoRepresentation(
 	representationId string,
 	encoderParams EncoderParams) StreamRepresentation {
 
-	return StreamRepresentation{
+	representation := StreamRepresentation{
 		Stream: stream,
 		Representation: Representation{
 			RepresentationId: representationId,
@@ -145,8 +246,13 @@ func GetTranscodedVideoRepresentation(
 			Width:            encoderParams.width,
 			Container:        "video/mp4",
 			Codecs:           encoderParams.Codecs,
+			VideoRange:       VideoRangeSDR,
 			Transcoded:       true,
 			encoderParams:    encoderParams,
 		},
 	}
+	if encoderParams.keepsHDR(stream) {
+		representation.Representation.VideoRange = stream.VideoRange()
+	}
+	return representation
 }
@@ -94,6 +94,12 @@ func GetTransmuxedRepresentation(stream Stream) StreamRepresentation {
 			Transmuxed:       true,
 		},
 	}
+	if stream.StreamType == "audio" {
+		representation.Representation.Channels = channelCount(stream)
+	}
+	if stream.StreamType == "video" {
+		representation.Representation.VideoRange = stream.VideoRange()
+	}
 
 	return representation
 }
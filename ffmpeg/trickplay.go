@@ -0,0 +1,34 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+)
+
+// ExtractThumbnails writes a JPEG thumbnail of the given width for every interval of the video to
+// dir, named thumb-00001.jpg, thumb-00002.jpg and so on. Only keyframes are decoded, so the
+// thumbnails are close to but not exactly at the interval boundaries.
+func ExtractThumbnails(fileLocator filesystem.FileLocator, interval time.Duration, width int, dir string) error {
+	var stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg", "-hide_banner", "-loglevel", "error",
+		"-skip_frame", "nokey",
+		"-i", buildFfmpegUrlFromFileLocator(fileLocator),
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("fps=1/%g,scale=%d:-2", interval.Seconds(), width),
+		"-vsync", "cfr",
+		"-c:v", "mjpeg", "-q:v", "5",
+		filepath.Join(dir, "thumb-%05d.jpg"))
+	cmd.Stderr = &stderr
+
+	log.Debugf("Starting %s with args %s", cmd.Path, cmd.Args)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg failed: %s: %s", err, stderr.String())
+	}
+	return nil
+}
@@ -15,9 +15,11 @@ import (
 func buildFfmpegUrlFromFileLocator(fileLocator filesystem.FileLocator) string {
 	switch fileLocator.Backend {
 	case filesystem.BackendRclone:
-		jwt, _ := auth.CreateStreamingJWT(0, fileLocator.String())
-		return fmt.Sprintf("http://127.0.0.1:%d/olaris/s/files/jwt/%s",
-			viper.GetInt("server.port"), url.PathEscape(jwt))
+		// ffmpeg gets its own credential that only works from the local host, it must not be
+		// able to leak a streaming ticket.
+		token := auth.CreateInternalFileToken(fileLocator.String())
+		return fmt.Sprintf("http://127.0.0.1:%d/olaris/s/files/internal/%s",
+			viper.GetInt("server.port"), url.PathEscape(token))
 	case filesystem.BackendLocal:
 		return "file://" + fileLocator.Path
 	}
@@ -38,6 +38,7 @@ type Node interface {
 	Name() string
 	Path() string
 	ListDir() ([]string, error)
+	ListFiles() ([]string, error)
 	IsDir() bool
 	Walk(walkFunc WalkFunc, followFileSymlinks bool) error
 	FileLocator() FileLocator
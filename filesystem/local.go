@@ -46,6 +46,23 @@ func (n *LocalNode) ListDir() ([]string, error) {
 	return dirs, nil
 }
 
+// ListFiles lists all the files inside the given node
+func (n *LocalNode) ListFiles() ([]string, error) {
+	names := []string{}
+	if n.IsDir() {
+		files, err := ioutil.ReadDir(n.Path())
+		if err != nil {
+			return names, err
+		}
+		for _, file := range files {
+			if !file.IsDir() {
+				names = append(names, file.Name())
+			}
+		}
+	}
+	return names, nil
+}
+
 func (n *LocalNode) Path() string {
 	return n.path
 }
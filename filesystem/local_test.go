@@ -36,3 +36,16 @@ func TestListDirNodeFromPath(t *testing.T) {
 		t.Errorf("Did not get the correct folders back from ListDir() for second level: %s:%s", dirs, secondLevel)
 	}
 }
+
+func TestListFilesNodeFromPath(t *testing.T) {
+	tmp := t.TempDir()
+	require.NoError(t, os.MkdirAll(path.Join(tmp, "Subs"), 0755))
+	require.NoError(t, ioutil.WriteFile(path.Join(tmp, "movie.mkv"), []byte{}, 0644))
+	require.NoError(t, ioutil.WriteFile(path.Join(tmp, "movie.en.srt"), []byte{}, 0644))
+
+	node, err := LocalNodeFromPath(tmp)
+	require.NoError(t, err)
+	files, err := node.ListFiles()
+	require.NoError(t, err)
+	require.Equal(t, []string{"movie.en.srt", "movie.mkv"}, files)
+}
@@ -115,6 +115,23 @@ func (n *RcloneNode) ListDir() ([]string, error) {
 	return []string{}, nil
 }
 
+func (n *RcloneNode) ListFiles() ([]string, error) {
+	names := []string{}
+	if n.IsDir() {
+		i := n.Node.(*vfs.Dir)
+		nodes, err := i.ReadDirAll()
+		if err != nil {
+			return names, err
+		}
+		for _, file := range nodes {
+			if !file.IsDir() {
+				names = append(names, file.Name())
+			}
+		}
+	}
+	return names, nil
+}
+
 func (n *RcloneNode) BackendType() BackendType {
 	return BackendRclone
 }
@@ -1,6 +1,8 @@
 package helpers
 
 import (
+	crand "crypto/rand"
+	"math/big"
 	"math/rand"
 	"time"
 )
@@ -23,3 +25,18 @@ func RandAlphaString(n int) string {
 	}
 	return string(b)
 }
+
+// SecureRandAlphaString returns a random alphabetic string of length n using a
+// cryptographically secure source. Use this for anything that acts as a credential.
+func SecureRandAlphaString(n int) (string, error) {
+	b := make([]byte, n)
+	max := big.NewInt(int64(len(letterBytes)))
+	for i := range b {
+		idx, err := crand.Int(crand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = letterBytes[idx.Int64()]
+	}
+	return string(b), nil
+}
@@ -3,6 +3,7 @@ package hls
 import (
 	"bytes"
 	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"math"
 	"text/template"
 )
 
@@ -18,13 +19,41 @@ type SubtitlePlaylistItem struct {
 	URI string
 }
 
+// ImagePlaylistItem references an image playlist of tiled thumbnails for scrub previews.
+type ImagePlaylistItem struct {
+	Bandwidth int
+	// Width and Height are the size of a single tile.
+	Width  int
+	Height int
+	URI    string
+}
+
+// ImagePlaylist describes the sprite sheets of an image media playlist.
+type ImagePlaylist struct {
+	// Width and Height are the size of a single tile.
+	Width   int
+	Height  int
+	Columns int
+	Rows    int
+	// TileDuration is the time each tile covers, in seconds.
+	TileDuration float64
+	Segments     []ImageSegment
+}
+
+// ImageSegment is a single sprite sheet.
+type ImageSegment struct {
+	// Duration is the time all tiles in the sheet cover, in seconds.
+	Duration float64
+	URI      string
+}
+
 const transcodingMasterPlaylistTemplate = `#EXTM3U
 #EXT-X-VERSION:7
 #EXT-X-INDEPENDENT-SEGMENTS
 
 {{ range $ci, $c := .representationCombinations -}}
 {{ range $si, $s := $c.AudioStreams -}}
-#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="{{$c.AudioGroupName}}",NAME="{{$s.Stream.Title}}",CHANNELS="2",URI="{{$s.Stream.StreamId}}/{{$s.Representation.RepresentationId}}/media.m3u8",AUTOSELECT=YES
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="{{$c.AudioGroupName}}",NAME="{{$s.Stream.Title}}",CHANNELS="{{$s.Representation.Channels}}",URI="{{$s.Stream.StreamId}}/{{$s.Representation.RepresentationId}}/media.m3u8",AUTOSELECT=YES
 {{- if $s.Stream.EnabledByDefault -}}
 ,DEFAULT=YES
 {{ else -}}
@@ -44,11 +73,17 @@ const transcodingMasterPlaylistTemplate = `#EXTM3U
 
 {{ range $ci, $c := .representationCombinations -}}
 #EXT-X-STREAM-INF:BANDWIDTH={{$c.VideoStream.Representation.BitRate}},CODECS="{{$c.VideoStream.Representation.Codecs}},{{$c.AudioCodecs}}",AUDIO="{{$c.AudioGroupName}}"
+{{- if $c.VideoStream.Representation.VideoRange -}}
+,VIDEO-RANGE={{$c.VideoStream.Representation.VideoRange}}
+{{- end -}}
 {{- if $.subtitlePlaylistItems -}}
 ,SUBTITLES="webvtt"
 {{- end }}
 {{$c.VideoStream.Stream.StreamId}}/{{$c.VideoStream.Representation.RepresentationId}}/media.m3u8
 {{ end }}
+{{- range $i, $s := .imagePlaylistItems }}
+#EXT-X-IMAGE-STREAM-INF:BANDWIDTH={{$s.Bandwidth}},RESOLUTION={{$s.Width}}x{{$s.Height}},CODECS="jpeg",URI="{{$s.URI}}"
+{{ end -}}
 `
 
 /*
@@ -79,9 +114,25 @@ const subtitleMediaPlaylistTemplate = `#EXTM3U
 #EXT-X-ENDLIST
 `
 
+// See https://developer.roku.com/docs/developer-program/media-playback/trick-mode/hls-and-dash.md
+const imageMediaPlaylistTemplate = `#EXTM3U
+#EXT-X-VERSION:7
+#EXT-X-TARGETDURATION:{{.targetDuration}}
+#EXT-X-MEDIA-SEQUENCE:1
+#EXT-X-PLAYLIST-TYPE:VOD
+#EXT-X-IMAGES-ONLY
+{{ range $i, $s := .p.Segments -}}
+#EXTINF:{{ printf "%.3f" $s.Duration }},
+#EXT-X-TILES:RESOLUTION={{$.p.Width}}x{{$.p.Height}},LAYOUT={{$.p.Columns}}x{{$.p.Rows}},DURATION={{ printf "%.3f" $.p.TileDuration }}
+{{$s.URI}}
+{{ end -}}
+#EXT-X-ENDLIST
+`
+
 func BuildMasterPlaylistFromFile(
 	representationCombinations []RepresentationCombination,
-	subtitlePlaylistItems []SubtitlePlaylistItem) string {
+	subtitlePlaylistItems []SubtitlePlaylistItem,
+	imagePlaylistItems []ImagePlaylistItem) string {
 
 	buf := bytes.Buffer{}
 	t := template.Must(template.New("manifest").Parse(transcodingMasterPlaylistTemplate))
@@ -89,6 +140,26 @@ func BuildMasterPlaylistFromFile(
 	t.Execute(&buf, map[string]interface{}{
 		"subtitlePlaylistItems":      subtitlePlaylistItems,
 		"representationCombinations": representationCombinations,
+		"imagePlaylistItems":         imagePlaylistItems,
+	})
+	return buf.String()
+}
+
+// BuildImageMediaPlaylist builds the playlist for the sprite sheets referenced by an
+// ImagePlaylistItem.
+func BuildImageMediaPlaylist(p ImagePlaylist) string {
+	targetDuration := 0
+	for _, s := range p.Segments {
+		if d := int(math.Ceil(s.Duration)); d > targetDuration {
+			targetDuration = d
+		}
+	}
+
+	buf := bytes.Buffer{}
+	t := template.Must(template.New("manifest").Parse(imageMediaPlaylistTemplate))
+	t.Execute(&buf, map[string]interface{}{
+		"p":              p,
+		"targetDuration": targetDuration,
 	})
 	return buf.String()
 }
@@ -0,0 +1,65 @@
+package markers
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+)
+
+// Result holds the markers found for an episode, nil if none were found.
+type Result struct {
+	Intro   *ffmpeg.TimeRange
+	Credits *ffmpeg.TimeRange
+	// Err is set if the file could not be analysed.
+	Err error
+}
+
+// AnalyzeSeason detects the intros and credits of all episodes of a season, given in episode
+// order. Intros can only be found if there are at least two episodes.
+func AnalyzeSeason(fileLocators []filesystem.FileLocator) []Result {
+	results := make([]Result, len(fileLocators))
+	fingerprints := make([][]uint32, len(fileLocators))
+
+	for i, fileLocator := range fileLocators {
+		st := time.Now()
+		probe, err := ffmpeg.Probe(fileLocator)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		duration := time.Duration(probe.Format.DurationSeconds * float64(time.Second))
+
+		searchLength := IntroSearchLength
+		if searchLength > duration/2 {
+			searchLength = duration / 2
+		}
+		samples, err := ffmpeg.ExtractAudioPCM(fileLocator, 0, searchLength, SampleRate)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		fingerprints[i] = Fingerprint(samples)
+
+		searchRange := CreditsSearchRange(duration)
+		black, silence, err := ffmpeg.DetectBlackAndSilence(fileLocator, searchRange.Start, searchRange.End)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		results[i].Credits = FindCredits(black, silence, duration)
+
+		log.WithFields(log.Fields{
+			"fileLocator": fileLocator.String(),
+			"duration":    time.Since(st).Seconds(),
+		}).Debugln("Analysed episode for markers")
+	}
+
+	for i, intro := range FindIntros(fingerprints) {
+		if results[i].Err == nil {
+			results[i].Intro = intro
+		}
+	}
+	return results
+}
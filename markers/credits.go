@@ -0,0 +1,46 @@
+package markers
+
+import (
+	"time"
+
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+)
+
+const (
+	creditsSearchLength = 5 * time.Minute
+	minCreditsLength    = 15 * time.Second
+	// transitionTolerance is how far apart black video and silence may be to count as one
+	// transition.
+	transitionTolerance = time.Second
+)
+
+// CreditsSearchRange returns the part of an episode that is searched for the start of the
+// credits, at most the last quarter.
+func CreditsSearchRange(duration time.Duration) ffmpeg.TimeRange {
+	start := duration - creditsSearchLength
+	if start < duration*3/4 {
+		start = duration * 3 / 4
+	}
+	return ffmpeg.TimeRange{Start: start, End: duration}
+}
+
+// FindCredits returns the credits given the black and silent parts near the end of an episode.
+// The credits are assumed to start at the last transition to black and silence that leaves
+// enough time for credits, nil if there's no such transition.
+func FindCredits(black []ffmpeg.TimeRange, silence []ffmpeg.TimeRange, duration time.Duration) *ffmpeg.TimeRange {
+	var credits *ffmpeg.TimeRange
+	for _, b := range black {
+		for _, s := range silence {
+			if b.Start > s.End+transitionTolerance || s.Start > b.End+transitionTolerance {
+				continue
+			}
+			if duration-b.Start < minCreditsLength {
+				continue
+			}
+			if credits == nil || b.Start > credits.Start {
+				credits = &ffmpeg.TimeRange{Start: b.Start, End: duration}
+			}
+		}
+	}
+	return credits
+}
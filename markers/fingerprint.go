@@ -0,0 +1,106 @@
+// Package markers detects the intros and credits of episodes so clients can offer to skip them.
+//
+// Intros are found by fingerprinting the audio at the start of every episode of a season and
+// looking for a segment that sounds the same across episodes. The fingerprints work like
+// Chromaprint: every frame is described by 32 bits that say whether the energy difference between
+// neighbouring frequency bands grew or shrank since the previous frame. Credits are found by
+// looking for a transition to black and silence near the end of an episode.
+package markers
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"time"
+)
+
+const (
+	// SampleRate is the sample rate audio has to be in for fingerprinting.
+	SampleRate = 11025
+
+	frameSize = 4096
+	frameHop  = frameSize / 3
+
+	minFrequency = 300
+	maxFrequency = 2000
+	bands        = 33
+)
+
+// FrameDuration is the time between two fingerprint frames.
+const FrameDuration = time.Duration(frameHop) * time.Second / SampleRate
+
+var hannWindow = func() []float64 {
+	w := make([]float64, frameSize)
+	for i := range w {
+		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(frameSize-1))
+	}
+	return w
+}()
+
+// bandEdges are the FFT bins the frequency bands start at, spaced logarithmically like pitch.
+var bandEdges = func() []int {
+	edges := make([]int, bands+1)
+	for i := range edges {
+		f := minFrequency * math.Pow(maxFrequency/minFrequency, float64(i)/bands)
+		edges[i] = int(f * frameSize / SampleRate)
+	}
+	return edges
+}()
+
+// Fingerprint computes one 32-bit value per frame of mono audio at SampleRate.
+func Fingerprint(samples []int16) []uint32 {
+	var fingerprint []uint32
+	buf := make([]complex128, frameSize)
+	var previous []float64
+
+	for offset := 0; offset+frameSize <= len(samples); offset += frameHop {
+		for i := range buf {
+			buf[i] = complex(float64(samples[offset+i])*hannWindow[i], 0)
+		}
+		fft(buf)
+
+		energies := make([]float64, bands)
+		for b := 0; b < bands; b++ {
+			for bin := bandEdges[b]; bin < bandEdges[b+1]; bin++ {
+				magnitude := cmplx.Abs(buf[bin])
+				energies[b] += magnitude * magnitude
+			}
+		}
+
+		if previous != nil {
+			var v uint32
+			for b := 0; b < bands-1; b++ {
+				if (energies[b]-energies[b+1])-(previous[b]-previous[b+1]) > 0 {
+					v |= 1 << uint(b)
+				}
+			}
+			fingerprint = append(fingerprint, v)
+		}
+		previous = energies
+	}
+	return fingerprint
+}
+
+// fft transforms buf in place, its length must be a power of two.
+func fft(buf []complex128) {
+	n := len(buf)
+	shift := 64 - uint(bits.Len(uint(n))-1)
+	for i := 0; i < n; i++ {
+		if j := int(bits.Reverse64(uint64(i)) >> shift); j > i {
+			buf[i], buf[j] = buf[j], buf[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := buf[start+k], buf[start+k+size/2]*w
+				buf[start+k] = even + odd
+				buf[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package markers
+
+import (
+	"time"
+
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+)
+
+const (
+	// IntroSearchLength is how much of the start of an episode is searched for the intro.
+	IntroSearchLength = 10 * time.Minute
+	minIntroLength    = 15 * time.Second
+	maxIntroLength    = 2 * time.Minute
+)
+
+// FindIntros returns the intro of every episode, given the fingerprints of their starts in
+// episode order. Every episode is compared with the episodes next to it. Episodes without an
+// intro get nil.
+func FindIntros(fingerprints [][]uint32) []*ffmpeg.TimeRange {
+	minLength := int(minIntroLength / FrameDuration)
+	maxLength := int(maxIntroLength / FrameDuration)
+
+	// matches[i] is the match between episode i and i+1.
+	matches := make([]*Match, len(fingerprints))
+	for i := 0; i+1 < len(fingerprints); i++ {
+		if m, ok := FindSharedSegment(fingerprints[i], fingerprints[i+1], minLength); ok && m.Length <= maxLength {
+			matches[i] = &m
+		}
+	}
+
+	intros := make([]*ffmpeg.TimeRange, len(fingerprints))
+	for i := range fingerprints {
+		var start, length int
+		if i > 0 && matches[i-1] != nil {
+			start, length = matches[i-1].StartB, matches[i-1].Length
+		}
+		if matches[i] != nil && matches[i].Length > length {
+			start, length = matches[i].StartA, matches[i].Length
+		}
+		if length > 0 {
+			intros[i] = &ffmpeg.TimeRange{
+				Start: time.Duration(start) * FrameDuration,
+				End:   time.Duration(start+length) * FrameDuration,
+			}
+		}
+	}
+	return intros
+}
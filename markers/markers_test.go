@@ -0,0 +1,109 @@
+package markers
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+)
+
+// testAudio generates a melody of random notes with a bit of noise.
+func testAudio(r *rand.Rand, length time.Duration) []int16 {
+	samples := make([]int16, int(length.Seconds()*SampleRate))
+	noteLength := SampleRate / 4
+	var frequency float64
+	for i := range samples {
+		if i%noteLength == 0 {
+			frequency = 300 + r.Float64()*1500
+		}
+		v := math.Sin(2*math.Pi*frequency*float64(i)/SampleRate)*8000 + r.NormFloat64()*200
+		samples[i] = int16(v)
+	}
+	return samples
+}
+
+func concat(parts ...[]int16) []int16 {
+	var samples []int16
+	for _, p := range parts {
+		samples = append(samples, p...)
+	}
+	return samples
+}
+
+func TestFFT(t *testing.T) {
+	buf := make([]complex128, 16)
+	for i := range buf {
+		buf[i] = complex(math.Cos(2*math.Pi*3*float64(i)/16), 0)
+	}
+	fft(buf)
+	for i, v := range buf {
+		if i == 3 || i == 13 {
+			assert.InDelta(t, 8, real(v), 1e-9)
+		} else {
+			assert.InDelta(t, 0, math.Abs(real(v))+math.Abs(imag(v)), 1e-9)
+		}
+	}
+}
+
+func TestFindSharedSegment(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	intro := testAudio(r, 30*time.Second)
+	a := Fingerprint(concat(testAudio(r, 20*time.Second), intro, testAudio(r, 40*time.Second)))
+	// Not a multiple of the frame hop, so the frames of the intro don't line up exactly
+	b := Fingerprint(concat(testAudio(r, 5123*time.Millisecond), intro, testAudio(r, 20*time.Second)))
+
+	m, ok := FindSharedSegment(a, b, int(15*time.Second/FrameDuration))
+	require.True(t, ok)
+	assert.InDelta(t, 20*time.Second, time.Duration(m.StartA)*FrameDuration, float64(time.Second))
+	assert.InDelta(t, 5123*time.Millisecond, time.Duration(m.StartB)*FrameDuration, float64(time.Second))
+	assert.InDelta(t, 30*time.Second, time.Duration(m.Length)*FrameDuration, float64(2*time.Second))
+
+	_, ok = FindSharedSegment(a, Fingerprint(testAudio(r, time.Minute)), int(15*time.Second/FrameDuration))
+	assert.False(t, ok)
+}
+
+func TestFindIntros(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	intro := testAudio(r, 20*time.Second)
+	fingerprints := [][]uint32{
+		Fingerprint(concat(testAudio(r, 10*time.Second), intro, testAudio(r, 30*time.Second))),
+		Fingerprint(concat(intro, testAudio(r, 30*time.Second))),
+		Fingerprint(testAudio(r, 40*time.Second)),
+	}
+
+	intros := FindIntros(fingerprints)
+	require.Len(t, intros, 3)
+	require.NotNil(t, intros[0])
+	require.NotNil(t, intros[1])
+	assert.Nil(t, intros[2])
+	assert.InDelta(t, 10*time.Second, intros[0].Start, float64(time.Second))
+	assert.InDelta(t, 0, intros[1].Start, float64(time.Second))
+	assert.InDelta(t, 20*time.Second, intros[1].Duration(), float64(2*time.Second))
+}
+
+func TestFindCredits(t *testing.T) {
+	duration := 40 * time.Minute
+	black := []ffmpeg.TimeRange{
+		{Start: 36 * time.Minute, End: 36*time.Minute + time.Second},
+		{Start: 38 * time.Minute, End: 38*time.Minute + 2*time.Second},
+		// Too close to the end
+		{Start: duration - 5*time.Second, End: duration},
+	}
+	silence := []ffmpeg.TimeRange{
+		{Start: 36 * time.Minute, End: 36*time.Minute + time.Second},
+		{Start: 38*time.Minute + 1500*time.Millisecond, End: 38*time.Minute + 3*time.Second},
+		{Start: duration - 5*time.Second, End: duration},
+	}
+
+	credits := FindCredits(black, silence, duration)
+	require.NotNil(t, credits)
+	assert.Equal(t, ffmpeg.TimeRange{Start: 38 * time.Minute, End: duration}, *credits)
+
+	assert.Nil(t, FindCredits(black, nil, duration))
+	assert.Equal(t, ffmpeg.TimeRange{Start: 35 * time.Minute, End: duration}, CreditsSearchRange(duration))
+	assert.Equal(t, ffmpeg.TimeRange{Start: 9 * time.Minute, End: 12 * time.Minute}, CreditsSearchRange(12*time.Minute))
+}
@@ -0,0 +1,56 @@
+package markers
+
+import (
+	"math/bits"
+)
+
+const (
+	// maxBitErrors is how many of the 32 bits may differ for two frames to sound the same. Random
+	// frames match with a chance of about 2.5%.
+	maxBitErrors = 10
+	// maxGap is how many frames in a row may not match within a shared segment.
+	maxGap = 8
+)
+
+// Match is a segment that sounds the same in two fingerprints, in frames.
+type Match struct {
+	StartA int
+	StartB int
+	Length int
+}
+
+// FindSharedSegment returns the longest segment of at least minLength frames that occurs in both
+// fingerprints, at any offset.
+func FindSharedSegment(a []uint32, b []uint32, minLength int) (Match, bool) {
+	var best Match
+	// Frame i of a is compared with frame i-offset of b.
+	for offset := -len(b) + minLength; offset <= len(a)-minLength; offset++ {
+		start, end := offset, len(b)+offset
+		if start < 0 {
+			start = 0
+		}
+		if end > len(a) {
+			end = len(a)
+		}
+
+		runStart, lastMatch := -1, -1
+		finishRun := func() {
+			if runStart >= 0 && lastMatch-runStart+1 > best.Length {
+				best = Match{StartA: runStart, StartB: runStart - offset, Length: lastMatch - runStart + 1}
+			}
+			runStart = -1
+		}
+		for i := start; i < end; i++ {
+			if bits.OnesCount32(a[i]^b[i-offset]) <= maxBitErrors {
+				if runStart < 0 {
+					runStart = i
+				}
+				lastMatch = i
+			} else if runStart >= 0 && i-lastMatch > maxGap {
+				finishRun()
+			}
+		}
+		finishRun()
+	}
+	return best, best.Length >= minLength
+}
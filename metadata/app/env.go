@@ -90,6 +90,7 @@ func NewMDContext(
 	go func() {
 		for range metadataRefreshTicker.C {
 			env.MetadataManager.RefreshAgentMetadataWithMissingArt()
+			env.MetadataManager.RefreshImagePlaceholders()
 		}
 	}()
 
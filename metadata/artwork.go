@@ -0,0 +1,36 @@
+package metadata
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// localArtwork extracts the artwork for the local image ID, e.g. "<uuid>.jpg", from the media file
+// of the movie or episode it belongs to.
+func localArtwork(id string) ([]byte, error) {
+	uuid := strings.TrimSuffix(id, ".jpg")
+
+	var filePath string
+	if movie, err := db.FindMovieByUUID(uuid); err == nil {
+		if len(movie.MovieFiles) > 0 {
+			filePath = movie.MovieFiles[0].FilePath
+		}
+	} else if episode, err := db.FindEpisodeByUUID(uuid); err == nil {
+		if len(episode.EpisodeFiles) > 0 {
+			filePath = episode.EpisodeFiles[0].FilePath
+		}
+	}
+	if filePath == "" {
+		return nil, fmt.Errorf("no media file found for artwork %s", id)
+	}
+
+	fileLocator, err := filesystem.ParseFileLocator(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return ffmpeg.ExtractArtwork(fileLocator)
+}
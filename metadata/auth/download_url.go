@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+func downloadSignature(downloadID uint, userID uint, expires int64) (string, error) {
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "download/%d/%d/%d", downloadID, userID, expires)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SignDownload returns the expiry and signature query parameters that allow the user to fetch the
+// download without further authentication, e.g. with a download manager, until it expires.
+func SignDownload(downloadID uint, userID uint, expiresAt time.Time) (expires string, signature string, err error) {
+	signature, err = downloadSignature(downloadID, userID, expiresAt.Unix())
+	if err != nil {
+		return "", "", err
+	}
+	return strconv.FormatInt(expiresAt.Unix(), 10), signature, nil
+}
+
+// ValidDownloadSignature checks the signature of a download URL created by SignDownload.
+func ValidDownloadSignature(downloadID uint, userID uint, expires string, signature string) bool {
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return false
+	}
+	expected, err := downloadSignature(downloadID, userID, expiresAt)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignDownload(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	expires, signature, err := SignDownload(1, 2, expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.FormatInt(expiresAt.Unix(), 10), expires)
+
+	assert.True(t, ValidDownloadSignature(1, 2, expires, signature))
+	assert.False(t, ValidDownloadSignature(3, 2, expires, signature))
+	assert.False(t, ValidDownloadSignature(1, 3, expires, signature), "signed for another user")
+	assert.False(t, ValidDownloadSignature(1, 2, expires+"0", signature))
+	assert.False(t, ValidDownloadSignature(1, 2, expires, ""))
+
+	// Expired
+	expires, signature, err = SignDownload(1, 2, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.False(t, ValidDownloadSignature(1, 2, expires, signature))
+}
@@ -3,19 +3,32 @@ package auth
 
 import (
 	"encoding/json"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 )
 
 const DefaultLoginTokenValidity = 24 * time.Hour
 
+// twoFactorTokenValidity is how long a user has to enter their TOTP code after entering the password.
+const twoFactorTokenValidity = 5 * time.Minute
+
 type userRequest struct {
 	Username string `json:"username"`
 	Code     string `json:"code"`
 	Password string `json:"password"`
+	// TOTPCode is either a TOTP code or a recovery code.
+	TOTPCode string `json:"totp_code"`
+	// TOTPToken is returned by the first login step if two-factor authentication is enabled.
+	TOTPToken string `json:"totp_token"`
+	// ResetToken is a one-time password reset token created by an admin.
+	ResetToken string `json:"reset_token"`
 }
 type userRequestRes struct {
 	HasError bool   `json:"has_error"`
@@ -26,6 +39,11 @@ type tokenResponse struct {
 	JWT string `json:"jwt"`
 }
 
+type twoFactorRequiredResponse struct {
+	TOTPRequired bool   `json:"totp_required"`
+	TOTPToken    string `json:"totp_token"`
+}
+
 // ReadyForSetup checks whether the metadata has been through it's initial setup
 // If this returns true a user can be created without an invite code.
 func ReadyForSetup(w http.ResponseWriter, r *http.Request) {
@@ -51,6 +69,33 @@ func UserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := ClientIP(r)
+
+	// Second step of a two-factor login.
+	if ur.TOTPToken != "" {
+		userID, err := validateTwoFactorJWT(ur.TOTPToken)
+		if err != nil {
+			writeError("Two-factor login expired, please log in again", w, http.StatusUnauthorized)
+			return
+		}
+		user, err := db.FindUser(userID)
+		if err != nil {
+			writeError("Invalid username or password", w, http.StatusUnauthorized)
+			return
+		}
+		if throttled(ip, user.Username, w) {
+			return
+		}
+		if !verifySecondFactor(user, ur.TOTPCode) {
+			loginFailed(ip, user.Username, user.ID, "invalid two-factor code")
+			writeError("Invalid two-factor code", w, http.StatusUnauthorized)
+			return
+		}
+		loginSucceeded(ip, user)
+		writeLoginToken(user, w)
+		return
+	}
+
 	if ur.Username == "" {
 		writeError("No username supplied", w, http.StatusBadRequest)
 		return
@@ -61,26 +106,150 @@ func UserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if throttled(ip, ur.Username, w) {
+		return
+	}
+
 	u := db.User{Username: ur.Username}
 
 	if u.ValidPassword(ur.Password) == true {
-		token, err := CreateMetadataJWT(&u, DefaultLoginTokenValidity)
-		if err != nil {
-			writeError(err.Error(), w, http.StatusUnauthorized)
-		} else {
-			tokenRes := tokenResponse{JWT: token}
-			jtoken, err := json.Marshal(tokenRes)
-			if err != nil {
-				log.Warnln("Could not marshall JWT token:", err)
+		if u.TOTPEnabled {
+			// Clients can send the code right away, otherwise we ask for it.
+			if ur.TOTPCode == "" {
+				writeTwoFactorRequired(&u, w)
+				return
+			}
+			if !verifySecondFactor(&u, ur.TOTPCode) {
+				loginFailed(ip, u.Username, u.ID, "invalid two-factor code")
+				writeError("Invalid two-factor code", w, http.StatusUnauthorized)
+				return
 			}
-			w.Header().Add("Content-Type", "application/json")
-			w.Write(jtoken)
 		}
+		loginSucceeded(ip, &u)
+		writeLoginToken(&u, w)
 	} else {
+		loginFailed(ip, ur.Username, u.ID, "invalid username or password")
 		writeError("Invalid username or password", w, http.StatusUnauthorized)
 	}
 }
 
+// throttled writes an error and returns true if the IP or username are currently locked out.
+func throttled(ip string, username string, w http.ResponseWriter) bool {
+	wait := ipThrottle.Check(ip)
+	if userWait := usernameThrottle.Check(username); userWait > wait {
+		wait = userWait
+	}
+	if wait <= 0 {
+		return false
+	}
+
+	db.AddAuditLogEntry(&db.AuditLogEntry{
+		Event:    db.AuditEventLoginThrottled,
+		Username: username,
+		IP:       ip,
+		Details:  fmt.Sprintf("locked out for %s", wait.Round(time.Second)),
+	})
+	writeThrottled(wait, w)
+	return true
+}
+
+func loginFailed(ip string, username string, userID uint, reason string) {
+	ipThrottle.Fail(ip)
+	usernameThrottle.Fail(username)
+
+	log.WithFields(log.Fields{"username": username, "ip": ip}).Warnln("Failed login attempt")
+	db.AddAuditLogEntry(&db.AuditLogEntry{
+		Event:    db.AuditEventLoginFailed,
+		UserID:   userID,
+		Username: username,
+		IP:       ip,
+		Details:  reason,
+	})
+}
+
+func loginSucceeded(ip string, user *db.User) {
+	// The IP is deliberately not reset, otherwise an attacker with a valid account could
+	// keep guessing other users' passwords from the same address.
+	usernameThrottle.Succeed(user.Username)
+
+	db.AddAuditLogEntry(&db.AuditLogEntry{
+		Event:    db.AuditEventLogin,
+		UserID:   user.ID,
+		Username: user.Username,
+		IP:       ip,
+	})
+}
+
+func writeThrottled(wait time.Duration, w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(
+		fmt.Sprintf("Too many failed attempts, try again in %s", wait.Round(time.Second)),
+		w,
+		http.StatusTooManyRequests)
+}
+
+// ClientIP returns the IP address of the client that made the request. Behind a trusted
+// proxy this is the address the proxy received the request from.
+func ClientIP(r *http.Request) string {
+	if forwarded := forwardedFor(r); forwarded != "" {
+		return forwarded
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// verifySecondFactor checks the given TOTP or recovery code for the user.
+func verifySecondFactor(user *db.User, code string) bool {
+	if code == "" {
+		return false
+	}
+
+	if step, ok := ValidateTOTPCode(user.TOTPSecret, code, time.Now(), user.TOTPLastStep); ok {
+		if err := db.UpdateTOTPLastStep(user.ID, step); err != nil {
+			log.WithError(err).Warnln("Could not store last TOTP step")
+		}
+		return true
+	}
+
+	if err := db.UseRecoveryCode(user.ID, code); err == nil {
+		log.WithField("username", user.Username).Infoln("User logged in with a recovery code")
+		return true
+	}
+	return false
+}
+
+func writeLoginToken(user *db.User, w http.ResponseWriter) {
+	token, err := CreateMetadataJWT(user, DefaultLoginTokenValidity)
+	if err != nil {
+		writeError(err.Error(), w, http.StatusUnauthorized)
+		return
+	}
+	tokenRes := tokenResponse{JWT: token}
+	jtoken, err := json.Marshal(tokenRes)
+	if err != nil {
+		log.Warnln("Could not marshall JWT token:", err)
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(jtoken)
+}
+
+func writeTwoFactorRequired(user *db.User, w http.ResponseWriter) {
+	token, err := createTwoFactorJWT(user.ID, twoFactorTokenValidity)
+	if err != nil {
+		writeError(err.Error(), w, http.StatusUnauthorized)
+		return
+	}
+	jres, err := json.Marshal(twoFactorRequiredResponse{TOTPRequired: true, TOTPToken: token})
+	if err != nil {
+		log.Warnln("Could not marshall two-factor response:", err)
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(jres)
+}
+
 // CreateUserHandler handles the creation of users, either via invite code or the first admin user.
 func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 	ur := userRequest{}
@@ -96,12 +265,92 @@ func CreateUserHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := ClientIP(r)
+	inviteKey := "invite:" + ip
+	if wait := ipThrottle.Check(inviteKey); wait > 0 {
+		writeThrottled(wait, w)
+		return
+	}
+
 	user, err := db.CreateUserWithCode(ur.Username, ur.Password, ur.Code)
 	if err != nil {
+		if err == db.ErrInvalidInvite {
+			ipThrottle.Fail(inviteKey)
+			db.AddAuditLogEntry(&db.AuditLogEntry{
+				Event:    db.AuditEventInviteFailed,
+				Username: ur.Username,
+				IP:       ip,
+				Details:  err.Error(),
+			})
+		}
 		writeError(err.Error(), w, http.StatusUnauthorized)
 		return
 	}
 
+	details := "initial admin user"
+	if ur.Code != "" {
+		details = "redeemed invite"
+	}
+	db.AddAuditLogEntry(&db.AuditLogEntry{
+		Event:    db.AuditEventInviteRedeemed,
+		UserID:   user.ID,
+		Username: user.Username,
+		IP:       ip,
+		Details:  details,
+	})
+
+	jre, _ := json.Marshal(user)
+	w.Write(jre)
+}
+
+// ResetPasswordHandler sets a new password for a user that got a password reset token from an admin.
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	ur := userRequest{}
+	b, err := ioutil.ReadAll(r.Body)
+
+	if err != nil {
+		log.Warnln("Could not read incoming request body.")
+		return
+	}
+
+	if err := json.Unmarshal(b, &ur); err != nil {
+		writeError("Could not parse JSON object", w, http.StatusBadRequest)
+		return
+	}
+
+	if ur.ResetToken == "" {
+		writeError("No reset token supplied", w, http.StatusBadRequest)
+		return
+	}
+
+	ip := ClientIP(r)
+	resetKey := "reset:" + ip
+	if wait := ipThrottle.Check(resetKey); wait > 0 {
+		writeThrottled(wait, w)
+		return
+	}
+
+	user, err := db.ResetPasswordWithToken(ur.ResetToken, ur.Password)
+	if err != nil {
+		if err == db.ErrInvalidResetToken {
+			ipThrottle.Fail(resetKey)
+			writeError(err.Error(), w, http.StatusUnauthorized)
+			return
+		}
+		writeError(err.Error(), w, http.StatusBadRequest)
+		return
+	}
+
+	// The user proved they own the account, so lift any lockout from forgotten password attempts.
+	usernameThrottle.Succeed(user.Username)
+	db.AddAuditLogEntry(&db.AuditLogEntry{
+		Event:    db.AuditEventPasswordReset,
+		UserID:   user.ID,
+		Username: user.Username,
+		IP:       ip,
+		Details:  "redeemed password reset token",
+	})
+
 	jre, _ := json.Marshal(user)
 	w.Write(jre)
 }
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func resetPassword(req userRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	rw := httptest.NewRecorder()
+	ResetPasswordHandler(rw, httptest.NewRequest(http.MethodPost, "/v1/user/reset-password", bytes.NewReader(body)))
+	return rw
+}
+
+func TestResetPasswordHandler(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	user, _ := db.CreateUser("forgetful", "testtest", false)
+
+	_, token, err := db.CreatePasswordResetToken(user.ID, 0, time.Hour)
+	require.NoError(t, err)
+
+	rw := resetPassword(userRequest{ResetToken: "invalid", Password: "newpassword"})
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+
+	rw = resetPassword(userRequest{ResetToken: token, Password: "newpassword"})
+	assert.Equal(t, http.StatusOK, rw.Code)
+
+	res := login(t, userRequest{Username: "forgetful", Password: "newpassword"})
+	assert.NotEmpty(t, res["jwt"])
+
+	entries := db.FindAuditLogEntries(db.AuditLogFilter{Event: db.AuditEventPasswordReset}, db.QueryDetails{Limit: 10})
+	assert.Len(t, entries, 1)
+}
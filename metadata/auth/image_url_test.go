@@ -0,0 +1,32 @@
+package auth
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignImage(t *testing.T) {
+	expires, signature, err := SignImage("tmdb", "w342", "poster.jpg", 1)
+	require.NoError(t, err)
+
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	require.NoError(t, err)
+	assert.True(t, time.Unix(expiresAt, 0).After(time.Now().Add(imageURLValidity)))
+
+	assert.True(t, ValidImageSignature("tmdb", "w342", "poster.jpg", 1, expires, signature))
+	assert.False(t, ValidImageSignature("tmdb", "original", "poster.jpg", 1, expires, signature))
+	assert.False(t, ValidImageSignature("tmdb", "w342", "other.jpg", 1, expires, signature))
+	assert.False(t, ValidImageSignature("tmdb", "w342", "poster.jpg", 1, expires+"0", signature))
+	assert.False(t, ValidImageSignature("tmdb", "w342", "poster.jpg", 1, expires, ""))
+	assert.False(t, ValidImageSignature("tmdb", "w342", "poster.jpg", 2, expires, signature), "URLs are signed for a user")
+
+	// Expired
+	past := time.Now().Add(-time.Minute).Unix()
+	pastSignature, err := imageSignature("tmdb", "w342", "poster.jpg", 1, past)
+	require.NoError(t, err)
+	assert.False(t, ValidImageSignature("tmdb", "w342", "poster.jpg", 1, strconv.FormatInt(past, 10), pastSignature))
+}
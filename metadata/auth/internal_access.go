@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// internalSecret signs tokens that give internal consumers like ffmpeg access to files. It only
+// lives in memory, so the tokens can't be used after a restart or by anything outside this process.
+var internalSecret = func() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("could not generate internal secret: %s", err))
+	}
+	return secret
+}()
+
+func internalSignature(fileLocator string) []byte {
+	mac := hmac.New(sha256.New, internalSecret)
+	mac.Write([]byte(fileLocator))
+	return mac.Sum(nil)
+}
+
+// CreateInternalFileToken returns a token that allows access to the given file from the local host.
+// These tokens must never be handed to clients.
+func CreateInternalFileToken(fileLocator string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fileLocator)) + "." +
+		base64.RawURLEncoding.EncodeToString(internalSignature(fileLocator))
+}
+
+// ValidateInternalFileToken returns the file locator the token gives access to.
+func ValidateInternalFileToken(token string) (string, error) {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("malformed internal file token")
+	}
+
+	fileLocator, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", fmt.Errorf("malformed internal file token")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("malformed internal file token")
+	}
+	if !hmac.Equal(signature, internalSignature(string(fileLocator))) {
+		return "", fmt.Errorf("invalid internal file token")
+	}
+	return string(fileLocator), nil
+}
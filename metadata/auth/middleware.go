@@ -22,10 +22,15 @@ func (c contextKey) String() string {
 }
 
 var (
-	contextKeyUserID  = contextKey("user_id")
-	ContextKeyIsAdmin = contextKey("is_admin")
+	contextKeyUserID       = contextKey("user_id")
+	ContextKeyIsAdmin      = contextKey("is_admin")
+	contextKeyAPIKeyScopes = contextKey("api_key_scopes")
+	contextKeyClientIP     = contextKey("client_ip")
 )
 
+// APIKeyHeader is the header used to authenticate with an API key instead of a JWT.
+const APIKeyHeader = "X-Olaris-API-Key"
+
 // UserClaims defines our custom JWT.
 type UserClaims struct {
 	Username string `json:"username"`
@@ -51,9 +56,71 @@ func UserAdmin(ctx context.Context) (bool, bool) {
 	return isAdmin, ok
 }
 
+// APIKeyScopes returns the scopes of the API key used for the request. If the request was not
+// authenticated with an API key ok will be false.
+func APIKeyScopes(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(contextKeyAPIKeyScopes).([]string)
+	return scopes, ok
+}
+
+// HasScope checks whether the request is allowed to act within the given API key scope.
+// Requests authenticated with a user JWT have access to all scopes.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := APIKeyScopes(ctx)
+	if !ok {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope || s == db.APIKeyScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIPFromContext returns the IP address of the client as recorded by the middleware.
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(contextKeyClientIP).(string)
+	return ip
+}
+
+// OptionalMiddleWare authenticates requests like MiddleWare if they carry credentials,
+// requests without credentials are passed on anonymously.
+func OptionalMiddleWare(h http.Handler) http.Handler {
+	authenticated := MiddleWare(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, fromProxy, _ := proxyUser(r)
+		if fromProxy || r.Header.Get(APIKeyHeader) != "" || r.Header.Get("Authorization") != "" ||
+			r.URL.Query().Get("JWT") != "" {
+			authenticated.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyClientIP, ClientIP(r))))
+	})
+}
+
 // MiddleWare checks for user authentication and prevents unauthorised access to the API.
 func MiddleWare(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), contextKeyClientIP, ClientIP(r)))
+
+		if apiKey := r.Header.Get(APIKeyHeader); apiKey != "" {
+			serveWithAPIKey(h, apiKey, w, r)
+			return
+		}
+
+		if user, ok, err := proxyUser(r); ok {
+			if err != nil {
+				writeError(
+					fmt.Sprintf("Unauthorized: %s", err.Error()),
+					w,
+					http.StatusForbidden)
+				return
+			}
+			serveWithProxyUser(h, user, w, r)
+			return
+		}
+
 		var authHeader, tokenStr string
 		authHeader = r.Header.Get("Authorization")
 
@@ -79,8 +146,9 @@ func MiddleWare(h http.Handler) http.Handler {
 			}
 
 			if claims, ok := token.Claims.(*UserClaims); ok && token.Valid {
-				// Check if the user still exists
-				_, err := db.FindUser(claims.UserID)
+				// Check if the user still exists, their admin status may have changed since the
+				// token was issued too.
+				user, err := db.FindUser(claims.UserID)
 				if err != nil {
 					writeError(
 						fmt.Sprintf("Unauthorized: %s", err.Error()),
@@ -98,7 +166,7 @@ func MiddleWare(h http.Handler) http.Handler {
 				).Debugln("Authenticated with valid JWT")
 				ctx := r.Context()
 				ctx = context.WithValue(ctx, contextKeyUserID, claims.UserID)
-				ctx = context.WithValue(ctx, ContextKeyIsAdmin, claims.Admin)
+				ctx = context.WithValue(ctx, ContextKeyIsAdmin, user.Admin)
 				h.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
@@ -109,6 +177,52 @@ func MiddleWare(h http.Handler) http.Handler {
 	})
 }
 
+func serveWithAPIKey(h http.Handler, apiKey string, w http.ResponseWriter, r *http.Request) {
+	key, err := db.FindAPIKeyByToken(apiKey)
+	if err != nil {
+		writeError(
+			fmt.Sprintf("Unauthorized: %s", err.Error()),
+			w,
+			http.StatusUnauthorized)
+		return
+	}
+
+	user, err := db.FindUser(key.UserID)
+	if err != nil {
+		writeError(
+			fmt.Sprintf("Unauthorized: %s", err.Error()),
+			w,
+			http.StatusUnauthorized)
+		return
+	}
+
+	if err := db.TouchAPIKey(key); err != nil {
+		log.WithError(err).Warnln("Could not update last use of API key")
+	}
+
+	scopes := key.EffectiveScopes(user)
+	isAdmin := false
+	for _, scope := range scopes {
+		if scope == db.APIKeyScopeAdmin {
+			isAdmin = true
+		}
+	}
+
+	log.WithFields(
+		log.Fields{
+			"username": user.Username,
+			"userID":   user.ID,
+			"apiKey":   key.Name,
+			"scopes":   scopes,
+		},
+	).Debugln("Authenticated with valid API key")
+	ctx := r.Context()
+	ctx = context.WithValue(ctx, contextKeyUserID, user.ID)
+	ctx = context.WithValue(ctx, ContextKeyIsAdmin, isAdmin)
+	ctx = context.WithValue(ctx, contextKeyAPIKeyScopes, scopes)
+	h.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // TODO Maran: Rotate secrets
 func tokenSecret() (string, error) {
 	tokenPath := path.Join(helpers.BaseConfigDir(), "token.secret")
@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"gitlab.com/olaris/olaris-server/metadata/app"
@@ -99,3 +100,59 @@ func TestMiddleWare(t *testing.T) {
 	assert.EqualValues(t, http.StatusOK, rw.Result().StatusCode)
 	assert.True(t, fakeHandler.Called())
 }
+
+func TestMiddleWare_APIKey(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	user, _ := db.CreateUser("test", "testtest", true)
+	_, token, _ := db.CreateAPIKey("test", user.ID, user.ID, []string{db.APIKeyScopeMetadataRead})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add(APIKeyHeader, token)
+
+	var scopes []string
+	var isAdmin bool
+	handler := MiddleWare(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		scopes, _ = APIKeyScopes(req.Context())
+		isAdmin, _ = UserAdmin(req.Context())
+	}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+
+	assert.EqualValues(t, http.StatusOK, rw.Result().StatusCode)
+	assert.Equal(t, []string{db.APIKeyScopeMetadataRead}, scopes)
+	// The key doesn't have the admin scope, so the owner's admin rights don't apply.
+	assert.False(t, isAdmin)
+}
+
+func TestMiddleWare_RevokedAPIKey(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	user, _ := db.CreateUser("test", "testtest", false)
+	key, token, _ := db.CreateAPIKey("test", user.ID, user.ID, []string{db.APIKeyScopeMetadataRead})
+	db.RevokeAPIKey(key.ID)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add(APIKeyHeader, token)
+
+	fakeHandler := TestHandler{}
+	handler := MiddleWare(fakeHandler.HandlerFunc())
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+
+	assert.EqualValues(t, http.StatusUnauthorized, rw.Result().StatusCode)
+	assert.False(t, fakeHandler.Called())
+}
+
+func TestHasScope(t *testing.T) {
+	ctx := context.Background()
+	// JWT sessions aren't restricted by scopes
+	assert.True(t, HasScope(ctx, db.APIKeyScopeLibraryRescan))
+
+	ctx = context.WithValue(ctx, contextKeyAPIKeyScopes, []string{db.APIKeyScopeMetadataRead})
+	assert.True(t, HasScope(ctx, db.APIKeyScopeMetadataRead))
+	assert.False(t, HasScope(ctx, db.APIKeyScopeLibraryRescan))
+
+	ctx = context.WithValue(ctx, contextKeyAPIKeyScopes, []string{db.APIKeyScopeAdmin})
+	assert.True(t, HasScope(ctx, db.APIKeyScopeLibraryRescan))
+}
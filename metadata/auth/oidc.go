@@ -0,0 +1,568 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// oidcLoginValidity is how long a user has to log in at the provider before the login attempt expires.
+const oidcLoginValidity = 10 * time.Minute
+
+// oidcMaxPendingLogins limits how many login attempts can be in progress at once, as anyone can
+// start one.
+const oidcMaxPendingLogins = 1000
+
+// oidcStateCookie ties a login attempt to the browser that started it, so a callback with the
+// state of someone else's login attempt is rejected.
+const oidcStateCookie = "olaris_oidc_state"
+
+// OIDCConfig configures logging in through an OpenID Connect provider.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback registered at the provider, it should point to
+	// /olaris/m/v1/auth/oidc/callback on this server.
+	RedirectURL string
+	Scopes      []string
+
+	// UsernameClaim is used as username for new users.
+	UsernameClaim string
+	GroupsClaim   string
+	// AdminGroup members become admin and everyone else loses admin rights on login.
+	// If empty admin rights are managed in olaris only.
+	AdminGroup string
+	// AllowedGroups restricts logins to members of any of these groups, if set.
+	AllowedGroups []string
+
+	// AutoProvision creates users that log in for the first time.
+	AutoProvision bool
+	// LinkExistingUsers links provider accounts to existing users whose username is the verified
+	// email address of the account. Usernames aren't used, providers often let users change them.
+	LinkExistingUsers bool
+
+	// PostLoginURL is where the browser is sent after logging in, with '#jwt=<token>' appended.
+	PostLoginURL string
+}
+
+// OIDCConfigFromViper reads the [auth.oidc] section of the configuration. It returns false if
+// OpenID Connect is not enabled.
+func OIDCConfigFromViper() (OIDCConfig, bool) {
+	if !viper.GetBool("auth.oidc.enabled") {
+		return OIDCConfig{}, false
+	}
+
+	config := OIDCConfig{
+		Issuer:            viper.GetString("auth.oidc.issuer"),
+		ClientID:          viper.GetString("auth.oidc.clientID"),
+		ClientSecret:      viper.GetString("auth.oidc.clientSecret"),
+		RedirectURL:       viper.GetString("auth.oidc.redirectURL"),
+		Scopes:            viper.GetStringSlice("auth.oidc.scopes"),
+		UsernameClaim:     viper.GetString("auth.oidc.usernameClaim"),
+		GroupsClaim:       viper.GetString("auth.oidc.groupsClaim"),
+		AdminGroup:        viper.GetString("auth.oidc.adminGroup"),
+		AllowedGroups:     viper.GetStringSlice("auth.oidc.allowedGroups"),
+		AutoProvision:     viper.GetBool("auth.oidc.autoProvision"),
+		LinkExistingUsers: viper.GetBool("auth.oidc.linkExistingUsers"),
+		PostLoginURL:      viper.GetString("auth.oidc.postLoginURL"),
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "profile", "email"}
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "preferred_username"
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if config.PostLoginURL == "" {
+		config.PostLoginURL = "/olaris/app/"
+	}
+
+	if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		log.Errorln("OpenID Connect is enabled but issuer, clientID or redirectURL is missing, disabling it.")
+		return OIDCConfig{}, false
+	}
+	return config, true
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcPendingLogin struct {
+	verifier  string
+	nonce     string
+	expiresAt time.Time
+}
+
+// OIDCProvider implements the OpenID Connect authorization code flow with PKCE.
+type OIDCProvider struct {
+	config OIDCConfig
+	client *http.Client
+
+	mutex     sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]interface{}
+	pending   map[string]oidcPendingLogin
+}
+
+// NewOIDCProvider creates a provider for the given configuration. The provider's endpoints are
+// discovered on the first login so an unreachable provider doesn't prevent the server from starting.
+func NewOIDCProvider(config OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{
+		config:  config,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		keys:    map[string]interface{}{},
+		pending: map[string]oidcPendingLogin{},
+	}
+}
+
+func (p *OIDCProvider) getJSON(url string, v interface{}) error {
+	res, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", url, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+func (p *OIDCProvider) discover() (*oidcDiscovery, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	discovery := oidcDiscovery{}
+	err := p.getJSON(strings.TrimSuffix(p.config.Issuer, "/")+"/.well-known/openid-configuration", &discovery)
+	if err != nil {
+		return nil, fmt.Errorf("could not discover OpenID Connect provider: %s", err)
+	}
+	if discovery.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("provider issuer '%s' does not match configured issuer '%s'",
+			discovery.Issuer, p.config.Issuer)
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+func (p *OIDCProvider) oauth2Config(discovery *oidcDiscovery) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Scopes:       p.config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}
+}
+
+// stateCookie returns the cookie holding a hash of the state of a login attempt. It's only sent
+// to the callback, and as a top-level navigation from the provider requires SameSite=Lax.
+func (p *OIDCProvider) stateCookie(value string, maxAge int) *http.Cookie {
+	cookie := &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if u, err := url.Parse(p.config.RedirectURL); err == nil {
+		if u.Path != "" {
+			cookie.Path = u.Path
+		}
+		cookie.Secure = u.Scheme == "https"
+	}
+	return cookie
+}
+
+func stateHash(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// LoginHandler redirects the browser to the provider to log in.
+func (p *OIDCProvider) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	discovery, err := p.discover()
+	if err != nil {
+		log.WithError(err).Errorln("OpenID Connect login failed")
+		writeError("Identity provider is not available", w, http.StatusBadGateway)
+		return
+	}
+
+	state, err := helpers.SecureRandAlphaString(32)
+	if err != nil {
+		writeError(err.Error(), w, http.StatusInternalServerError)
+		return
+	}
+	nonce, err := helpers.SecureRandAlphaString(32)
+	if err != nil {
+		writeError(err.Error(), w, http.StatusInternalServerError)
+		return
+	}
+	verifier, err := helpers.SecureRandAlphaString(64)
+	if err != nil {
+		writeError(err.Error(), w, http.StatusInternalServerError)
+		return
+	}
+
+	p.mutex.Lock()
+	now := time.Now()
+	for s, pending := range p.pending {
+		if now.After(pending.expiresAt) {
+			delete(p.pending, s)
+		}
+	}
+	if len(p.pending) >= oidcMaxPendingLogins {
+		p.mutex.Unlock()
+		log.WithField("ip", ClientIP(r)).Warnln("Too many OpenID Connect logins in progress")
+		writeError("Too many logins in progress, please try again later", w, http.StatusServiceUnavailable)
+		return
+	}
+	p.pending[state] = oidcPendingLogin{verifier: verifier, nonce: nonce, expiresAt: now.Add(oidcLoginValidity)}
+	p.mutex.Unlock()
+	http.SetCookie(w, p.stateCookie(stateHash(state), int(oidcLoginValidity/time.Second)))
+
+	url := p.oauth2Config(discovery).AuthCodeURL(state,
+		oauth2.SetAuthURLParam("nonce", nonce),
+		oauth2.SetAuthURLParam("code_challenge", pkceChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// CallbackHandler finishes the login after the provider redirected the browser back and
+// hands out a metadata JWT.
+func (p *OIDCProvider) CallbackHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ip := ClientIP(r)
+
+	if providerErr := q.Get("error"); providerErr != "" {
+		log.WithFields(log.Fields{"error": providerErr, "description": q.Get("error_description")}).
+			Warnln("OpenID Connect provider returned an error")
+		writeError("Login at identity provider failed", w, http.StatusUnauthorized)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	http.SetCookie(w, p.stateCookie("", -1))
+	if err != nil || !hmac.Equal([]byte(cookie.Value), []byte(stateHash(q.Get("state")))) {
+		log.WithField("ip", ip).Warnln("OpenID Connect callback without matching state cookie")
+		writeError("Login expired, please try again", w, http.StatusUnauthorized)
+		return
+	}
+
+	p.mutex.Lock()
+	pending, ok := p.pending[q.Get("state")]
+	delete(p.pending, q.Get("state"))
+	p.mutex.Unlock()
+	if !ok || time.Now().After(pending.expiresAt) {
+		writeError("Login expired, please try again", w, http.StatusUnauthorized)
+		return
+	}
+
+	discovery, err := p.discover()
+	if err != nil {
+		writeError("Identity provider is not available", w, http.StatusBadGateway)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), oauth2.HTTPClient, p.client)
+	token, err := p.oauth2Config(discovery).Exchange(ctx, q.Get("code"),
+		oauth2.SetAuthURLParam("code_verifier", pending.verifier))
+	if err != nil {
+		log.WithError(err).Warnln("Could not exchange OpenID Connect authorization code")
+		writeError("Login at identity provider failed", w, http.StatusUnauthorized)
+		return
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	claims, err := p.verifyIDToken(discovery, rawIDToken, pending.nonce)
+	if err != nil {
+		log.WithError(err).Warnln("Invalid OpenID Connect ID token")
+		writeError("Login at identity provider failed", w, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := p.userFromClaims(discovery, claims)
+	if err != nil {
+		subject, _ := claims["sub"].(string)
+		loginFailed(ip, subject, 0, fmt.Sprintf("oidc: %s", err))
+		writeError(err.Error(), w, http.StatusForbidden)
+		return
+	}
+
+	jwtToken, err := CreateMetadataJWT(user, DefaultLoginTokenValidity)
+	if err != nil {
+		writeError(err.Error(), w, http.StatusInternalServerError)
+		return
+	}
+	db.AddAuditLogEntry(&db.AuditLogEntry{
+		Event:    db.AuditEventLogin,
+		UserID:   user.ID,
+		Username: user.Username,
+		IP:       ip,
+		Details:  "oidc",
+	})
+
+	// The token is passed in the fragment so it doesn't end up in server or proxy logs.
+	http.Redirect(w, r, p.config.PostLoginURL+"#jwt="+jwtToken, http.StatusFound)
+}
+
+func (p *OIDCProvider) verifyIDToken(discovery *oidcDiscovery, rawIDToken string, nonce string) (jwt.MapClaims, error) {
+	if rawIDToken == "" {
+		return nil, fmt.Errorf("token response contains no ID token")
+	}
+
+	token, err := jwt.Parse(rawIDToken, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return p.key(discovery, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("invalid ID token")
+	}
+	if iss, _ := claims["iss"].(string); iss != discovery.Issuer {
+		return nil, fmt.Errorf("ID token issued by '%s'", iss)
+	}
+	if !containsString(claimStrings(claims["aud"]), p.config.ClientID) {
+		return nil, fmt.Errorf("ID token not issued for this client")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, fmt.Errorf("ID token nonce mismatch")
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("ID token has no subject")
+	}
+	return claims, nil
+}
+
+// key returns the provider's signing key with the given ID. Keys are fetched again if the
+// ID is unknown, as providers rotate them.
+func (p *OIDCProvider) key(discovery *oidcDiscovery, kid string) (interface{}, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+
+	keySet := struct {
+		Keys []jsonWebKey `json:"keys"`
+	}{}
+	if err := p.getJSON(discovery.JWKSURI, &keySet); err != nil {
+		return nil, err
+	}
+	p.keys = map[string]interface{}{}
+	for _, jwk := range keySet.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.WithError(err).WithField("kid", jwk.Kid).Debugln("Skipping unsupported key")
+			continue
+		}
+		p.keys[jwk.Kid] = key
+	}
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key '%s'", kid)
+}
+
+func (p *OIDCProvider) userFromClaims(discovery *oidcDiscovery, claims jwt.MapClaims) (*db.User, error) {
+	subject := claims["sub"].(string)
+	groups := claimStrings(claims[p.config.GroupsClaim])
+
+	if len(p.config.AllowedGroups) > 0 {
+		allowed := false
+		for _, group := range p.config.AllowedGroups {
+			if containsString(groups, group) {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("you are not a member of a group that is allowed to log in")
+		}
+	}
+	isAdmin := p.config.AdminGroup != "" && containsString(groups, p.config.AdminGroup)
+
+	user, err := db.FindUserByOIDCSubject(discovery.Issuer, subject)
+	if err != nil {
+		username, _ := claims[p.config.UsernameClaim].(string)
+		if username == "" {
+			return nil, fmt.Errorf("identity provider did not supply the '%s' claim", p.config.UsernameClaim)
+		}
+
+		existing, found := p.userToLink(claims)
+		switch {
+		case found:
+			if err := db.LinkOIDCSubject(existing.ID, discovery.Issuer, subject); err != nil {
+				return nil, err
+			}
+			log.WithField("username", existing.Username).Infoln("Linked existing user to OpenID Connect account")
+			user = existing
+		case p.config.AutoProvision:
+			user, err = db.CreateOIDCUser(username, discovery.Issuer, subject, isAdmin)
+			if err != nil {
+				return nil, err
+			}
+			log.WithField("username", username).Infoln("Created user for OpenID Connect account")
+		default:
+			return nil, fmt.Errorf("no account exists for you yet, please ask an admin")
+		}
+	}
+
+	if p.config.AdminGroup != "" {
+		user = syncAdmin(user, isAdmin)
+	}
+	return user, nil
+}
+
+// userToLink returns the existing user a provider account is linked to on its first login, if
+// linking is enabled. Only verified email addresses are matched against usernames.
+func (p *OIDCProvider) userToLink(claims jwt.MapClaims) (*db.User, bool) {
+	if !p.config.LinkExistingUsers {
+		return nil, false
+	}
+	email, _ := claims["email"].(string)
+	if verified, _ := claims["email_verified"].(bool); !verified || email == "" {
+		return nil, false
+	}
+	user, err := db.FindUserByUsername(email)
+	if err != nil || user.OIDCSubject != "" {
+		return nil, false
+	}
+	return user, true
+}
+
+// claimStrings returns a claim that can be either a single string or a list of strings as a list.
+func claimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var strs []string
+		for _, s := range v {
+			if str, ok := s.(string); ok {
+				strs = append(strs, str)
+			}
+		}
+		return strs
+	}
+	return nil
+}
+
+func containsString(strs []string, str string) bool {
+	for _, s := range strs {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonWebKey is a public key as published by the provider, see RFC 7517.
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (jwk *jsonWebKey) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve '%s'", jwk.Crv)
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type '%s'", jwk.Kty)
+}
@@ -0,0 +1,341 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+type mockAuthorization struct {
+	challenge string
+	nonce     string
+	subject   string
+	username  string
+	groups    []string
+}
+
+// mockIssuer is a minimal OpenID Connect provider that hands out codes without a login page.
+type mockIssuer struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mutex sync.Mutex
+	codes map[string]mockAuthorization
+	// emails are the email addresses of subjects, and verified whether the provider checked them.
+	emails   map[string]string
+	verified map[string]bool
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	m := &mockIssuer{key: key, codes: map[string]mockAuthorization{},
+		emails: map[string]string{}, verified: map[string]bool{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                m.URL,
+			AuthorizationEndpoint: m.URL + "/authorize",
+			TokenEndpoint:         m.URL + "/token",
+			JWKSURI:               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []jsonWebKey{{
+			Kid: "test",
+			Kty: "RSA",
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		m.mutex.Lock()
+		authorization, ok := m.codes[r.Form.Get("code")]
+		delete(m.codes, r.Form.Get("code"))
+		m.mutex.Unlock()
+
+		if !ok || pkceChallenge(r.Form.Get("code_verifier")) != authorization.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+
+		claims := jwt.MapClaims{
+			"iss":                m.URL,
+			"aud":                "olaris",
+			"sub":                authorization.subject,
+			"nonce":              authorization.nonce,
+			"exp":                time.Now().Add(time.Minute).Unix(),
+			"preferred_username": authorization.username,
+			"groups":             authorization.groups,
+		}
+		m.mutex.Lock()
+		if email, ok := m.emails[authorization.subject]; ok {
+			claims["email"] = email
+			claims["email_verified"] = m.verified[authorization.subject]
+		}
+		m.mutex.Unlock()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		idToken, err := token.SignedString(key)
+		require.NoError(t, err)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     idToken,
+		})
+	})
+	m.Server = httptest.NewServer(mux)
+	return m
+}
+
+// authorize simulates the user logging in at the provider and returns the callback URL.
+func (m *mockIssuer) authorize(t *testing.T, loginLocation string, subject string, username string, groups ...string) string {
+	u, err := url.Parse(loginLocation)
+	require.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	code := "code-" + subject
+	m.mutex.Lock()
+	m.codes[code] = mockAuthorization{
+		challenge: q.Get("code_challenge"),
+		nonce:     q.Get("nonce"),
+		subject:   subject,
+		username:  username,
+		groups:    groups,
+	}
+	m.mutex.Unlock()
+
+	return "/v1/auth/oidc/callback?" + url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+}
+
+func oidcLogin(t *testing.T, p *OIDCProvider, m *mockIssuer, subject string, username string, groups ...string) *httptest.ResponseRecorder {
+	rw := httptest.NewRecorder()
+	p.LoginHandler(rw, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/login", nil))
+	require.Equal(t, http.StatusFound, rw.Code)
+	require.True(t, strings.HasPrefix(rw.Header().Get("Location"), m.URL+"/authorize"))
+
+	callback := m.authorize(t, rw.Header().Get("Location"), subject, username, groups...)
+	req := httptest.NewRequest(http.MethodGet, callback, nil)
+	for _, cookie := range rw.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+	rw = httptest.NewRecorder()
+	p.CallbackHandler(rw, req)
+	return rw
+}
+
+func TestOIDC_AutoProvision(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	m := newMockIssuer(t)
+	defer m.Close()
+
+	// The last admin can't be demoted, so make sure there is another one.
+	db.CreateUser("admin", "testtest", true)
+
+	p := NewOIDCProvider(OIDCConfig{
+		Issuer:        m.URL,
+		ClientID:      "olaris",
+		RedirectURL:   "http://olaris/olaris/m/v1/auth/oidc/callback",
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		AdminGroup:    "olaris-admins",
+		AutoProvision: true,
+		PostLoginURL:  "/olaris/app/",
+	})
+
+	rw := oidcLogin(t, p, m, "subject-1", "alice", "family", "olaris-admins")
+	require.Equal(t, http.StatusFound, rw.Code)
+	location := rw.Header().Get("Location")
+	require.True(t, strings.HasPrefix(location, "/olaris/app/#jwt="))
+
+	token, err := jwt.ParseWithClaims(strings.TrimPrefix(location, "/olaris/app/#jwt="), &UserClaims{}, jwtSecretFunc)
+	require.NoError(t, err)
+	claims := token.Claims.(*UserClaims)
+	assert.Equal(t, "alice", claims.Username)
+	assert.True(t, claims.Admin)
+
+	user, err := db.FindUserByOIDCSubject(m.URL, "subject-1")
+	require.NoError(t, err)
+	assert.Equal(t, claims.UserID, user.ID)
+
+	// Admin rights follow the group membership
+	rw = oidcLogin(t, p, m, "subject-1", "alice", "family")
+	require.Equal(t, http.StatusFound, rw.Code)
+	user, _ = db.FindUser(user.ID)
+	assert.False(t, user.Admin)
+}
+
+func TestOIDC_Rejected(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	m := newMockIssuer(t)
+	defer m.Close()
+
+	p := NewOIDCProvider(OIDCConfig{
+		Issuer:        m.URL,
+		ClientID:      "olaris",
+		RedirectURL:   "http://olaris/olaris/m/v1/auth/oidc/callback",
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		AllowedGroups: []string{"family"},
+		PostLoginURL:  "/olaris/app/",
+	})
+
+	// Not in an allowed group
+	rw := oidcLogin(t, p, m, "subject-2", "mallory", "strangers")
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+
+	// No account and auto provisioning is disabled
+	rw = oidcLogin(t, p, m, "subject-3", "bob", "family")
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+
+	// Unknown state
+	rw = httptest.NewRecorder()
+	p.CallbackHandler(rw, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/callback?code=x&state=y", nil))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+}
+
+func TestOIDC_StateCookie(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	m := newMockIssuer(t)
+	defer m.Close()
+	db.CreateUser("admin", "testtest", true)
+
+	p := NewOIDCProvider(OIDCConfig{
+		Issuer:        m.URL,
+		ClientID:      "olaris",
+		RedirectURL:   "https://olaris/olaris/m/v1/auth/oidc/callback",
+		UsernameClaim: "preferred_username",
+		AutoProvision: true,
+		PostLoginURL:  "/olaris/app/",
+	})
+
+	login := func(subject string, username string) (string, *http.Cookie) {
+		rw := httptest.NewRecorder()
+		p.LoginHandler(rw, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/login", nil))
+		require.Equal(t, http.StatusFound, rw.Code)
+		cookies := rw.Result().Cookies()
+		require.Len(t, cookies, 1)
+		return m.authorize(t, rw.Header().Get("Location"), subject, username), cookies[0]
+	}
+
+	attackerCallback, attackerCookie := login("subject-4", "mallory")
+	assert.True(t, attackerCookie.HttpOnly)
+	assert.True(t, attackerCookie.Secure)
+	assert.Equal(t, http.SameSiteLaxMode, attackerCookie.SameSite)
+	assert.Equal(t, "/olaris/m/v1/auth/oidc/callback", attackerCookie.Path)
+	assert.NotContains(t, attackerCallback, attackerCookie.Value, "the cookie only holds a hash of the state")
+
+	// The callback of a login started in another browser is rejected, with or without a cookie
+	_, victimCookie := login("subject-5", "dave")
+	for _, cookies := range [][]*http.Cookie{nil, {victimCookie}} {
+		req := httptest.NewRequest(http.MethodGet, attackerCallback, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		rw := httptest.NewRecorder()
+		p.CallbackHandler(rw, req)
+		assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	}
+
+	// The browser that started the login can finish it
+	req := httptest.NewRequest(http.MethodGet, attackerCallback, nil)
+	req.AddCookie(attackerCookie)
+	rw := httptest.NewRecorder()
+	p.CallbackHandler(rw, req)
+	assert.Equal(t, http.StatusFound, rw.Code)
+}
+
+func TestOIDC_LinkExistingUser(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	m := newMockIssuer(t)
+	defer m.Close()
+
+	existing, err := db.CreateUser("carol@example.com", "testtest", false)
+	require.NoError(t, err)
+	_, err = db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+
+	p := NewOIDCProvider(OIDCConfig{
+		Issuer:            m.URL,
+		ClientID:          "olaris",
+		RedirectURL:       "http://olaris/olaris/m/v1/auth/oidc/callback",
+		UsernameClaim:     "preferred_username",
+		GroupsClaim:       "groups",
+		LinkExistingUsers: true,
+		PostLoginURL:      "/olaris/app/",
+	})
+
+	// Usernames can usually be changed by users, they're never used to link accounts
+	rw := oidcLogin(t, p, m, "subject-3", "admin")
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+
+	m.emails["subject-4"] = "carol@example.com"
+	rw = oidcLogin(t, p, m, "subject-4", "carol")
+	assert.Equal(t, http.StatusForbidden, rw.Code, "unverified email addresses should not be linked")
+	_, err = db.FindUserByOIDCSubject(m.URL, "subject-4")
+	assert.Error(t, err)
+
+	m.verified["subject-4"] = true
+	rw = oidcLogin(t, p, m, "subject-4", "carol")
+	require.Equal(t, http.StatusFound, rw.Code)
+
+	user, err := db.FindUserByOIDCSubject(m.URL, "subject-4")
+	require.NoError(t, err)
+	assert.Equal(t, existing.ID, user.ID)
+}
+
+func TestOIDC_TooManyPendingLogins(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	m := newMockIssuer(t)
+	defer m.Close()
+
+	p := NewOIDCProvider(OIDCConfig{
+		Issuer:        m.URL,
+		ClientID:      "olaris",
+		RedirectURL:   "http://olaris/olaris/m/v1/auth/oidc/callback",
+		UsernameClaim: "preferred_username",
+		GroupsClaim:   "groups",
+		AutoProvision: true,
+		PostLoginURL:  "/olaris/app/",
+	})
+
+	expiresAt := time.Now().Add(oidcLoginValidity)
+	for i := 0; i < oidcMaxPendingLogins; i++ {
+		p.pending[fmt.Sprint(i)] = oidcPendingLogin{expiresAt: expiresAt}
+	}
+	rw := httptest.NewRecorder()
+	p.LoginHandler(rw, httptest.NewRequest(http.MethodGet, "/v1/auth/oidc/login", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.Len(t, p.pending, oidcMaxPendingLogins)
+
+	// Expired attempts make room again
+	for i := 0; i < 10; i++ {
+		p.pending[fmt.Sprint(i)] = oidcPendingLogin{expiresAt: time.Now().Add(-time.Second)}
+	}
+	rw = oidcLogin(t, p, m, "subject-5", "dave")
+	assert.Equal(t, http.StatusFound, rw.Code)
+}
@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// ProxyAuthConfig configures authentication by a reverse proxy that passes the identity of the
+// logged in user in request headers.
+type ProxyAuthConfig struct {
+	// TrustedProxies are the networks the proxy connects from. Headers from other addresses are ignored.
+	TrustedProxies []*net.IPNet
+	UserHeader     string
+	// GroupsHeader holds a comma-separated list of groups.
+	GroupsHeader string
+	// AdminGroup members become admin and everyone else loses admin rights.
+	// If empty admin rights are managed in olaris only.
+	AdminGroup string
+	// AutoProvision creates users the first time they make a request.
+	AutoProvision bool
+}
+
+// proxyAuth is the active proxy authentication configuration, nil if disabled.
+var proxyAuth *ProxyAuthConfig
+
+// ProxyAuthConfigFromViper reads the [auth.proxy] section of the configuration. It returns false
+// if proxy authentication is not enabled.
+func ProxyAuthConfigFromViper() (ProxyAuthConfig, bool) {
+	if !viper.GetBool("auth.proxy.enabled") {
+		return ProxyAuthConfig{}, false
+	}
+
+	config := ProxyAuthConfig{
+		UserHeader:    viper.GetString("auth.proxy.userHeader"),
+		GroupsHeader:  viper.GetString("auth.proxy.groupsHeader"),
+		AdminGroup:    viper.GetString("auth.proxy.adminGroup"),
+		AutoProvision: viper.GetBool("auth.proxy.autoProvision"),
+	}
+	if config.UserHeader == "" {
+		config.UserHeader = "Remote-User"
+	}
+	if config.GroupsHeader == "" {
+		config.GroupsHeader = "Remote-Groups"
+	}
+
+	for _, cidr := range viper.GetStringSlice("auth.proxy.trustedProxies") {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			log.WithError(err).Errorln("Invalid trusted proxy network, disabling proxy authentication.")
+			return ProxyAuthConfig{}, false
+		}
+		config.TrustedProxies = append(config.TrustedProxies, network)
+	}
+	if len(config.TrustedProxies) == 0 {
+		log.Errorln("Proxy authentication is enabled but no trusted proxies are configured, disabling it.")
+		return ProxyAuthConfig{}, false
+	}
+	return config, true
+}
+
+// EnableProxyAuth makes the middleware accept identities from the given proxies.
+func EnableProxyAuth(config ProxyAuthConfig) {
+	log.WithFields(log.Fields{"userHeader": config.UserHeader, "trustedProxies": config.TrustedProxies}).
+		Infoln("Enabling authentication by reverse proxy")
+	proxyAuth = &config
+}
+
+// DisableProxyAuth stops accepting identities from proxies.
+func DisableProxyAuth() {
+	proxyAuth = nil
+}
+
+func remoteIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func (config *ProxyAuthConfig) trusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range config.TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the address of the client that connected to a trusted proxy, or an empty string.
+func forwardedFor(r *http.Request) string {
+	if proxyAuth == nil || !proxyAuth.trusted(remoteIP(r)) {
+		return ""
+	}
+	// Walk from the right as only entries added by trusted proxies can be relied on.
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if ip := net.ParseIP(hop); ip != nil && !proxyAuth.trusted(ip) {
+			return hop
+		}
+	}
+	return ""
+}
+
+// proxyUser returns the user identified by a trusted proxy. ok is false if the request didn't
+// come through a trusted proxy or carries no identity, in which case other authentication
+// methods apply.
+func proxyUser(r *http.Request) (user *db.User, ok bool, err error) {
+	config := proxyAuth
+	if config == nil || !config.trusted(remoteIP(r)) {
+		return nil, false, nil
+	}
+	username := strings.TrimSpace(r.Header.Get(config.UserHeader))
+	if username == "" {
+		return nil, false, nil
+	}
+
+	var groups []string
+	for _, group := range strings.Split(r.Header.Get(config.GroupsHeader), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	isAdmin := config.AdminGroup != "" && containsString(groups, config.AdminGroup)
+
+	user, err = db.FindUserByUsername(username)
+	if err != nil {
+		if !config.AutoProvision {
+			return nil, true, err
+		}
+		user, err = db.CreateExternalUser(username, isAdmin)
+		if err != nil {
+			return nil, true, err
+		}
+		log.WithField("username", username).Infoln("Created user for reverse proxy login")
+	}
+
+	if config.AdminGroup != "" {
+		user = syncAdmin(user, isAdmin)
+	}
+	return user, true, nil
+}
+
+// syncAdmin updates the admin rights of the user to match an external identity system.
+func syncAdmin(user *db.User, isAdmin bool) *db.User {
+	if user.Admin == isAdmin {
+		return user
+	}
+	updated, err := db.SetUserAdmin(user.ID, isAdmin)
+	if err != nil {
+		log.WithError(err).WithField("username", user.Username).Warnln("Could not sync admin rights")
+		return user
+	}
+	return updated
+}
+
+func serveWithProxyUser(h http.Handler, user *db.User, w http.ResponseWriter, r *http.Request) {
+	log.WithFields(
+		log.Fields{
+			"username": user.Username,
+			"userID":   user.ID,
+		},
+	).Debugln("Authenticated by reverse proxy")
+	ctx := r.Context()
+	ctx = context.WithValue(ctx, contextKeyUserID, user.ID)
+	ctx = context.WithValue(ctx, ContextKeyIsAdmin, user.Admin)
+	h.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// ProxyLoginHandler hands out a metadata JWT to users authenticated by a trusted proxy, so
+// clients that expect a JWT work unchanged.
+func ProxyLoginHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok, err := proxyUser(r)
+	if !ok {
+		writeError("No identity supplied by a trusted proxy", w, http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		writeError("Unauthorized: no account exists for you yet, please ask an admin", w, http.StatusForbidden)
+		return
+	}
+
+	db.AddAuditLogEntry(&db.AuditLogEntry{
+		Event:    db.AuditEventLogin,
+		UserID:   user.ID,
+		Username: user.Username,
+		IP:       ClientIP(r),
+		Details:  "reverse proxy",
+	})
+	writeLoginToken(user, w)
+}
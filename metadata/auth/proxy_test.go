@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func enableTestProxyAuth(t *testing.T, autoProvision bool) {
+	_, network, err := net.ParseCIDR("10.0.0.0/8")
+	require.NoError(t, err)
+	EnableProxyAuth(ProxyAuthConfig{
+		TrustedProxies: []*net.IPNet{network},
+		UserHeader:     "Remote-User",
+		GroupsHeader:   "Remote-Groups",
+		AdminGroup:     "admins",
+		AutoProvision:  autoProvision,
+	})
+}
+
+func proxyRequest(remoteAddr string, user string, groups string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("Remote-User", user)
+	req.Header.Set("Remote-Groups", groups)
+	return req
+}
+
+func TestMiddleWare_ProxyAuth(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	enableTestProxyAuth(t, true)
+	defer DisableProxyAuth()
+
+	var userID uint
+	var isAdmin bool
+	handler := MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ = UserID(r.Context())
+		isAdmin, _ = UserAdmin(r.Context())
+	}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, proxyRequest("10.1.2.3:4000", "dave", "family, admins"))
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.NotZero(t, userID)
+	assert.True(t, isAdmin)
+
+	user, err := db.FindUserByUsername("dave")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, userID)
+
+	// Headers from untrusted addresses are ignored
+	userID = 0
+	rw = httptest.NewRecorder()
+	handler.ServeHTTP(rw, proxyRequest("192.0.2.1:4000", "dave", "admins"))
+	assert.Equal(t, http.StatusUnauthorized, rw.Code)
+	assert.Zero(t, userID)
+}
+
+func TestMiddleWare_ProxyAuthNoProvisioning(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	enableTestProxyAuth(t, false)
+	defer DisableProxyAuth()
+
+	handler := MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, proxyRequest("10.1.2.3:4000", "eve", ""))
+	assert.Equal(t, http.StatusForbidden, rw.Code)
+
+	db.CreateUser("frank", "testtest", false)
+	rw = httptest.NewRecorder()
+	ProxyLoginHandler(rw, proxyRequest("10.1.2.3:4000", "frank", ""))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), "jwt")
+}
+
+func TestClientIP_TrustedProxy(t *testing.T) {
+	enableTestProxyAuth(t, false)
+	defer DisableProxyAuth()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.1.2.3:4000"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9, 198.51.100.4, 10.2.3.4")
+	assert.Equal(t, "198.51.100.4", ClientIP(req))
+
+	req.RemoteAddr = "192.0.2.1:4000"
+	assert.Equal(t, "192.0.2.1", ClientIP(req))
+}
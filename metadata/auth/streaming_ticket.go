@@ -2,55 +2,226 @@ package auth
 
 import (
 	"fmt"
+	"sync"
+	"time"
+
 	"github.com/dgrijalva/jwt-go"
 	log "github.com/sirupsen/logrus"
-	"time"
+
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/db"
 )
 
+// StreamingTicketValidity is how long a streaming ticket is valid without being refreshed.
+const StreamingTicketValidity = 4 * time.Hour
+
+// streamingTicketMaxLifetime is the hard limit for refreshing a ticket, it's also the expiry of the JWT.
+const streamingTicketMaxLifetime = 24 * time.Hour
+
+// streamingTicketCacheTTL limits how long validated tickets are kept in memory. Refreshing and
+// revoking tickets invalidates the cache, so this only bounds its size.
+const streamingTicketCacheTTL = 10 * time.Second
+
 // StreamingClaims is a custom JWT that allows filesystem access to files for a certain timespan.
+// The JWT ID refers to a db.StreamingTicket that holds the actual expiry and revocation state.
 type StreamingClaims struct {
-	UserID   uint
-	FilePath string
+	UserID    uint
+	FilePath  string
+	SessionID string
+	// TranscodingProfile is the name of the profile used when the file needs to be transcoded.
+	TranscodingProfile string `json:",omitempty"`
+	// Deinterlace is the mode used for interlaced video, the default one is used if empty.
+	Deinterlace string `json:",omitempty"`
+	// OptimizedVersions are the file locators of the optimized versions of the file. Manifests
+	// redirect to one of them if the client can play it without transcoding.
+	OptimizedVersions []string `json:",omitempty"`
 	jwt.StandardClaims
 }
 
-// CreateStreamingJWT creates a new JWT that will give permission to stream certain media for a certain timespan.
-func CreateStreamingJWT(userID uint, fileLocator string) (string, error) {
-	expiresAt := time.Now().Add(time.Hour * 8).Unix()
+// StreamingTicketOptions describe a new streaming ticket.
+type StreamingTicketOptions struct {
+	UserID    uint
+	FilePath  string
+	SessionID string
+	// ClientIP binds the ticket to a single client address if set.
+	ClientIP string
+	// TranscodingProfile selects the transcoding profile, the default one is used if empty.
+	TranscodingProfile string
+	// Deinterlace selects how interlaced video is deinterlaced, see ffmpeg.DeinterlacingModes.
+	Deinterlace string
+	// OptimizedVersions are the file locators of the optimized versions of the file.
+	OptimizedVersions []string
+}
+
+type cachedStreamingTicket struct {
+	ticket    db.StreamingTicket
+	fetchedAt time.Time
+}
 
-	claims := StreamingClaims{
-		userID,
-		fileLocator,
-		jwt.StandardClaims{ExpiresAt: expiresAt, Issuer: "bss"},
+var streamingTicketCache = struct {
+	sync.Mutex
+	tickets map[string]cachedStreamingTicket
+}{tickets: map[string]cachedStreamingTicket{}}
+
+func findStreamingTicket(ticketID string) (*db.StreamingTicket, error) {
+	streamingTicketCache.Lock()
+	defer streamingTicketCache.Unlock()
+
+	if cached, ok := streamingTicketCache.tickets[ticketID]; ok &&
+		time.Since(cached.fetchedAt) < streamingTicketCacheTTL {
+		ticket := cached.ticket
+		return &ticket, nil
 	}
 
+	ticket, err := db.FindStreamingTicket(ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, cached := range streamingTicketCache.tickets {
+		if time.Since(cached.fetchedAt) >= streamingTicketCacheTTL {
+			delete(streamingTicketCache.tickets, id)
+		}
+	}
+	streamingTicketCache.tickets[ticketID] = cachedStreamingTicket{*ticket, time.Now()}
+	return ticket, nil
+}
+
+func forgetStreamingTicket(ticketID string) {
+	streamingTicketCache.Lock()
+	delete(streamingTicketCache.tickets, ticketID)
+	streamingTicketCache.Unlock()
+}
+
+func signStreamingClaims(claims StreamingClaims) (string, error) {
 	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	secret, err := tokenSecret()
 	if err != nil {
 		return "", err
 	}
 
-	ss, err := t.SignedString([]byte(secret))
+	return t.SignedString([]byte(secret))
+}
+
+// CreateStreamingTicket creates a new ticket for a playback session and returns a JWT that gives
+// permission to stream the file until the ticket expires or is revoked.
+func CreateStreamingTicket(options StreamingTicketOptions) (string, *db.StreamingTicket, error) {
+	if options.SessionID == "" {
+		return "", nil, fmt.Errorf("a streaming ticket requires a playback session")
+	}
+
+	ticketID, err := helpers.SecureRandAlphaString(32)
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+
+	now := time.Now()
+	ticket := &db.StreamingTicket{
+		TicketID:  ticketID,
+		SessionID: options.SessionID,
+		UserID:    options.UserID,
+		FilePath:  options.FilePath,
+		ClientIP:  options.ClientIP,
+		ExpiresAt: now.Add(StreamingTicketValidity),
+	}
+	if err := db.CreateStreamingTicket(ticket); err != nil {
+		return "", nil, err
 	}
 
-	return ss, nil
+	token, err := signStreamingClaims(StreamingClaims{
+		UserID:             options.UserID,
+		FilePath:           options.FilePath,
+		SessionID:          options.SessionID,
+		TranscodingProfile: options.TranscodingProfile,
+		Deinterlace:        options.Deinterlace,
+		OptimizedVersions:  options.OptimizedVersions,
+		StandardClaims: jwt.StandardClaims{
+			Id:        ticketID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(streamingTicketMaxLifetime).Unix(),
+			Issuer:    "bss",
+		},
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, ticket, nil
+}
+
+// DeriveStreamingJWT creates a JWT for another file, e.g. an external subtitle, that belongs to
+// the same ticket as the given claims.
+func DeriveStreamingJWT(claims *StreamingClaims, fileLocator string) (string, error) {
+	derived := *claims
+	derived.FilePath = fileLocator
+	// Optimized versions belong to the file of the ticket only
+	derived.OptimizedVersions = nil
+	return signStreamingClaims(derived)
 }
 
 // ValidateStreamingJWT validates whether a JWT is still valid and allows access to the requested file.
-func ValidateStreamingJWT(tokenStr string) (*StreamingClaims, error) {
+// The ticket it belongs to must neither be expired nor revoked, and it must be used from the
+// client address it is bound to, if any.
+func ValidateStreamingJWT(tokenStr string, clientIP string) (*StreamingClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &StreamingClaims{}, jwtSecretFunc)
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*StreamingClaims); ok && token.Valid {
-		log.WithFields(log.Fields{"user": claims.UserID, "file": claims.FilePath, "expires": claims.ExpiresAt}).Debugf("Validate streaming ticket")
-		return claims, nil
+	claims, ok := token.Claims.(*StreamingClaims)
+	if !ok || !token.Valid || claims.Id == "" {
+		return nil, fmt.Errorf("could not validate ticket")
+	}
+
+	ticket, err := findStreamingTicket(claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.SessionID != claims.SessionID || ticket.UserID != claims.UserID {
+		return nil, fmt.Errorf("could not validate ticket")
 	}
+	if ticket.Revoked() {
+		return nil, fmt.Errorf("ticket has been revoked")
+	}
+	if time.Now().After(ticket.ExpiresAt) {
+		return nil, fmt.Errorf("ticket has expired")
+	}
+	if ticket.ClientIP != "" && ticket.ClientIP != clientIP {
+		return nil, fmt.Errorf("ticket is not valid for this client")
+	}
+
+	log.WithFields(log.Fields{
+		"user":    claims.UserID,
+		"file":    claims.FilePath,
+		"session": claims.SessionID,
+		"expires": ticket.ExpiresAt,
+	}).Debugf("Validate streaming ticket")
+	return claims, nil
+}
+
+// RefreshStreamingTicket extends the validity of a ticket so that long playback sessions keep
+// working with the URLs the client already has.
+func RefreshStreamingTicket(ticket *db.StreamingTicket) error {
+	if ticket.Revoked() {
+		return fmt.Errorf("ticket has been revoked")
+	}
+
+	maxExpiresAt := ticket.CreatedAt.Add(streamingTicketMaxLifetime)
+	expiresAt := time.Now().Add(StreamingTicketValidity)
+	if expiresAt.After(maxExpiresAt) {
+		expiresAt = maxExpiresAt
+	}
+	if !expiresAt.After(time.Now()) {
+		return fmt.Errorf("ticket can't be refreshed anymore, please create a new one")
+	}
+
+	defer forgetStreamingTicket(ticket.TicketID)
+	return db.ExtendStreamingTicket(ticket, expiresAt)
+}
 
-	return nil, fmt.Errorf("could not validate ticket")
+// RevokeStreamingTicket immediately stops all tokens of the ticket from working.
+func RevokeStreamingTicket(ticket *db.StreamingTicket) error {
+	defer forgetStreamingTicket(ticket.TicketID)
+	return db.RevokeStreamingTicket(ticket)
 }
 
 func jwtSecretFunc(token *jwt.Token) (interface{}, error) {
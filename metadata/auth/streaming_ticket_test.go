@@ -3,16 +3,24 @@ package auth
 import (
 	"fmt"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
 )
 
 func TestStreamingTicket(t *testing.T) {
+	app.NewTestingMDContext(nil)
+
 	path := "/users/maran/does/not/exist.mkv"
 	secret, err := tokenSecret()
 	if err != nil {
 		t.Errorf("No secret could be generated: %s", err)
 	}
 	fmt.Println("Secret:", secret)
-	token, err := CreateStreamingJWT(1, path)
+	token, _, err := CreateStreamingTicket(StreamingTicketOptions{UserID: 1, FilePath: path, SessionID: "abc"})
 	if err != nil {
 		t.Errorf("Expected error to be nil, got error instead: %s", err)
 	}
@@ -24,9 +32,9 @@ func TestStreamingTicket(t *testing.T) {
 		t.Errorf("JWT Secret somehow changed, something is wrong! Secret %s and %s", newsecret, secret)
 	}
 
-	claim, err := ValidateStreamingJWT(token)
+	claim, err := ValidateStreamingJWT(token, "192.0.2.1")
 	if err != nil {
-		t.Errorf("Could not validate created token: %s", err)
+		t.Fatalf("Could not validate created token: %s", err)
 	}
 	if claim.FilePath != path {
 		t.Errorf("Filepath was not correct in token. Expected %s but got %s", path, claim.FilePath)
@@ -36,4 +44,108 @@ func TestStreamingTicket(t *testing.T) {
 		t.Errorf("User was not valid expected %d got %d", 1, claim.UserID)
 	}
 
+	if claim.SessionID != "abc" {
+		t.Errorf("Session was not valid expected %s got %s", "abc", claim.SessionID)
+	}
+}
+
+func TestStreamingTicket_Revoke(t *testing.T) {
+	app.NewTestingMDContext(nil)
+
+	token, ticket, err := CreateStreamingTicket(StreamingTicketOptions{UserID: 1, FilePath: "/movie.mkv", SessionID: "revoke"})
+	require.NoError(t, err)
+
+	claims, err := ValidateStreamingJWT(token, "192.0.2.1")
+	require.NoError(t, err)
+	subtitleToken, err := DeriveStreamingJWT(claims, "/movie.srt")
+	require.NoError(t, err)
+	subtitleClaims, err := ValidateStreamingJWT(subtitleToken, "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "/movie.srt", subtitleClaims.FilePath)
+
+	require.NoError(t, RevokeStreamingTicket(ticket))
+	_, err = ValidateStreamingJWT(token, "192.0.2.1")
+	assert.Error(t, err)
+	_, err = ValidateStreamingJWT(subtitleToken, "192.0.2.1")
+	assert.Error(t, err)
+
+	assert.Error(t, RefreshStreamingTicket(ticket))
+}
+
+func TestStreamingTicket_Refresh(t *testing.T) {
+	app.NewTestingMDContext(nil)
+
+	token, ticket, err := CreateStreamingTicket(StreamingTicketOptions{UserID: 1, FilePath: "/movie.mkv", SessionID: "refresh"})
+	require.NoError(t, err)
+
+	// Let the ticket expire
+	require.NoError(t, db.ExtendStreamingTicket(ticket, time.Now().Add(-time.Minute)))
+	forgetStreamingTicket(ticket.TicketID)
+	_, err = ValidateStreamingJWT(token, "192.0.2.1")
+	assert.Error(t, err)
+
+	require.NoError(t, RefreshStreamingTicket(ticket))
+	assert.True(t, ticket.ExpiresAt.After(time.Now().Add(StreamingTicketValidity-time.Minute)))
+	_, err = ValidateStreamingJWT(token, "192.0.2.1")
+	assert.NoError(t, err)
+}
+
+func TestStreamingTicket_ClientIP(t *testing.T) {
+	app.NewTestingMDContext(nil)
+
+	token, _, err := CreateStreamingTicket(StreamingTicketOptions{
+		UserID:    1,
+		FilePath:  "/movie.mkv",
+		SessionID: "bound",
+		ClientIP:  "192.0.2.1",
+	})
+	require.NoError(t, err)
+
+	_, err = ValidateStreamingJWT(token, "192.0.2.1")
+	assert.NoError(t, err)
+	_, err = ValidateStreamingJWT(token, "198.51.100.7")
+	assert.Error(t, err)
+}
+
+func TestStreamingTicket_TranscodingProfile(t *testing.T) {
+	app.NewTestingMDContext(nil)
+
+	token, _, err := CreateStreamingTicket(StreamingTicketOptions{
+		UserID:             1,
+		FilePath:           "/movie.mkv",
+		SessionID:          "profile",
+		TranscodingProfile: "mobile",
+		Deinterlace:        "ivtc",
+		OptimizedVersions:  []string{"local#/optimized/movie.mp4"},
+	})
+	require.NoError(t, err)
+
+	claims, err := ValidateStreamingJWT(token, "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "mobile", claims.TranscodingProfile)
+	assert.Equal(t, "ivtc", claims.Deinterlace)
+	assert.Equal(t, []string{"local#/optimized/movie.mp4"}, claims.OptimizedVersions)
+
+	derived, err := DeriveStreamingJWT(claims, "/movie.en.srt")
+	require.NoError(t, err)
+	claims, err = ValidateStreamingJWT(derived, "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, "mobile", claims.TranscodingProfile)
+	assert.Empty(t, claims.OptimizedVersions)
+}
+
+func TestInternalFileToken(t *testing.T) {
+	token := CreateInternalFileToken("rclone#remote/movie.mkv")
+
+	fileLocator, err := ValidateInternalFileToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, "rclone#remote/movie.mkv", fileLocator)
+
+	// A token for another file can't be made from an existing one
+	forged := CreateInternalFileToken("rclone#remote/other.mkv")
+	_, err = ValidateInternalFileToken(forged[:len(forged)-4] + token[len(token)-4:])
+	assert.Error(t, err)
+
+	_, err = ValidateInternalFileToken("garbage")
+	assert.Error(t, err)
 }
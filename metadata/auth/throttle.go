@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Throttles for authentication attempts. Usernames are locked out quicker than IPs as a single
+// address might be shared by several legitimate users.
+var (
+	usernameThrottle = NewLoginThrottle(5, 30*time.Second, time.Hour)
+	ipThrottle       = NewLoginThrottle(20, 30*time.Second, time.Hour)
+)
+
+type failedAttempts struct {
+	count       int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// LoginThrottle keeps track of failed authentication attempts per key (e.g. an IP or
+// username) and locks keys out with exponentially increasing durations.
+type LoginThrottle struct {
+	mutex    sync.Mutex
+	attempts map[string]*failedAttempts
+
+	// freeAttempts is the number of failures allowed before lockouts start.
+	freeAttempts int
+	baseLockout  time.Duration
+	maxLockout   time.Duration
+
+	now func() time.Time
+}
+
+// NewLoginThrottle creates a throttle that allows freeAttempts failures, then locks out for
+// baseLockout, doubling with every further failure up to maxLockout.
+func NewLoginThrottle(freeAttempts int, baseLockout time.Duration, maxLockout time.Duration) *LoginThrottle {
+	return &LoginThrottle{
+		attempts:     map[string]*failedAttempts{},
+		freeAttempts: freeAttempts,
+		baseLockout:  baseLockout,
+		maxLockout:   maxLockout,
+		now:          time.Now,
+	}
+}
+
+// Check returns how long the given key is still locked out, zero if it is allowed to try.
+func (t *LoginThrottle) Check(key string) time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	a, ok := t.attempts[key]
+	if !ok {
+		return 0
+	}
+	if wait := a.lockedUntil.Sub(t.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Fail records a failed attempt for the given key.
+func (t *LoginThrottle) Fail(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+	t.prune(now)
+
+	a, ok := t.attempts[key]
+	if !ok {
+		a = &failedAttempts{}
+		t.attempts[key] = a
+	}
+	a.count++
+	a.lastFailure = now
+
+	if a.count > t.freeAttempts {
+		exponent := float64(a.count - t.freeAttempts - 1)
+		lockout := time.Duration(float64(t.baseLockout) * math.Pow(2, exponent))
+		if lockout > t.maxLockout || lockout <= 0 {
+			lockout = t.maxLockout
+		}
+		a.lockedUntil = now.Add(lockout)
+	}
+}
+
+// Succeed forgets all failed attempts for the given key.
+func (t *LoginThrottle) Succeed(key string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	delete(t.attempts, key)
+}
+
+// prune removes keys that haven't failed for longer than the maximum lockout.
+func (t *LoginThrottle) prune(now time.Time) {
+	for key, a := range t.attempts {
+		if now.Sub(a.lastFailure) > t.maxLockout && now.After(a.lockedUntil) {
+			delete(t.attempts, key)
+		}
+	}
+}
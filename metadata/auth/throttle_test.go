@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func TestLoginThrottle(t *testing.T) {
+	now := time.Unix(1000, 0)
+	throttle := NewLoginThrottle(2, 10*time.Second, time.Minute)
+	throttle.now = func() time.Time { return now }
+
+	throttle.Fail("key")
+	throttle.Fail("key")
+	assert.Zero(t, throttle.Check("key"))
+
+	throttle.Fail("key")
+	assert.Equal(t, 10*time.Second, throttle.Check("key"))
+	throttle.Fail("key")
+	assert.Equal(t, 20*time.Second, throttle.Check("key"))
+	throttle.Fail("key")
+	assert.Equal(t, 40*time.Second, throttle.Check("key"))
+	throttle.Fail("key")
+	assert.Equal(t, time.Minute, throttle.Check("key"))
+
+	assert.Zero(t, throttle.Check("other"))
+
+	now = now.Add(2 * time.Minute)
+	assert.Zero(t, throttle.Check("key"))
+
+	throttle.Fail("key")
+	throttle.Succeed("key")
+	throttle.Fail("key")
+	assert.Zero(t, throttle.Check("key"))
+}
+
+func TestUserHandler_Throttled(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	db.CreateUser("throttled", "testtest", false)
+
+	attempt := func(password string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(userRequest{Username: "throttled", Password: password})
+		req := httptest.NewRequest(http.MethodPost, "/v1/auth", bytes.NewReader(body))
+		req.RemoteAddr = "198.51.100.7:4321"
+		rw := httptest.NewRecorder()
+		UserHandler(rw, req)
+		return rw
+	}
+
+	for i := 0; i <= usernameThrottle.freeAttempts; i++ {
+		assert.EqualValues(t, http.StatusUnauthorized, attempt("wrong").Code)
+	}
+
+	// Even the right password is refused during the lockout
+	rw := attempt("testtest")
+	assert.EqualValues(t, http.StatusTooManyRequests, rw.Code)
+	assert.NotEmpty(t, rw.Header().Get("Retry-After"))
+
+	failed := db.FindAuditLogEntries(db.AuditLogFilter{Event: db.AuditEventLoginFailed}, db.QueryDetails{Limit: 50})
+	assert.Len(t, failed, usernameThrottle.freeAttempts+1)
+	assert.Equal(t, "198.51.100.7", failed[0].IP)
+	throttled := db.FindAuditLogEntries(db.AuditLogFilter{Event: db.AuditEventLoginThrottled}, db.QueryDetails{Limit: 50})
+	assert.Len(t, throttled, 1)
+}
@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// TOTP parameters, these are the defaults that all common authenticator apps support.
+const (
+	TOTPDigits = 6
+	TOTPPeriod = 30 * time.Second
+	// totpSkew is the number of periods before and after the current one that are accepted
+	// to allow for clock drift between server and authenticator.
+	totpSkew = 1
+	// totpIssuer is displayed in authenticator apps.
+	totpIssuer = "Olaris"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewTOTPSecret generates a new random base32-encoded TOTP secret.
+func NewTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPProvisioningURI returns an otpauth:// URI that can be rendered as a QR code
+// and scanned by authenticator apps.
+func TOTPProvisioningURI(username string, secret string) string {
+	label := url.PathEscape(fmt.Sprintf("%s:%s", totpIssuer, username))
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", totpIssuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprintf("%d", TOTPDigits))
+	v.Set("period", fmt.Sprintf("%d", int(TOTPPeriod.Seconds())))
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, v.Encode())
+}
+
+// totpStep returns the RFC 6238 time step for the given time.
+func totpStep(t time.Time, period time.Duration) int64 {
+	return t.Unix() / int64(period.Seconds())
+}
+
+// hotp implements the HOTP algorithm from RFC 4226.
+func hotp(h func() hash.Hash, key []byte, counter int64, digits int) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(h, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, code%mod)
+}
+
+// generateTOTP implements the TOTP algorithm from RFC 6238.
+func generateTOTP(h func() hash.Hash, key []byte, t time.Time, period time.Duration, digits int) string {
+	return hotp(h, key, totpStep(t, period), digits)
+}
+
+// ValidateTOTPCode checks the given code against the base32-encoded secret at time t. Codes from
+// time steps up to and including lastStep are rejected to prevent replays. On success the time
+// step the code belongs to is returned so it can be stored as the new lastStep.
+func ValidateTOTPCode(secret string, code string, t time.Time, lastStep int64) (int64, bool) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return 0, false
+	}
+
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := totpStep(t, TOTPPeriod)
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected := hotp(sha1.New, key, step, TOTPDigits)
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// twoFactorAudience marks JWTs that only prove the password step of a two-factor login.
+const twoFactorAudience = "totp"
+
+// twoFactorClaims is handed out after a successful password check for users with two-factor
+// authentication enabled. It uses its own user ID field so it can never pass as UserClaims.
+type twoFactorClaims struct {
+	PendingUserID uint `json:"pending_user_id"`
+	jwt.StandardClaims
+}
+
+func createTwoFactorJWT(userID uint, validFor time.Duration) (string, error) {
+	claims := twoFactorClaims{
+		userID,
+		jwt.StandardClaims{
+			ExpiresAt: time.Now().Add(validFor).Unix(),
+			Issuer:    "bss",
+			Audience:  twoFactorAudience,
+		},
+	}
+
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	secret, err := tokenSecret()
+	if err != nil {
+		return "", err
+	}
+	return t.SignedString([]byte(secret))
+}
+
+func validateTwoFactorJWT(tokenStr string) (uint, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &twoFactorClaims{}, jwtSecretFunc)
+	if err != nil {
+		return 0, err
+	}
+
+	claims, ok := token.Claims.(*twoFactorClaims)
+	if !ok || !token.Valid || !claims.VerifyAudience(twoFactorAudience, true) || claims.PendingUserID == 0 {
+		return 0, fmt.Errorf("invalid two-factor token")
+	}
+	return claims.PendingUserID, nil
+}
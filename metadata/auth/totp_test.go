@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// Test vectors from RFC 6238, Appendix B.
+func TestGenerateTOTP_RFC6238(t *testing.T) {
+	seeds := map[string]struct {
+		h   func() hash.Hash
+		key []byte
+	}{
+		"SHA1":   {sha1.New, []byte("12345678901234567890")},
+		"SHA256": {sha256.New, []byte("12345678901234567890123456789012")},
+		"SHA512": {sha512.New, []byte("1234567890123456789012345678901234567890123456789012345678901234")},
+	}
+
+	vectors := []struct {
+		time int64
+		mode string
+		code string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111109, "SHA256", "68084774"},
+		{1111111109, "SHA512", "25091201"},
+		{1111111111, "SHA1", "14050471"},
+		{1111111111, "SHA256", "67062674"},
+		{1111111111, "SHA512", "99943326"},
+		{1234567890, "SHA1", "89005924"},
+		{1234567890, "SHA256", "91819424"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{2000000000, "SHA256", "90698825"},
+		{2000000000, "SHA512", "38618901"},
+		{20000000000, "SHA1", "65353130"},
+		{20000000000, "SHA256", "77737706"},
+		{20000000000, "SHA512", "47863826"},
+	}
+
+	for _, v := range vectors {
+		seed := seeds[v.mode]
+		code := generateTOTP(seed.h, seed.key, time.Unix(v.time, 0), 30*time.Second, 8)
+		assert.Equal(t, v.code, code, "T=%d mode=%s", v.time, v.mode)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+	// The last six digits of the RFC vector, as used by authenticator apps.
+	code := "050471"
+
+	step, ok := ValidateTOTPCode(secret, code, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, totpStep(now, TOTPPeriod), step)
+
+	// Small clock drift is fine
+	_, ok = ValidateTOTPCode(secret, code, now.Add(TOTPPeriod), 0)
+	assert.True(t, ok)
+	_, ok = ValidateTOTPCode(secret, code, now.Add(5*TOTPPeriod), 0)
+	assert.False(t, ok)
+
+	// Codes can't be replayed
+	_, ok = ValidateTOTPCode(secret, code, now, step)
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTPCode(secret, "000000", now, 0)
+	assert.False(t, ok)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("animazing", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Olaris:animazing?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Olaris")
+}
+
+func login(t *testing.T, req userRequest) map[string]interface{} {
+	body, _ := json.Marshal(req)
+	rw := httptest.NewRecorder()
+	UserHandler(rw, httptest.NewRequest(http.MethodPost, "/v1/auth", bytes.NewReader(body)))
+
+	res := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &res))
+	return res
+}
+
+func TestUserHandler_TwoFactor(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	user, _ := db.CreateUser("test", "testtest", false)
+
+	secret, _ := NewTOTPSecret()
+	require.NoError(t, db.SetPendingTOTPSecret(user.ID, secret))
+	codes, err := db.EnableTOTP(user.ID, 0)
+	require.NoError(t, err)
+
+	// The password alone is not enough anymore
+	res := login(t, userRequest{Username: "test", Password: "testtest"})
+	assert.Equal(t, true, res["totp_required"])
+	assert.Nil(t, res["jwt"])
+	totpToken := res["totp_token"].(string)
+
+	// A pending two-factor token is no login token
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add("Authorization", "Bearer "+totpToken)
+	rw := httptest.NewRecorder()
+	MiddleWare(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).ServeHTTP(rw, req)
+	assert.EqualValues(t, http.StatusUnauthorized, rw.Result().StatusCode)
+
+	res = login(t, userRequest{TOTPToken: totpToken, TOTPCode: "000000"})
+	assert.Equal(t, true, res["has_error"])
+
+	key, _ := totpEncoding.DecodeString(secret)
+	code := generateTOTP(sha1.New, key, time.Now(), TOTPPeriod, TOTPDigits)
+	res = login(t, userRequest{TOTPToken: totpToken, TOTPCode: code})
+	assert.NotEmpty(t, res["jwt"])
+
+	// Recovery codes work in a single step, but only once
+	res = login(t, userRequest{Username: "test", Password: "testtest", TOTPCode: codes[0]})
+	assert.NotEmpty(t, res["jwt"])
+	res = login(t, userRequest{Username: "test", Password: "testtest", TOTPCode: codes[0]})
+	assert.Equal(t, true, res["has_error"])
+}
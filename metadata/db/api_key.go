@@ -0,0 +1,216 @@
+package db
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"gitlab.com/olaris/olaris-server/helpers"
+)
+
+// Scopes that can be granted to an API key.
+const (
+	// APIKeyScopeMetadataRead allows read-only access to the metadata API.
+	APIKeyScopeMetadataRead = "metadata:read"
+	// APIKeyScopeLibraryRescan allows triggering library rescans.
+	APIKeyScopeLibraryRescan = "library:rescan"
+	// APIKeyScopePlayStateWrite allows creating and updating playstates.
+	APIKeyScopePlayStateWrite = "playstate:write"
+	// APIKeyScopeAdmin grants all the admin rights of the key's owner.
+	APIKeyScopeAdmin = "admin"
+)
+
+// apiKeyPrefix makes keys recognisable, for example for secret scanners.
+const apiKeyPrefix = "olk"
+
+// How often the last used timestamp of a key gets written to the database.
+const apiKeyLastUsedResolution = time.Minute
+
+// AllAPIKeyScopes lists all valid API key scopes.
+var AllAPIKeyScopes = []string{
+	APIKeyScopeMetadataRead,
+	APIKeyScopeLibraryRescan,
+	APIKeyScopePlayStateWrite,
+	APIKeyScopeAdmin,
+}
+
+// adminAPIKeyScopes are scopes that only have an effect if the key's owner is an admin.
+var adminAPIKeyScopes = []string{APIKeyScopeLibraryRescan, APIKeyScopeAdmin}
+
+// APIKey is a long-lived credential for automation that acts on behalf of a user
+// with a limited set of scopes.
+type APIKey struct {
+	gorm.Model
+	Name string `gorm:"not null"`
+	// Identifier is the public part of the key, used to look it up.
+	Identifier string `gorm:"not null;unique_index"`
+	// KeyHash is the SHA256 hash of the secret part of the key.
+	KeyHash string `gorm:"not null"`
+	// Scopes is a comma-separated list of granted scopes.
+	Scopes      string
+	UserID      uint
+	User        *User
+	CreatedByID uint
+	LastUsedAt  *time.Time
+	RevokedAt   *time.Time
+}
+
+// ScopeList returns the scopes granted to the key.
+func (key *APIKey) ScopeList() []string {
+	if key.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(key.Scopes, ",")
+}
+
+// EffectiveScopes returns the scopes the key can use given the current rights of its owner.
+func (key *APIKey) EffectiveScopes(owner *User) []string {
+	var scopes []string
+	for _, scope := range key.ScopeList() {
+		if !owner.Admin && containsScope(adminAPIKeyScopes, scope) {
+			continue
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes
+}
+
+// Revoked returns true if the key can no longer be used.
+func (key *APIKey) Revoked() bool {
+	return key.RevokedAt != nil
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashAPIKeySecret(secret string) string {
+	h := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(h[:])
+}
+
+// ValidateAPIKeyScopes returns an error if any of the given scopes is unknown.
+func ValidateAPIKeyScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !containsScope(AllAPIKeyScopes, scope) {
+			return fmt.Errorf("unknown scope '%s'", scope)
+		}
+	}
+	return nil
+}
+
+// CreateAPIKey creates a new API key for the given user. The returned string is the
+// only copy of the full key, it can't be recovered later.
+func CreateAPIKey(name string, userID uint, createdByID uint, scopes []string) (*APIKey, string, error) {
+	if name == "" {
+		return nil, "", fmt.Errorf("API keys need a name")
+	}
+	if err := ValidateAPIKeyScopes(scopes); err != nil {
+		return nil, "", err
+	}
+
+	owner, err := FindUser(userID)
+	if err != nil {
+		return nil, "", fmt.Errorf("owner of the API key could not be found")
+	}
+	if !owner.Admin {
+		for _, scope := range scopes {
+			if containsScope(adminAPIKeyScopes, scope) {
+				return nil, "", fmt.Errorf("scope '%s' can only be granted to admins", scope)
+			}
+		}
+	}
+
+	identifier, err := helpers.SecureRandAlphaString(12)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := helpers.SecureRandAlphaString(40)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := APIKey{
+		Name:        name,
+		Identifier:  identifier,
+		KeyHash:     hashAPIKeySecret(secret),
+		Scopes:      strings.Join(scopes, ","),
+		UserID:      userID,
+		CreatedByID: createdByID,
+	}
+	if err := db.Create(&key).Error; err != nil {
+		return nil, "", err
+	}
+
+	return &key, fmt.Sprintf("%s_%s_%s", apiKeyPrefix, identifier, secret), nil
+}
+
+// FindAPIKeyByToken returns the active API key matching the given full key.
+func FindAPIKeyByToken(token string) (*APIKey, error) {
+	parts := strings.Split(token, "_")
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return nil, fmt.Errorf("malformed API key")
+	}
+
+	var key APIKey
+	if err := db.Take(&key, "identifier = ?", parts[1]).Error; err != nil {
+		return nil, fmt.Errorf("invalid API key")
+	}
+
+	hash := hashAPIKeySecret(parts[2])
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(key.KeyHash)) != 1 {
+		return nil, fmt.Errorf("invalid API key")
+	}
+	if key.Revoked() {
+		return nil, fmt.Errorf("API key has been revoked")
+	}
+
+	return &key, nil
+}
+
+// TouchAPIKey records that the key has been used. To avoid a write on every request
+// the timestamp is only updated once per apiKeyLastUsedResolution.
+func TouchAPIKey(key *APIKey) error {
+	now := time.Now()
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < apiKeyLastUsedResolution {
+		return nil
+	}
+	key.LastUsedAt = &now
+	return db.Model(key).UpdateColumn("last_used_at", now).Error
+}
+
+// AllAPIKeys returns all API keys, including revoked ones.
+func AllAPIKeys() (keys []APIKey) {
+	db.Order("created_at DESC").Find(&keys)
+	return keys
+}
+
+// RevokeAPIKey revokes the key with the given ID.
+func RevokeAPIKey(id uint) (*APIKey, error) {
+	var key APIKey
+	if err := db.Take(&key, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("API key could not be found")
+	}
+	if key.Revoked() {
+		return &key, nil
+	}
+
+	now := time.Now()
+	key.RevokedAt = &now
+	if err := db.Model(&key).UpdateColumn("revoked_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
@@ -0,0 +1,74 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func TestCreateAPIKey(t *testing.T) {
+	defer setupTest(t)()
+
+	user, err := db.CreateUser("automation", "testtest", false)
+	require.NoError(t, err)
+
+	key, token, err := db.CreateAPIKey("backup script", user.ID, 0,
+		[]string{db.APIKeyScopeMetadataRead, db.APIKeyScopePlayStateWrite})
+	require.NoError(t, err)
+	assert.NotContains(t, key.KeyHash, token)
+
+	found, err := db.FindAPIKeyByToken(token)
+	require.NoError(t, err)
+	assert.Equal(t, key.ID, found.ID)
+	assert.Equal(t, []string{db.APIKeyScopeMetadataRead, db.APIKeyScopePlayStateWrite}, found.ScopeList())
+
+	_, err = db.FindAPIKeyByToken(token + "x")
+	assert.Error(t, err)
+	_, err = db.FindAPIKeyByToken("garbage")
+	assert.Error(t, err)
+}
+
+func TestCreateAPIKey_InvalidScopes(t *testing.T) {
+	defer setupTest(t)()
+
+	user, _ := db.CreateUser("automation", "testtest", false)
+
+	_, _, err := db.CreateAPIKey("no scopes", user.ID, 0, []string{})
+	assert.Error(t, err)
+
+	_, _, err = db.CreateAPIKey("unknown scope", user.ID, 0, []string{"everything"})
+	assert.Error(t, err)
+
+	// Only admins can hand out admin scopes
+	_, _, err = db.CreateAPIKey("admin scope", user.ID, 0, []string{db.APIKeyScopeAdmin})
+	assert.Error(t, err)
+}
+
+func TestAPIKey_EffectiveScopes(t *testing.T) {
+	key := db.APIKey{Scopes: "metadata:read,library:rescan,admin"}
+
+	assert.Equal(t, []string{"metadata:read", "library:rescan", "admin"},
+		key.EffectiveScopes(&db.User{Admin: true}))
+	assert.Equal(t, []string{"metadata:read"},
+		key.EffectiveScopes(&db.User{Admin: false}))
+}
+
+func TestRevokeAPIKey(t *testing.T) {
+	defer setupTest(t)()
+
+	user, _ := db.CreateUser("automation", "testtest", true)
+	key, token, err := db.CreateAPIKey("rescan", user.ID, user.ID, []string{db.APIKeyScopeLibraryRescan})
+	require.NoError(t, err)
+
+	require.NoError(t, db.TouchAPIKey(key))
+	found, _ := db.FindAPIKeyByToken(token)
+	assert.NotNil(t, found.LastUsedAt)
+
+	_, err = db.RevokeAPIKey(key.ID)
+	require.NoError(t, err)
+
+	_, err = db.FindAPIKeyByToken(token)
+	assert.Error(t, err)
+}
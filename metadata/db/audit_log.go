@@ -0,0 +1,62 @@
+package db
+
+import (
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+)
+
+// Events recorded in the audit log.
+const (
+	AuditEventLogin          = "login"
+	AuditEventLoginFailed    = "login_failed"
+	AuditEventLoginThrottled = "login_throttled"
+	AuditEventInviteRedeemed = "invite_redeemed"
+	AuditEventInviteFailed   = "invite_failed"
+	AuditEventUserDeleted    = "user_deleted"
+	AuditEventAdminMutation  = "admin_mutation"
+	AuditEventPasswordChange = "password_changed"
+	AuditEventPasswordReset  = "password_reset"
+	AuditEventUserRenamed    = "user_renamed"
+)
+
+// AuditLogEntry records a security relevant event such as a login.
+type AuditLogEntry struct {
+	gorm.Model
+	Event string `gorm:"not null;index"`
+	// UserID is the user that performed the action, 0 if unknown.
+	UserID uint `gorm:"index"`
+	// Username as supplied by the client, also set for failed logins of unknown users.
+	Username string
+	IP       string
+	// Action is the mutation or other operation that was performed.
+	Action string
+	// Details holds a human readable description of the event.
+	Details string
+}
+
+// AuditLogFilter narrows down the entries returned by FindAuditLogEntries.
+type AuditLogFilter struct {
+	Event  string
+	UserID uint
+}
+
+// AddAuditLogEntry persists an entry to the audit log. Failures are logged but not returned,
+// the audit log should never prevent the action itself.
+func AddAuditLogEntry(entry *AuditLogEntry) {
+	if err := db.Create(entry).Error; err != nil {
+		log.WithError(err).WithField("event", entry.Event).Warnln("Could not write audit log entry")
+	}
+}
+
+// FindAuditLogEntries returns audit log entries, newest first.
+func FindAuditLogEntries(filter AuditLogFilter, qd QueryDetails) (entries []AuditLogEntry) {
+	q := db.Order("created_at DESC, id DESC")
+	if filter.Event != "" {
+		q = q.Where("event = ?", filter.Event)
+	}
+	if filter.UserID != 0 {
+		q = q.Where("user_id = ?", filter.UserID)
+	}
+	q.Offset(qd.Offset).Limit(qd.Limit).Find(&entries)
+	return entries
+}
@@ -6,4 +6,9 @@ type BaseItem struct {
 	Overview     string `gorm:"type:text"`
 	BackdropPath string
 	PosterPath   string
-}
\ No newline at end of file
+	// Placeholders shown by clients while the artwork loads.
+	PosterBlurHash   string
+	PosterColor      string
+	BackdropBlurHash string
+	BackdropColor    string
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Owner types of chapters, the tables of the files they belong to.
+const (
+	chapterOwnerMovieFile   = "movie_files"
+	chapterOwnerEpisodeFile = "episode_files"
+)
+
+// Chapter is a chapter marker of a movie or episode file.
+type Chapter struct {
+	gorm.Model
+	OwnerID   uint
+	OwnerType string
+
+	// Index is the position of the chapter in the file, starting at 0.
+	Index int
+	Start time.Duration `gorm:"column:start_time"`
+	End   time.Duration `gorm:"column:end_time"`
+	// Title is empty if the file doesn't name its chapters.
+	Title string
+}
+
+func findChapters(ownerType string, ownerID uint) (chapters []Chapter) {
+	db.Where("owner_type = ? AND owner_id = ?", ownerType, ownerID).
+		Order("start_time").
+		Find(&chapters)
+	return chapters
+}
+
+// FindChaptersForMovieFile returns the chapters of the movie file in order.
+func FindChaptersForMovieFile(file *MovieFile) []Chapter {
+	return findChapters(chapterOwnerMovieFile, file.ID)
+}
+
+// FindChaptersForEpisodeFile returns the chapters of the episode file in order.
+func FindChaptersForEpisodeFile(file *EpisodeFile) []Chapter {
+	return findChapters(chapterOwnerEpisodeFile, file.ID)
+}
+
+func replaceChapters(ownerType string, ownerID uint, chapters []Chapter) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(Chapter{}, "owner_type = ? AND owner_id = ?", ownerType, ownerID).Error; err != nil {
+			return err
+		}
+		for i := range chapters {
+			chapters[i].ID = 0
+			chapters[i].OwnerType = ownerType
+			chapters[i].OwnerID = ownerID
+			if err := tx.Create(&chapters[i]).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Table(ownerType).Where("id = ?", ownerID).Update("chapters_probed", true).Error
+	})
+}
+
+// ReplaceMovieFileChapters stores the chapters of an existing movie file, replacing any it had.
+func ReplaceMovieFileChapters(file *MovieFile, chapters []Chapter) error {
+	if err := replaceChapters(chapterOwnerMovieFile, file.ID, chapters); err != nil {
+		return err
+	}
+	file.Chapters = chapters
+	file.ChaptersProbed = true
+	return nil
+}
+
+// ReplaceEpisodeFileChapters stores the chapters of an existing episode file, replacing any it had.
+func ReplaceEpisodeFileChapters(file *EpisodeFile, chapters []Chapter) error {
+	if err := replaceChapters(chapterOwnerEpisodeFile, file.ID, chapters); err != nil {
+		return err
+	}
+	file.Chapters = chapters
+	file.ChaptersProbed = true
+	return nil
+}
+
+// FindMovieFilesWithoutChapters returns the movie files in the library whose chapters haven't been
+// probed yet, e.g. because they were added before chapters were supported. The column is NULL for
+// those files as it was added later.
+func FindMovieFilesWithoutChapters(libraryID uint) (files []MovieFile) {
+	db.Where("library_id = ? AND (chapters_probed = ? OR chapters_probed IS NULL)", libraryID, false).Find(&files)
+	return files
+}
+
+// FindEpisodeFilesWithoutChapters returns the episode files in the library whose chapters haven't
+// been probed yet.
+func FindEpisodeFilesWithoutChapters(libraryID uint) (files []EpisodeFile) {
+	db.Where("library_id = ? AND (chapters_probed = ? OR chapters_probed IS NULL)", libraryID, false).Find(&files)
+	return files
+}
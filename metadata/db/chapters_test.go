@@ -0,0 +1,68 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func TestChapters(t *testing.T) {
+	defer setupTest(t)()
+
+	movieFile := db.MovieFile{MediaItem: db.MediaItem{FilePath: "local#/movie.mkv", LibraryID: 1}}
+	movieFile.Chapters = []db.Chapter{
+		{Index: 0, Start: 0, End: time.Minute, Title: "Opening"},
+		{Index: 1, Start: time.Minute, End: time.Hour},
+	}
+	movieFile.ChaptersProbed = true
+	db.SaveMovieFile(&movieFile)
+
+	oldFile := db.MovieFile{MediaItem: db.MediaItem{FilePath: "local#/old.mkv", LibraryID: 1}}
+	db.SaveMovieFile(&oldFile)
+
+	chapters := db.FindChaptersForMovieFile(&movieFile)
+	require.Len(t, chapters, 2)
+	assert.Equal(t, "Opening", chapters[0].Title)
+	assert.Equal(t, time.Hour, chapters[1].End)
+
+	missing := db.FindMovieFilesWithoutChapters(1)
+	require.Len(t, missing, 1)
+	assert.Equal(t, oldFile.ID, missing[0].ID)
+
+	require.NoError(t, db.ReplaceMovieFileChapters(&missing[0], []db.Chapter{{Index: 0, End: time.Minute}}))
+	assert.Empty(t, db.FindMovieFilesWithoutChapters(1))
+	assert.Len(t, db.FindChaptersForMovieFile(&oldFile), 1)
+
+	// Chapters of episode files are kept apart even if the IDs are the same
+	episodeFile := db.EpisodeFile{MediaItem: db.MediaItem{FilePath: "local#/episode.mkv", LibraryID: 2}}
+	db.SaveEpisodeFile(&episodeFile)
+	require.NoError(t, db.ReplaceEpisodeFileChapters(&episodeFile, nil))
+	assert.Empty(t, db.FindChaptersForEpisodeFile(&episodeFile))
+	assert.Empty(t, db.FindEpisodeFilesWithoutChapters(2))
+
+	movieFile.DeleteWithStreams()
+	assert.Empty(t, db.FindChaptersForMovieFile(&movieFile))
+}
+
+func TestFilesWithoutChapters_AddedBeforeChapters(t *testing.T) {
+	dbc := db.NewDb(db.DatabaseOptions{Connection: db.InMemory})
+	defer dbc.Close()
+
+	// Files added before chapters were supported have no value in the column
+	movieFile := db.MovieFile{MediaItem: db.MediaItem{FilePath: "local#/movie.mkv", LibraryID: 1}}
+	db.SaveMovieFile(&movieFile)
+	episodeFile := db.EpisodeFile{MediaItem: db.MediaItem{FilePath: "local#/episode.mkv", LibraryID: 1}}
+	db.SaveEpisodeFile(&episodeFile)
+	require.NoError(t, dbc.Exec("UPDATE movie_files SET chapters_probed = NULL").Error)
+	require.NoError(t, dbc.Exec("UPDATE episode_files SET chapters_probed = NULL").Error)
+
+	movieFiles := db.FindMovieFilesWithoutChapters(1)
+	require.Len(t, movieFiles, 1)
+	assert.Equal(t, movieFile.ID, movieFiles[0].ID)
+	episodeFiles := db.FindEpisodeFilesWithoutChapters(1)
+	require.Len(t, episodeFiles, 1)
+	assert.Equal(t, episodeFile.ID, episodeFiles[0].ID)
+}
@@ -108,7 +108,10 @@ func NewDb(options DatabaseOptions) *gorm.DB {
 
 var allModels = []interface{}{
 	&Movie{}, &MovieFile{}, &Library{}, &Series{}, &Season{}, &Episode{},
-	&EpisodeFile{}, &User{}, &Invite{}, &PlayState{}, &Stream{},
+	&EpisodeFile{}, &User{}, &Invite{}, &PlayState{}, &Stream{}, &APIKey{},
+	&RecoveryCode{}, &AuditLogEntry{}, &InviteRedemption{}, &LibraryGrant{},
+	&PasswordResetToken{}, &StreamingTicket{}, &Chapter{}, &Marker{},
+	&OptimizeJob{}, &Download{},
 }
 
 func initSchema(tx *gorm.DB) error {
@@ -162,6 +165,33 @@ func migrateSchema(db *gorm.DB) error {
 			Migrate: func(tx *gorm.DB) error {
 				return db.Exec("DELETE FROM movies WHERE tmdb_id = 0;").Error
 			},
+		}, {
+			// Invites can now be used multiple times, record who redeemed the existing ones.
+			ID: "2026-10-19-invite-redemptions",
+			Migrate: func(tx *gorm.DB) error {
+				type Invite struct {
+					gorm.Model
+					UserID  uint
+					MaxUses int `gorm:"not null;default:1"`
+					Uses    int `gorm:"not null;default:0"`
+				}
+				type InviteRedemption struct {
+					gorm.Model
+					InviteID uint `gorm:"index"`
+					UserID   uint `gorm:"index"`
+				}
+				if err := tx.AutoMigrate(&Invite{}, &InviteRedemption{}).Error; err != nil {
+					return err
+				}
+
+				var invites []Invite
+				tx.Where("user_id != 0").Find(&invites)
+				for _, invite := range invites {
+					tx.Create(&InviteRedemption{InviteID: invite.ID, UserID: invite.UserID})
+					tx.Model(&invite).UpdateColumn("uses", 1)
+				}
+				return nil
+			},
 		},
 	})
 
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// States of downloads.
+const (
+	DownloadQueued  = "queued"
+	DownloadRunning = "running"
+	DownloadReady   = "ready"
+	DownloadFailed  = "failed"
+)
+
+// Download is a rendition of a movie or episode file a user requested to download and watch
+// offline. It is encoded to a single MP4 file that is removed once the download expires.
+type Download struct {
+	gorm.Model
+	// UserID is the user that requested the download, only they can download it.
+	UserID uint `gorm:"index"`
+	// FileUUID is the UUID of the MovieFile or EpisodeFile the download is created from.
+	FileUUID string
+	// Profile is the transcoding profile and VideoCodec the video format to encode with.
+	Profile    string
+	VideoCodec string
+	// AudioStreamIDs and SubtitleStreamIDs are comma-separated lists of the streams of the file
+	// included in the download.
+	AudioStreamIDs    string
+	SubtitleStreamIDs string
+	State             string `gorm:"index"`
+	// Error is why the download could not be created.
+	Error string
+	// FileName is the name the download is saved as by clients.
+	FileName string
+	Size     int64
+	// ExpiresAt is when the file of the download is removed, set once it is ready.
+	ExpiresAt *time.Time
+}
+
+// Expired returns true if the download is no longer available.
+func (d *Download) Expired(now time.Time) bool {
+	return d.ExpiresAt != nil && now.After(*d.ExpiresAt)
+}
+
+// AudioStreamIDList returns the audio streams included in the download.
+func (d *Download) AudioStreamIDList() []int64 {
+	return parseStreamIDs(d.AudioStreamIDs)
+}
+
+// SubtitleStreamIDList returns the subtitle streams included in the download.
+func (d *Download) SubtitleStreamIDList() []int64 {
+	return parseStreamIDs(d.SubtitleStreamIDs)
+}
+
+// JoinStreamIDs returns the comma-separated list of stream IDs stored in downloads.
+func JoinStreamIDs(ids []int64) string {
+	var s []string
+	for _, id := range ids {
+		s = append(s, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(s, ",")
+}
+
+// parseStreamIDs never returns nil so an empty list selects no streams.
+func parseStreamIDs(s string) []int64 {
+	ids := []int64{}
+	for _, part := range strings.Split(s, ",") {
+		if id, err := strconv.ParseInt(part, 10, 64); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// CreateDownload queues a new download.
+func CreateDownload(download *Download) error {
+	download.State = DownloadQueued
+	return db.Create(download).Error
+}
+
+// SaveDownload updates a download.
+func SaveDownload(download *Download) error {
+	return db.Save(download).Error
+}
+
+// FindDownload returns the download with the given ID.
+func FindDownload(id uint) (*Download, error) {
+	var download Download
+	err := db.First(&download, id).Error
+	return &download, err
+}
+
+// FindDownloads returns the downloads of the user, newest first. Downloads of all users are
+// returned if userID is 0.
+func FindDownloads(userID uint) []Download {
+	downloads := []Download{}
+	q := db.Order("id desc")
+	if userID != 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	q.Find(&downloads)
+	return downloads
+}
+
+// NextQueuedDownload returns the oldest queued download.
+func NextQueuedDownload() (*Download, bool) {
+	var download Download
+	err := db.Where("state = ?", DownloadQueued).Order("id").First(&download).Error
+	return &download, err == nil
+}
+
+// RequeueRunningDownloads queues the downloads that were interrupted by a restart again.
+func RequeueRunningDownloads() error {
+	return db.Model(&Download{}).Where("state = ?", DownloadRunning).
+		Update("state", DownloadQueued).Error
+}
+
+// FindExpiredDownloads returns the downloads that expired before now.
+func FindExpiredDownloads(now time.Time) []Download {
+	downloads := []Download{}
+	db.Where("expires_at IS NOT NULL AND expires_at < ?", now).Find(&downloads)
+	return downloads
+}
+
+// DeleteDownload removes the download with the given ID.
+func DeleteDownload(id uint) error {
+	return db.Unscoped().Delete(&Download{}, "id = ?", id).Error
+}
@@ -0,0 +1,51 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func TestDownloads(t *testing.T) {
+	defer setupTest(t)()
+
+	first := db.Download{FileUUID: "a", UserID: 1, AudioStreamIDs: db.JoinStreamIDs([]int64{1, 2})}
+	second := db.Download{FileUUID: "b", UserID: 2}
+	require.NoError(t, db.CreateDownload(&first))
+	require.NoError(t, db.CreateDownload(&second))
+	assert.Equal(t, db.DownloadQueued, first.State)
+	assert.Equal(t, []int64{1, 2}, first.AudioStreamIDList())
+	assert.Equal(t, []int64{}, second.AudioStreamIDList(), "no streams selected")
+
+	next, ok := db.NextQueuedDownload()
+	require.True(t, ok)
+	assert.Equal(t, first.ID, next.ID, "oldest download should run first")
+
+	next.State = db.DownloadRunning
+	require.NoError(t, db.SaveDownload(next))
+	require.NoError(t, db.RequeueRunningDownloads())
+	download, err := db.FindDownload(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.DownloadQueued, download.State)
+
+	downloads := db.FindDownloads(2)
+	require.Len(t, downloads, 1)
+	assert.Equal(t, second.ID, downloads[0].ID)
+	assert.Len(t, db.FindDownloads(0), 2)
+
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	download.State, download.ExpiresAt = db.DownloadReady, &expired
+	require.NoError(t, db.SaveDownload(download))
+	assert.True(t, download.Expired(now))
+	assert.False(t, second.Expired(now), "downloads expire once they are ready")
+	expiredDownloads := db.FindExpiredDownloads(now)
+	require.Len(t, expiredDownloads, 1)
+	assert.Equal(t, first.ID, expiredDownloads[0].ID)
+
+	require.NoError(t, db.DeleteDownload(first.ID))
+	assert.Len(t, db.FindDownloads(0), 1)
+}
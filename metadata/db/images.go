@@ -0,0 +1,107 @@
+package db
+
+import "strings"
+
+// LocalImagePrefix marks image paths of artwork that was extracted from the media files instead of
+// provided by an agent.
+const LocalImagePrefix = "local:"
+
+// LocalImagePath returns the image path of extracted artwork for the item with the given UUID.
+func LocalImagePath(uuid string) string {
+	return LocalImagePrefix + uuid + ".jpg"
+}
+
+// IsLocalImagePath returns true for image paths of extracted artwork.
+func IsLocalImagePath(imagePath string) bool {
+	return strings.HasPrefix(imagePath, LocalImagePrefix)
+}
+
+// ImageInLibrary returns true if the given image path, e.g. "/abc.jpg" for TMDB images, is used by
+// a movie, series, season or episode in one of the libraries the user can access.
+func ImageInLibrary(imagePath string, userID uint) bool {
+	if imagePath == "" || imagePath == "/" || imagePath == LocalImagePrefix {
+		return false
+	}
+	libraryIDs, restricted := AccessibleLibraryIDs(userID)
+
+	var count int
+	q := db.Model(&Movie{}).Where("poster_path = ? OR backdrop_path = ?", imagePath, imagePath)
+	if restricted {
+		q = q.Where(moviesInLibrariesQuery, libraryIDs)
+	}
+	q.Count(&count)
+	if count > 0 {
+		return true
+	}
+
+	// Seasons and episodes are accessible if their series is.
+	series := db.Model(&Series{}).Select("id").Where(seriesInLibrariesQuery, libraryIDs).QueryExpr()
+	q = db.Model(&Series{}).Where("poster_path = ? OR backdrop_path = ?", imagePath, imagePath)
+	if restricted {
+		q = q.Where("id IN (?)", series)
+	}
+	q.Count(&count)
+	if count > 0 {
+		return true
+	}
+	q = db.Model(&Season{}).Where("poster_path = ? OR backdrop_path = ?", imagePath, imagePath)
+	if restricted {
+		q = q.Where("series_id IN (?)", series)
+	}
+	q.Count(&count)
+	if count > 0 {
+		return true
+	}
+	q = db.Model(&Episode{}).Where("still_path = ? OR poster_path = ? OR backdrop_path = ?", imagePath, imagePath, imagePath)
+	if restricted {
+		q = q.Where("season_id IN (SELECT id FROM seasons WHERE series_id IN (?))", series)
+	}
+	q.Count(&count)
+	return count > 0
+}
+
+// imageColumns lists the image path columns of each item type with the columns of their placeholders.
+var imageColumns = []struct {
+	model       interface{}
+	pathColumn  string
+	hashColumn  string
+	colorColumn string
+}{
+	{&Movie{}, "poster_path", "poster_blur_hash", "poster_color"},
+	{&Movie{}, "backdrop_path", "backdrop_blur_hash", "backdrop_color"},
+	{&Series{}, "poster_path", "poster_blur_hash", "poster_color"},
+	{&Series{}, "backdrop_path", "backdrop_blur_hash", "backdrop_color"},
+	{&Season{}, "poster_path", "poster_blur_hash", "poster_color"},
+	{&Episode{}, "still_path", "still_blur_hash", "still_color"},
+}
+
+// SetImagePlaceholder stores the BlurHash and dominant colour of an image on all items using it.
+func SetImagePlaceholder(imagePath string, blurHash string, color string) {
+	if imagePath == "" {
+		return
+	}
+	for _, c := range imageColumns {
+		db.Model(c.model).
+			Where(c.pathColumn+" = ?", imagePath).
+			UpdateColumns(map[string]interface{}{c.hashColumn: blurHash, c.colorColumn: color})
+	}
+}
+
+// ImagesWithoutPlaceholder returns the image paths of all items that have no placeholder yet.
+func ImagesWithoutPlaceholder() []string {
+	var imagePaths []string
+	seen := map[string]bool{}
+	for _, c := range imageColumns {
+		var paths []string
+		db.Model(c.model).
+			Where(c.pathColumn+" != '' AND ("+c.hashColumn+" IS NULL OR "+c.hashColumn+" = '')").
+			Pluck("DISTINCT "+c.pathColumn, &paths)
+		for _, p := range paths {
+			if !seen[p] {
+				seen[p] = true
+				imagePaths = append(imagePaths, p)
+			}
+		}
+	}
+	return imagePaths
+}
@@ -0,0 +1,110 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func TestImageInLibrary(t *testing.T) {
+	defer setupTest(t)()
+
+	movie := db.Movie{Title: "Test"}
+	movie.PosterPath = "/poster.jpg"
+	movie.BackdropPath = "/backdrop.jpg"
+	db.SaveMovie(&movie)
+
+	episode := db.Episode{Name: "Pilot", StillPath: "/still.jpg"}
+	db.CreateEpisode(&episode)
+	user, err := db.CreateUser("viewer", "testtest", false)
+	require.NoError(t, err)
+
+	assert.True(t, db.ImageInLibrary("/poster.jpg", user.ID))
+	assert.True(t, db.ImageInLibrary("/backdrop.jpg", user.ID))
+	assert.True(t, db.ImageInLibrary("/still.jpg", user.ID))
+	assert.False(t, db.ImageInLibrary("/elsewhere.jpg", user.ID))
+	assert.False(t, db.ImageInLibrary("/", user.ID))
+	assert.False(t, db.ImageInLibrary("/poster.jpg", user.ID+1), "unknown users can't see any images")
+}
+
+func TestImageInLibrary_LibraryGrants(t *testing.T) {
+	defer setupTest(t)()
+
+	movie := db.Movie{Title: "Test"}
+	movie.PosterPath = "/movie.jpg"
+	db.SaveMovie(&movie)
+	db.SaveMovieFile(&db.MovieFile{MediaItem: db.MediaItem{FilePath: "local#/movie.mkv", LibraryID: 1}, MovieID: movie.ID})
+
+	series := db.Series{Name: "Test"}
+	series.PosterPath = "/series.jpg"
+	db.CreateSeries(&series)
+	season := db.Season{SeasonNumber: 1, SeriesID: series.ID}
+	season.PosterPath = "/season.jpg"
+	require.NoError(t, db.SaveSeason(&season))
+	episode := db.Episode{Name: "Pilot", StillPath: "/still.jpg", SeasonID: season.ID}
+	db.CreateEpisode(&episode)
+	require.NoError(t, db.SaveEpisodeFile(&db.EpisodeFile{
+		MediaItem: db.MediaItem{FilePath: "local#/episode.mkv", LibraryID: 2}, EpisodeID: episode.ID}))
+
+	moviesOnly, err := db.CreateUser("movies", "testtest", false)
+	require.NoError(t, err)
+	require.NoError(t, db.GrantLibraries(moviesOnly.ID, []uint{1}))
+	seriesOnly, err := db.CreateUser("series", "testtest", false)
+	require.NoError(t, err)
+	require.NoError(t, db.GrantLibraries(seriesOnly.ID, []uint{2}))
+
+	assert.True(t, db.ImageInLibrary("/movie.jpg", moviesOnly.ID))
+	for _, imagePath := range []string{"/series.jpg", "/season.jpg", "/still.jpg"} {
+		assert.False(t, db.ImageInLibrary(imagePath, moviesOnly.ID), imagePath)
+		assert.True(t, db.ImageInLibrary(imagePath, seriesOnly.ID), imagePath)
+	}
+	assert.False(t, db.ImageInLibrary("/movie.jpg", seriesOnly.ID))
+}
+
+func TestImagePlaceholder(t *testing.T) {
+	defer setupTest(t)()
+
+	first := db.Movie{Title: "First"}
+	first.PosterPath = "/shared.jpg"
+	db.SaveMovie(&first)
+	second := db.Movie{Title: "Second"}
+	second.PosterPath = "/other.jpg"
+	second.BackdropPath = "/shared.jpg"
+	db.SaveMovie(&second)
+	episode := db.Episode{Name: "Pilot", StillPath: "/still.jpg"}
+	db.CreateEpisode(&episode)
+
+	assert.ElementsMatch(t, []string{"/shared.jpg", "/other.jpg", "/still.jpg"}, db.ImagesWithoutPlaceholder())
+
+	db.SetImagePlaceholder("/shared.jpg", "LEHV6nWB2yk8pyo0adR*.7kCMdnj", "#102030")
+	db.SetImagePlaceholder("/still.jpg", "L00000fQfQfQfQfQfQfQfQfQfQfQ", "#000000")
+	assert.Equal(t, []string{"/other.jpg"}, db.ImagesWithoutPlaceholder())
+
+	movie, err := db.FindMovieByUUID(first.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, "LEHV6nWB2yk8pyo0adR*.7kCMdnj", movie.PosterBlurHash)
+	assert.Equal(t, "#102030", movie.PosterColor)
+	movie, err = db.FindMovieByUUID(second.UUID)
+	assert.NoError(t, err)
+	assert.Equal(t, "", movie.PosterBlurHash)
+	assert.Equal(t, "#102030", movie.BackdropColor)
+}
+
+func TestLocalImagePath(t *testing.T) {
+	defer setupTest(t)()
+
+	path := db.LocalImagePath("abc-123")
+	assert.True(t, db.IsLocalImagePath(path))
+	assert.False(t, db.IsLocalImagePath("/poster.jpg"))
+
+	movie := db.Movie{Title: "Home video"}
+	movie.PosterPath = path
+	db.SaveMovie(&movie)
+	user, err := db.CreateUser("viewer", "testtest", false)
+	require.NoError(t, err)
+
+	assert.True(t, db.ImageInLibrary(path, user.ID))
+	assert.False(t, db.ImageInLibrary(db.LocalImagePrefix, user.ID))
+}
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/helpers"
+)
+
+// ErrInvalidInvite is returned when an invite code doesn't exist, expired, has been revoked or
+// has no uses left. We deliberately don't tell which, so codes can't be probed.
+var ErrInvalidInvite = fmt.Errorf("invite code invalid")
+
+// Invite is a model used to invite users to your server.
+type Invite struct {
+	gorm.Model
+	Code string
+	// UserID is the first user who redeemed this invite, see Redemptions for all of them.
+	UserID uint
+	User   *User
+
+	// Note is a free-form text to remember who the invite was meant for.
+	Note      string
+	ExpiresAt *time.Time
+	// MaxUses is the number of users that can sign up with this invite, 0 means unlimited.
+	// It has no default, gorm would otherwise store that instead of 0.
+	MaxUses   int `gorm:"not null"`
+	Uses      int `gorm:"not null;default:0"`
+	RevokedAt *time.Time
+	// CreatedByID is the admin who created the invite, 0 if unknown.
+	CreatedByID uint
+
+	// Admin sets whether users signing up with this invite become admins.
+	Admin bool `gorm:"not null;default:false"`
+	// LibraryIDs is a comma-separated list of libraries users of this invite get access to.
+	// If empty, users have access to all libraries.
+	LibraryIDs string
+
+	Redemptions []InviteRedemption
+}
+
+// InviteRedemption records a user that signed up with an invite.
+type InviteRedemption struct {
+	gorm.Model
+	InviteID uint `gorm:"index"`
+	UserID   uint `gorm:"index"`
+}
+
+// InviteOptions are the settings for a new invite.
+type InviteOptions struct {
+	Note        string
+	ValidFor    time.Duration
+	MaxUses     int
+	Admin       bool
+	LibraryIDs  []uint
+	CreatedByID uint
+}
+
+// Expired returns true if the invite can no longer be used because of its expiry time.
+func (invite *Invite) Expired(now time.Time) bool {
+	return invite.ExpiresAt != nil && now.After(*invite.ExpiresAt)
+}
+
+// Revoked returns true if an admin revoked the invite.
+func (invite *Invite) Revoked() bool {
+	return invite.RevokedAt != nil
+}
+
+// UsedUp returns true if the invite has no uses left.
+func (invite *Invite) UsedUp() bool {
+	return invite.MaxUses > 0 && invite.Uses >= invite.MaxUses
+}
+
+// Redeemable returns true if new users can sign up with this invite.
+func (invite *Invite) Redeemable(now time.Time) bool {
+	return invite.ID != 0 && !invite.Expired(now) && !invite.Revoked() && !invite.UsedUp()
+}
+
+// LibraryIDList returns the libraries that users of this invite get access to.
+func (invite *Invite) LibraryIDList() []uint {
+	var ids []uint
+	for _, s := range strings.Split(invite.LibraryIDs, ",") {
+		if id, err := strconv.ParseUint(s, 10, 64); err == nil {
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids
+}
+
+// CreateInvite creates an invite code that can be redeemed by new users.
+func CreateInvite(options InviteOptions) (Invite, error) {
+	if options.MaxUses < 0 {
+		return Invite{}, fmt.Errorf("maximum uses can't be negative")
+	}
+
+	var libraryIDs []string
+	for _, id := range options.LibraryIDs {
+		if lib := FindLibrary(int(id)); lib.ID == 0 {
+			return Invite{}, fmt.Errorf("library %d does not exist", id)
+		}
+		libraryIDs = append(libraryIDs, strconv.FormatUint(uint64(id), 10))
+	}
+
+	code, err := helpers.SecureRandAlphaString(24)
+	if err != nil {
+		return Invite{}, err
+	}
+
+	invite := Invite{
+		Code:        code,
+		Note:        options.Note,
+		MaxUses:     options.MaxUses,
+		Admin:       options.Admin,
+		LibraryIDs:  strings.Join(libraryIDs, ","),
+		CreatedByID: options.CreatedByID,
+	}
+	if options.ValidFor > 0 {
+		expiresAt := time.Now().Add(options.ValidFor)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	err = db.Save(&invite).Error
+	return invite, err
+}
+
+// AllInvites returns all invites from the db.
+func AllInvites() (invites []Invite) {
+	db.Preload("Redemptions").Find(&invites)
+	return invites
+}
+
+// RevokeInvite revokes the invite with the given ID so it can't be used anymore.
+func RevokeInvite(id uint) (*Invite, error) {
+	var invite Invite
+	if err := db.Preload("Redemptions").Take(&invite, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("invite could not be found")
+	}
+	if invite.Revoked() {
+		return &invite, nil
+	}
+
+	now := time.Now()
+	invite.RevokedAt = &now
+	if err := db.Model(&invite).UpdateColumn("revoked_at", now).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// reserveInviteUse atomically takes one use of the invite. It returns false if there are no uses left.
+func reserveInviteUse(invite *Invite) bool {
+	res := db.Model(&Invite{}).
+		Where("id = ? AND (max_uses = 0 OR uses < max_uses)", invite.ID).
+		UpdateColumn("uses", gorm.Expr("uses + 1"))
+	return res.Error == nil && res.RowsAffected == 1
+}
+
+func releaseInviteUse(invite *Invite) {
+	db.Model(&Invite{}).Where("id = ?", invite.ID).UpdateColumn("uses", gorm.Expr("uses - 1"))
+}
+
+// CreateUserWithCode creates a new user. The invite code will be ignored if no other users exist yet.
+func CreateUserWithCode(username string, password string, code string) (User, error) {
+	count := 0
+	db.Table("users").Count(&count)
+
+	// The first user becomes admin and doesn't need an invite.
+	if count == 0 {
+		return CreateUser(username, password, true)
+	}
+
+	invite := Invite{}
+	if code != "" {
+		db.Where("code = ?", code).First(&invite)
+	}
+
+	if !invite.Redeemable(time.Now()) || !reserveInviteUse(&invite) {
+		log.Warnln("Not a valid code or already used.")
+		return User{}, ErrInvalidInvite
+	}
+
+	user, err := CreateUser(username, password, invite.Admin)
+	if err != nil {
+		releaseInviteUse(&invite)
+		return user, err
+	}
+
+	// Without grants the user would have access to all libraries, so don't leave them behind.
+	if err := GrantLibraries(user.ID, invite.LibraryIDList()); err != nil {
+		DeleteUser(user.ID)
+		releaseInviteUse(&invite)
+		return User{}, err
+	}
+
+	db.Create(&InviteRedemption{InviteID: invite.ID, UserID: user.ID})
+	if invite.UserID == 0 {
+		db.Model(&invite).UpdateColumn("user_id", user.ID)
+	}
+
+	return user, nil
+}
@@ -0,0 +1,144 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func createInviteTestAdmin(t *testing.T) db.User {
+	admin, err := db.CreateUserWithCode("admin", "testtest", "")
+	require.NoError(t, err)
+	require.True(t, admin.Admin)
+	return admin
+}
+
+func TestCreateUserWithCode_MaxUses(t *testing.T) {
+	defer setupTest(t)()
+	admin := createInviteTestAdmin(t)
+
+	invite, err := db.CreateInvite(db.InviteOptions{MaxUses: 2, Note: "family", CreatedByID: admin.ID})
+	require.NoError(t, err)
+
+	first, err := db.CreateUserWithCode("first", "testtest", invite.Code)
+	require.NoError(t, err)
+	assert.False(t, first.Admin)
+	_, err = db.CreateUserWithCode("second", "testtest", invite.Code)
+	require.NoError(t, err)
+	_, err = db.CreateUserWithCode("third", "testtest", invite.Code)
+	assert.Equal(t, db.ErrInvalidInvite, err)
+
+	invites := db.AllInvites()
+	require.Len(t, invites, 1)
+	assert.Equal(t, 2, invites[0].Uses)
+	assert.Equal(t, first.ID, invites[0].UserID)
+	assert.Len(t, invites[0].Redemptions, 2)
+	assert.Equal(t, "family", invites[0].Note)
+}
+
+func TestDeleteUser_KeepsSharedInvites(t *testing.T) {
+	defer setupTest(t)()
+	admin := createInviteTestAdmin(t)
+
+	shared, err := db.CreateInvite(db.InviteOptions{MaxUses: 3, CreatedByID: admin.ID})
+	require.NoError(t, err)
+	first, err := db.CreateUserWithCode("first", "testtest", shared.Code)
+	require.NoError(t, err)
+	second, err := db.CreateUserWithCode("second", "testtest", shared.Code)
+	require.NoError(t, err)
+	_, err = db.CreateInvite(db.InviteOptions{MaxUses: 1, CreatedByID: first.ID})
+	require.NoError(t, err)
+
+	_, err = db.DeleteUser(first.ID)
+	require.NoError(t, err)
+
+	invites := db.AllInvites()
+	require.Len(t, invites, 1, "only the invite created by the user is deleted")
+	assert.Equal(t, shared.ID, invites[0].ID)
+	require.Len(t, invites[0].Redemptions, 1)
+	assert.Equal(t, second.ID, invites[0].Redemptions[0].UserID)
+	_, err = db.CreateUserWithCode("third", "testtest", shared.Code)
+	assert.NoError(t, err, "others can still use the invite")
+}
+
+func TestCreateUserWithCode_Unlimited(t *testing.T) {
+	defer setupTest(t)()
+	createInviteTestAdmin(t)
+
+	invite, err := db.CreateInvite(db.InviteOptions{MaxUses: 0})
+	require.NoError(t, err)
+
+	_, err = db.CreateUserWithCode("first", "testtest", invite.Code)
+	require.NoError(t, err)
+	_, err = db.CreateUserWithCode("second", "testtest", invite.Code)
+	require.NoError(t, err)
+
+	invites := db.AllInvites()
+	require.Len(t, invites, 1)
+	assert.Equal(t, 0, invites[0].MaxUses)
+	assert.Equal(t, 2, invites[0].Uses)
+}
+
+func TestCreateUserWithCode_Expired(t *testing.T) {
+	defer setupTest(t)()
+	createInviteTestAdmin(t)
+
+	invite, err := db.CreateInvite(db.InviteOptions{MaxUses: 1, ValidFor: time.Hour})
+	require.NoError(t, err)
+	require.NotNil(t, invite.ExpiresAt)
+	assert.False(t, invite.Expired(time.Now()))
+	assert.True(t, invite.Expired(time.Now().Add(2*time.Hour)))
+	assert.False(t, invite.Redeemable(time.Now().Add(2*time.Hour)))
+}
+
+func TestCreateUserWithCode_Revoked(t *testing.T) {
+	defer setupTest(t)()
+	createInviteTestAdmin(t)
+
+	invite, err := db.CreateInvite(db.InviteOptions{MaxUses: 0})
+	require.NoError(t, err)
+
+	revoked, err := db.RevokeInvite(invite.ID)
+	require.NoError(t, err)
+	assert.True(t, revoked.Revoked())
+
+	_, err = db.CreateUserWithCode("late", "testtest", invite.Code)
+	assert.Equal(t, db.ErrInvalidInvite, err)
+
+	_, err = db.RevokeInvite(invite.ID + 100)
+	assert.Error(t, err)
+}
+
+func TestCreateUserWithCode_Permissions(t *testing.T) {
+	defer setupTest(t)()
+	createInviteTestAdmin(t)
+
+	movies := db.Library{Name: "Movies", FilePath: t.TempDir()}
+	require.NoError(t, db.AddLibrary(&movies))
+	shows := db.Library{Name: "Shows", FilePath: t.TempDir(), Kind: db.MediaTypeSeries}
+	require.NoError(t, db.AddLibrary(&shows))
+
+	_, err := db.CreateInvite(db.InviteOptions{MaxUses: 1, LibraryIDs: []uint{shows.ID + 100}})
+	assert.Error(t, err)
+
+	restrictedInvite, err := db.CreateInvite(db.InviteOptions{MaxUses: 1, LibraryIDs: []uint{movies.ID}})
+	require.NoError(t, err)
+	restricted, err := db.CreateUserWithCode("restricted", "testtest", restrictedInvite.Code)
+	require.NoError(t, err)
+
+	ids, isRestricted := db.AccessibleLibraryIDs(restricted.ID)
+	assert.True(t, isRestricted)
+	assert.Equal(t, []uint{movies.ID}, ids)
+	assert.True(t, db.CanAccessLibrary(restricted.ID, movies.ID))
+	assert.False(t, db.CanAccessLibrary(restricted.ID, shows.ID))
+
+	adminInvite, err := db.CreateInvite(db.InviteOptions{MaxUses: 1, Admin: true})
+	require.NoError(t, err)
+	admin, err := db.CreateUserWithCode("coadmin", "testtest", adminInvite.Code)
+	require.NoError(t, err)
+	assert.True(t, admin.Admin)
+	assert.True(t, db.CanAccessLibrary(admin.ID, shows.ID))
+}
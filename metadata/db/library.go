@@ -36,6 +36,14 @@ type Library struct {
 	Healthy            bool `gorm:"default:'1'"`
 	RefreshStartedAt   time.Time
 	RefreshCompletedAt time.Time
+	// Trickplay enables generating thumbnails for scrub previews for all files in the library.
+	Trickplay bool
+	// DetectMarkers enables detecting intros and credits of episodes in series libraries.
+	DetectMarkers bool
+	// TranscodingProfile is used for files in the library unless the user selected their own.
+	TranscodingProfile string
+	// Deinterlace is the mode used for interlaced video in the library, empty for the default.
+	Deinterlace string
 }
 
 // IsLocal returns true when a library is based on a local filesystem
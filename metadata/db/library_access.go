@@ -0,0 +1,109 @@
+package db
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// LibraryGrant gives a user access to a library. Users without any grants have access to all
+// libraries, as soon as a user has a grant they can only access granted libraries.
+type LibraryGrant struct {
+	gorm.Model
+	UserID    uint `gorm:"unique_index:idx_unique_library_grant"`
+	LibraryID uint `gorm:"unique_index:idx_unique_library_grant"`
+}
+
+// GrantLibraries gives the user access to the given libraries.
+func GrantLibraries(userID uint, libraryIDs []uint) error {
+	for _, libraryID := range libraryIDs {
+		grant := LibraryGrant{UserID: userID, LibraryID: libraryID}
+		if err := db.Where(&grant).FirstOrCreate(&grant).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AccessibleLibraryIDs returns the libraries the user has been granted access to. If restricted
+// is false the user has access to all libraries.
+func AccessibleLibraryIDs(userID uint) (libraryIDs []uint, restricted bool) {
+	user, err := FindUser(userID)
+	if err != nil {
+		// Unknown users don't get access to anything.
+		return []uint{}, true
+	}
+	if user.Admin {
+		return nil, false
+	}
+
+	var grants []LibraryGrant
+	db.Where("user_id = ?", userID).Find(&grants)
+	if len(grants) == 0 {
+		return nil, false
+	}
+
+	for _, grant := range grants {
+		libraryIDs = append(libraryIDs, grant.LibraryID)
+	}
+	return libraryIDs, true
+}
+
+// CanAccessLibrary returns true if the user is allowed to access the given library.
+func CanAccessLibrary(userID uint, libraryID uint) bool {
+	libraryIDs, restricted := AccessibleLibraryIDs(userID)
+	if !restricted {
+		return true
+	}
+	for _, id := range libraryIDs {
+		if id == libraryID {
+			return true
+		}
+	}
+	return false
+}
+
+// MovieInLibraries returns true if the movie has files in the given libraries. All movies are
+// if libraryIDs is nil.
+func MovieInLibraries(movieID uint, libraryIDs []uint) bool {
+	if libraryIDs == nil {
+		return true
+	}
+	count := 0
+	db.Model(&Movie{}).Where("id = ?", movieID).Where(moviesInLibrariesQuery, libraryIDs).Count(&count)
+	return count > 0
+}
+
+// SeriesInLibraries returns true if the series has episode files in the given libraries. All
+// series are if libraryIDs is nil. Seasons and episodes are accessible if their series is.
+func SeriesInLibraries(seriesID uint, libraryIDs []uint) bool {
+	if libraryIDs == nil {
+		return true
+	}
+	count := 0
+	db.Model(&Series{}).Where("id = ?", seriesID).Where(seriesInLibrariesQuery, libraryIDs).Count(&count)
+	return count > 0
+}
+
+// EpisodeInLibraries returns true if the series of the episode has episode files in the given
+// libraries.
+func EpisodeInLibraries(episode *Episode, libraryIDs []uint) bool {
+	if libraryIDs == nil {
+		return true
+	}
+	count := 0
+	db.Model(&Series{}).Where("id IN (SELECT series_id FROM seasons WHERE id = ?)", episode.SeasonID).
+		Where(seriesInLibrariesQuery, libraryIDs).Count(&count)
+	return count > 0
+}
+
+// MovieIDsInLibraries returns the IDs of the movies that have files in the given libraries.
+func MovieIDsInLibraries(libraryIDs []uint) (movieIDs []uint) {
+	db.Model(&Movie{}).Where(moviesInLibrariesQuery, libraryIDs).Pluck("id", &movieIDs)
+	return movieIDs
+}
+
+// SeriesIDsInLibraries returns the IDs of the series that have episode files in the given
+// libraries.
+func SeriesIDsInLibraries(libraryIDs []uint) (seriesIDs []uint) {
+	db.Model(&Series{}).Where(seriesInLibrariesQuery, libraryIDs).Pluck("id", &seriesIDs)
+	return seriesIDs
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Kinds of markers.
+const (
+	MarkerKindIntro   = "intro"
+	MarkerKindCredits = "credits"
+)
+
+// Marker is a part of an episode file clients can offer to skip.
+type Marker struct {
+	gorm.Model
+	EpisodeFileID uint          `gorm:"index"`
+	Kind          string        `gorm:"index"`
+	Start         time.Duration `gorm:"column:start_time"`
+	End           time.Duration `gorm:"column:end_time"`
+	// Manual markers were set by an admin and are never replaced by detected ones. A manual marker
+	// without an end hides detected markers of its kind.
+	Manual bool
+}
+
+// Hidden returns true if the marker only suppresses detected markers.
+func (m *Marker) Hidden() bool {
+	return m.End == 0
+}
+
+// FindMarkersForEpisodeFile returns the markers of the file that should be shown to clients.
+func FindMarkersForEpisodeFile(episodeFileID uint) []Marker {
+	var all []Marker
+	db.Where("episode_file_id = ?", episodeFileID).Order("start_time").Find(&all)
+
+	markers := []Marker{}
+	for _, m := range all {
+		if !m.Hidden() {
+			markers = append(markers, m)
+		}
+	}
+	return markers
+}
+
+// SaveDetectedMarkers replaces the detected markers of the file and marks it as analysed. Kinds
+// with a manual marker are left alone.
+func SaveDetectedMarkers(episodeFileID uint, markers []Marker) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(Marker{}, "episode_file_id = ? AND manual = ?", episodeFileID, false).Error; err != nil {
+			return err
+		}
+
+		var manual []Marker
+		if err := tx.Where("episode_file_id = ?", episodeFileID).Find(&manual).Error; err != nil {
+			return err
+		}
+		manualKinds := map[string]bool{}
+		for _, m := range manual {
+			manualKinds[m.Kind] = true
+		}
+
+		for _, m := range markers {
+			if manualKinds[m.Kind] {
+				continue
+			}
+			m.ID = 0
+			m.EpisodeFileID = episodeFileID
+			m.Manual = false
+			if err := tx.Create(&m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&EpisodeFile{}).Where("id = ?", episodeFileID).Update("markers_analyzed", true).Error
+	})
+}
+
+// SetManualMarker replaces any marker of the given kind with a manual one. If end is zero the
+// file is marked as not having such a marker.
+func SetManualMarker(episodeFileID uint, kind string, start time.Duration, end time.Duration) (*Marker, error) {
+	marker := Marker{EpisodeFileID: episodeFileID, Kind: kind, Start: start, End: end, Manual: true}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(Marker{}, "episode_file_id = ? AND kind = ?", episodeFileID, kind).Error; err != nil {
+			return err
+		}
+		return tx.Create(&marker).Error
+	})
+	return &marker, err
+}
+
+// ResetMarker removes the markers of the given kind, including manual ones, and queues the file
+// for detection again.
+func ResetMarker(episodeFileID uint, kind string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(Marker{}, "episode_file_id = ? AND kind = ?", episodeFileID, kind).Error; err != nil {
+			return err
+		}
+		return tx.Model(&EpisodeFile{}).Where("id = ?", episodeFileID).Update("markers_analyzed", false).Error
+	})
+}
+
+// FindSeasonsWithUnanalyzedEpisodeFiles returns the IDs of the seasons in the library that have
+// episode files whose markers haven't been detected yet. The column is NULL for files added
+// before markers were supported.
+func FindSeasonsWithUnanalyzedEpisodeFiles(libraryID uint) []uint {
+	var seasonIDs []uint
+	db.Model(&EpisodeFile{}).
+		Joins("JOIN episodes ON episodes.id = episode_files.episode_id").
+		Where("episode_files.library_id = ?", libraryID).
+		Where("episode_files.markers_analyzed = ? OR episode_files.markers_analyzed IS NULL", false).
+		Where("episodes.deleted_at IS NULL").
+		Pluck("DISTINCT episodes.season_id", &seasonIDs)
+	return seasonIDs
+}
+
+// FindEpisodeFilesInSeason returns the episode files of a season in episode order. Optimized
+// versions are left out, they would match their source from start to end. Files added before
+// optimized versions existed have NULL instead of 0.
+func FindEpisodeFilesInSeason(seasonID uint) []EpisodeFile {
+	var files []EpisodeFile
+	db.Joins("JOIN episodes ON episodes.id = episode_files.episode_id").
+		Where("episodes.season_id = ? AND episodes.deleted_at IS NULL", seasonID).
+		Where("episode_files.optimized_from_id = 0 OR episode_files.optimized_from_id IS NULL").
+		Order("episodes.episode_num, episode_files.id").
+		Find(&files)
+	return files
+}
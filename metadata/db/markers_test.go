@@ -0,0 +1,96 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func TestMarkers(t *testing.T) {
+	defer setupTest(t)()
+
+	season := db.Season{SeasonNumber: 1}
+	require.NoError(t, db.SaveSeason(&season))
+	var files []db.EpisodeFile
+	for _, num := range []int{2, 1} {
+		episode := db.Episode{Name: "Episode", EpisodeNum: num, SeasonID: season.ID}
+		db.CreateEpisode(&episode)
+		file := db.EpisodeFile{MediaItem: db.MediaItem{LibraryID: 1}, EpisodeID: episode.ID}
+		require.NoError(t, db.SaveEpisodeFile(&file))
+		files = append(files, file)
+	}
+
+	assert.Equal(t, []uint{season.ID}, db.FindSeasonsWithUnanalyzedEpisodeFiles(1))
+	inSeason := db.FindEpisodeFilesInSeason(season.ID)
+	require.Len(t, inSeason, 2)
+	assert.Equal(t, files[1].ID, inSeason[0].ID, "files should be in episode order")
+
+	// A manual marker survives detection and hides detected markers of its kind
+	_, err := db.SetManualMarker(files[0].ID, db.MarkerKindCredits, 0, 0)
+	require.NoError(t, err)
+	for _, f := range files {
+		require.NoError(t, db.SaveDetectedMarkers(f.ID, []db.Marker{
+			{Kind: db.MarkerKindIntro, Start: 10 * time.Second, End: time.Minute},
+			{Kind: db.MarkerKindCredits, Start: 40 * time.Minute, End: 42 * time.Minute},
+		}))
+	}
+	assert.Empty(t, db.FindSeasonsWithUnanalyzedEpisodeFiles(1))
+
+	markers := db.FindMarkersForEpisodeFile(files[0].ID)
+	require.Len(t, markers, 1)
+	assert.Equal(t, db.MarkerKindIntro, markers[0].Kind)
+	assert.False(t, markers[0].Manual)
+	assert.Len(t, db.FindMarkersForEpisodeFile(files[1].ID), 2)
+
+	_, err = db.SetManualMarker(files[1].ID, db.MarkerKindIntro, 5*time.Second, 50*time.Second)
+	require.NoError(t, err)
+	markers = db.FindMarkersForEpisodeFile(files[1].ID)
+	require.Len(t, markers, 2)
+	assert.True(t, markers[0].Manual)
+	assert.Equal(t, 5*time.Second, markers[0].Start)
+
+	require.NoError(t, db.ResetMarker(files[0].ID, db.MarkerKindCredits))
+	assert.Equal(t, []uint{season.ID}, db.FindSeasonsWithUnanalyzedEpisodeFiles(1))
+
+	files[1].DeleteWithStreams()
+	assert.Empty(t, db.FindMarkersForEpisodeFile(files[1].ID))
+}
+
+func TestSeasonsWithUnanalyzedEpisodeFiles_AddedBeforeMarkers(t *testing.T) {
+	dbc := db.NewDb(db.DatabaseOptions{Connection: db.InMemory})
+	defer dbc.Close()
+
+	season := db.Season{SeasonNumber: 1}
+	require.NoError(t, db.SaveSeason(&season))
+	episode := db.Episode{Name: "Episode", EpisodeNum: 1, SeasonID: season.ID}
+	db.CreateEpisode(&episode)
+	file := db.EpisodeFile{MediaItem: db.MediaItem{LibraryID: 1}, EpisodeID: episode.ID}
+	require.NoError(t, db.SaveEpisodeFile(&file))
+
+	// Files added before markers were supported have no value in the column
+	require.NoError(t, dbc.Exec("UPDATE episode_files SET markers_analyzed = NULL").Error)
+	assert.Equal(t, []uint{season.ID}, db.FindSeasonsWithUnanalyzedEpisodeFiles(1))
+}
+
+func TestEpisodeFilesInSeason_AddedBeforeOptimizedVersions(t *testing.T) {
+	dbc := db.NewDb(db.DatabaseOptions{Connection: db.InMemory})
+	defer dbc.Close()
+
+	season := db.Season{SeasonNumber: 1}
+	require.NoError(t, db.SaveSeason(&season))
+	episode := db.Episode{Name: "Episode", EpisodeNum: 1, SeasonID: season.ID}
+	db.CreateEpisode(&episode)
+	file := db.EpisodeFile{MediaItem: db.MediaItem{LibraryID: 1}, EpisodeID: episode.ID}
+	require.NoError(t, db.SaveEpisodeFile(&file))
+	optimized := db.EpisodeFile{MediaItem: db.MediaItem{LibraryID: 1, OptimizedFromID: file.ID}, EpisodeID: episode.ID}
+	require.NoError(t, db.SaveEpisodeFile(&optimized))
+
+	// Files added before optimized versions existed have no value in the column
+	require.NoError(t, dbc.Exec("UPDATE episode_files SET optimized_from_id = NULL WHERE id = ?", file.ID).Error)
+	files := db.FindEpisodeFilesInSeason(season.ID)
+	require.Len(t, files, 1)
+	assert.Equal(t, file.ID, files[0].ID)
+}
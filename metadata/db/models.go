@@ -53,6 +53,17 @@ type MediaItem struct {
 	Size      int64
 	Library   Library
 	LibraryID uint
+	// ChaptersProbed is set once the chapters of the file were stored, even if it has none.
+	ChaptersProbed bool
+	// OptimizedFromID is the ID of the file this file is an optimized version of, 0 for files
+	// found in the library. OptimizedProfile is the transcoding profile it was created with.
+	OptimizedFromID  uint `gorm:"index"`
+	OptimizedProfile string
+}
+
+// IsOptimizedVersion returns true if the file was created from another one by an optimize job.
+func (m *MediaItem) IsOptimizedVersion() bool {
+	return m.OptimizedFromID != 0
 }
 
 // FindContentByUUID can retrieve episode or movie data based on a UUID.
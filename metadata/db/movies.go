@@ -17,6 +17,8 @@ type MovieFile struct {
 	Movie   Movie
 	MovieID uint
 	Streams []Stream `gorm:"polymorphic:Owner;"`
+
+	Chapters []Chapter `gorm:"polymorphic:Owner;"`
 }
 
 // Movie is used to store movie metadata information.
@@ -76,6 +78,7 @@ func (file MovieFile) DeleteWithStreams() {
 
 	// Delete all stream information since it's only for this file
 	db.Unscoped().Delete(Stream{}, "owner_id = ? AND owner_type = 'movies'", &file.ID)
+	db.Unscoped().Delete(Chapter{}, "owner_id = ? AND owner_type = ?", &file.ID, chapterOwnerMovieFile)
 	// Delete all file information
 	db.Unscoped().Delete(&file)
 
@@ -124,6 +127,8 @@ type QueryDetails struct {
 	UserID uint
 	Offset int
 	Limit  int
+	// LibraryIDs limits results to items with files in these libraries, nil means no limit.
+	LibraryIDs []uint
 }
 
 // CollectMovieInfo ensures that all relevant information for a movie is loaded
@@ -150,9 +155,11 @@ func FindAllUnidentifiedMovieFilesInLibrary(libraryID uint) ([]*MovieFile, error
 func FindAllUnidentifiedMovieFiles(qd QueryDetails) ([]MovieFile, error) {
 	var movieFiles []MovieFile
 
-	query := db.
-		Find(&movieFiles, "movie_id = 0").
-		Offset(qd.Offset).Limit(qd.Limit)
+	query := db.Offset(qd.Offset).Limit(qd.Limit)
+	if qd.LibraryIDs != nil {
+		query = query.Where("library_id IN (?)", qd.LibraryIDs)
+	}
+	query = query.Find(&movieFiles, "movie_id = 0")
 	if err := query.Error; err != nil {
 		return []MovieFile{},
 			errors.Wrap(err, "Failed to find unidentified movie files")
@@ -171,6 +178,9 @@ func FindAllMovies(qd *QueryDetails) (movies []Movie) {
 	q := db
 	if qd != nil {
 		q = q.Limit(qd.Limit).Offset(qd.Offset)
+		if qd.LibraryIDs != nil {
+			q = q.Where(moviesInLibrariesQuery, qd.LibraryIDs)
+		}
 	}
 	q = q.Find(&movies)
 	for _, movie := range movies {
@@ -204,9 +214,16 @@ func findMovie(where ...interface{}) (*Movie, error) {
 	return &movie, nil
 }
 
-// SearchMovieByTitle search movies by title.
-func SearchMovieByTitle(name string) (movies []Movie) {
-	db.Where("LOWER(original_title) LIKE LOWER(?)", "%"+name+"%").Find(&movies)
+// moviesInLibrariesQuery restricts movies to those that have files in the given libraries.
+const moviesInLibrariesQuery = "id IN (SELECT movie_id FROM movie_files WHERE library_id IN (?) AND deleted_at IS NULL)"
+
+// SearchMovieByTitle search movies by title. If libraryIDs is not nil only movies in those libraries are returned.
+func SearchMovieByTitle(name string, libraryIDs []uint) (movies []Movie) {
+	q := db.Where("LOWER(original_title) LIKE LOWER(?)", "%"+name+"%")
+	if libraryIDs != nil {
+		q = q.Where(moviesInLibrariesQuery, libraryIDs)
+	}
+	q.Find(&movies)
 	for _, movie := range movies {
 		CollectMovieInfo(&movie)
 	}
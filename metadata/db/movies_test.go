@@ -54,7 +54,7 @@ func TestSearchMovieByTitle(t *testing.T) {
 	defer setupTest(t)()
 	createMovieData()
 	var movies []db.Movie
-	movies = db.SearchMovieByTitle("max")
+	movies = db.SearchMovieByTitle("max", nil)
 	if len(movies) == 0 {
 		t.Error("Did not get any movies while searching")
 		return
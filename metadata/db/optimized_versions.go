@@ -0,0 +1,140 @@
+package db
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// States of optimize jobs.
+const (
+	OptimizeJobQueued  = "queued"
+	OptimizeJobRunning = "running"
+	OptimizeJobDone    = "done"
+	OptimizeJobFailed  = "failed"
+)
+
+// OptimizeJob is a request to store an optimized version of a movie or episode file.
+type OptimizeJob struct {
+	gorm.Model
+	// FileUUID is the UUID of the MovieFile or EpisodeFile to optimize.
+	FileUUID string `gorm:"index"`
+	// Profile is the transcoding profile and VideoCodec the video format to encode with.
+	Profile    string
+	VideoCodec string
+	// UserID is the user that requested the optimized version.
+	UserID uint
+	State  string `gorm:"index"`
+	// Error is why the job failed.
+	Error string
+	// OptimizedUUID is the UUID of the created file once the job is done.
+	OptimizedUUID string
+}
+
+// CreateOptimizeJob queues a new optimize job.
+func CreateOptimizeJob(job *OptimizeJob) error {
+	job.State = OptimizeJobQueued
+	return db.Create(job).Error
+}
+
+// SaveOptimizeJob updates an optimize job.
+func SaveOptimizeJob(job *OptimizeJob) error {
+	return db.Save(job).Error
+}
+
+// FindOptimizeJob returns the optimize job with the given ID.
+func FindOptimizeJob(id uint) (*OptimizeJob, error) {
+	var job OptimizeJob
+	err := db.First(&job, id).Error
+	return &job, err
+}
+
+// FindOptimizeJobs returns the optimize jobs requested by the user, newest first. Jobs of all
+// users are returned if userID is 0.
+func FindOptimizeJobs(userID uint) []OptimizeJob {
+	jobs := []OptimizeJob{}
+	q := db.Order("id desc")
+	if userID != 0 {
+		q = q.Where("user_id = ?", userID)
+	}
+	q.Find(&jobs)
+	return jobs
+}
+
+// FindPendingOptimizeJob returns the queued or running job that creates the same optimized version.
+func FindPendingOptimizeJob(fileUUID string, profile string, videoCodec string) (*OptimizeJob, bool) {
+	var job OptimizeJob
+	err := db.Where("file_uuid = ? AND profile = ? AND video_codec = ?", fileUUID, profile, videoCodec).
+		Where("state IN (?)", []string{OptimizeJobQueued, OptimizeJobRunning}).
+		First(&job).Error
+	return &job, err == nil
+}
+
+// NextQueuedOptimizeJob returns the oldest queued optimize job.
+func NextQueuedOptimizeJob() (*OptimizeJob, bool) {
+	var job OptimizeJob
+	err := db.Where("state = ?", OptimizeJobQueued).Order("id").First(&job).Error
+	return &job, err == nil
+}
+
+// RequeueRunningOptimizeJobs queues the jobs that were interrupted by a restart again.
+func RequeueRunningOptimizeJobs() error {
+	return db.Model(&OptimizeJob{}).Where("state = ?", OptimizeJobRunning).
+		Update("state", OptimizeJobQueued).Error
+}
+
+// DeleteOptimizeJob removes the optimize job with the given ID.
+func DeleteOptimizeJob(id uint) error {
+	return db.Unscoped().Delete(&OptimizeJob{}, "id = ?", id).Error
+}
+
+// FindOptimizedMovieFiles returns the optimized versions of the movie file.
+func FindOptimizedMovieFiles(movieFileID uint) (files []MovieFile) {
+	db.Where("optimized_from_id = ?", movieFileID).Find(&files)
+	return files
+}
+
+// FindOptimizedEpisodeFiles returns the optimized versions of the episode file.
+func FindOptimizedEpisodeFiles(episodeFileID uint) (files []EpisodeFile) {
+	db.Where("optimized_from_id = ?", episodeFileID).Find(&files)
+	return files
+}
+
+// FindOptimizedVersionPaths returns the file locators of the optimized versions of the movie or
+// episode file with the given file locator.
+func FindOptimizedVersionPaths(filePath string) []string {
+	var paths []string
+	for _, table := range []string{"movie_files", "episode_files"} {
+		var tablePaths []string
+		db.Table(table).
+			Where("optimized_from_id IN (?)",
+				db.Table(table).Select("id").Where("file_path = ? AND deleted_at IS NULL", filePath).QueryExpr()).
+			Where("deleted_at IS NULL").
+			Order("id").
+			Pluck("file_path", &tablePaths)
+		paths = append(paths, tablePaths...)
+	}
+	return paths
+}
+
+// OptimizedFromUUID returns the UUID of the file this file is an optimized version of, empty if it
+// isn't one.
+func (file *MovieFile) OptimizedFromUUID() string {
+	return optimizedFromUUID("movie_files", file.OptimizedFromID)
+}
+
+// OptimizedFromUUID returns the UUID of the file this file is an optimized version of, empty if it
+// isn't one.
+func (file *EpisodeFile) OptimizedFromUUID() string {
+	return optimizedFromUUID("episode_files", file.OptimizedFromID)
+}
+
+func optimizedFromUUID(table string, id uint) string {
+	if id == 0 {
+		return ""
+	}
+	var uuids []string
+	db.Table(table).Where("id = ? AND deleted_at IS NULL", id).Pluck("uuid", &uuids)
+	if len(uuids) == 0 {
+		return ""
+	}
+	return uuids[0]
+}
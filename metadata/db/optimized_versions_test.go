@@ -0,0 +1,72 @@
+package db_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func TestOptimizeJobs(t *testing.T) {
+	defer setupTest(t)()
+
+	first := db.OptimizeJob{FileUUID: "a", Profile: "default", VideoCodec: "h264", UserID: 1}
+	second := db.OptimizeJob{FileUUID: "b", Profile: "default", VideoCodec: "h264", UserID: 2}
+	require.NoError(t, db.CreateOptimizeJob(&first))
+	require.NoError(t, db.CreateOptimizeJob(&second))
+	assert.Equal(t, db.OptimizeJobQueued, first.State)
+
+	next, ok := db.NextQueuedOptimizeJob()
+	require.True(t, ok)
+	assert.Equal(t, first.ID, next.ID, "oldest job should run first")
+
+	next.State = db.OptimizeJobRunning
+	require.NoError(t, db.SaveOptimizeJob(next))
+	_, ok = db.FindPendingOptimizeJob("a", "default", "h264")
+	assert.True(t, ok)
+	_, ok = db.FindPendingOptimizeJob("a", "default", "hevc")
+	assert.False(t, ok)
+
+	require.NoError(t, db.RequeueRunningOptimizeJobs())
+	job, err := db.FindOptimizeJob(first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, db.OptimizeJobQueued, job.State)
+
+	assert.Len(t, db.FindOptimizeJobs(0), 2)
+	jobs := db.FindOptimizeJobs(2)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, second.ID, jobs[0].ID)
+
+	require.NoError(t, db.DeleteOptimizeJob(second.ID))
+	assert.Len(t, db.FindOptimizeJobs(0), 1)
+}
+
+func TestOptimizedVersions(t *testing.T) {
+	defer setupTest(t)()
+
+	source := db.MovieFile{MediaItem: db.MediaItem{FilePath: "local#/movies/a.mkv", LibraryID: 1}}
+	db.SaveMovieFile(&source)
+	optimized := db.MovieFile{MediaItem: db.MediaItem{
+		FilePath:         "local#/optimized/a.mp4",
+		LibraryID:        1,
+		OptimizedFromID:  source.ID,
+		OptimizedProfile: "default",
+	}}
+	db.SaveMovieFile(&optimized)
+	// Episode files with the same ID must not be mixed up with movie files
+	episodeFile := db.EpisodeFile{MediaItem: db.MediaItem{FilePath: "local#/series/a.mkv", LibraryID: 2}}
+	require.NoError(t, db.SaveEpisodeFile(&episodeFile))
+
+	assert.True(t, optimized.IsOptimizedVersion())
+	assert.False(t, source.IsOptimizedVersion())
+	assert.Equal(t, source.UUID, optimized.OptimizedFromUUID())
+	assert.Empty(t, source.OptimizedFromUUID())
+	assert.Equal(t, []string{optimized.FilePath}, db.FindOptimizedVersionPaths(source.FilePath))
+	assert.Empty(t, db.FindOptimizedVersionPaths(optimized.FilePath))
+	assert.Empty(t, db.FindOptimizedVersionPaths(episodeFile.FilePath))
+
+	files := db.FindOptimizedMovieFiles(source.ID)
+	require.Len(t, files, 1)
+	assert.Equal(t, optimized.ID, files[0].ID)
+}
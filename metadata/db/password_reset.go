@@ -0,0 +1,88 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"gitlab.com/olaris/olaris-server/helpers"
+)
+
+// ErrInvalidResetToken is returned when a password reset token doesn't exist, expired or has been used.
+var ErrInvalidResetToken = fmt.Errorf("password reset token invalid")
+
+// PasswordResetToken allows a user to set a new password once, without knowing the current one.
+// Tokens are created by admins for users that forgot their password.
+type PasswordResetToken struct {
+	gorm.Model
+	UserID uint `gorm:"index"`
+	// TokenHash is the SHA256 hash of the token, the token itself is never stored.
+	TokenHash   string `gorm:"not null;unique_index"`
+	ExpiresAt   time.Time
+	UsedAt      *time.Time
+	CreatedByID uint
+}
+
+func hashResetToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// CreatePasswordResetToken creates a one-time token the given user can use to set a new password.
+// Any older tokens for the user stop working. The returned string is the only copy of the token.
+func CreatePasswordResetToken(userID uint, createdByID uint, validFor time.Duration) (*PasswordResetToken, string, error) {
+	if _, err := FindUser(userID); err != nil {
+		return nil, "", fmt.Errorf("user could not be found")
+	}
+
+	token, err := helpers.SecureRandAlphaString(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := db.Unscoped().Where("user_id = ?", userID).Delete(PasswordResetToken{}).Error; err != nil {
+		return nil, "", err
+	}
+
+	reset := PasswordResetToken{
+		UserID:      userID,
+		TokenHash:   hashResetToken(token),
+		ExpiresAt:   time.Now().Add(validFor),
+		CreatedByID: createdByID,
+	}
+	if err := db.Create(&reset).Error; err != nil {
+		return nil, "", err
+	}
+	return &reset, token, nil
+}
+
+// ResetPasswordWithToken sets a new password for the user the token was created for and
+// invalidates the token.
+func ResetPasswordWithToken(token string, password string) (*User, error) {
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+
+	var reset PasswordResetToken
+	if err := db.Take(&reset, "token_hash = ?", hashResetToken(token)).Error; err != nil {
+		return nil, ErrInvalidResetToken
+	}
+	if reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return nil, ErrInvalidResetToken
+	}
+
+	// Mark the token as used first so it can't be redeemed twice concurrently.
+	res := db.Model(&PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", reset.ID).
+		UpdateColumn("used_at", time.Now())
+	if res.Error != nil || res.RowsAffected != 1 {
+		return nil, ErrInvalidResetToken
+	}
+
+	if err := UpdatePassword(reset.UserID, password); err != nil {
+		return nil, err
+	}
+	return FindUser(reset.UserID)
+}
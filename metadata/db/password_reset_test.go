@@ -0,0 +1,55 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func TestResetPasswordWithToken(t *testing.T) {
+	defer setupTest(t)()
+
+	user, err := db.CreateUser("forgetful", "testtest", false)
+	require.NoError(t, err)
+
+	_, oldToken, err := db.CreatePasswordResetToken(user.ID, 0, time.Hour)
+	require.NoError(t, err)
+	_, token, err := db.CreatePasswordResetToken(user.ID, 0, time.Hour)
+	require.NoError(t, err)
+
+	// Only the newest token works
+	_, err = db.ResetPasswordWithToken(oldToken, "newpassword")
+	assert.Equal(t, db.ErrInvalidResetToken, err)
+
+	_, err = db.ResetPasswordWithToken(token, "short")
+	assert.Error(t, err)
+
+	reset, err := db.ResetPasswordWithToken(token, "newpassword")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, reset.ID)
+
+	u := db.User{Username: "forgetful"}
+	assert.True(t, u.ValidPassword("newpassword"))
+	assert.False(t, u.ValidPassword("testtest"))
+
+	// Tokens are single use
+	_, err = db.ResetPasswordWithToken(token, "otherpassword")
+	assert.Equal(t, db.ErrInvalidResetToken, err)
+}
+
+func TestResetPasswordWithToken_Expired(t *testing.T) {
+	defer setupTest(t)()
+
+	user, _ := db.CreateUser("forgetful", "testtest", false)
+	_, token, err := db.CreatePasswordResetToken(user.ID, 0, -time.Minute)
+	require.NoError(t, err)
+
+	_, err = db.ResetPasswordWithToken(token, "newpassword")
+	assert.Equal(t, db.ErrInvalidResetToken, err)
+
+	_, _, err = db.CreatePasswordResetToken(user.ID+100, 0, time.Hour)
+	assert.Error(t, err)
+}
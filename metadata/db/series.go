@@ -53,6 +53,10 @@ type Episode struct {
 	StillPath    string
 	Season       *Season
 	EpisodeFiles []EpisodeFile
+
+	// Placeholders shown by clients while the still loads.
+	StillBlurHash string
+	StillColor    string
 }
 
 // TimeStamp returns a unix timestamp for the given episode.
@@ -87,6 +91,10 @@ type EpisodeFile struct {
 	EpisodeID uint
 	Episode   *Episode
 	Streams   []Stream `gorm:"polymorphic:Owner;"`
+
+	Chapters []Chapter `gorm:"polymorphic:Owner;"`
+	// MarkersAnalyzed is set once intros and credits have been detected, even if none were found.
+	MarkersAnalyzed bool
 }
 
 // GetStreams returns all streams for this file
@@ -129,6 +137,8 @@ func (file EpisodeFile) DeleteWithStreams() {
 
 	// Delete all stream information
 	db.Unscoped().Delete(Stream{}, "owner_id = ? AND owner_type = 'episode_files'", &file.ID)
+	db.Unscoped().Delete(Chapter{}, "owner_id = ? AND owner_type = ?", &file.ID, chapterOwnerEpisodeFile)
+	db.Unscoped().Delete(Marker{}, "episode_file_id = ?", &file.ID)
 	// Delete all file information
 	db.Unscoped().Delete(&file)
 
@@ -167,7 +177,9 @@ func FindAllSeries(qd *QueryDetails) ([]*Series, error) {
 	q := db
 	if qd != nil {
 		q = q.Offset(qd.Offset).Limit(qd.Limit)
-
+		if qd.LibraryIDs != nil {
+			q = q.Where(seriesInLibrariesQuery, qd.LibraryIDs)
+		}
 	}
 	if err := q.
 		Preload("Seasons.Episodes.EpisodeFiles.Streams").
@@ -178,11 +190,21 @@ func FindAllSeries(qd *QueryDetails) ([]*Series, error) {
 	return series, nil
 }
 
-// SearchSeriesByTitle searches for series based on their name.
-func SearchSeriesByTitle(name string) (series []Series) {
-	db.Preload("Seasons.Episodes.EpisodeFiles.Streams").
-		Where("LOWER(name) LIKE LOWER(?)", "%"+name+"%").
-		Find(&series)
+// seriesInLibrariesQuery restricts series to those that have episode files in the given libraries.
+const seriesInLibrariesQuery = "id IN (SELECT seasons.series_id FROM seasons " +
+	"JOIN episodes ON episodes.season_id = seasons.id " +
+	"JOIN episode_files ON episode_files.episode_id = episodes.id " +
+	"WHERE episode_files.library_id IN (?) AND episode_files.deleted_at IS NULL)"
+
+// SearchSeriesByTitle searches for series based on their name. If libraryIDs is not nil only series
+// in those libraries are returned.
+func SearchSeriesByTitle(name string, libraryIDs []uint) (series []Series) {
+	q := db.Preload("Seasons.Episodes.EpisodeFiles.Streams").
+		Where("LOWER(name) LIKE LOWER(?)", "%"+name+"%")
+	if libraryIDs != nil {
+		q = q.Where(seriesInLibrariesQuery, libraryIDs)
+	}
+	q.Find(&series)
 	return series
 }
 
@@ -430,19 +452,23 @@ func CreateEpisode(episode *Episode) {
 	db.Create(episode)
 }
 
-// ItemsWithMissingMetadata fetches series with missing metadata.
+// ItemsWithMissingMetadata fetches series with missing metadata. Items using extracted artwork
+// are included so the agent is asked again for proper artwork.
 func ItemsWithMissingMetadata() []string {
 	var uuids []string
 
 	// We can probably optimise this by only initiating strings somehow
 	var episodes []Episode
-	db.Select("uuid").Where("still_path = ''").Find(&episodes)
+	db.Select("uuid").Where("still_path = '' OR still_path LIKE ?", LocalImagePrefix+"%").Find(&episodes)
 	for _, episode := range episodes {
 		uuids = append(uuids, episode.UUID)
 	}
 
 	var movies []Movie
-	db.Select("uuid").Where("poster_path = ''").Or("backdrop_path = ''").Find(&movies)
+	db.Select("uuid").
+		Where("poster_path = '' OR poster_path LIKE ?", LocalImagePrefix+"%").
+		Or("backdrop_path = ''").
+		Find(&movies)
 	for _, movie := range movies {
 		uuids = append(uuids, movie.UUID)
 	}
@@ -469,6 +495,9 @@ func FindAllUnidentifiedEpisodeFiles(qd *QueryDetails) ([]EpisodeFile, error) {
 	query := db
 	if qd != nil {
 		query = query.Offset(qd.Offset).Limit(qd.Limit)
+		if qd.LibraryIDs != nil {
+			query = query.Where("library_id IN (?)", qd.LibraryIDs)
+		}
 	}
 
 	query = query.Find(&episodeFiles, "episode_id = 0")
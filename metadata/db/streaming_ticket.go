@@ -0,0 +1,69 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// StreamingTicket is the server-side state of a streaming JWT. It allows tickets to be
+// refreshed and revoked while the JWT itself stays the same.
+type StreamingTicket struct {
+	gorm.Model
+	// TicketID is stored as the JWT ID of all tokens belonging to this ticket.
+	TicketID string `gorm:"not null;unique_index"`
+	// SessionID is the playback session the ticket was created for.
+	SessionID string `gorm:"not null;index"`
+	UserID    uint   `gorm:"index"`
+	FilePath  string
+	// ClientIP restricts the ticket to a single client address if set.
+	ClientIP  string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+}
+
+// Revoked returns true if the ticket has been revoked.
+func (ticket *StreamingTicket) Revoked() bool {
+	return ticket.RevokedAt != nil
+}
+
+// CreateStreamingTicket stores a new ticket. Tickets that expired a while ago are cleaned up.
+func CreateStreamingTicket(ticket *StreamingTicket) error {
+	db.Unscoped().Where("expires_at < ?", time.Now().Add(-24*time.Hour)).Delete(StreamingTicket{})
+	return db.Create(ticket).Error
+}
+
+// FindStreamingTicket returns the ticket with the given ticket ID.
+func FindStreamingTicket(ticketID string) (*StreamingTicket, error) {
+	var ticket StreamingTicket
+	if err := db.Take(&ticket, "ticket_id = ?", ticketID).Error; err != nil {
+		return nil, fmt.Errorf("streaming ticket could not be found")
+	}
+	return &ticket, nil
+}
+
+// FindStreamingTicketBySession returns the ticket for the given playback session.
+func FindStreamingTicketBySession(sessionID string) (*StreamingTicket, error) {
+	var ticket StreamingTicket
+	if err := db.Take(&ticket, "session_id = ?", sessionID).Error; err != nil {
+		return nil, fmt.Errorf("streaming ticket could not be found")
+	}
+	return &ticket, nil
+}
+
+// ExtendStreamingTicket sets a new expiry time for the ticket.
+func ExtendStreamingTicket(ticket *StreamingTicket, expiresAt time.Time) error {
+	ticket.ExpiresAt = expiresAt
+	return db.Model(ticket).UpdateColumn("expires_at", expiresAt).Error
+}
+
+// RevokeStreamingTicket revokes the ticket, all its tokens stop working.
+func RevokeStreamingTicket(ticket *StreamingTicket) error {
+	if ticket.Revoked() {
+		return nil
+	}
+	now := time.Now()
+	ticket.RevokedAt = &now
+	return db.Model(ticket).UpdateColumn("revoked_at", now).Error
+}
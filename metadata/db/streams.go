@@ -40,6 +40,18 @@ type Stream struct {
 	Width  int
 	Height int
 
+	// Only relevant for audio, Channels is 0 if unknown.
+	Channels      int
+	ChannelLayout string
+
+	// Only relevant for video, colour properties as reported by ffprobe.
+	ColorTransfer      string
+	ColorPrimaries     string
+	ColorSpace         string
+	PixelFormat        string
+	DolbyVisionProfile int
+	FieldOrder         string
+
 	// "audio", "video", "subtitle"
 	StreamType string
 	// Only relevant for audio and subtitles. Language code.
@@ -47,6 +59,10 @@ type Stream struct {
 	// User-visible string for this audio or subtitle track
 	Title            string
 	EnabledByDefault bool
+
+	// Only relevant for subtitles.
+	Forced          bool
+	HearingImpaired bool
 }
 
 // UpdateAllStreams updates all streams for all mediaItems
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"gitlab.com/olaris/olaris-server/helpers"
+)
+
+// recoveryCodeCount is the number of recovery codes generated when enabling two-factor authentication.
+const recoveryCodeCount = 10
+
+// RecoveryCode is a single-use code that can be used instead of a TOTP code, for example
+// when the user lost their authenticator.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"index"`
+	CodeHash string `gorm:"not null"`
+	UsedAt   *time.Time
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	h := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(h[:])
+}
+
+// SetPendingTOTPSecret stores a new TOTP secret for the user. Two-factor authentication stays
+// disabled until EnableTOTP is called after the user proved they can generate valid codes.
+func SetPendingTOTPSecret(userID uint, secret string) error {
+	return db.Model(&User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled": false, "totp_last_step": 0}).
+		Error
+}
+
+// EnableTOTP enables two-factor authentication for the user and returns a fresh set of
+// recovery codes. These are only returned once, only their hashes are stored.
+func EnableTOTP(userID uint, lastStep int64) ([]string, error) {
+	err := db.Model(&User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_enabled": true, "totp_last_step": lastStep}).
+		Error
+	if err != nil {
+		return nil, err
+	}
+	return createRecoveryCodes(userID)
+}
+
+// UpdateTOTPLastStep stores the time step of the last accepted TOTP code.
+func UpdateTOTPLastStep(userID uint, step int64) error {
+	return db.Model(&User{}).Where("id = ?", userID).UpdateColumn("totp_last_step", step).Error
+}
+
+// ResetTOTP disables two-factor authentication for the user and removes all secrets.
+func ResetTOTP(userID uint) error {
+	err := db.Model(&User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_secret": "", "totp_enabled": false, "totp_last_step": 0}).
+		Error
+	if err != nil {
+		return err
+	}
+	return db.Unscoped().Where("user_id = ?", userID).Delete(RecoveryCode{}).Error
+}
+
+func createRecoveryCodes(userID uint) ([]string, error) {
+	if err := db.Unscoped().Where("user_id = ?", userID).Delete(RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := helpers.SecureRandAlphaString(10)
+		if err != nil {
+			return nil, err
+		}
+		code = strings.ToLower(code[:5] + "-" + code[5:])
+		if err := db.Create(&RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// UseRecoveryCode checks the code against the unused recovery codes of the user and marks it
+// as used if it matches.
+func UseRecoveryCode(userID uint, code string) error {
+	var recoveryCodes []RecoveryCode
+	db.Where("user_id = ? AND used_at IS NULL", userID).Find(&recoveryCodes)
+
+	hash := hashRecoveryCode(code)
+	for _, rc := range recoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(hash), []byte(rc.CodeHash)) == 1 {
+			return db.Model(&rc).UpdateColumn("used_at", time.Now()).Error
+		}
+	}
+	return fmt.Errorf("invalid recovery code")
+}
+
+// UnusedRecoveryCodeCount returns the number of recovery codes the user has left.
+func UnusedRecoveryCodeCount(userID uint) int {
+	count := 0
+	db.Model(&RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count)
+	return count
+}
@@ -4,9 +4,8 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
-	"github.com/jinzhu/gorm"
-	log "github.com/sirupsen/logrus"
 	"gitlab.com/olaris/olaris-server/helpers"
+	"strings"
 	"time"
 )
 
@@ -26,28 +25,20 @@ type User struct {
 	Admin        bool   `gorm:"not null" json:"admin"`
 	PasswordHash string `gorm:"not null" json:"-"`
 	Salt         string `gorm:"not null" json:"-"`
-}
 
-// Invite is a model used to invite users to your server.
-type Invite struct {
-	gorm.Model
-	Code   string
-	UserID uint
-	User   *User
-}
+	// TOTPSecret is the base32-encoded secret for two-factor authentication. It is set as soon
+	// as enrollment starts but only enforced once TOTPEnabled is true.
+	TOTPSecret  string `json:"-"`
+	TOTPEnabled bool   `gorm:"not null;default:false" json:"totp_enabled"`
+	// TOTPLastStep is the time step of the last accepted code, used to prevent replays.
+	TOTPLastStep int64 `json:"-"`
 
-// CreateInvite creates an invite code that can be redeemed by new users.
-func CreateInvite() Invite {
-	invite := Invite{Code: helpers.RandAlphaString(24)}
-	db.Save(&invite)
+	// OIDCIssuer and OIDCSubject link the user to an account at an OpenID Connect provider.
+	OIDCIssuer  string `gorm:"column:oidc_issuer;index:idx_user_oidc" json:"-"`
+	OIDCSubject string `gorm:"column:oidc_subject;index:idx_user_oidc" json:"-"`
 
-	return invite
-}
-
-// AllInvites returns all invites from the db.
-func AllInvites() (invites []Invite) {
-	db.Find(&invites)
-	return invites
+	// TranscodingProfile overrides the profile of the library when streaming, if set.
+	TranscodingProfile string `json:"transcoding_profile"`
 }
 
 // ValidPassword checks if the given password is valid for the user.
@@ -75,35 +66,11 @@ func (user *User) hashPassword(password string, salt string) string {
 	return hashedStr
 }
 
-// CreateUserWithCode creates a new user. The invite code will be ignored if no other users exist yet.
-func CreateUserWithCode(username string, password string, code string) (User, error) {
-	count := 0
-	db.Table("users").Count(&count)
-
-	invite := Invite{}
-	admin := false
-
-	// Not the first user, checking invite.
-	if count > 0 {
-		db.Where("code = ?", code).First(&invite)
-
-		if (invite.Code == "") || (invite.UserID != 0) {
-			log.Warnln("Not a valid code or already used.")
-			return User{}, fmt.Errorf("invite code invalid")
-		}
-	} else {
-		admin = true
-	}
-
-	user, err := CreateUser(username, password, admin)
-
-	if count > 0 {
-		if !db.NewRecord(&user) {
-			invite.UserID = user.ID
-			db.Save(&invite)
-		}
+func validatePassword(password string) error {
+	if len(password) < 8 {
+		return fmt.Errorf("password should be at least 8 characters")
 	}
-	return user, err
+	return nil
 }
 
 // CreateUser creates a new (admin) user to allow access via the web-interface
@@ -113,8 +80,8 @@ func CreateUser(username string, password string, admin bool) (User, error) {
 		return User{}, fmt.Errorf("username should be at least 3 characters")
 	}
 
-	if len(password) < 8 {
-		return User{}, fmt.Errorf("password should be at least 8 characters")
+	if err := validatePassword(password); err != nil {
+		return User{}, err
 	}
 
 	user := User{Username: username, Admin: admin}
@@ -161,10 +128,147 @@ func DeleteUser(id uint) (User, error) {
 	db.Find(&user, id)
 
 	if user.ID != 0 {
-		db.Unscoped().Where("user_id = ?", user.ID).Delete(Invite{})
+		// Invites the user redeemed may still be used by others, only the ones they created go.
+		db.Unscoped().Where("created_by_id = ?", user.ID).Delete(Invite{})
+		db.Model(&Invite{}).Where("user_id = ?", user.ID).UpdateColumn("user_id", 0)
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(InviteRedemption{})
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(LibraryGrant{})
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(APIKey{})
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(RecoveryCode{})
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(PasswordResetToken{})
+		db.Unscoped().Where("user_id = ?", user.ID).Delete(StreamingTicket{})
 		obj := db.Unscoped().Delete(&user)
 		return user, obj.Error
 	}
 
 	return user, fmt.Errorf("user could not be found, not deleted")
 }
+
+// UpdatePassword sets a new password for the given user.
+func UpdatePassword(userID uint, password string) error {
+	if err := validatePassword(password); err != nil {
+		return err
+	}
+
+	user, err := FindUser(userID)
+	if err != nil {
+		return fmt.Errorf("user could not be found")
+	}
+	user.SetPassword(password, helpers.RandAlphaString(24))
+	return db.Model(user).
+		Updates(map[string]interface{}{"password_hash": user.PasswordHash, "salt": user.Salt}).
+		Error
+}
+
+// RenameUser changes the username of the given user. Usernames have to be unique.
+func RenameUser(userID uint, username string) (*User, error) {
+	username = strings.TrimSpace(username)
+	if len(username) < 3 {
+		return nil, fmt.Errorf("username should be at least 3 characters")
+	}
+	user, err := FindUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user could not be found")
+	}
+	if existing, err := FindUserByUsername(username); err == nil && existing.ID != user.ID {
+		return nil, fmt.Errorf("username is already taken")
+	}
+
+	user.Username = username
+	if err := db.Model(user).UpdateColumn("username", username).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// AdminCount counts the amount of admins in the db.
+func AdminCount() int {
+	count := 0
+	db.Model(&User{}).Where("admin = ?", true).Count(&count)
+	return count
+}
+
+// SetUserAdmin promotes the given user to admin or demotes them. The last admin can't be demoted.
+func SetUserAdmin(userID uint, admin bool) (*User, error) {
+	user, err := FindUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user could not be found")
+	}
+	if user.Admin == admin {
+		return user, nil
+	}
+	if !admin && AdminCount() <= 1 {
+		return nil, fmt.Errorf("the last admin can't be demoted")
+	}
+
+	user.Admin = admin
+	if err := db.Model(user).UpdateColumn("admin", admin).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// SetUserTranscodingProfile selects the transcoding profile of the given user, an empty profile
+// uses the one of the library.
+func SetUserTranscodingProfile(userID uint, profile string) (*User, error) {
+	user, err := FindUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user could not be found")
+	}
+
+	user.TranscodingProfile = profile
+	if err := db.Model(user).UpdateColumn("transcoding_profile", profile).Error; err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// FindUserByOIDCSubject returns the user linked to the given OpenID Connect account.
+func FindUserByOIDCSubject(issuer string, subject string) (*User, error) {
+	var user User
+	if err := db.Take(&user, "oidc_issuer = ? AND oidc_subject = ?", issuer, subject).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// LinkOIDCSubject links an existing user to an OpenID Connect account.
+func LinkOIDCSubject(userID uint, issuer string, subject string) error {
+	return db.Model(&User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"oidc_issuer": issuer, "oidc_subject": subject}).
+		Error
+}
+
+// CreateExternalUser creates a user that is authenticated by an external system, such as an
+// OpenID Connect provider or an authenticating proxy. The user gets a random password so it can't
+// be used to log in locally until an admin resets it.
+func CreateExternalUser(username string, admin bool) (*User, error) {
+	password, err := helpers.SecureRandAlphaString(32)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := FindUserByUsername(username); err == nil {
+		return nil, fmt.Errorf("username '%s' is already taken", username)
+	}
+
+	user, err := CreateUser(username, password, admin)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// CreateOIDCUser creates a user that logs in through an OpenID Connect provider.
+func CreateOIDCUser(username string, issuer string, subject string, admin bool) (*User, error) {
+	user, err := CreateExternalUser(username, admin)
+	if err != nil {
+		return nil, err
+	}
+	if err := LinkOIDCSubject(user.ID, issuer, subject); err != nil {
+		DeleteUser(user.ID)
+		return nil, err
+	}
+	user.OIDCIssuer = issuer
+	user.OIDCSubject = subject
+	return user, nil
+}
@@ -1,6 +1,8 @@
 package db_test
 
 import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 	"testing"
 )
@@ -15,3 +17,58 @@ func TestSetPassword(t *testing.T) {
 		t.Errorf("Password hash is not set on user")
 	}
 }
+
+func TestUpdatePassword(t *testing.T) {
+	defer setupTest(t)()
+
+	user, err := db.CreateUser("changer", "testtest", false)
+	require.NoError(t, err)
+
+	assert.Error(t, db.UpdatePassword(user.ID, "short"))
+	require.NoError(t, db.UpdatePassword(user.ID, "newpassword"))
+
+	u := db.User{Username: "changer"}
+	assert.True(t, u.ValidPassword("newpassword"))
+}
+
+func TestSetUserAdmin(t *testing.T) {
+	defer setupTest(t)()
+
+	admin, _ := db.CreateUser("admin", "testtest", true)
+	user, _ := db.CreateUser("user", "testtest", false)
+
+	_, err := db.SetUserAdmin(admin.ID, false)
+	assert.Error(t, err, "the last admin can't be demoted")
+
+	promoted, err := db.SetUserAdmin(user.ID, true)
+	require.NoError(t, err)
+	assert.True(t, promoted.Admin)
+	assert.Equal(t, 2, db.AdminCount())
+
+	demoted, err := db.SetUserAdmin(admin.ID, false)
+	require.NoError(t, err)
+	assert.False(t, demoted.Admin)
+	assert.Equal(t, 1, db.AdminCount())
+}
+
+func TestRenameUser(t *testing.T) {
+	defer setupTest(t)()
+
+	user, _ := db.CreateUser("user", "testtest", false)
+	_, _ = db.CreateUser("taken", "testtest", false)
+
+	_, err := db.RenameUser(user.ID, "taken")
+	assert.Error(t, err, "usernames have to be unique")
+	_, err = db.RenameUser(user.ID, "ab")
+	assert.Error(t, err)
+
+	renamed, err := db.RenameUser(user.ID, " renamed ")
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", renamed.Username)
+	found, err := db.FindUserByUsername("renamed")
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, found.ID)
+
+	_, err = db.RenameUser(user.ID, "renamed")
+	assert.NoError(t, err, "keeping the own username is fine")
+}
@@ -0,0 +1,88 @@
+package metadata
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/metadata/managers"
+)
+
+// authorizeDownloadRequest checks that the download is ready and that the client is the user who
+// requested it, either authenticated or with a signed URL as handed out by the resolvers. It
+// returns the HTTP status code to send if not.
+func authorizeDownloadRequest(r *http.Request) (*db.Download, int, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return nil, http.StatusNotFound, false
+	}
+	download, err := db.FindDownload(uint(id))
+	if err != nil {
+		return nil, http.StatusNotFound, false
+	}
+
+	if userID, ok := auth.UserID(r.Context()); ok {
+		if userID != download.UserID {
+			return nil, http.StatusNotFound, false
+		}
+	} else {
+		query := r.URL.Query()
+		if !auth.ValidDownloadSignature(download.ID, download.UserID, query.Get("expires"), query.Get("signature")) {
+			return nil, http.StatusUnauthorized, false
+		}
+	}
+
+	if download.State != db.DownloadReady || download.Expired(time.Now()) {
+		return nil, http.StatusNotFound, false
+	}
+	// Users that were deleted or lost access to the library can't fetch their downloads anymore
+	if _, err := db.FindUser(download.UserID); err != nil {
+		return nil, http.StatusNotFound, false
+	}
+	file := db.FindContentByUUID(download.FileUUID)
+	if file == nil || !db.CanAccessLibrary(download.UserID, file.GetLibrary().ID) {
+		return nil, http.StatusNotFound, false
+	}
+	return download, 0, true
+}
+
+// downloadHandler serves the file of a download. Range requests are supported so clients can
+// resume interrupted downloads.
+func downloadHandler(w http.ResponseWriter, r *http.Request) {
+	download, code, ok := authorizeDownloadRequest(r)
+	if !ok {
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+
+	file, err := os.Open(managers.DownloadPath(download))
+	if err != nil {
+		log.WithError(err).WithField("downloadID", download.ID).Warnln("Could not read download from disk.")
+		http.Error(w, "Could not read download", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Could not read download", http.StatusInternalServerError)
+		return
+	}
+
+	// The file of a download never changes, so the ID and size make a strong ETag for If-Range.
+	w.Header().Set("ETag", fmt.Sprintf(`"download-%d-%x"`, download.ID, info.Size()))
+	w.Header().Set("Cache-Control", "private, no-transform")
+	w.Header().Set("Content-Type", "video/mp4")
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": download.FileName})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", disposition)
+	http.ServeContent(w, r, download.FileName, info.ModTime(), file)
+}
@@ -21,14 +21,27 @@ func RegisterRoutes(menv *app.MetadataContext, r *mux.Router) {
 	r.Handle("/query", auth.MiddleWare(graphqlws.NewHandlerFunc(schema, handler)))
 
 	r.HandleFunc("/v1/auth", auth.UserHandler).Methods("POST")
+	if proxyConfig, ok := auth.ProxyAuthConfigFromViper(); ok {
+		auth.EnableProxyAuth(proxyConfig)
+		r.HandleFunc("/v1/auth/proxy", auth.ProxyLoginHandler).Methods("GET", "POST")
+	}
+	if oidcConfig, ok := auth.OIDCConfigFromViper(); ok {
+		oidc := auth.NewOIDCProvider(oidcConfig)
+		r.HandleFunc("/v1/auth/oidc/login", oidc.LoginHandler).Methods("GET")
+		r.HandleFunc("/v1/auth/oidc/callback", oidc.CallbackHandler).Methods("GET")
+	}
 
 	r.HandleFunc("/v1/version", versionHandler).Methods("GET")
 
 	r.HandleFunc("/v1/user", auth.CreateUserHandler).Methods("POST")
 	r.HandleFunc("/v1/user/setup", auth.ReadyForSetup)
+	r.HandleFunc("/v1/user/reset-password", auth.ResetPasswordHandler).Methods("POST")
 
-	// TODO(Maran): This should be authenticated too.
-	r.HandleFunc("/images/{provider}/{size}/{id}", imageManager.HTTPHandler)
+	// Images require a user or a signed URL as handed out by the resolvers.
+	r.Handle("/images/{provider}/{size}/{id}", auth.OptionalMiddleWare(http.HandlerFunc(imageManager.HTTPHandler)))
+	// Downloads too, so download managers can fetch and resume them without credentials.
+	r.Handle("/v1/downloads/{id}/{name}", auth.OptionalMiddleWare(http.HandlerFunc(downloadHandler))).
+		Methods("GET", "HEAD")
 }
 
 func versionHandler(w http.ResponseWriter, r *http.Request){
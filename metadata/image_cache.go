@@ -1,80 +1,228 @@
 package metadata
 
 import (
-	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
-	"github.com/gorilla/mux"
-	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
-	"gitlab.com/olaris/olaris-server/helpers"
-	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/metadata/images"
 )
 
+// defaultImageCacheSize is the cache size in megabytes if server.imageCacheSize isn't set.
+const defaultImageCacheSize = 1024
+
+// maxImageWidth limits the widths clients can request images to be resized to.
+const maxImageWidth = 3840
+
 // ImageManager cache implementation for themoviedb.
 type ImageManager struct {
-	// Path where cached images will be stored.
-	cachePath string
+	cache *images.Cache
 }
 
 // NewImageManager creates a new instance of a image caching server for themoviedb.
 func NewImageManager() *ImageManager {
 	cachePath := path.Join(viper.GetString("server.cacheDir"), "images")
 	helpers.EnsurePath(cachePath)
-	return &ImageManager{cachePath: cachePath}
+
+	cacheSize := viper.GetInt64("server.imageCacheSize")
+	if cacheSize <= 0 {
+		cacheSize = defaultImageCacheSize
+	}
+	return &ImageManager{cache: images.NewCache(cachePath, cacheSize*1024*1024)}
 }
 
-// HTTPHandler responsible for proxying calls to themoviedb. If an image is already present locally it will be served from filesystem. If it's not present it will attempt to download a file into the filesystem cache from tmdb.
-func (man *ImageManager) HTTPHandler(w http.ResponseWriter, r *http.Request) {
+// Image providers. Local images are extracted from the media files and only come in their
+// original size.
+const (
+	imageProviderTMDB  = "tmdb"
+	imageProviderLocal = "local"
+)
+
+// imageSizes are the image sizes offered by TMDB.
+var imageSizes = map[string]bool{
+	"original": true,
+	"w45":      true,
+	"w92":      true,
+	"w154":     true,
+	"w185":     true,
+	"w300":     true,
+	"w342":     true,
+	"w500":     true,
+	"w780":     true,
+	"w1280":    true,
+	"h632":     true,
+}
+
+// authorizeImageRequest checks that the request is for an image we serve and that the client is
+// allowed to see it. It returns the HTTP status code to send if not.
+func authorizeImageRequest(r *http.Request) (int, bool) {
 	provider := mux.Vars(r)["provider"]
 	size := mux.Vars(r)["size"]
 	id := mux.Vars(r)["id"]
-	folderPath := path.Join(man.cachePath, provider, size)
-	filePath := path.Join(folderPath, id)
 
-	if helpers.FileExists(filePath) {
-		log.WithFields(log.Fields{"file": filePath}).Debugln("Requested file already in cache.")
-		file, err := ioutil.ReadFile(filePath)
-		if err != nil {
-			log.Warnln("Could not read file from disk.")
-		} else {
-			w.Write(file)
+	imagePath := "/" + id
+	switch provider {
+	case imageProviderTMDB:
+		if !imageSizes[size] {
+			return http.StatusNotFound, false
+		}
+	case imageProviderLocal:
+		if size != "original" {
+			return http.StatusNotFound, false
 		}
+		imagePath = db.LocalImagePrefix + id
+	default:
+		return http.StatusNotFound, false
+	}
+
+	// Signed URLs carry the user they were signed for.
+	userID, ok := auth.UserID(r.Context())
+	if !ok {
+		query := r.URL.Query()
+		signedUserID, err := strconv.ParseUint(query.Get("user"), 10, 64)
+		if err != nil || !auth.ValidImageSignature(provider, size, id, uint(signedUserID),
+			query.Get("expires"), query.Get("signature")) {
+			return http.StatusUnauthorized, false
+		}
+		userID = uint(signedUserID)
+	}
+
+	// Admins need to see images of TMDB search results that aren't in a library yet when
+	// identifying media, but can't make us fetch and cache arbitrary TMDB paths.
+	if isAdmin, _ := auth.UserAdmin(r.Context()); isAdmin &&
+		provider == imageProviderTMDB && images.IsSearchResult(imagePath) {
+		return 0, true
+	}
+	if !db.ImageInLibrary(imagePath, userID) {
+		return http.StatusNotFound, false
+	}
+	return 0, true
+}
+
+// fetchImage downloads or extracts an image and stores its placeholder on the items using it,
+// so clients get it the next time they load them.
+func fetchImage(provider string, size string, id string) ([]byte, error) {
+	var data []byte
+	var err error
+	imagePath := "/" + id
+	if provider == imageProviderLocal {
+		data, err = localArtwork(id)
+		imagePath = db.LocalImagePrefix + id
 	} else {
-		log.WithFields(log.Fields{"file": filePath}).Debugln("Requested file not in cache yet.")
+		data, err = images.DownloadTMDB(size, id)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		url := fmt.Sprintf("http://image.tmdb.org/t/p/%s/%s", size, id)
-		response, err := http.Get(url)
+	go func() {
+		img, err := images.Decode(data)
 		if err != nil {
-			log.Warnf("Could not reach image url '%s': %s", url, err)
+			log.WithError(err).WithField("id", id).Debugln("Could not decode image for placeholder")
 			return
 		}
-		defer response.Body.Close()
+		placeholder := images.NewPlaceholder(img)
+		db.SetImagePlaceholder(imagePath, placeholder.BlurHash, placeholder.Color)
+	}()
+	return data, nil
+}
 
-		helpers.EnsurePath(folderPath)
-		openFile, err := os.Create(filePath)
-		if err != nil {
-			log.Warnf("Error while creating file '%s': %s", filePath, err)
+// HTTPHandler responsible for proxying calls to themoviedb and serving artwork extracted from
+// media files. Images are downloaded or extracted into a filesystem cache once and served from there. If the client asks for a width smaller than the
+// image, or accepts WebP, a converted copy is created and cached as well.
+func (man *ImageManager) HTTPHandler(w http.ResponseWriter, r *http.Request) {
+	if code, ok := authorizeImageRequest(r); !ok {
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+
+	provider := mux.Vars(r)["provider"]
+	size := mux.Vars(r)["size"]
+	id := mux.Vars(r)["id"]
+
+	width := 0
+	if widthStr := r.URL.Query().Get("width"); widthStr != "" {
+		var err error
+		width, err = strconv.Atoi(widthStr)
+		if err != nil || width <= 0 || width > maxImageWidth {
+			http.Error(w, "Invalid width", http.StatusBadRequest)
 			return
 		}
-		defer openFile.Close()
+	}
+	format := images.NegotiateFormat(r.Header.Get("Accept"))
+
+	key := path.Join(provider, size, id)
+	filePath, err := man.cache.Get(key, func() ([]byte, error) {
+		return fetchImage(provider, size, id)
+	})
+	if err != nil {
+		log.WithError(err).Warnln("Could not download image")
+		http.Error(w, "Could not download image", http.StatusBadGateway)
+		return
+	}
+	contentType := ""
 
-		var b bytes.Buffer
-		_, err = io.Copy(&b, response.Body)
+	if width > 0 || format != images.FormatJPEG {
+		source := filePath
+		key = fmt.Sprintf("%s@%d.%s", key, width, format)
+		filePath, err = man.cache.Get(key, func() ([]byte, error) {
+			data, err := ioutil.ReadFile(source)
+			if err != nil {
+				return nil, err
+			}
+			img, err := images.Decode(data)
+			if err != nil {
+				return nil, err
+			}
+			return images.Encode(images.Resize(img, width), format)
+		})
 		if err != nil {
-			log.Warnf("Error while downloading file from '%s': '%s'", url, err)
+			log.WithError(err).Warnln("Could not convert image")
+			http.Error(w, "Could not convert image", http.StatusInternalServerError)
 			return
 		}
-		// Write to a secondary variable so we can serve the image right away without rereading it from disk
-		imageB := b.Bytes()
-		_, err = b.WriteTo(openFile)
-		if err != nil {
-			log.Warnln("Error writing downloaded file to disk:", err)
-		}
+		contentType = images.ContentType(format)
+	}
+
+	serveCachedImage(w, r, key, filePath, contentType)
+}
+
+// serveCachedImage sends a file from the image cache. Cached images never change, so the key
+// and size make a strong ETag.
+func serveCachedImage(w http.ResponseWriter, r *http.Request, key string, filePath string, contentType string) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		log.WithError(err).Warnln("Could not read file from disk.")
+		http.Error(w, "Could not read image", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "Could not read image", http.StatusInternalServerError)
+		return
+	}
 
-		w.Write(imageB)
+	hash := sha1.Sum([]byte(key))
+	w.Header().Set("ETag", fmt.Sprintf(`"%s-%x"`, hex.EncodeToString(hash[:8]), info.Size()))
+	// URLs are signed for a specific user, so shared caches must not store the images.
+	w.Header().Set("Cache-Control", "private, max-age=604800, immutable")
+	w.Header().Set("Vary", "Accept")
+	if contentType != "" {
+		w.Header().Set("Content-Type", contentType)
 	}
+	// The modification time marks recent use for the cache, so it's no use for clients.
+	http.ServeContent(w, r, path.Base(filePath), time.Time{}, file)
 }
@@ -0,0 +1,52 @@
+package metadata
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/metadata/images"
+)
+
+func TestAuthorizeImageRequest_Admin(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	admin, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	token, err := auth.CreateMetadataJWT(&admin, auth.DefaultLoginTokenValidity)
+	require.NoError(t, err)
+
+	authorize := func(provider string, id string) (code int, ok bool) {
+		handler := auth.MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			code, ok = authorizeImageRequest(req)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		req = mux.SetURLVars(req, map[string]string{"provider": provider, "size": "original", "id": id})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return code, ok
+	}
+
+	code, ok := authorize(imageProviderTMDB, "arbitrary.jpg")
+	assert.False(t, ok, "admins can't fetch TMDB images nothing refers to")
+	assert.Equal(t, http.StatusNotFound, code)
+
+	images.RememberSearchResult("/found.jpg")
+	_, ok = authorize(imageProviderTMDB, "found.jpg")
+	assert.True(t, ok, "admins can see the images of search results")
+
+	_, ok = authorize(imageProviderLocal, "found.jpg")
+	assert.False(t, ok, "search results only cover TMDB images")
+
+	movie := db.Movie{Title: "Test"}
+	movie.PosterPath = "/movie.jpg"
+	db.SaveMovie(&movie)
+	_, ok = authorize(imageProviderTMDB, "movie.jpg")
+	assert.True(t, ok, "admins can see the images of all libraries")
+}
@@ -0,0 +1,191 @@
+// Package images implements caching and processing of artwork served by the metadata server.
+package images
+
+import (
+	"container/list"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache stores images on disk and evicts the least recently used ones when it grows beyond its
+// maximum size. Concurrent requests for the same missing image only create it once.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mutex   sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	// lru holds the most recently used entry at the front.
+	lru *list.List
+
+	group singleflight.Group
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+}
+
+// NewCache creates a cache in the given directory. Files already in the directory are picked up,
+// ordered by their modification time which is updated whenever a file is used.
+func NewCache(dir string, maxSize int64) *Cache {
+	c := &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+
+	type existingFile struct {
+		key     string
+		size    int64
+		modTime time.Time
+	}
+	var files []existingFile
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if strings.HasPrefix(info.Name(), ".tmp-") {
+			os.Remove(path)
+			return nil
+		}
+		key, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		files = append(files, existingFile{filepath.ToSlash(key), info.Size(), info.ModTime()})
+		return nil
+	})
+
+	// Add the oldest first so the newest ends up at the front.
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		c.add(f.key, f.size)
+	}
+	c.mutex.Lock()
+	c.evict()
+	c.mutex.Unlock()
+
+	return c
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, filepath.FromSlash(key))
+}
+
+func (c *Cache) add(key string, size int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if e, ok := c.entries[key]; ok {
+		c.size -= e.Value.(*cacheEntry).size
+		c.lru.Remove(e)
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, size: size})
+	c.size += size
+}
+
+// evict removes the least recently used files until the cache fits its maximum size. The most
+// recently used file is always kept, it may just have been handed out. The mutex must be held.
+func (c *Cache) evict() {
+	for c.size > c.maxSize && c.lru.Len() > 1 {
+		e := c.lru.Back()
+		entry := e.Value.(*cacheEntry)
+		c.lru.Remove(e)
+		delete(c.entries, entry.key)
+		c.size -= entry.size
+
+		log.WithField("file", entry.key).Debugln("Evicting image from cache")
+		if err := os.Remove(c.path(entry.key)); err != nil && !os.IsNotExist(err) {
+			log.WithError(err).Warnln("Could not remove cached image")
+		}
+	}
+}
+
+// lookup returns the path of a cached file and marks it as recently used.
+func (c *Cache) lookup(key string) (string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	path := c.path(key)
+	if _, err := os.Stat(path); err != nil {
+		c.size -= e.Value.(*cacheEntry).size
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		return "", false
+	}
+	c.lru.MoveToFront(e)
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return path, true
+}
+
+// Get returns the path of the cached file for the key. If it's not cached yet create is called to
+// make it, only once for concurrent callers.
+func (c *Cache) Get(key string, create func() ([]byte, error)) (string, error) {
+	if path, ok := c.lookup(key); ok {
+		return path, nil
+	}
+
+	path, err, _ := c.group.Do(key, func() (interface{}, error) {
+		if path, ok := c.lookup(key); ok {
+			return path, nil
+		}
+
+		data, err := create()
+		if err != nil {
+			return "", err
+		}
+
+		path := c.path(key)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", err
+		}
+		// Write to a temporary file first so nobody serves a partially written image.
+		tmp, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+		if err != nil {
+			return "", err
+		}
+		if _, err := tmp.Write(data); err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+			return "", err
+		}
+		tmp.Close()
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			os.Remove(tmp.Name())
+			return "", err
+		}
+
+		c.add(key, int64(len(data)))
+		c.mutex.Lock()
+		c.evict()
+		c.mutex.Unlock()
+		return path, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return path.(string), nil
+}
+
+// Size returns the total size of all cached files.
+func (c *Cache) Size() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.size
+}
@@ -0,0 +1,87 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func data(size int) func() ([]byte, error) {
+	return func() ([]byte, error) {
+		return bytes.Repeat([]byte{'x'}, size), nil
+	}
+}
+
+func TestCache_Eviction(t *testing.T) {
+	c := NewCache(t.TempDir(), 250)
+
+	first, err := c.Get("tmdb/w92/first.jpg", data(100))
+	require.NoError(t, err)
+	second, err := c.Get("tmdb/w92/second.jpg", data(100))
+	require.NoError(t, err)
+
+	// Using the first image makes the second the least recently used one.
+	_, err = c.Get("tmdb/w92/first.jpg", func() ([]byte, error) {
+		return nil, fmt.Errorf("should be cached")
+	})
+	require.NoError(t, err)
+
+	_, err = c.Get("tmdb/w92/third.jpg", data(100))
+	require.NoError(t, err)
+
+	assert.FileExists(t, first)
+	_, err = os.Stat(second)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, int64(200), c.Size())
+}
+
+func TestCache_ExistingFiles(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(dir+"/tmdb/w92", 0755))
+	require.NoError(t, ioutil.WriteFile(dir+"/tmdb/w92/old.jpg", make([]byte, 100), 0644))
+	require.NoError(t, ioutil.WriteFile(dir+"/tmdb/w92/new.jpg", make([]byte, 100), 0644))
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(dir+"/tmdb/w92/old.jpg", old, old))
+
+	c := NewCache(dir, 150)
+	assert.Equal(t, int64(100), c.Size())
+	assert.FileExists(t, dir+"/tmdb/w92/new.jpg")
+	_, err := os.Stat(dir + "/tmdb/w92/old.jpg")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCache_SingleFlight(t *testing.T) {
+	c := NewCache(t.TempDir(), 1000)
+
+	var calls int32
+	release := make(chan struct{})
+	create := func() ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte("image"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			path, err := c.Get("tmdb/original/poster.jpg", create)
+			assert.NoError(t, err)
+			assert.FileExists(t, path)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
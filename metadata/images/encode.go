@@ -0,0 +1,108 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os/exec"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Formats images can be served in.
+const (
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+)
+
+// jpegQuality is used for all re-encoded JPEG images.
+const jpegQuality = 85
+
+// ContentType returns the MIME type of the format.
+func ContentType(format string) string {
+	return "image/" + format
+}
+
+var webPSupport struct {
+	once      sync.Once
+	supported bool
+}
+
+// WebPSupported returns true if ffmpeg can encode WebP images. Go has no WebP encoder, so we
+// use ffmpeg like for everything else media related.
+func WebPSupported() bool {
+	webPSupport.once.Do(func() {
+		out, err := exec.Command("ffmpeg", "-hide_banner", "-encoders").Output()
+		webPSupport.supported = err == nil && strings.Contains(string(out), "libwebp")
+		if !webPSupport.supported {
+			log.Infoln("ffmpeg can't encode WebP, images will be served as JPEG")
+		}
+	})
+	return webPSupport.supported
+}
+
+// NegotiateFormat picks the format to serve based on the Accept header of the request.
+func NegotiateFormat(accept string) string {
+	if strings.Contains(accept, ContentType(FormatWebP)) && WebPSupported() {
+		return FormatWebP
+	}
+	return FormatJPEG
+}
+
+// Decode reads a JPEG or PNG image, the formats TMDB serves.
+func Decode(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// Encode writes the image in the given format.
+func Encode(img image.Image, format string) ([]byte, error) {
+	switch format {
+	case FormatJPEG:
+		var b bytes.Buffer
+		if err := jpeg.Encode(&b, flatten(img), &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case FormatWebP:
+		return encodeWebP(img)
+	}
+	return nil, fmt.Errorf("unknown image format %s", format)
+}
+
+// flatten draws images with transparency on black, JPEG has no alpha channel.
+func flatten(img image.Image) image.Image {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return img
+	}
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.Black, image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+func encodeWebP(img image.Image) ([]byte, error) {
+	var in bytes.Buffer
+	if err := png.Encode(&in, img); err != nil {
+		return nil, err
+	}
+
+	var out, stderr bytes.Buffer
+	cmd := exec.Command("ffmpeg",
+		"-hide_banner", "-loglevel", "error",
+		"-f", "png_pipe", "-i", "pipe:0",
+		"-c:v", "libwebp", "-quality", "80",
+		"-f", "webp", "pipe:1")
+	cmd.Stdin = &in
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed to encode WebP: %s: %s", err, stderr.String())
+	}
+	return out.Bytes(), nil
+}
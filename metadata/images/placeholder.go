@@ -0,0 +1,167 @@
+package images
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// BlurHash components used for all artwork. 4x3 captures enough of a poster's layout while
+// keeping the hash short.
+const (
+	blurHashXComponents = 4
+	blurHashYComponents = 3
+)
+
+// placeholderWidth is the width images are scaled to before computing placeholders. Both are
+// blurry by design, so more pixels only cost time.
+const placeholderWidth = 64
+
+const base83Characters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// Placeholder holds what clients need to show something meaningful while an image loads.
+type Placeholder struct {
+	BlurHash string
+	// Color is the dominant colour as a CSS hex colour, e.g. "#1a2b3c".
+	Color string
+}
+
+// NewPlaceholder computes the BlurHash and dominant colour of an image.
+func NewPlaceholder(img image.Image) Placeholder {
+	small := Resize(img, placeholderWidth)
+	return Placeholder{
+		BlurHash: BlurHash(small, blurHashXComponents, blurHashYComponents),
+		Color:    DominantColor(small),
+	}
+}
+
+// BlurHash encodes the image as described on https://blurha.sh.
+func BlurHash(img image.Image, xComponents int, yComponents int) string {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1.0
+			}
+
+			var r, g, b float64
+			for y := 0; y < height; y++ {
+				basisY := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+				for x := 0; x < width; x++ {
+					basis := math.Cos(math.Pi*float64(i)*float64(x)/float64(width)) * basisY
+					cr, cg, cb, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+					r += basis * sRGBToLinear(cr>>8)
+					g += basis * sRGBToLinear(cg>>8)
+					b += basis * sRGBToLinear(cb>>8)
+				}
+			}
+
+			scale := normalisation / float64(width*height)
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+
+	var hash strings.Builder
+	hash.WriteString(encode83((xComponents-1)+(yComponents-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+	maximumValue := 1.0
+	if len(ac) > 0 {
+		actualMaximumValue := 0.0
+		for _, factor := range ac {
+			for _, v := range factor {
+				actualMaximumValue = math.Max(actualMaximumValue, math.Abs(v))
+			}
+		}
+		quantisedMaximumValue := int(math.Max(0, math.Min(82, math.Floor(actualMaximumValue*166-0.5))))
+		maximumValue = float64(quantisedMaximumValue+1) / 166
+		hash.WriteString(encode83(quantisedMaximumValue, 1))
+	} else {
+		hash.WriteString(encode83(0, 1))
+	}
+
+	hash.WriteString(encode83(linearToSRGB(dc[0])<<16+linearToSRGB(dc[1])<<8+linearToSRGB(dc[2]), 4))
+	for _, factor := range ac {
+		quantised := 0
+		for _, v := range factor {
+			q := int(math.Max(0, math.Min(18, math.Floor(signPow(v/maximumValue, 0.5)*9+9.5))))
+			quantised = quantised*19 + q
+		}
+		hash.WriteString(encode83(quantised, 2))
+	}
+	return hash.String()
+}
+
+// DominantColor returns the most common colour of the image. Similar colours are grouped so
+// noise and gradients don't matter.
+func DominantColor(img image.Image) string {
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+	buckets := map[int]*bucket{}
+	var best *bucket
+
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cr, cg, cb, ca := img.At(x, y).RGBA()
+			if ca < 0x8000 {
+				continue
+			}
+			r, g, b := int(cr>>8), int(cg>>8), int(cb>>8)
+			key := (r>>4)<<8 | (g>>4)<<4 | b>>4
+			bu, ok := buckets[key]
+			if !ok {
+				bu = &bucket{}
+				buckets[key] = bu
+			}
+			bu.count++
+			bu.r += r
+			bu.g += g
+			bu.b += b
+			if best == nil || bu.count > best.count {
+				best = bu
+			}
+		}
+	}
+
+	if best == nil {
+		return "#000000"
+	}
+	return fmt.Sprintf("#%02x%02x%02x", best.r/best.count, best.g/best.count, best.b/best.count)
+}
+
+func encode83(value int, length int) string {
+	var result strings.Builder
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		result.WriteByte(base83Characters[digit])
+	}
+	return result.String()
+}
+
+func sRGBToLinear(value uint32) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value float64, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
@@ -0,0 +1,52 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func solid(c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 30))
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 20; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestBlurHash_Solid(t *testing.T) {
+	hash := BlurHash(solid(color.RGBA{255, 0, 0, 255}), 4, 3)
+
+	// Size flag, maximum AC value, the colour and 11 AC components
+	assert.Len(t, hash, 28)
+	assert.Equal(t, "L", hash[:1])
+	assert.Equal(t, encode83(0xff0000, 4), hash[2:6])
+}
+
+func TestBlurHash_Gradient(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 8), 0, uint8(y * 8), 255})
+		}
+	}
+
+	hash := BlurHash(img, 4, 3)
+	assert.Len(t, hash, 28)
+	assert.NotContains(t, hash[6:], strings.Repeat("fQ", 11))
+}
+
+func TestDominantColor(t *testing.T) {
+	img := solid(color.RGBA{0x20, 0x40, 0x60, 255}).(*image.RGBA)
+	// A few pixels of another colour don't matter
+	img.Set(0, 0, color.White)
+	img.Set(1, 0, color.White)
+
+	assert.Equal(t, "#204060", DominantColor(img))
+	assert.Equal(t, "#204060", NewPlaceholder(img).Color)
+}
@@ -0,0 +1,56 @@
+package images
+
+import (
+	"image"
+	"image/color"
+)
+
+// Resize scales the image down to the given width, keeping its aspect ratio. Every target pixel
+// is the average of the source pixels it covers, which is cheap and looks good for downscaling.
+// Images that are already narrow enough are returned unchanged.
+func Resize(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+	if width <= 0 || width >= srcWidth {
+		return src
+	}
+	height := srcHeight * width / srcWidth
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*srcHeight/height
+		y1 := bounds.Min.Y + (y+1)*srcHeight/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*srcWidth/width
+			x1 := bounds.Min.X + (x+1)*srcWidth/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					b += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
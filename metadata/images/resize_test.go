@@ -0,0 +1,43 @@
+package images
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResize(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 40, 60))
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 40; x++ {
+			if x < 20 {
+				src.Set(x, y, color.White)
+			} else {
+				src.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	dst := Resize(src, 10)
+	assert.Equal(t, image.Rect(0, 0, 10, 15), dst.Bounds())
+	r, _, _, _ := dst.At(0, 0).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+	r, _, _, _ = dst.At(9, 14).RGBA()
+	assert.Equal(t, uint32(0), r)
+
+	// Images are never scaled up
+	assert.Equal(t, src, Resize(src, 100))
+}
+
+func TestEncodeJPEG(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	data, err := Encode(src, FormatJPEG)
+	require.NoError(t, err)
+
+	img, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, src.Bounds(), img.Bounds())
+}
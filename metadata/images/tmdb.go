@@ -0,0 +1,95 @@
+package images
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// placeholderSize is the TMDB size downloaded to compute placeholders for images that aren't
+// cached yet.
+const placeholderSize = "w92"
+
+// searchResultValidity is how long images of TMDB search results can be fetched after the search.
+const searchResultValidity = time.Hour
+
+// maxSearchResults limits the number of search result images remembered at the same time.
+const maxSearchResults = 10000
+
+// searchResults holds the TMDB image paths returned by recent searches with their expiry.
+var searchResults = struct {
+	sync.Mutex
+	expires map[string]time.Time
+}{expires: map[string]time.Time{}}
+
+// RememberSearchResult records an image path, e.g. "/abc.jpg", returned by a TMDB search so it
+// can be fetched before any item in a library uses it.
+func RememberSearchResult(imagePath string) {
+	if imagePath == "" {
+		return
+	}
+
+	searchResults.Lock()
+	defer searchResults.Unlock()
+
+	now := time.Now()
+	if len(searchResults.expires) >= maxSearchResults {
+		for p, expires := range searchResults.expires {
+			if now.After(expires) {
+				delete(searchResults.expires, p)
+			}
+		}
+	}
+	// Make room for the newest results, they are the ones clients are about to show.
+	for p := range searchResults.expires {
+		if len(searchResults.expires) < maxSearchResults {
+			break
+		}
+		delete(searchResults.expires, p)
+	}
+	searchResults.expires[imagePath] = now.Add(searchResultValidity)
+}
+
+// IsSearchResult reports whether the image path was returned by a recent TMDB search.
+func IsSearchResult(imagePath string) bool {
+	searchResults.Lock()
+	defer searchResults.Unlock()
+
+	expires, ok := searchResults.expires[imagePath]
+	return ok && time.Now().Before(expires)
+}
+
+// DownloadTMDB fetches an image from themoviedb.
+func DownloadTMDB(size string, id string) ([]byte, error) {
+	url := fmt.Sprintf("http://image.tmdb.org/t/p/%s/%s", size, id)
+	log.WithField("url", url).Debugln("Downloading image")
+
+	response, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("could not reach image url '%s': %s", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("image url '%s' returned %s", url, response.Status)
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+// TMDBPlaceholder downloads a small version of the TMDB image at the given path, e.g.
+// "/abc.jpg", and computes its placeholder.
+func TMDBPlaceholder(imagePath string) (Placeholder, error) {
+	data, err := DownloadTMDB(placeholderSize, strings.TrimPrefix(imagePath, "/"))
+	if err != nil {
+		return Placeholder{}, err
+	}
+	img, err := Decode(data)
+	if err != nil {
+		return Placeholder{}, err
+	}
+	return NewPlaceholder(img), nil
+}
@@ -0,0 +1,31 @@
+package images
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchResults(t *testing.T) {
+	assert.False(t, IsSearchResult("/search.jpg"))
+	RememberSearchResult("/search.jpg")
+	assert.True(t, IsSearchResult("/search.jpg"))
+
+	RememberSearchResult("")
+	assert.False(t, IsSearchResult(""))
+
+	searchResults.Lock()
+	searchResults.expires["/search.jpg"] = time.Now().Add(-time.Second)
+	searchResults.Unlock()
+	assert.False(t, IsSearchResult("/search.jpg"), "search results expire")
+}
+
+func TestSearchResults_Bounded(t *testing.T) {
+	for i := 0; i < maxSearchResults+100; i++ {
+		RememberSearchResult(fmt.Sprintf("/%d.jpg", i))
+	}
+	assert.Len(t, searchResults.expires, maxSearchResults)
+	assert.True(t, IsSearchResult(fmt.Sprintf("/%d.jpg", maxSearchResults+99)), "the newest result is kept")
+}
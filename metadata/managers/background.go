@@ -0,0 +1,89 @@
+package managers
+
+import (
+	"context"
+	"sync"
+)
+
+// backgroundJob runs a function in its own goroutine whenever it's queued. Queueing it while it
+// runs makes it run once more afterwards, anything beyond that is coalesced.
+type backgroundJob struct {
+	queue chan bool
+}
+
+func newBackgroundJob() *backgroundJob {
+	return &backgroundJob{queue: make(chan bool, 1)}
+}
+
+// Queue makes the job run soon without waiting for it.
+func (j *backgroundJob) Queue() {
+	select {
+	case j.queue <- true:
+	default:
+	}
+}
+
+// run executes fn whenever the job is queued until exit is closed.
+func (j *backgroundJob) run(fn func(), exit chan bool) {
+	for {
+		select {
+		case <-j.queue:
+			fn()
+		case <-exit:
+			return
+		}
+	}
+}
+
+// runningEncode tracks the job a queue is encoding, so it can be cancelled and its progress can
+// be reported while it runs.
+type runningEncode struct {
+	mtx sync.Mutex
+	// id is the ID of the job being encoded, 0 if none is.
+	id       uint
+	progress float64
+	cancel   context.CancelFunc
+}
+
+// start marks the job as running. It returns the context to encode with and a function that must
+// be called once the encode finished.
+func (e *runningEncode) start(id uint) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.mtx.Lock()
+	e.id, e.progress, e.cancel = id, 0, cancel
+	e.mtx.Unlock()
+
+	return ctx, func() {
+		cancel()
+		e.mtx.Lock()
+		e.id, e.progress, e.cancel = 0, 0, nil
+		e.mtx.Unlock()
+	}
+}
+
+func (e *runningEncode) setProgress(progress float64) {
+	e.mtx.Lock()
+	e.progress = progress
+	e.mtx.Unlock()
+}
+
+// progressOf returns the share of the job that has been encoded, or 0 if it isn't running.
+func (e *runningEncode) progressOf(id uint) float64 {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if e.id != id {
+		return 0
+	}
+	return e.progress
+}
+
+// cancelIfRunning stops the encode if the job is running and returns whether it was.
+func (e *runningEncode) cancelIfRunning(id uint) bool {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	if e.id != id || e.cancel == nil {
+		return false
+	}
+	e.cancel()
+	return true
+}
@@ -0,0 +1,235 @@
+package managers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// defaultDownloadExpiry is how long downloads can be fetched once they are ready, unless
+// configured otherwise.
+const defaultDownloadExpiry = 72 * time.Hour
+
+// DownloadManager encodes the downloads users requested in the background on the encode queue,
+// in the order they were requested, and removes them once they expire.
+type DownloadManager struct {
+	queue   *EncodeQueue
+	startup sync.Once
+	running runningEncode
+}
+
+// DownloadRequest describes the rendition of a file a user wants to download.
+type DownloadRequest struct {
+	FileUUID    string
+	Profile     string
+	VideoFormat string
+	// AudioStreamIDs selects the audio streams to include, all of them if nil.
+	AudioStreamIDs []int64
+	// SubtitleStreamIDs selects the embedded text subtitles to include, none if nil.
+	SubtitleStreamIDs []int64
+	UserID            uint
+}
+
+// NewDownloadManager creates a DownloadManager that runs the downloads that are still queued or
+// were interrupted by a restart on the encode queue.
+func NewDownloadManager(queue *EncodeQueue) *DownloadManager {
+	m := &DownloadManager{queue: queue}
+	queue.add(m.runNext)
+
+	expiryTicker := time.NewTicker(time.Hour)
+	go func() {
+		for range expiryTicker.C {
+			RemoveExpiredDownloads()
+		}
+	}()
+	return m
+}
+
+func downloadsDir() string {
+	if dir := viper.GetString("transcoding.downloadsDir"); dir != "" {
+		return dir
+	}
+	return path.Join(helpers.BaseConfigDir(), "downloads")
+}
+
+func downloadExpiry() time.Duration {
+	if hours := viper.GetInt("transcoding.downloadExpiryHours"); hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return defaultDownloadExpiry
+}
+
+// DownloadPath returns where the file of the download is stored.
+func DownloadPath(download *db.Download) string {
+	return path.Join(downloadsDir(), fmt.Sprintf("%d.mp4", download.ID))
+}
+
+// Request queues a download of the movie or episode file.
+func (m *DownloadManager) Request(req DownloadRequest) (*db.Download, error) {
+	file := db.FindContentByUUID(req.FileUUID)
+	if file == nil {
+		return nil, fmt.Errorf("no file with UUID %s", req.FileUUID)
+	}
+	if req.Profile == "" {
+		req.Profile = ffmpeg.DefaultTranscodingProfile
+	}
+	if _, ok := ffmpeg.FindTranscodingProfile(req.Profile); !ok {
+		return nil, fmt.Errorf("no transcoding profile \"%s\"", req.Profile)
+	}
+	if req.VideoFormat == "" {
+		req.VideoFormat = ffmpeg.VideoCodecH264
+	}
+
+	audioStreamIDs := req.AudioStreamIDs
+	if audioStreamIDs == nil {
+		for _, s := range file.GetStreams() {
+			if s.StreamType == "audio" {
+				audioStreamIDs = append(audioStreamIDs, s.StreamId)
+			}
+		}
+	}
+
+	download := &db.Download{
+		UserID:            req.UserID,
+		FileUUID:          req.FileUUID,
+		Profile:           req.Profile,
+		VideoCodec:        req.VideoFormat,
+		AudioStreamIDs:    db.JoinStreamIDs(audioStreamIDs),
+		SubtitleStreamIDs: db.JoinStreamIDs(req.SubtitleStreamIDs),
+		FileName:          strings.TrimSuffix(file.GetFileName(), path.Ext(file.GetFileName())) + ".mp4",
+	}
+	if err := db.CreateDownload(download); err != nil {
+		return nil, err
+	}
+	m.queue.Queue()
+	return download, nil
+}
+
+// Delete stops the download if it is being encoded and removes it and its file.
+func (m *DownloadManager) Delete(downloadID uint) error {
+	download, err := db.FindDownload(downloadID)
+	if err != nil {
+		return err
+	}
+	if m.running.cancelIfRunning(downloadID) {
+		// The download is deleted once its ffmpeg process exited
+		return nil
+	}
+	removeDownload(download)
+	return nil
+}
+
+// Progress returns the share of the download that has been encoded, or 0 if it isn't running.
+func (m *DownloadManager) Progress(downloadID uint) float64 {
+	return m.running.progressOf(downloadID)
+}
+
+// runNext encodes the oldest queued download and returns false if there is none. The first time,
+// downloads interrupted by a restart are queued again and the ones that expired meanwhile removed.
+func (m *DownloadManager) runNext() bool {
+	m.startup.Do(func() {
+		if err := db.RequeueRunningDownloads(); err != nil {
+			log.WithError(err).Warnln("Failed to requeue interrupted downloads")
+		}
+		RemoveExpiredDownloads()
+	})
+	download, ok := db.NextQueuedDownload()
+	if !ok {
+		return false
+	}
+	m.runDownload(download)
+	return true
+}
+
+func (m *DownloadManager) runDownload(download *db.Download) {
+	ctx, finish := m.running.start(download.ID)
+	defer finish()
+
+	download.State = db.DownloadRunning
+	db.SaveDownload(download)
+	logger := log.WithFields(log.Fields{"fileUUID": download.FileUUID, "profile": download.Profile,
+		"videoCodec": download.VideoCodec, "userID": download.UserID})
+	logger.Infoln("Creating download")
+
+	size, err := m.encode(ctx, download)
+	if ctx.Err() != nil {
+		logger.Infoln("Download was cancelled")
+		removeDownload(download)
+		return
+	}
+
+	if err != nil {
+		logger.WithError(err).Warnln("Failed to create download")
+		download.State, download.Error = db.DownloadFailed, err.Error()
+	} else {
+		logger.Infoln("Created download")
+		expiresAt := time.Now().Add(downloadExpiry())
+		download.State, download.Size, download.ExpiresAt = db.DownloadReady, size, &expiresAt
+	}
+	db.SaveDownload(download)
+}
+
+// encode creates the file of the download and returns its size.
+func (m *DownloadManager) encode(ctx context.Context, download *db.Download) (int64, error) {
+	source := db.FindContentByUUID(download.FileUUID)
+	if source == nil {
+		return 0, fmt.Errorf("file no longer exists")
+	}
+	sourceLocator, err := filesystem.ParseFileLocator(source.GetFilePath())
+	if err != nil {
+		return 0, err
+	}
+	streams, err := ffmpeg.GetStreams(sourceLocator)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to probe file")
+	}
+
+	output := DownloadPath(download)
+	if err := os.MkdirAll(path.Dir(output), 0755); err != nil {
+		return 0, err
+	}
+	opts := ffmpeg.OptimizeOptions{
+		Profile:           ffmpeg.GetTranscodingProfile(download.Profile),
+		VideoFormat:       download.VideoCodec,
+		Deinterlace:       source.GetLibrary().Deinterlace,
+		AudioStreamIDs:    download.AudioStreamIDList(),
+		SubtitleStreamIDs: download.SubtitleStreamIDList(),
+	}
+	if err := ffmpeg.Optimize(ctx, streams, opts, output, m.running.setProgress); err != nil {
+		return 0, err
+	}
+
+	info, err := os.Stat(output)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// RemoveExpiredDownloads deletes the downloads that expired and their files.
+func RemoveExpiredDownloads() {
+	for _, download := range db.FindExpiredDownloads(time.Now()) {
+		log.WithField("downloadID", download.ID).Debugln("Removing expired download")
+		removeDownload(&download)
+	}
+}
+
+func removeDownload(download *db.Download) {
+	err := os.Remove(DownloadPath(download))
+	if err != nil && !os.IsNotExist(err) {
+		log.WithError(err).WithField("downloadID", download.ID).Warnln("Failed to remove download")
+	}
+	db.DeleteDownload(download.ID)
+}
@@ -0,0 +1,51 @@
+package managers
+
+import "sync"
+
+// EncodeQueue runs the encodes of optimize jobs and downloads in the background. Only one of them
+// runs at a time, so together they never take more CPU than a single ffmpeg process.
+type EncodeQueue struct {
+	job *backgroundJob
+
+	mtx sync.Mutex
+	// sources run their oldest queued encode and return false if there was none.
+	sources []func() bool
+}
+
+// NewEncodeQueue creates an EncodeQueue. It runs for the lifetime of the server.
+func NewEncodeQueue() *EncodeQueue {
+	q := &EncodeQueue{job: newBackgroundJob()}
+	go q.job.run(q.process, make(chan bool))
+	return q
+}
+
+// add registers a source of encodes and runs what it has queued.
+func (q *EncodeQueue) add(next func() bool) {
+	q.mtx.Lock()
+	q.sources = append(q.sources, next)
+	q.mtx.Unlock()
+	q.Queue()
+}
+
+// Queue makes the queue look for new encodes soon.
+func (q *EncodeQueue) Queue() {
+	q.job.Queue()
+}
+
+// process takes turns running an encode of each source until none of them have any left,
+// including encodes queued meanwhile.
+func (q *EncodeQueue) process() {
+	for {
+		q.mtx.Lock()
+		sources := append([]func() bool{}, q.sources...)
+		q.mtx.Unlock()
+
+		ran := false
+		for _, next := range sources {
+			ran = next() || ran
+		}
+		if !ran {
+			return
+		}
+	}
+}
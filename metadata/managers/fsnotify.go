@@ -135,11 +135,13 @@ loop:
 				if movieFile, err := db.FindMovieFileByPath(n); err == nil {
 					log.WithField("path", event.Name).Debugf("deleting movie")
 					movieID := movieFile.MovieID
+					removeTrickplay(movieFile.FilePath)
 					movieFile.DeleteWithStreams()
 					man.metadataManager.GarbageCollectMovieIfRequired(movieID)
 				} else if episodeFile, err := db.FindEpisodeFileByPath(n); err == nil {
 					log.WithField("path", event.Name).Debugf("deleting episode")
 					episodeID := episodeFile.EpisodeID
+					removeTrickplay(episodeFile.FilePath)
 					episodeFile.DeleteWithStreams()
 					man.metadataManager.GarbageCollectEpisodeIfRequired(episodeID)
 				} else {
@@ -22,10 +22,22 @@ func FfmpegStreamFromDatabaseStream(s db.Stream) ffmpeg.Stream {
 		FrameRate:        s.FrameRate,
 		Width:            s.Width,
 		Height:           s.Height,
+		Channels:         s.Channels,
+		ChannelLayout:    s.ChannelLayout,
+
+		ColorTransfer:      s.ColorTransfer,
+		ColorPrimaries:     s.ColorPrimaries,
+		ColorSpace:         s.ColorSpace,
+		PixelFormat:        s.PixelFormat,
+		DolbyVisionProfile: s.DolbyVisionProfile,
+		FieldOrder:         s.FieldOrder,
+
 		StreamType:       s.StreamType,
 		Language:         s.Language,
 		Title:            s.Title,
 		EnabledByDefault: s.EnabledByDefault,
+		Forced:           s.Forced,
+		HearingImpaired:  s.HearingImpaired,
 	}
 }
 
@@ -46,9 +58,31 @@ func DatabaseStreamFromFfmpegStream(s ffmpeg.Stream) db.Stream {
 		FrameRate:        s.FrameRate,
 		Width:            s.Width,
 		Height:           s.Height,
+		Channels:         s.Channels,
+		ChannelLayout:    s.ChannelLayout,
+
+		ColorTransfer:      s.ColorTransfer,
+		ColorPrimaries:     s.ColorPrimaries,
+		ColorSpace:         s.ColorSpace,
+		PixelFormat:        s.PixelFormat,
+		DolbyVisionProfile: s.DolbyVisionProfile,
+		FieldOrder:         s.FieldOrder,
+
 		StreamType:       s.StreamType,
 		Language:         s.Language,
 		Title:            s.Title,
 		EnabledByDefault: s.EnabledByDefault,
+		Forced:           s.Forced,
+		HearingImpaired:  s.HearingImpaired,
+	}
+}
+
+// DatabaseChapterFromFfmpegChapter creates a database chapter for the chapter at the given index.
+func DatabaseChapterFromFfmpegChapter(index int, c ffmpeg.Chapter) db.Chapter {
+	return db.Chapter{
+		Index: index,
+		Start: c.Start,
+		End:   c.End,
+		Title: c.Title,
 	}
 }
@@ -44,6 +44,10 @@ type LibraryManager struct {
 	Library         *db.Library
 	exitChan        chan bool
 	isShuttingDown  bool
+
+	trickplayJob   *backgroundJob
+	markersJob     *backgroundJob
+	backgroundExit chan bool
 }
 
 // NewLibraryManager creates a new LibraryManager
@@ -54,6 +58,9 @@ func NewLibraryManager(lib *db.Library, metadataManager *metadata.MetadataManage
 		metadataManager: metadataManager,
 		Pool:            NewDefaultWorkerPool(),
 		exitChan:        make(chan bool),
+		trickplayJob:    newBackgroundJob(),
+		markersJob:      newBackgroundJob(),
+		backgroundExit:  make(chan bool),
 	}
 
 	manager.Watcher, err = fsnotify.NewWatcher()
@@ -62,6 +69,8 @@ func NewLibraryManager(lib *db.Library, metadataManager *metadata.MetadataManage
 	} else {
 	}
 	go manager.startWatcher(manager.exitChan)
+	go manager.trickplayJob.run(manager.generateTrickplay, manager.backgroundExit)
+	go manager.markersJob.run(manager.detectMarkers, manager.backgroundExit)
 	log.WithFields(log.Fields{"libraryID": lib.ID}).Println("Created new LibraryManager")
 
 	return &manager
@@ -72,6 +81,7 @@ func (man *LibraryManager) Shutdown() {
 	log.WithFields(log.Fields{"libraryID": man.Library.ID}).Debugln("Closing down LibraryManager")
 	man.isShuttingDown = true
 	man.exitChan <- true
+	close(man.backgroundExit)
 	man.Pool.Shutdown()
 }
 
@@ -84,14 +94,22 @@ func (man *LibraryManager) DeleteLibrary() error {
 		movieFiles, _ := db.FindMovieFilesInLibrary(man.Library.ID)
 		for _, movieFile := range movieFiles {
 			movieID := movieFile.MovieID
+			removeTrickplay(movieFile.FilePath)
 			movieFile.DeleteWithStreams()
+			if movieFile.IsOptimizedVersion() {
+				removeOptimizedFile(movieFile.FilePath)
+			}
 			man.metadataManager.GarbageCollectMovieIfRequired(movieID)
 		}
 	case db.MediaTypeSeries:
 		episodeFiles, _ := db.FindEpisodeFilesInLibrary(man.Library.ID)
 		for _, episodeFile := range episodeFiles {
 			episodeID := episodeFile.EpisodeID
+			removeTrickplay(episodeFile.FilePath)
 			episodeFile.DeleteWithStreams()
+			if episodeFile.IsOptimizedVersion() {
+				removeOptimizedFile(episodeFile.FilePath)
+			}
 			man.metadataManager.GarbageCollectEpisodeIfRequired(episodeID)
 		}
 	default:
@@ -318,6 +336,7 @@ func (man *LibraryManager) ProbeFile(n filesystem.Node) error {
 			},
 			Streams: collectStreams(streams),
 		}
+		episodeFile.Chapters, episodeFile.ChaptersProbed = collectChapters(n.FileLocator())
 
 		db.SaveEpisodeFile(&episodeFile)
 
@@ -337,6 +356,7 @@ func (man *LibraryManager) ProbeFile(n filesystem.Node) error {
 			},
 			Streams: collectStreams(streams),
 		}
+		movieFile.Chapters, movieFile.ChaptersProbed = collectChapters(n.FileLocator())
 		db.SaveMovieFile(&movieFile)
 
 		_, err := man.metadataManager.GetOrCreateMovieForMovieFile(&movieFile)
@@ -346,6 +366,11 @@ func (man *LibraryManager) ProbeFile(n filesystem.Node) error {
 		}
 	}
 
+	man.QueueTrickplay()
+	if library.Kind == db.MediaTypeSeries {
+		man.QueueMarkerDetection()
+	}
+
 	dur := time.Since(st)
 	log.WithFields(log.Fields{"duration": dur.Seconds(), "path": n.Path()}).Printf("done scanning file")
 	return nil
@@ -424,7 +449,9 @@ func (man *LibraryManager) RemoveMissingFiles(locator filesystem.FileLocator) {
 	for _, movieFile := range db.FindMovieFilesInLibraryByLocator(man.Library.ID, locator) {
 		if FileMissing(movieFile) {
 			movieID := movieFile.MovieID
+			removeTrickplay(movieFile.FilePath)
 			movieFile.DeleteWithStreams()
+			removeOptimizedMovieFiles(movieFile.ID)
 			man.metadataManager.GarbageCollectMovieIfRequired(movieID)
 		}
 	}
@@ -432,7 +459,9 @@ func (man *LibraryManager) RemoveMissingFiles(locator filesystem.FileLocator) {
 	for _, episodeFile := range db.FindEpisodeFilesInLibraryByLocator(man.Library.ID, locator) {
 		if FileMissing(episodeFile) {
 			episodeID := episodeFile.EpisodeID
+			removeTrickplay(episodeFile.FilePath)
 			episodeFile.DeleteWithStreams()
+			removeOptimizedEpisodeFiles(episodeFile.ID)
 			man.metadataManager.GarbageCollectEpisodeIfRequired(episodeID)
 		}
 	}
@@ -452,6 +481,9 @@ func (man *LibraryManager) RefreshAll() {
 
 	man.RescanFilesystem("")
 	man.IdentifyUnidentifiedFiles()
+	man.ProbeMissingChapters()
+	man.QueueTrickplay()
+	man.QueueMarkerDetection()
 }
 
 func checkPanic() {
@@ -477,3 +509,49 @@ func collectStreams(s *ffmpeg.Streams) []db.Stream {
 
 	return streams
 }
+
+// collectChapters returns the chapters of the file and whether probing them succeeded.
+func collectChapters(fileLocator filesystem.FileLocator) ([]db.Chapter, bool) {
+	chapters, err := ffmpeg.GetChapters(fileLocator)
+	if err != nil {
+		log.WithError(err).WithField("fileLocator", fileLocator.String()).
+			Warnln("Failed to probe chapters")
+		return nil, false
+	}
+
+	var dbChapters []db.Chapter
+	for i, c := range chapters {
+		dbChapters = append(dbChapters, DatabaseChapterFromFfmpegChapter(i, c))
+	}
+	return dbChapters, true
+}
+
+// ProbeMissingChapters stores the chapters of files that were added before chapters were
+// collected during scanning.
+func (man *LibraryManager) ProbeMissingChapters() {
+	for _, movieFile := range db.FindMovieFilesWithoutChapters(man.Library.ID) {
+		if man.isShuttingDown {
+			return
+		}
+		fileLocator, err := filesystem.ParseFileLocator(movieFile.FilePath)
+		if err != nil {
+			continue
+		}
+		if chapters, ok := collectChapters(fileLocator); ok {
+			db.ReplaceMovieFileChapters(&movieFile, chapters)
+		}
+	}
+
+	for _, episodeFile := range db.FindEpisodeFilesWithoutChapters(man.Library.ID) {
+		if man.isShuttingDown {
+			return
+		}
+		fileLocator, err := filesystem.ParseFileLocator(episodeFile.FilePath)
+		if err != nil {
+			continue
+		}
+		if chapters, ok := collectChapters(fileLocator); ok {
+			db.ReplaceEpisodeFileChapters(&episodeFile, chapters)
+		}
+	}
+}
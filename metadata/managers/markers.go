@@ -0,0 +1,86 @@
+package managers
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/markers"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// QueueMarkerDetection makes the library detect intros and credits of new episodes in the
+// background if it has marker detection enabled.
+func (man *LibraryManager) QueueMarkerDetection() {
+	if man.Library.Kind != db.MediaTypeSeries || !man.Library.DetectMarkers {
+		return
+	}
+	man.markersJob.Queue()
+}
+
+// detectMarkers analyses all seasons with episode files that haven't been analysed yet. Whole
+// seasons are analysed because intros are found by comparing episodes.
+func (man *LibraryManager) detectMarkers() {
+	attempted := map[uint]bool{}
+	for {
+		var pending []uint
+		for _, seasonID := range db.FindSeasonsWithUnanalyzedEpisodeFiles(man.Library.ID) {
+			if !attempted[seasonID] {
+				pending = append(pending, seasonID)
+			}
+		}
+		if len(pending) == 0 {
+			return
+		}
+
+		for _, seasonID := range pending {
+			if man.isShuttingDown || !man.Library.DetectMarkers {
+				return
+			}
+			attempted[seasonID] = true
+			detectSeasonMarkers(seasonID)
+		}
+	}
+}
+
+func detectSeasonMarkers(seasonID uint) {
+	st := time.Now()
+	var files []db.EpisodeFile
+	var fileLocators []filesystem.FileLocator
+	for _, f := range db.FindEpisodeFilesInSeason(seasonID) {
+		fileLocator, err := filesystem.ParseFileLocator(f.FilePath)
+		if err != nil {
+			log.WithError(err).WithField("filePath", f.FilePath).Warnln("Failed to parse file locator")
+			continue
+		}
+		files = append(files, f)
+		fileLocators = append(fileLocators, fileLocator)
+	}
+
+	for i, result := range markers.AnalyzeSeason(fileLocators) {
+		if result.Err != nil {
+			log.WithError(result.Err).WithField("filePath", files[i].FilePath).
+				Warnln("Failed to detect intro and credits")
+			continue
+		}
+
+		var detected []db.Marker
+		if result.Intro != nil {
+			detected = append(detected, db.Marker{
+				Kind: db.MarkerKindIntro, Start: result.Intro.Start, End: result.Intro.End})
+		}
+		if result.Credits != nil {
+			detected = append(detected, db.Marker{
+				Kind: db.MarkerKindCredits, Start: result.Credits.Start, End: result.Credits.End})
+		}
+		if err := db.SaveDetectedMarkers(files[i].ID, detected); err != nil {
+			log.WithError(err).WithField("filePath", files[i].FilePath).Warnln("Failed to save markers")
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"seasonID": seasonID,
+		"files":    len(files),
+		"duration": time.Since(st).Seconds(),
+	}).Infoln("Detected intros and credits")
+}
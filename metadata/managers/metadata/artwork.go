@@ -0,0 +1,20 @@
+package metadata
+
+import (
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// useLocalMovieArtwork links artwork extracted from the movie file if the agent has no poster.
+// The image itself is extracted when it's first requested from the image cache.
+func useLocalMovieArtwork(movie *db.Movie) {
+	if movie.UUID != "" && (movie.PosterPath == "" || db.IsLocalImagePath(movie.PosterPath)) {
+		movie.PosterPath = db.LocalImagePath(movie.UUID)
+	}
+}
+
+// useLocalEpisodeArtwork links a frame from the episode file if the agent has no still.
+func useLocalEpisodeArtwork(ep *db.Episode) {
+	if ep.UUID != "" && (ep.StillPath == "" || db.IsLocalImagePath(ep.StillPath)) {
+		ep.StillPath = db.LocalImagePath(ep.UUID)
+	}
+}
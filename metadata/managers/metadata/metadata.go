@@ -8,6 +8,7 @@ import (
 	"gitlab.com/olaris/olaris-server/metadata/agents"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 	mhelpers "gitlab.com/olaris/olaris-server/metadata/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/images"
 )
 
 // MetadataManager manages the metadata repository that is referenced by the files in the various
@@ -58,6 +59,37 @@ func (m *MetadataManager) RefreshAgentMetadataWithMissingArt() {
 	return
 }
 
+// RefreshImagePlaceholders computes the BlurHash and dominant colour of all agent artwork that
+// doesn't have them yet, e.g. because it was never requested from the image cache. Placeholders
+// of extracted artwork are computed when it's first requested.
+func (m *MetadataManager) RefreshImagePlaceholders() {
+	imagePaths := db.ImagesWithoutPlaceholder()
+	log.Debugln(len(imagePaths), " images appear to be missing placeholders.")
+
+	for _, imagePath := range imagePaths {
+		if db.IsLocalImagePath(imagePath) {
+			continue
+		}
+		placeholder, err := images.TMDBPlaceholder(imagePath)
+		if err != nil {
+			log.WithError(err).WithField("image", imagePath).Debugln("Could not compute image placeholder")
+			continue
+		}
+		db.SetImagePlaceholder(imagePath, placeholder.BlurHash, placeholder.Color)
+	}
+}
+
+// resetPlaceholders clears the placeholders of artwork that was changed by the agent, they are
+// computed again for the new images.
+func resetPlaceholders(item *db.BaseItem, posterPath string, backdropPath string) {
+	if item.PosterPath != posterPath {
+		item.PosterBlurHash, item.PosterColor = "", ""
+	}
+	if item.BackdropPath != backdropPath {
+		item.BackdropBlurHash, item.BackdropColor = "", ""
+	}
+}
+
 // RefreshAgentMetadataForUUID takes an UUID of a mediaitem and refreshes all metadata
 func (m *MetadataManager) RefreshAgentMetadataForUUID(UUID string) bool {
 
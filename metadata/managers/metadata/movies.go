@@ -47,10 +47,13 @@ func (m *MetadataManager) RefreshMovieMetadata(movie *db.Movie) error {
 // refreshMovieMetadataFromAgent updates the given struct with the latest metadata from the agent
 // but does not save the database record.
 func (m *MetadataManager) refreshMovieMetadataFromAgent(movie *db.Movie) error {
+	posterPath, backdropPath := movie.PosterPath, movie.BackdropPath
 	if err := m.agent.UpdateMovieMD(movie, movie.TmdbID); err != nil {
 		return errors.Wrapf(err,
 			"Failed to refresh metadata from agent for movie %s", movie.UUID)
 	}
+	useLocalMovieArtwork(movie)
+	resetPlaceholders(&movie.BaseItem, posterPath, backdropPath)
 	log.WithFields(log.Fields{"title": movie.Title, "tmdbID": movie.TmdbID}).
 		Println("refreshed metadata for movie")
 
@@ -165,6 +168,7 @@ func (m *MetadataManager) GetOrCreateMovieForMovieFile(
 		return nil, err
 	}
 
+	useLocalMovieArtwork(movie)
 	movieFile.Movie = *movie
 	// This automatically saves the Movie as well
 	db.SaveMovieFile(movieFile)
@@ -78,7 +78,12 @@ func (m *MetadataManager) RefreshSeriesMetadata(series *db.Series) error {
 
 // refreshSeriesMetadataFromAgent refreshes metadata but does not save.
 func (m *MetadataManager) refreshSeriesMetadataFromAgent(series *db.Series) error {
-	return m.agent.UpdateSeriesMD(series, series.TmdbID)
+	posterPath, backdropPath := series.PosterPath, series.BackdropPath
+	if err := m.agent.UpdateSeriesMD(series, series.TmdbID); err != nil {
+		return err
+	}
+	resetPlaceholders(&series.BaseItem, posterPath, backdropPath)
+	return nil
 }
 
 func (m *MetadataManager) RefreshEpisodeMetadata(ep *db.Episode) error {
@@ -98,8 +103,16 @@ func (m *MetadataManager) RefreshEpisodeMetadata(ep *db.Episode) error {
 
 // refreshEpisodeMetadataFromAgent updates the database record with the latest data from the agent
 func (m *MetadataManager) refreshEpisodeMetadataFromAgent(ep *db.Episode) error {
-	return m.agent.UpdateEpisodeMD(ep,
-		ep.GetSeries().TmdbID, ep.GetSeason().SeasonNumber, ep.EpisodeNum)
+	stillPath := ep.StillPath
+	if err := m.agent.UpdateEpisodeMD(ep,
+		ep.GetSeries().TmdbID, ep.GetSeason().SeasonNumber, ep.EpisodeNum); err != nil {
+		return err
+	}
+	useLocalEpisodeArtwork(ep)
+	if ep.StillPath != stillPath {
+		ep.StillBlurHash, ep.StillColor = "", ""
+	}
+	return nil
 }
 
 // RefreshSeasonMetadata refreshes and saves season metadata
@@ -115,11 +128,13 @@ func (m *MetadataManager) RefreshSeasonMetadata(season *db.Season) error {
 
 // refreshSeasonMetadataFromAgent refreshes metadata from the agent but does not save
 func (m *MetadataManager) refreshSeasonMetadataFromAgent(season *db.Season) error {
+	posterPath, backdropPath := season.PosterPath, season.BackdropPath
 	if err := m.agent.UpdateSeasonMD(
 		season, season.GetSeries().TmdbID, season.SeasonNumber); err != nil {
 		return errors.Wrapf(err,
 			"Failed to refresh metadata from agent for Season %s", season.UUID)
 	}
+	resetPlaceholders(&season.BaseItem, posterPath, backdropPath)
 	return nil
 }
 
@@ -227,6 +242,13 @@ func (m *MetadataManager) GetOrCreateEpisodeForEpisodeFile(
 		return nil, err
 	}
 
+	if episode.StillPath == "" {
+		useLocalEpisodeArtwork(episode)
+		if err := db.SaveEpisode(episode); err != nil {
+			return nil, err
+		}
+	}
+
 	episodeFile.Episode = episode
 	episodeFile.EpisodeID = episode.ID
 	db.SaveEpisodeFile(episodeFile)
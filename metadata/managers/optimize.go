@@ -0,0 +1,285 @@
+package managers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/helpers"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// OptimizeManager creates the optimized versions requested with optimize jobs in the background
+// on the encode queue, in the order they were requested.
+type OptimizeManager struct {
+	queue   *EncodeQueue
+	requeue sync.Once
+	running runningEncode
+}
+
+// NewOptimizeManager creates an OptimizeManager that runs the jobs that are still queued or were
+// interrupted by a restart on the encode queue.
+func NewOptimizeManager(queue *EncodeQueue) *OptimizeManager {
+	m := &OptimizeManager{queue: queue}
+	queue.add(m.runNext)
+	return m
+}
+
+func optimizedDir() string {
+	if dir := viper.GetString("transcoding.optimizedDir"); dir != "" {
+		return dir
+	}
+	return path.Join(helpers.BaseConfigDir(), "optimized")
+}
+
+// Request queues an optimize job for the movie or episode file with the given UUID. If the same
+// optimized version is already queued or being created, the existing job is returned.
+func (m *OptimizeManager) Request(fileUUID string, profileName string, videoFormat string, userID uint) (*db.OptimizeJob, error) {
+	file := db.FindContentByUUID(fileUUID)
+	if file == nil {
+		return nil, fmt.Errorf("no file with UUID %s", fileUUID)
+	}
+	if isOptimizedVersion(file) {
+		return nil, fmt.Errorf("file is already an optimized version")
+	}
+	if profileName == "" {
+		profileName = ffmpeg.DefaultTranscodingProfile
+	}
+	if _, ok := ffmpeg.FindTranscodingProfile(profileName); !ok {
+		return nil, fmt.Errorf("no transcoding profile \"%s\"", profileName)
+	}
+	if videoFormat == "" {
+		videoFormat = ffmpeg.VideoCodecH264
+	}
+
+	if job, ok := db.FindPendingOptimizeJob(fileUUID, profileName, videoFormat); ok {
+		return job, nil
+	}
+	if db.MovieFileExists(optimizedFileLocator(fileUUID, profileName, videoFormat).String()) ||
+		db.EpisodeFileExists(optimizedFileLocator(fileUUID, profileName, videoFormat).String()) {
+		return nil, fmt.Errorf("optimized version already exists")
+	}
+
+	job := &db.OptimizeJob{FileUUID: fileUUID, Profile: profileName, VideoCodec: videoFormat, UserID: userID}
+	if err := db.CreateOptimizeJob(job); err != nil {
+		return nil, err
+	}
+	m.queue.Queue()
+	return job, nil
+}
+
+// Cancel stops the job if it is running and removes it. The optimized version created by a job
+// that is done is kept.
+func (m *OptimizeManager) Cancel(jobID uint) error {
+	if _, err := db.FindOptimizeJob(jobID); err != nil {
+		return err
+	}
+
+	if m.running.cancelIfRunning(jobID) {
+		// The job is deleted once its ffmpeg process exited
+		return nil
+	}
+	return db.DeleteOptimizeJob(jobID)
+}
+
+// Progress returns the share of the job that has been encoded, or 0 if it isn't running.
+func (m *OptimizeManager) Progress(jobID uint) float64 {
+	return m.running.progressOf(jobID)
+}
+
+// runNext runs the oldest queued job and returns false if there is none. Jobs interrupted by a
+// restart are queued again the first time.
+func (m *OptimizeManager) runNext() bool {
+	m.requeue.Do(func() {
+		if err := db.RequeueRunningOptimizeJobs(); err != nil {
+			log.WithError(err).Warnln("Failed to requeue interrupted optimize jobs")
+		}
+	})
+	job, ok := db.NextQueuedOptimizeJob()
+	if !ok {
+		return false
+	}
+	m.runJob(job)
+	return true
+}
+
+func (m *OptimizeManager) runJob(job *db.OptimizeJob) {
+	ctx, finish := m.running.start(job.ID)
+	defer finish()
+
+	job.State = db.OptimizeJobRunning
+	db.SaveOptimizeJob(job)
+	logger := log.WithFields(log.Fields{"fileUUID": job.FileUUID, "profile": job.Profile, "videoCodec": job.VideoCodec})
+	logger.Infoln("Creating optimized version")
+
+	optimizedUUID, err := m.optimize(ctx, job)
+	if ctx.Err() != nil {
+		logger.Infoln("Optimize job was cancelled")
+		db.DeleteOptimizeJob(job.ID)
+		return
+	}
+
+	if err != nil {
+		logger.WithError(err).Warnln("Failed to create optimized version")
+		job.State, job.Error = db.OptimizeJobFailed, err.Error()
+	} else {
+		logger.Infoln("Created optimized version")
+		job.State, job.OptimizedUUID = db.OptimizeJobDone, optimizedUUID
+	}
+	db.SaveOptimizeJob(job)
+}
+
+// optimizedFileLocator returns where the optimized version of the file is stored.
+func optimizedFileLocator(fileUUID string, profileName string, videoFormat string) filesystem.FileLocator {
+	// HDR formats like "hevc:hdr" contain a colon
+	name := fmt.Sprintf("%s-%s-%s.mp4", fileUUID, profileName, strings.Replace(videoFormat, ":", "-", -1))
+	return filesystem.FileLocator{
+		Backend: filesystem.BackendLocal,
+		Path:    path.Join(optimizedDir(), name),
+	}
+}
+
+// optimize encodes the file of the job and stores it as an additional file of the same movie or
+// episode. It returns the UUID of the new file.
+func (m *OptimizeManager) optimize(ctx context.Context, job *db.OptimizeJob) (string, error) {
+	source := db.FindContentByUUID(job.FileUUID)
+	if source == nil {
+		return "", fmt.Errorf("file no longer exists")
+	}
+	sourceLocator, err := filesystem.ParseFileLocator(source.GetFilePath())
+	if err != nil {
+		return "", err
+	}
+	streams, err := ffmpeg.GetStreams(sourceLocator)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to probe file")
+	}
+	library := source.GetLibrary()
+
+	output := optimizedFileLocator(job.FileUUID, job.Profile, job.VideoCodec)
+	if err := os.MkdirAll(path.Dir(output.Path), 0755); err != nil {
+		return "", err
+	}
+	opts := ffmpeg.OptimizeOptions{
+		Profile:     ffmpeg.GetTranscodingProfile(job.Profile),
+		VideoFormat: job.VideoCodec,
+		Deinterlace: library.Deinterlace,
+	}
+	err = ffmpeg.Optimize(ctx, streams, opts, output.Path, m.running.setProgress)
+	if err != nil {
+		return "", err
+	}
+
+	node, err := filesystem.LocalNodeFromPath(output.Path)
+	if err != nil {
+		return "", err
+	}
+	optimizedStreams, err := ffmpeg.GetStreams(output)
+	if err != nil {
+		os.Remove(output.Path)
+		return "", errors.Wrap(err, "failed to probe optimized version")
+	}
+
+	item := db.MediaItem{
+		FileName:         node.Name(),
+		FilePath:         output.String(),
+		Size:             node.Size(),
+		LibraryID:        library.ID,
+		OptimizedProfile: job.Profile,
+	}
+	chapters, chaptersProbed := collectChapters(output)
+	item.ChaptersProbed = chaptersProbed
+
+	switch f := source.(type) {
+	case db.MovieFile:
+		item.OptimizedFromID = f.ID
+		optimized := db.MovieFile{
+			MediaItem: item,
+			MovieID:   f.MovieID,
+			Streams:   collectStreams(optimizedStreams),
+			Chapters:  chapters,
+		}
+		db.SaveMovieFile(&optimized)
+		return optimized.UUID, nil
+	case db.EpisodeFile:
+		item.OptimizedFromID = f.ID
+		optimized := db.EpisodeFile{
+			MediaItem: item,
+			EpisodeID: f.EpisodeID,
+			Streams:   collectStreams(optimizedStreams),
+			Chapters:  chapters,
+			// Markers of the source apply, optimized versions aren't analysed
+			MarkersAnalyzed: true,
+		}
+		if err := db.SaveEpisodeFile(&optimized); err != nil {
+			return "", err
+		}
+		return optimized.UUID, nil
+	}
+	return "", fmt.Errorf("unsupported file type")
+}
+
+func isOptimizedVersion(file db.MediaFile) bool {
+	switch f := file.(type) {
+	case db.MovieFile:
+		return f.IsOptimizedVersion()
+	case db.EpisodeFile:
+		return f.IsOptimizedVersion()
+	}
+	return false
+}
+
+// DeleteOptimizedVersion removes the optimized version with the given UUID and its file.
+func DeleteOptimizedVersion(fileUUID string) error {
+	file := db.FindContentByUUID(fileUUID)
+	if file == nil {
+		return fmt.Errorf("no file with UUID %s", fileUUID)
+	}
+	if !isOptimizedVersion(file) {
+		return fmt.Errorf("file is not an optimized version")
+	}
+	switch f := file.(type) {
+	case db.MovieFile:
+		f.DeleteWithStreams()
+	case db.EpisodeFile:
+		f.DeleteWithStreams()
+	}
+	removeOptimizedFile(file.GetFilePath())
+	return nil
+}
+
+// removeOptimizedMovieFiles deletes the optimized versions of the movie file from the database and
+// disk, e.g. because the file itself is gone.
+func removeOptimizedMovieFiles(movieFileID uint) {
+	for _, f := range db.FindOptimizedMovieFiles(movieFileID) {
+		f.DeleteWithStreams()
+		removeOptimizedFile(f.FilePath)
+	}
+}
+
+// removeOptimizedEpisodeFiles deletes the optimized versions of the episode file from the database
+// and disk.
+func removeOptimizedEpisodeFiles(episodeFileID uint) {
+	for _, f := range db.FindOptimizedEpisodeFiles(episodeFileID) {
+		f.DeleteWithStreams()
+		removeOptimizedFile(f.FilePath)
+	}
+}
+
+func removeOptimizedFile(filePath string) {
+	fileLocator, err := filesystem.ParseFileLocator(filePath)
+	if err == nil && fileLocator.Backend == filesystem.BackendLocal {
+		err = os.Remove(fileLocator.Path)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		log.WithError(err).WithField("filePath", filePath).Warnln("Failed to remove optimized version")
+	}
+}
@@ -0,0 +1,92 @@
+package managers
+
+import (
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/trickplay"
+)
+
+// QueueTrickplay makes the library generate missing trickplay images in the background if it has
+// trickplay enabled. Calls while images are being generated are coalesced.
+func (man *LibraryManager) QueueTrickplay() {
+	if !man.Library.Trickplay {
+		return
+	}
+	man.trickplayJob.Queue()
+}
+
+// generateTrickplay generates images for all files without them, including files that are added
+// while it's running. Files that fail are not retried until the next run.
+func (man *LibraryManager) generateTrickplay() {
+	attempted := map[string]bool{}
+	for {
+		pending := man.filesWithoutTrickplay(attempted)
+		if len(pending) == 0 {
+			return
+		}
+
+		for _, filePath := range pending {
+			if man.isShuttingDown || !man.Library.Trickplay {
+				return
+			}
+			attempted[filePath] = true
+
+			fileLocator, err := filesystem.ParseFileLocator(filePath)
+			if err == nil {
+				err = trickplay.Generate(fileLocator)
+			}
+			if err != nil {
+				log.WithError(err).WithField("filePath", filePath).
+					Warnln("Failed to generate trickplay images")
+			}
+		}
+	}
+}
+
+// mediaFilePaths returns the file locators of the files in the library. Optimized versions are
+// left out, they share the trickplay images of their source.
+func (man *LibraryManager) mediaFilePaths() []string {
+	var filePaths []string
+	switch man.Library.Kind {
+	case db.MediaTypeMovie:
+		movieFiles, _ := db.FindMovieFilesInLibrary(man.Library.ID)
+		for _, f := range movieFiles {
+			if !f.IsOptimizedVersion() {
+				filePaths = append(filePaths, f.FilePath)
+			}
+		}
+	case db.MediaTypeSeries:
+		episodeFiles, _ := db.FindEpisodeFilesInLibrary(man.Library.ID)
+		for _, f := range episodeFiles {
+			if !f.IsOptimizedVersion() {
+				filePaths = append(filePaths, f.FilePath)
+			}
+		}
+	}
+	return filePaths
+}
+
+func (man *LibraryManager) filesWithoutTrickplay(skip map[string]bool) []string {
+	var pending []string
+	for _, filePath := range man.mediaFilePaths() {
+		if !skip[filePath] && !trickplay.Available(filePath) {
+			pending = append(pending, filePath)
+		}
+	}
+	return pending
+}
+
+// RemoveTrickplay deletes the trickplay images of all files in the library.
+func (man *LibraryManager) RemoveTrickplay() {
+	for _, filePath := range man.mediaFilePaths() {
+		removeTrickplay(filePath)
+	}
+}
+
+func removeTrickplay(filePath string) {
+	if err := trickplay.Remove(filePath); err != nil {
+		log.WithError(err).WithField("filePath", filePath).
+			Warnln("Failed to remove trickplay images")
+	}
+}
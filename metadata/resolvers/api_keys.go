@@ -0,0 +1,170 @@
+package resolvers
+
+import (
+	"context"
+	"time"
+
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// APIKeyResolver resolves an API key. It never exposes the key itself.
+type APIKeyResolver struct {
+	r db.APIKey
+}
+
+// ID returns the API key ID.
+func (r *APIKeyResolver) ID() int32 {
+	return int32(r.r.ID)
+}
+
+// Name returns the name the key was given on creation.
+func (r *APIKeyResolver) Name() string {
+	return r.r.Name
+}
+
+// Scopes returns the scopes granted to the key.
+func (r *APIKeyResolver) Scopes() []string {
+	return r.r.ScopeList()
+}
+
+// User returns the user the key acts on behalf of.
+func (r *APIKeyResolver) User() (*UserResolver, error) {
+	user, err := db.FindUser(r.r.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &UserResolver{*user}, nil
+}
+
+// CreatedAt returns the creation time of the key in RFC3339 format.
+func (r *APIKeyResolver) CreatedAt() string {
+	return r.r.CreatedAt.Format(time.RFC3339)
+}
+
+// LastUsedAt returns the time the key was last used in RFC3339 format.
+func (r *APIKeyResolver) LastUsedAt() *string {
+	return formatOptionalTime(r.r.LastUsedAt)
+}
+
+// RevokedAt returns the time the key was revoked in RFC3339 format.
+func (r *APIKeyResolver) RevokedAt() *string {
+	return formatOptionalTime(r.r.RevokedAt)
+}
+
+// Revoked returns true if the key can no longer be used.
+func (r *APIKeyResolver) Revoked() bool {
+	return r.r.Revoked()
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
+
+// APIKeys returns all API keys.
+func (r *Resolver) APIKeys(ctx context.Context) (keys []*APIKeyResolver) {
+	err := ifAdmin(ctx)
+	if err == nil {
+		for _, key := range db.AllAPIKeys() {
+			keys = append(keys, &APIKeyResolver{key})
+		}
+	}
+	return keys
+}
+
+// CreateAPIKeyResponse is returned when creating an API key.
+type CreateAPIKeyResponse struct {
+	Error  *ErrorResolver
+	Key    *string
+	APIKey *APIKeyResolver
+}
+
+// CreateAPIKeyResponseResolver resolves CreateAPIKeyResponse.
+type CreateAPIKeyResponseResolver struct {
+	r CreateAPIKeyResponse
+}
+
+// Error returns error.
+func (r *CreateAPIKeyResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Key returns the full API key, this is the only time it is available.
+func (r *CreateAPIKeyResponseResolver) Key() *string {
+	return r.r.Key
+}
+
+// APIKey returns the created API key.
+func (r *CreateAPIKeyResponseResolver) APIKey() *APIKeyResolver {
+	return r.r.APIKey
+}
+
+type createAPIKeyArgs struct {
+	Name   string
+	Scopes []string
+	UserID *int32
+}
+
+// CreateAPIKey creates a new API key.
+func (r *Resolver) CreateAPIKey(ctx context.Context, args *createAPIKeyArgs) *CreateAPIKeyResponseResolver {
+	err := ifAdmin(ctx)
+	if err != nil {
+		return &CreateAPIKeyResponseResolver{CreateAPIKeyResponse{Error: CreateErrResolver(err)}}
+	}
+
+	currentUserID, _ := auth.UserID(ctx)
+	ownerID := currentUserID
+	if args.UserID != nil {
+		ownerID = uint(*args.UserID)
+	}
+
+	key, token, err := db.CreateAPIKey(args.Name, ownerID, currentUserID, args.Scopes)
+	if err != nil {
+		return &CreateAPIKeyResponseResolver{CreateAPIKeyResponse{Error: CreateErrResolver(err)}}
+	}
+	auditAdminMutation(ctx, "createAPIKey", "created API key '%s' (%d) for user %d with scopes %s",
+		key.Name, key.ID, key.UserID, key.Scopes)
+
+	return &CreateAPIKeyResponseResolver{CreateAPIKeyResponse{Key: &token, APIKey: &APIKeyResolver{*key}}}
+}
+
+// APIKeyResponse holds an API key and an error if needed.
+type APIKeyResponse struct {
+	Error  *ErrorResolver
+	APIKey *APIKeyResolver
+}
+
+// APIKeyResponseResolver resolves APIKeyResponse.
+type APIKeyResponseResolver struct {
+	r APIKeyResponse
+}
+
+// Error returns error.
+func (r *APIKeyResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// APIKey returns the API key.
+func (r *APIKeyResponseResolver) APIKey() *APIKeyResolver {
+	return r.r.APIKey
+}
+
+// RevokeAPIKey revokes the given API key.
+func (r *Resolver) RevokeAPIKey(ctx context.Context, args struct{ ID int32 }) *APIKeyResponseResolver {
+	err := ifAdmin(ctx)
+	if err != nil {
+		return &APIKeyResponseResolver{APIKeyResponse{Error: CreateErrResolver(err)}}
+	}
+
+	key, err := db.RevokeAPIKey(uint(args.ID))
+	if err != nil {
+		return &APIKeyResponseResolver{APIKeyResponse{Error: CreateErrResolver(err)}}
+	}
+	auditAdminMutation(ctx, "revokeAPIKey", "revoked API key '%s' (%d)", key.Name, key.ID)
+
+	return &APIKeyResponseResolver{APIKeyResponse{APIKey: &APIKeyResolver{*key}}}
+}
@@ -0,0 +1,108 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// AuditLogEntryResolver resolves an audit log entry.
+type AuditLogEntryResolver struct {
+	r db.AuditLogEntry
+}
+
+// ID returns the entry ID.
+func (r *AuditLogEntryResolver) ID() int32 {
+	return int32(r.r.ID)
+}
+
+// Event returns the kind of event.
+func (r *AuditLogEntryResolver) Event() string {
+	return r.r.Event
+}
+
+// Time returns when the event happened in RFC3339 format.
+func (r *AuditLogEntryResolver) Time() string {
+	return r.r.CreatedAt.Format(time.RFC3339)
+}
+
+// UserID returns the ID of the user that caused the event, if known.
+func (r *AuditLogEntryResolver) UserID() *int32 {
+	if r.r.UserID == 0 {
+		return nil
+	}
+	id := int32(r.r.UserID)
+	return &id
+}
+
+// Username returns the username involved in the event.
+func (r *AuditLogEntryResolver) Username() string {
+	return r.r.Username
+}
+
+// IP returns the client IP address.
+func (r *AuditLogEntryResolver) IP() string {
+	return r.r.IP
+}
+
+// Action returns the performed mutation, if any.
+func (r *AuditLogEntryResolver) Action() string {
+	return r.r.Action
+}
+
+// Details returns additional information about the event.
+func (r *AuditLogEntryResolver) Details() string {
+	return r.r.Details
+}
+
+type auditLogArgs struct {
+	Offset *int32
+	Limit  *int32
+	Event  *string
+	UserID *int32
+}
+
+// AuditLog returns audit log entries, newest first.
+func (r *Resolver) AuditLog(ctx context.Context, args *auditLogArgs) []*AuditLogEntryResolver {
+	entries := []*AuditLogEntryResolver{}
+	if err := ifAdmin(ctx); err != nil {
+		return entries
+	}
+
+	filter := db.AuditLogFilter{}
+	if args.Event != nil {
+		filter.Event = *args.Event
+	}
+	if args.UserID != nil {
+		filter.UserID = uint(*args.UserID)
+	}
+
+	for _, entry := range db.FindAuditLogEntries(filter, buildDatabaseQueryDetails(args.Offset, args.Limit)) {
+		entries = append(entries, &AuditLogEntryResolver{entry})
+	}
+	return entries
+}
+
+// auditAdminMutation records a mutation performed by an admin in the audit log.
+func auditAdminMutation(ctx context.Context, action string, format string, a ...interface{}) {
+	auditEvent(ctx, db.AuditEventAdminMutation, action, fmt.Sprintf(format, a...))
+}
+
+func auditEvent(ctx context.Context, event string, action string, details string) {
+	entry := db.AuditLogEntry{
+		Event:   event,
+		IP:      auth.ClientIPFromContext(ctx),
+		Action:  action,
+		Details: details,
+	}
+	if userID, ok := auth.UserID(ctx); ok {
+		entry.UserID = userID
+		if user, err := db.FindUser(userID); err == nil {
+			entry.Username = user.Username
+		}
+	}
+	db.AddAuditLogEntry(&entry)
+}
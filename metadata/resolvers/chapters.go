@@ -0,0 +1,41 @@
+package resolvers
+
+import (
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// ChapterResolver resolves a chapter of a media file.
+type ChapterResolver struct {
+	r db.Chapter
+}
+
+// Index returns the position of the chapter in the file.
+func (r *ChapterResolver) Index() int32 {
+	return int32(r.r.Index)
+}
+
+// Start returns the start of the chapter in seconds.
+func (r *ChapterResolver) Start() float64 {
+	return r.r.Start.Seconds()
+}
+
+// End returns the end of the chapter in seconds.
+func (r *ChapterResolver) End() float64 {
+	return r.r.End.Seconds()
+}
+
+// Title returns the chapter title, if the file names its chapters.
+func (r *ChapterResolver) Title() *string {
+	if r.r.Title == "" {
+		return nil
+	}
+	return &r.r.Title
+}
+
+func chapterResolvers(chapters []db.Chapter) []*ChapterResolver {
+	resolvers := []*ChapterResolver{}
+	for _, c := range chapters {
+		resolvers = append(resolvers, &ChapterResolver{r: c})
+	}
+	return resolvers
+}
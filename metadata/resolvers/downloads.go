@@ -0,0 +1,227 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/metadata/managers"
+)
+
+// DownloadResolver resolves a download requested to watch offline.
+type DownloadResolver struct {
+	r        db.Download
+	progress float64
+}
+
+// ID returns the ID of the download.
+func (r *DownloadResolver) ID() int32 {
+	return int32(r.r.ID)
+}
+
+// FileUUID returns the UUID of the file that is downloaded.
+func (r *DownloadResolver) FileUUID() string {
+	return r.r.FileUUID
+}
+
+// FileName returns the name the file is saved as.
+func (r *DownloadResolver) FileName() string {
+	return r.r.FileName
+}
+
+// Profile returns the transcoding profile the file is encoded with.
+func (r *DownloadResolver) Profile() string {
+	return r.r.Profile
+}
+
+// VideoCodec returns the codec the video is encoded to.
+func (r *DownloadResolver) VideoCodec() string {
+	return r.r.VideoCodec
+}
+
+// AudioStreamIDs returns the audio streams included in the download.
+func (r *DownloadResolver) AudioStreamIDs() []int32 {
+	return streamIDsToInt32(r.r.AudioStreamIDList())
+}
+
+// SubtitleStreamIDs returns the subtitle streams included in the download.
+func (r *DownloadResolver) SubtitleStreamIDs() []int32 {
+	return streamIDsToInt32(r.r.SubtitleStreamIDList())
+}
+
+// State returns whether the download is queued, running, ready or failed.
+func (r *DownloadResolver) State() string {
+	return r.r.State
+}
+
+// Progress returns the share of the file encoded so far.
+func (r *DownloadResolver) Progress() float64 {
+	return r.progress
+}
+
+// Error returns why the download could not be created.
+func (r *DownloadResolver) Error() *string {
+	if r.r.Error == "" {
+		return nil
+	}
+	return &r.r.Error
+}
+
+// FileSize returns the size of the file in bytes.
+func (r *DownloadResolver) FileSize() string {
+	return strconv.FormatInt(r.r.Size, 10)
+}
+
+// URL returns the signed URL of the file once the download is ready.
+func (r *DownloadResolver) URL() *string {
+	if r.r.State != db.DownloadReady || r.r.ExpiresAt == nil || r.r.Expired(time.Now()) {
+		return nil
+	}
+	expires, signature, err := auth.SignDownload(r.r.ID, r.r.UserID, *r.r.ExpiresAt)
+	if err != nil {
+		log.WithError(err).Warnln("Could not sign download URL")
+		return nil
+	}
+	query := url.Values{"expires": {expires}, "signature": {signature}}
+	u := fmt.Sprintf("/olaris/m/v1/downloads/%d/%s?%s", r.r.ID, url.PathEscape(r.r.FileName), query.Encode())
+	return &u
+}
+
+// ExpiresAt returns when the download is removed in RFC3339 format.
+func (r *DownloadResolver) ExpiresAt() *string {
+	if r.r.ExpiresAt == nil {
+		return nil
+	}
+	expiresAt := r.r.ExpiresAt.Format(time.RFC3339)
+	return &expiresAt
+}
+
+// CreatedAt returns when the download was requested in RFC3339 format.
+func (r *DownloadResolver) CreatedAt() string {
+	return r.r.CreatedAt.Format(time.RFC3339)
+}
+
+func streamIDsToInt32(ids []int64) []int32 {
+	converted := []int32{}
+	for _, id := range ids {
+		converted = append(converted, int32(id))
+	}
+	return converted
+}
+
+func streamIDsFromInt32(ids *[]int32) []int64 {
+	if ids == nil {
+		return nil
+	}
+	converted := []int64{}
+	for _, id := range *ids {
+		converted = append(converted, int64(id))
+	}
+	return converted
+}
+
+// DownloadResponse is returned when downloads are requested or deleted.
+type DownloadResponse struct {
+	Error    *ErrorResolver
+	Download *DownloadResolver
+}
+
+// DownloadResponseResolver resolves DownloadResponse.
+type DownloadResponseResolver struct {
+	r DownloadResponse
+}
+
+// Error returns error.
+func (r *DownloadResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Download returns the download.
+func (r *DownloadResponseResolver) Download() *DownloadResolver {
+	return r.r.Download
+}
+
+func downloadErrResponse(err error) *DownloadResponseResolver {
+	return &DownloadResponseResolver{DownloadResponse{Error: CreateErrResolver(err)}}
+}
+
+func (r *Resolver) downloadResolver(download db.Download) *DownloadResolver {
+	return &DownloadResolver{r: download, progress: r.downloads.Progress(download.ID)}
+}
+
+// Downloads returns the downloads of the user.
+func (r *Resolver) Downloads(ctx context.Context) []*DownloadResolver {
+	downloads := []*DownloadResolver{}
+	userID, ok := auth.UserID(ctx)
+	if !ok {
+		return downloads
+	}
+	for _, download := range db.FindDownloads(userID) {
+		downloads = append(downloads, r.downloadResolver(download))
+	}
+	return downloads
+}
+
+// CreateDownload queues a download of a movie or episode file.
+func (r *Resolver) CreateDownload(ctx context.Context, args struct {
+	UUID              string
+	Profile           *string
+	VideoCodec        *string
+	AudioStreamIDs    *[]int32
+	SubtitleStreamIDs *[]int32
+}) *DownloadResponseResolver {
+	if err := ifUserSession(ctx); err != nil {
+		return downloadErrResponse(err)
+	}
+	file := db.FindContentByUUID(args.UUID)
+	if file == nil {
+		return downloadErrResponse(fmt.Errorf("no file found for UUID %s", args.UUID))
+	}
+	if err := ifLibraryAccess(ctx, file.GetLibrary().ID); err != nil {
+		return downloadErrResponse(err)
+	}
+
+	userID, _ := auth.UserID(ctx)
+	req := managers.DownloadRequest{
+		FileUUID:          args.UUID,
+		AudioStreamIDs:    streamIDsFromInt32(args.AudioStreamIDs),
+		SubtitleStreamIDs: streamIDsFromInt32(args.SubtitleStreamIDs),
+		UserID:            userID,
+	}
+	if args.Profile != nil {
+		req.Profile = *args.Profile
+	}
+	if args.VideoCodec != nil {
+		req.VideoFormat = *args.VideoCodec
+	}
+	download, err := r.downloads.Request(req)
+	if err != nil {
+		return downloadErrResponse(err)
+	}
+	return &DownloadResponseResolver{DownloadResponse{Download: r.downloadResolver(*download)}}
+}
+
+// DeleteDownload stops a download and removes it and its file.
+func (r *Resolver) DeleteDownload(ctx context.Context, args struct{ ID int32 }) *DownloadResponseResolver {
+	download, err := db.FindDownload(uint(args.ID))
+	if err != nil {
+		return downloadErrResponse(fmt.Errorf("download could not be found"))
+	}
+	userID, _ := auth.UserID(ctx)
+	if ifAdmin(ctx) != nil && (userID == 0 || download.UserID != userID) {
+		return downloadErrResponse(CreateNoAuthorisationError())
+	}
+
+	if err := r.downloads.Delete(download.ID); err != nil {
+		return downloadErrResponse(err)
+	}
+	if download.UserID != userID {
+		auditAdminMutation(ctx, "deleteDownload", "deleted download %d of user %d", download.ID, download.UserID)
+	}
+	return &DownloadResponseResolver{DownloadResponse{Download: &DownloadResolver{r: *download}}}
+}
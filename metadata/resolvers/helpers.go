@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
 )
 
 // CreateNoAuthorisationError returns a standard error for unauthorised requests.
@@ -18,3 +19,68 @@ func ifAdmin(ctx context.Context) error {
 	}
 	return CreateNoAuthorisationError()
 }
+
+// ifScope checks whether the request may act within the given scope. This only
+// restricts requests that were authenticated with an API key.
+func ifScope(ctx context.Context, scope string) error {
+	if auth.HasScope(ctx, scope) {
+		return nil
+	}
+	return CreateNoAuthorisationError()
+}
+
+// ifAdminOrScope allows admins, or API keys that have been granted the given scope.
+func ifAdminOrScope(ctx context.Context, scope string) error {
+	if _, ok := auth.APIKeyScopes(ctx); ok {
+		return ifScope(ctx, scope)
+	}
+	return ifAdmin(ctx)
+}
+
+// ifUserSession only allows requests that were made by a logged-in user, not by an API key.
+// Use this for changes to the account itself.
+func ifUserSession(ctx context.Context) error {
+	if _, ok := auth.APIKeyScopes(ctx); ok {
+		return CreateNoAuthorisationError()
+	}
+	if _, ok := auth.UserID(ctx); !ok {
+		return CreateNoAuthorisationError()
+	}
+	return nil
+}
+
+// accessibleLibraryIDs returns the libraries the current user may see, nil means all of them.
+func accessibleLibraryIDs(ctx context.Context) []uint {
+	userID, ok := auth.UserID(ctx)
+	if !ok {
+		return []uint{}
+	}
+	libraryIDs, restricted := db.AccessibleLibraryIDs(userID)
+	if !restricted {
+		return nil
+	}
+	return libraryIDs
+}
+
+// ifLibraryAccess checks whether the current user has been granted access to the library.
+func ifLibraryAccess(ctx context.Context, libraryID uint) error {
+	userID, _ := auth.UserID(ctx)
+	if db.CanAccessLibrary(userID, libraryID) {
+		return nil
+	}
+	return CreateNoAuthorisationError()
+}
+
+// ifMediaItemAccess checks whether the movie or episode with the given UUID is in a library the
+// current user has been granted access to. Unknown items are treated the same so they can't be
+// told apart from hidden ones.
+func ifMediaItemAccess(ctx context.Context, uuid string) error {
+	libraryIDs := accessibleLibraryIDs(ctx)
+	if movie, err := db.FindMovieByUUID(uuid); err == nil && db.MovieInLibraries(movie.ID, libraryIDs) {
+		return nil
+	}
+	if episode, err := db.FindEpisodeByUUID(uuid); err == nil && db.EpisodeInLibraries(episode, libraryIDs) {
+		return nil
+	}
+	return CreateNoAuthorisationError()
+}
@@ -0,0 +1,68 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// TODO: get these dynamically from TMDB at server startup
+var (
+	posterWidths   = []int32{92, 154, 185, 342, 500, 780}
+	backdropWidths = []int32{300, 780, 1280}
+	stillWidths    = []int32{92, 185, 300}
+)
+
+// imageURL returns a signed URL for the TMDB image in the smallest available width that is at
+// least the requested one, which the image cache scales down to the exact width. A width of 0
+// requests the original. Artwork extracted from media files only exists in its original size. The
+// URL is signed for the user of the request, only libraries they can access are served with it.
+func imageURL(ctx context.Context, imagePath string, width int32, availableWidths []int32) string {
+	if imagePath == "" {
+		return ""
+	}
+
+	provider, id := "tmdb", strings.TrimPrefix(imagePath, "/")
+	if db.IsLocalImagePath(imagePath) {
+		provider, id = "local", strings.TrimPrefix(imagePath, db.LocalImagePrefix)
+		availableWidths = nil
+	}
+
+	size := "original"
+	resize := width > 0
+	if width > 0 {
+		for _, currentWidth := range availableWidths {
+			if currentWidth >= width {
+				size = fmt.Sprintf("w%d", currentWidth)
+				resize = currentWidth != width
+				break
+			}
+		}
+	}
+
+	userID, _ := auth.UserID(ctx)
+	expires, signature, err := auth.SignImage(provider, size, id, userID)
+	if err != nil {
+		log.WithError(err).Warnln("Could not sign image URL")
+		return ""
+	}
+	query := url.Values{"user": {fmt.Sprint(userID)}, "expires": {expires}, "signature": {signature}}
+	if resize {
+		query.Set("width", fmt.Sprint(width))
+	}
+	return fmt.Sprintf("/olaris/m/images/%s/%s/%s?%s", provider, size, id, query.Encode())
+}
+
+// tmdbImagePath returns the image path for the *Path fields, from which clients build TMDB image
+// URLs. Artwork extracted from media files is only available through the signed URLs.
+func tmdbImagePath(imagePath string) string {
+	if db.IsLocalImagePath(imagePath) {
+		return ""
+	}
+	return imagePath
+}
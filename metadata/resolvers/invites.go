@@ -2,6 +2,9 @@ package resolvers
 
 import (
 	"context"
+	"time"
+
+	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 )
 
@@ -10,12 +13,17 @@ type InviteResolver struct {
 	r db.Invite
 }
 
+// ID returns the invite ID.
+func (ir *InviteResolver) ID() int32 {
+	return int32(ir.r.ID)
+}
+
 // Code returns the invite code.
 func (ir *InviteResolver) Code() *string {
 	return &ir.r.Code
 }
 
-// User returns the user who redeemed this invite.
+// User returns the first user who redeemed this invite.
 func (ir *InviteResolver) User() (*UserResolver, error) {
 	if ir.r.UserID != 0 {
 		user, err := db.FindUser(ir.r.UserID)
@@ -28,6 +36,82 @@ func (ir *InviteResolver) User() (*UserResolver, error) {
 	return nil, nil
 }
 
+// Note returns the note the invite was created with.
+func (ir *InviteResolver) Note() string {
+	return ir.r.Note
+}
+
+// CreatedAt returns the creation time of the invite in RFC3339 format.
+func (ir *InviteResolver) CreatedAt() string {
+	return ir.r.CreatedAt.Format(time.RFC3339)
+}
+
+// CreatedBy returns the admin who created the invite.
+func (ir *InviteResolver) CreatedBy() *UserResolver {
+	if ir.r.CreatedByID == 0 {
+		return nil
+	}
+	user, err := db.FindUser(ir.r.CreatedByID)
+	if err != nil {
+		// The admin might have been deleted since.
+		return nil
+	}
+	return &UserResolver{*user}
+}
+
+// ExpiresAt returns the time the invite expires in RFC3339 format.
+func (ir *InviteResolver) ExpiresAt() *string {
+	return formatOptionalTime(ir.r.ExpiresAt)
+}
+
+// MaxUses returns the number of users that can sign up with this invite.
+func (ir *InviteResolver) MaxUses() int32 {
+	return int32(ir.r.MaxUses)
+}
+
+// Uses returns the number of users that signed up with this invite.
+func (ir *InviteResolver) Uses() int32 {
+	return int32(ir.r.Uses)
+}
+
+// Revoked returns true if the invite has been revoked.
+func (ir *InviteResolver) Revoked() bool {
+	return ir.r.Revoked()
+}
+
+// Expired returns true if the invite expired.
+func (ir *InviteResolver) Expired() bool {
+	return ir.r.Expired(time.Now())
+}
+
+// Admin returns whether users signing up with this invite become admin.
+func (ir *InviteResolver) Admin() bool {
+	return ir.r.Admin
+}
+
+// LibraryIDs returns the libraries users of this invite get access to.
+func (ir *InviteResolver) LibraryIDs() []int32 {
+	ids := []int32{}
+	for _, id := range ir.r.LibraryIDList() {
+		ids = append(ids, int32(id))
+	}
+	return ids
+}
+
+// RedeemedBy returns all users that signed up with this invite.
+func (ir *InviteResolver) RedeemedBy() []*UserResolver {
+	users := []*UserResolver{}
+	for _, redemption := range ir.r.Redemptions {
+		user, err := db.FindUser(redemption.UserID)
+		if err != nil {
+			// Deleted users are not listed.
+			continue
+		}
+		users = append(users, &UserResolver{*user})
+	}
+	return users
+}
+
 // Invites returns all current invites.
 func (r *Resolver) Invites(ctx context.Context) *[]*InviteResolver {
 	var invites []*InviteResolver
@@ -43,8 +127,9 @@ func (r *Resolver) Invites(ctx context.Context) *[]*InviteResolver {
 
 // UserInviteResponse response when creating a new invite.
 type UserInviteResponse struct {
-	Error *ErrorResolver
-	Code  string
+	Error  *ErrorResolver
+	Code   string
+	Invite *InviteResolver
 }
 
 // UserInviteResponseResolver resolver.
@@ -62,13 +147,90 @@ func (r *UserInviteResponseResolver) Code() string {
 	return r.r.Code
 }
 
+// Invite returns the created invite.
+func (r *UserInviteResponseResolver) Invite() *InviteResolver {
+	return r.r.Invite
+}
+
+type createUserInviteArgs struct {
+	Note          *string
+	ValidForHours *int32
+	MaxUses       *int32
+	Admin         *bool
+	LibraryIDs    *[]int32
+}
+
 // CreateUserInvite creates a new invite code.
-func (r *Resolver) CreateUserInvite(ctx context.Context) *UserInviteResponseResolver {
+func (r *Resolver) CreateUserInvite(ctx context.Context, args *createUserInviteArgs) *UserInviteResponseResolver {
 	//TODO(Maran): Refactor all this error/not-error response stuff.
 	err := ifAdmin(ctx)
-	if err == nil {
-		invite := db.CreateInvite()
-		return &UserInviteResponseResolver{&UserInviteResponse{Error: nil, Code: invite.Code}}
+	if err != nil {
+		return &UserInviteResponseResolver{&UserInviteResponse{Error: CreateErrResolver(err), Code: ""}}
+	}
+
+	createdByID, _ := auth.UserID(ctx)
+	options := db.InviteOptions{MaxUses: 1, CreatedByID: createdByID}
+	if args.Note != nil {
+		options.Note = *args.Note
+	}
+	if args.ValidForHours != nil {
+		options.ValidFor = time.Duration(*args.ValidForHours) * time.Hour
 	}
-	return &UserInviteResponseResolver{&UserInviteResponse{Error: CreateErrResolver(err), Code: ""}}
+	if args.MaxUses != nil {
+		options.MaxUses = int(*args.MaxUses)
+	}
+	if args.Admin != nil {
+		options.Admin = *args.Admin
+	}
+	if args.LibraryIDs != nil {
+		for _, id := range *args.LibraryIDs {
+			options.LibraryIDs = append(options.LibraryIDs, uint(id))
+		}
+	}
+
+	invite, err := db.CreateInvite(options)
+	if err != nil {
+		return &UserInviteResponseResolver{&UserInviteResponse{Error: CreateErrResolver(err), Code: ""}}
+	}
+	auditAdminMutation(ctx, "createUserInvite", "created invite %d (max uses %d, admin %t, libraries '%s')",
+		invite.ID, invite.MaxUses, invite.Admin, invite.LibraryIDs)
+
+	return &UserInviteResponseResolver{&UserInviteResponse{Code: invite.Code, Invite: &InviteResolver{invite}}}
+}
+
+// InviteResponse holds an invite and an error if needed.
+type InviteResponse struct {
+	Error  *ErrorResolver
+	Invite *InviteResolver
+}
+
+// InviteResponseResolver resolves InviteResponse.
+type InviteResponseResolver struct {
+	r InviteResponse
+}
+
+// Error returns error.
+func (r *InviteResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Invite returns the invite.
+func (r *InviteResponseResolver) Invite() *InviteResolver {
+	return r.r.Invite
+}
+
+// RevokeInvite revokes the given invite.
+func (r *Resolver) RevokeInvite(ctx context.Context, args struct{ ID int32 }) *InviteResponseResolver {
+	err := ifAdmin(ctx)
+	if err != nil {
+		return &InviteResponseResolver{InviteResponse{Error: CreateErrResolver(err)}}
+	}
+
+	invite, err := db.RevokeInvite(uint(args.ID))
+	if err != nil {
+		return &InviteResponseResolver{InviteResponse{Error: CreateErrResolver(err)}}
+	}
+	auditAdminMutation(ctx, "revokeInvite", "revoked invite %d", invite.ID)
+
+	return &InviteResponseResolver{InviteResponse{Invite: &InviteResolver{*invite}}}
 }
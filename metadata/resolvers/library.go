@@ -57,6 +57,26 @@ func (r *LibraryResolver) RcloneName() *string {
 	return &r.r.RcloneName
 }
 
+// Trickplay returns whether scrub preview thumbnails are generated for the library.
+func (r *LibraryResolver) Trickplay() bool {
+	return r.r.Trickplay
+}
+
+// DetectMarkers returns whether intros and credits are detected for the library.
+func (r *LibraryResolver) DetectMarkers() bool {
+	return r.r.DetectMarkers
+}
+
+// TranscodingProfile returns the transcoding profile of the library, empty for the default one.
+func (r *LibraryResolver) TranscodingProfile() string {
+	return r.r.TranscodingProfile
+}
+
+// Deinterlace returns how interlaced video in the library is deinterlaced, empty for the default.
+func (r *LibraryResolver) Deinterlace() string {
+	return r.r.Deinterlace
+}
+
 // ID returns library ID
 func (r *LibraryResolver) ID() int32 {
 	return int32(r.r.ID)
@@ -102,11 +122,15 @@ func (r *LibraryResolver) Kind() int32 {
 }
 
 type createLibraryArgs struct {
-	Name       string
-	FilePath   string
-	Kind       int32
-	Backend    int32
-	RcloneName *string
+	Name               string
+	FilePath           string
+	Kind               int32
+	Backend            int32
+	RcloneName         *string
+	Trickplay          *bool
+	DetectMarkers      *bool
+	TranscodingProfile *string
+	Deinterlace        *string
 }
 
 // RefreshAgentMetadata refreshes all metadata from agent
@@ -121,6 +145,8 @@ func (r *Resolver) RefreshAgentMetadata(ctx context.Context, args struct {
 	}
 
 	if args.LibraryID != nil {
+		auditAdminMutation(ctx, "refreshAgentMetadata", "refreshed metadata of library %d", *args.LibraryID)
+
 		// TODO(Leon Handreke): Either add a refresh-per-library call to the LibraryManager
 		//  or make this a global update call without a Library ID
 
@@ -142,6 +168,7 @@ func (r *Resolver) RefreshAgentMetadata(ctx context.Context, args struct {
 	}
 
 	if args.UUID != nil {
+		auditAdminMutation(ctx, "refreshAgentMetadata", "refreshed metadata of item %s", *args.UUID)
 		return r.env.MetadataManager.RefreshAgentMetadataForUUID(*args.UUID)
 	}
 
@@ -153,7 +180,7 @@ func (r *Resolver) RescanLibrary(ctx context.Context, args struct {
 	ID       *int32
 	FilePath *string
 }) bool {
-	err := ifAdmin(ctx)
+	err := ifAdminOrScope(ctx, db.APIKeyScopeLibraryRescan)
 	if err != nil {
 		return false
 	}
@@ -164,6 +191,8 @@ func (r *Resolver) RescanLibrary(ctx context.Context, args struct {
 			return false
 		}
 
+		auditAdminMutation(ctx, "rescanLibrary", "rescanned '%s' in all libraries", *args.FilePath)
+
 		// A valid filepath has been given so let's look in all libraries for the given path
 		validLibFound := false
 		for _, man := range r.libs {
@@ -183,10 +212,12 @@ func (r *Resolver) RescanLibrary(ctx context.Context, args struct {
 
 	// No specific filepath has been given so we can refresh the whole library.
 	if args.FilePath == nil {
+		auditAdminMutation(ctx, "rescanLibrary", "rescanned library %d", libId)
 		go mhelpers.WithLock(func() {
 			man.RefreshAll()
 		}, fmt.Sprintf("refresh-lib-%s", strconv.Itoa(int(man.Library.ID))))
 	} else {
+		auditAdminMutation(ctx, "rescanLibrary", "rescanned '%s' in library %d", *args.FilePath, libId)
 		go mhelpers.WithLock(func() {
 			man.RescanFilesystem(*args.FilePath)
 		}, fmt.Sprintf("refresh-lib-%s", strconv.Itoa(int(man.Library.ID))))
@@ -196,12 +227,13 @@ func (r *Resolver) RescanLibrary(ctx context.Context, args struct {
 
 // RescanLibraries rescans all libraries for new files.
 func (r *Resolver) RescanLibraries(ctx context.Context) bool {
-	err := ifAdmin(ctx)
+	err := ifAdminOrScope(ctx, db.APIKeyScopeLibraryRescan)
 	if err != nil {
 		return false
 	}
 
 	if rescanningLibraries == false {
+		auditAdminMutation(ctx, "rescanLibraries", "rescanned all libraries")
 		rescanningLibraries = true
 		go func() {
 			for _, lm := range r.libs {
@@ -227,6 +259,7 @@ func (r *Resolver) DeleteLibrary(ctx context.Context, args struct{ ID int32 }) *
 	// We are stopping the watcher to then remove the library manager
 	libraryManager.Shutdown()
 	libraryManager.DeleteLibrary()
+	auditAdminMutation(ctx, "deleteLibrary", "deleted library '%s' (%d)", library.Name, library.ID)
 
 	var libRes LibraryResponse
 	// TODO(Maran): Dry up resolver creation here and in CreateLibrary
@@ -263,6 +296,24 @@ func (r *Resolver) CreateLibrary(ctx context.Context, args *createLibraryArgs) *
 	}
 
 	library = db.Library{Name: args.Name, FilePath: args.FilePath, Kind: db.MediaType(args.Kind), Backend: int(args.Backend), RcloneName: rcloneName}
+	if args.Trickplay != nil {
+		library.Trickplay = *args.Trickplay
+	}
+	if args.DetectMarkers != nil {
+		library.DetectMarkers = *args.DetectMarkers
+	}
+	if args.TranscodingProfile != nil {
+		if err := validTranscodingProfile(*args.TranscodingProfile); err != nil {
+			return errResponse(err)
+		}
+		library.TranscodingProfile = *args.TranscodingProfile
+	}
+	if args.Deinterlace != nil {
+		if err := validDeinterlacing(*args.Deinterlace); err != nil {
+			return errResponse(err)
+		}
+		library.Deinterlace = *args.Deinterlace
+	}
 
 	// Make sure we don't initialize the library with zero time (issue with strict mode in MySQL)
 	library.RefreshStartedAt = time.Now().Add(defaultTimeOffset)
@@ -272,6 +323,7 @@ func (r *Resolver) CreateLibrary(ctx context.Context, args *createLibraryArgs) *
 
 	if err == nil {
 		r.AddLibraryManager(&library)
+		auditAdminMutation(ctx, "createLibrary", "created library '%s' at '%s'", library.Name, library.FilePath)
 		libRes = LibraryResponse{Library: &LibraryResolver{Library{library, nil, nil}}}
 	} else {
 		// TODO(Maran): We probably want to not do this in the resolver but in the database layer so that it gets scanned no matter how you add it.
@@ -281,6 +333,68 @@ func (r *Resolver) CreateLibrary(ctx context.Context, args *createLibraryArgs) *
 	return &LibResResolv{libRes}
 }
 
+// UpdateLibrary changes the settings of a library.
+func (r *Resolver) UpdateLibrary(ctx context.Context, args struct {
+	ID                 int32
+	Trickplay          *bool
+	DetectMarkers      *bool
+	TranscodingProfile *string
+	Deinterlace        *string
+}) *LibResResolv {
+	if err := ifAdmin(ctx); err != nil {
+		return errResponse(err)
+	}
+
+	man, ok := r.libs[uint(args.ID)]
+	if !ok {
+		return errResponse(fmt.Errorf("library %d could not be found", args.ID))
+	}
+	library := man.Library
+
+	if args.Trickplay != nil && *args.Trickplay != library.Trickplay {
+		library.Trickplay = *args.Trickplay
+		db.SaveLibrary(library)
+		auditAdminMutation(ctx, "updateLibrary", "set trickplay of library '%s' (%d) to %t",
+			library.Name, library.ID, library.Trickplay)
+
+		if library.Trickplay {
+			man.QueueTrickplay()
+		} else {
+			go man.RemoveTrickplay()
+		}
+	}
+
+	if args.DetectMarkers != nil && *args.DetectMarkers != library.DetectMarkers {
+		library.DetectMarkers = *args.DetectMarkers
+		db.SaveLibrary(library)
+		auditAdminMutation(ctx, "updateLibrary", "set marker detection of library '%s' (%d) to %t",
+			library.Name, library.ID, library.DetectMarkers)
+		man.QueueMarkerDetection()
+	}
+
+	if args.TranscodingProfile != nil && *args.TranscodingProfile != library.TranscodingProfile {
+		if err := validTranscodingProfile(*args.TranscodingProfile); err != nil {
+			return errResponse(err)
+		}
+		library.TranscodingProfile = *args.TranscodingProfile
+		db.SaveLibrary(library)
+		auditAdminMutation(ctx, "updateLibrary", "set transcoding profile of library '%s' (%d) to '%s'",
+			library.Name, library.ID, library.TranscodingProfile)
+	}
+
+	if args.Deinterlace != nil && *args.Deinterlace != library.Deinterlace {
+		if err := validDeinterlacing(*args.Deinterlace); err != nil {
+			return errResponse(err)
+		}
+		library.Deinterlace = *args.Deinterlace
+		db.SaveLibrary(library)
+		auditAdminMutation(ctx, "updateLibrary", "set deinterlacing of library '%s' (%d) to '%s'",
+			library.Name, library.ID, library.Deinterlace)
+	}
+
+	return &LibResResolv{LibraryResponse{Library: &LibraryResolver{Library{*library, nil, nil}}}}
+}
+
 // LibResResolv holds a library response.
 type LibResResolv struct {
 	r LibraryResponse
@@ -305,8 +419,14 @@ type LibraryResponse struct {
 // Libraries return all libraries.
 func (r *Resolver) Libraries(ctx context.Context) []*LibraryResolver {
 	var l []*LibraryResolver
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return l
+	}
 	libraries := db.AllLibraries()
 	for _, library := range libraries {
+		if ifLibraryAccess(ctx, library.ID) != nil {
+			continue
+		}
 		list := Library{library, nil, nil}
 		lib := LibraryResolver{r: list}
 		l = append(l, &lib)
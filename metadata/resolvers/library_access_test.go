@@ -0,0 +1,97 @@
+package resolvers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func TestLibraryGrantsApplyToItemsByUUID(t *testing.T) {
+	r := NewResolver(app.NewTestingMDContext(nil))
+	_, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+
+	movies := db.Library{Name: "Movies", FilePath: t.TempDir()}
+	require.NoError(t, db.AddLibrary(&movies))
+	shows := db.Library{Name: "Shows", FilePath: t.TempDir(), Kind: db.MediaTypeSeries}
+	require.NoError(t, db.AddLibrary(&shows))
+
+	movie := db.Movie{Title: "Movie", MovieFiles: []db.MovieFile{
+		{MediaItem: db.MediaItem{FilePath: "local#/movies/a.mkv", LibraryID: movies.ID}}}}
+	db.SaveMovie(&movie)
+	series := db.Series{Name: "Series"}
+	db.SaveSeries(&series)
+	season := db.Season{SeasonNumber: 1, SeriesID: series.ID}
+	db.SaveSeason(&season)
+	episode := db.Episode{Name: "Episode", SeasonID: season.ID, EpisodeNum: 1, EpisodeFiles: []db.EpisodeFile{
+		{MediaItem: db.MediaItem{FilePath: "local#/shows/a.mkv", LibraryID: shows.ID}}}}
+	db.CreateEpisode(&episode)
+
+	// Only has access to the movies, the series is in a library without a grant
+	restricted, err := db.CreateUser("restricted", "testtest", false)
+	require.NoError(t, err)
+	require.NoError(t, db.GrantLibraries(restricted.ID, []uint{movies.ID}))
+	ctx := auth.ContextWithUserID(context.Background(), restricted.ID)
+	// And the other way around
+	showsOnly, err := db.CreateUser("showsonly", "testtest", false)
+	require.NoError(t, err)
+	require.NoError(t, db.GrantLibraries(showsOnly.ID, []uint{shows.ID}))
+	showsCtx := auth.ContextWithUserID(context.Background(), showsOnly.ID)
+	for _, userID := range []uint{restricted.ID, showsOnly.ID} {
+		for _, uuid := range []string{movie.UUID, episode.UUID} {
+			db.SavePlayState(&db.PlayState{Playtime: 33, MediaUUID: uuid, UserID: userID})
+		}
+	}
+
+	assert.Len(t, r.Movies(ctx, &queryArgs{UUID: &movie.UUID}), 1)
+	assert.Empty(t, r.Series(ctx, &queryArgs{UUID: &series.UUID}))
+	assert.Empty(t, r.Season(ctx, &mustUUIDArgs{UUID: &season.UUID}).UUID())
+	assert.Empty(t, r.Episode(ctx, &mustUUIDArgs{UUID: &episode.UUID}).UUID())
+	_, err = r.SeasonChanged(ctx, &seasonChangedArgs{SeriesUUID: &series.UUID})
+	assert.Error(t, err)
+
+	upNext := *r.UpNext(ctx)
+	require.Len(t, upNext, 1)
+	upNextMovie, ok := upNext[0].ToMovie()
+	require.True(t, ok)
+	assert.Equal(t, movie.UUID, upNextMovie.UUID())
+
+	assert.Empty(t, r.Movies(showsCtx, &queryArgs{UUID: &movie.UUID}))
+	assert.Len(t, r.Series(showsCtx, &queryArgs{UUID: &series.UUID}), 1)
+	assert.Equal(t, season.UUID, r.Season(showsCtx, &mustUUIDArgs{UUID: &season.UUID}).UUID())
+	assert.Equal(t, episode.UUID, r.Episode(showsCtx, &mustUUIDArgs{UUID: &episode.UUID}).UUID())
+	upNext = *r.UpNext(showsCtx)
+	require.Len(t, upNext, 1)
+	_, ok = upNext[0].ToEpisode()
+	assert.True(t, ok)
+}
+
+func TestCreatePlayState_LibraryGrants(t *testing.T) {
+	r := NewResolver(app.NewTestingMDContext(nil))
+	_, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+
+	visible := db.Movie{Title: "Visible", MovieFiles: []db.MovieFile{
+		{MediaItem: db.MediaItem{FilePath: "local#/movies/visible.mkv", LibraryID: 1}}}}
+	db.SaveMovie(&visible)
+	hidden := db.Movie{Title: "Hidden", MovieFiles: []db.MovieFile{
+		{MediaItem: db.MediaItem{FilePath: "local#/movies/hidden.mkv", LibraryID: 2}}}}
+	db.SaveMovie(&hidden)
+
+	restricted, err := db.CreateUser("restricted", "testtest", false)
+	require.NoError(t, err)
+	require.NoError(t, db.GrantLibraries(restricted.ID, []uint{1}))
+	ctx := auth.ContextWithUserID(context.Background(), restricted.ID)
+
+	assert.True(t, r.CreatePlayState(ctx, &playStateArgs{UUID: visible.UUID, Playtime: 33}).Success())
+	assert.False(t, r.CreatePlayState(ctx, &playStateArgs{UUID: hidden.UUID, Playtime: 33}).Success())
+	assert.False(t, r.CreatePlayState(ctx, &playStateArgs{UUID: "does-not-exist", Playtime: 33}).Success())
+
+	_, err = db.FindPlayState(hidden.UUID, restricted.ID)
+	assert.Error(t, err, "no playstate should have been saved for the hidden movie")
+}
@@ -0,0 +1,153 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// MarkerResolver resolves an intro or credits marker.
+type MarkerResolver struct {
+	r db.Marker
+}
+
+// Kind returns whether this is the intro or the credits.
+func (r *MarkerResolver) Kind() string {
+	return r.r.Kind
+}
+
+// Start returns the start of the marker in seconds.
+func (r *MarkerResolver) Start() float64 {
+	return r.r.Start.Seconds()
+}
+
+// End returns the end of the marker in seconds.
+func (r *MarkerResolver) End() float64 {
+	return r.r.End.Seconds()
+}
+
+// Manual returns true if the marker was set by hand.
+func (r *MarkerResolver) Manual() bool {
+	return r.r.Manual
+}
+
+func markerResolvers(episodeFileID uint) []*MarkerResolver {
+	resolvers := []*MarkerResolver{}
+	for _, m := range db.FindMarkersForEpisodeFile(episodeFileID) {
+		resolvers = append(resolvers, &MarkerResolver{r: m})
+	}
+	return resolvers
+}
+
+// Markers returns the intro and credits of the episode file.
+func (r *EpisodeFileResolver) Markers() []*MarkerResolver {
+	return markerResolvers(r.r.ID)
+}
+
+// MarkerResponse is returned when markers are changed.
+type MarkerResponse struct {
+	Error   *ErrorResolver
+	Markers *[]*MarkerResolver
+}
+
+// MarkerResponseResolver resolves MarkerResponse.
+type MarkerResponseResolver struct {
+	r MarkerResponse
+}
+
+// Error returns error.
+func (r *MarkerResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Markers returns the markers of the file after the change.
+func (r *MarkerResponseResolver) Markers() *[]*MarkerResolver {
+	return r.r.Markers
+}
+
+func markerErrResponse(err error) *MarkerResponseResolver {
+	return &MarkerResponseResolver{MarkerResponse{Error: CreateErrResolver(err)}}
+}
+
+func markerResponse(episodeFileID uint) *MarkerResponseResolver {
+	markers := markerResolvers(episodeFileID)
+	return &MarkerResponseResolver{MarkerResponse{Markers: &markers}}
+}
+
+func validMarkerKind(kind string) error {
+	if kind != db.MarkerKindIntro && kind != db.MarkerKindCredits {
+		return fmt.Errorf("unknown marker kind '%s'", kind)
+	}
+	return nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// SetEpisodeFileMarker sets the intro or credits of an episode file by hand.
+func (r *Resolver) SetEpisodeFileMarker(ctx context.Context, args struct {
+	EpisodeFileUUID string
+	Kind            string
+	Start           *float64
+	End             *float64
+}) *MarkerResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return markerErrResponse(err)
+	}
+	if err := validMarkerKind(args.Kind); err != nil {
+		return markerErrResponse(err)
+	}
+	if (args.Start == nil) != (args.End == nil) {
+		return markerErrResponse(fmt.Errorf("start and end have to be given together"))
+	}
+
+	var start, end time.Duration
+	if args.Start != nil {
+		start, end = secondsToDuration(*args.Start), secondsToDuration(*args.End)
+		if start < 0 || end <= start {
+			return markerErrResponse(fmt.Errorf("end has to be after start"))
+		}
+	}
+
+	episodeFile, err := db.FindEpisodeFileByUUID(args.EpisodeFileUUID)
+	if err != nil {
+		return markerErrResponse(fmt.Errorf("episode file could not be found"))
+	}
+	if _, err := db.SetManualMarker(episodeFile.ID, args.Kind, start, end); err != nil {
+		return markerErrResponse(err)
+	}
+	auditAdminMutation(ctx, "setEpisodeFileMarker", "set %s of '%s' to %s-%s",
+		args.Kind, episodeFile.FileName, start, end)
+
+	return markerResponse(episodeFile.ID)
+}
+
+// ResetEpisodeFileMarker removes the intro or credits of an episode file and detects them again.
+func (r *Resolver) ResetEpisodeFileMarker(ctx context.Context, args struct {
+	EpisodeFileUUID string
+	Kind            string
+}) *MarkerResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return markerErrResponse(err)
+	}
+	if err := validMarkerKind(args.Kind); err != nil {
+		return markerErrResponse(err)
+	}
+
+	episodeFile, err := db.FindEpisodeFileByUUID(args.EpisodeFileUUID)
+	if err != nil {
+		return markerErrResponse(fmt.Errorf("episode file could not be found"))
+	}
+	if err := db.ResetMarker(episodeFile.ID, args.Kind); err != nil {
+		return markerErrResponse(err)
+	}
+	auditAdminMutation(ctx, "resetEpisodeFileMarker", "reset %s of '%s'", args.Kind, episodeFile.FileName)
+
+	if man, ok := r.libs[episodeFile.LibraryID]; ok {
+		man.QueueMarkerDetection()
+	}
+	return markerResponse(episodeFile.ID)
+}
@@ -2,7 +2,6 @@ package resolvers
 
 import (
 	"context"
-	"fmt"
 	"strconv"
 
 	"gitlab.com/olaris/olaris-server/filesystem"
@@ -40,11 +39,17 @@ func createQd(args *queryArgs) *db.QueryDetails {
 // Movies returns all movies.
 func (r *Resolver) Movies(ctx context.Context, args *queryArgs) []*MovieResolver {
 	var l []*MovieResolver
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return l
+	}
 	var movies []db.Movie
 	qd := createQd(args)
+	qd.LibraryIDs = accessibleLibraryIDs(ctx)
 	if args.UUID != nil {
 		movie, _ := db.FindMovieByUUID(*args.UUID)
-		movies = []db.Movie{*movie}
+		if db.MovieInLibraries(movie.ID, qd.LibraryIDs) {
+			movies = []db.Movie{*movie}
+		}
 	} else {
 		movies = db.FindAllMovies(qd)
 	}
@@ -87,29 +92,42 @@ func (r *MovieResolver) Name() string {
 
 // BackdropPath returns backdrop
 func (r *MovieResolver) BackdropPath() string {
-	return r.r.BackdropPath
+	return tmdbImagePath(r.r.BackdropPath)
 }
 
 // PosterPath returns poster
 func (r *MovieResolver) PosterPath() string {
-	return r.r.PosterPath
+	return tmdbImagePath(r.r.PosterPath)
 }
 
 // PosterURL returns poster's URL for the given size
 func (r *MovieResolver) PosterURL(ctx context.Context, args *posterURLArgs) string {
-	actualWidth := "original"
-	if args.Width > 0 {
-		// TODO: get this dynamically from TMDB at server startup
-		// (or maybe it's already fetched somewhere?)
-		availableWidths := []int32{92, 154, 185, 342, 500, 780}
-		for _, currentWidth := range availableWidths {
-			if currentWidth >= args.Width {
-				actualWidth = fmt.Sprintf("w%d", currentWidth)
-				break
-			}
-		}
-	}
-	return fmt.Sprintf("/olaris/m/images/tmdb/%s%s", actualWidth, r.r.PosterPath)
+	return imageURL(ctx, r.r.PosterPath, args.Width, posterWidths)
+}
+
+// BackdropURL returns backdrop's URL for the given size
+func (r *MovieResolver) BackdropURL(ctx context.Context, args *posterURLArgs) string {
+	return imageURL(ctx, r.r.BackdropPath, args.Width, backdropWidths)
+}
+
+// PosterBlurHash returns a BlurHash of the poster to show while it loads.
+func (r *MovieResolver) PosterBlurHash() string {
+	return r.r.PosterBlurHash
+}
+
+// PosterColor returns the dominant colour of the poster.
+func (r *MovieResolver) PosterColor() string {
+	return r.r.PosterColor
+}
+
+// BackdropBlurHash returns a BlurHash of the backdrop to show while it loads.
+func (r *MovieResolver) BackdropBlurHash() string {
+	return r.r.BackdropBlurHash
+}
+
+// BackdropColor returns the dominant colour of the backdrop.
+func (r *MovieResolver) BackdropColor() string {
+	return r.r.BackdropColor
 }
 
 // Year returns year
@@ -201,3 +219,25 @@ func (r *MovieFileResolver) Streams() (streams []*StreamResolver) {
 	}
 	return streams
 }
+
+// Chapters returns the chapter markers of the file.
+func (r *MovieFileResolver) Chapters() []*ChapterResolver {
+	return chapterResolvers(db.FindChaptersForMovieFile(&r.r))
+}
+
+// OptimizedFrom returns the UUID of the file this file is an optimized version of.
+func (r *MovieFileResolver) OptimizedFrom() *string {
+	if !r.r.IsOptimizedVersion() {
+		return nil
+	}
+	uuid := r.r.OptimizedFromUUID()
+	return &uuid
+}
+
+// OptimizedProfile returns the transcoding profile the optimized version was created with.
+func (r *MovieFileResolver) OptimizedProfile() *string {
+	if !r.r.IsOptimizedVersion() {
+		return nil
+	}
+	return &r.r.OptimizedProfile
+}
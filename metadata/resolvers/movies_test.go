@@ -3,6 +3,7 @@ package resolvers
 import (
 	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gitlab.com/olaris/olaris-server/metadata/app"
 	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"gitlab.com/olaris/olaris-server/metadata/db"
@@ -10,10 +11,11 @@ import (
 )
 
 func TestPlayState(t *testing.T) {
-	const testUserID = 1
-
-	ctx := auth.ContextWithUserID(context.Background(), testUserID)
 	r := NewResolver(app.NewTestingMDContext(nil))
+	user, err := db.CreateUser("testuser", "testtest", false)
+	require.NoError(t, err)
+	testUserID := user.ID
+	ctx := auth.ContextWithUserID(context.Background(), testUserID)
 
 	mi := db.MediaItem{FilePath: "/tmp/test.mkv"}
 	stream := db.Stream{CodecName: "test"}
@@ -42,3 +44,20 @@ func TestPlayState(t *testing.T) {
 
 	assert.EqualValues(t, 33, movieResolver.PlayState(ctx).Playtime())
 }
+
+func TestLocalArtworkPaths(t *testing.T) {
+	app.NewTestingMDContext(nil)
+	ctx := context.Background()
+
+	movie := &MovieResolver{r: db.Movie{}}
+	movie.r.PosterPath = db.LocalImagePath("abc-123")
+	movie.r.BackdropPath = "/backdrop.jpg"
+	// Clients build TMDB URLs from the paths, extracted artwork is only served by URL
+	assert.Empty(t, movie.PosterPath())
+	assert.Contains(t, movie.PosterURL(ctx, &posterURLArgs{}), "/olaris/m/images/local/original/abc-123.jpg")
+	assert.Equal(t, "/backdrop.jpg", movie.BackdropPath())
+
+	episode := &EpisodeResolver{r: db.Episode{StillPath: db.LocalImagePath("def-456")}}
+	assert.Empty(t, episode.StillPath())
+	assert.Contains(t, episode.StillURL(ctx, &posterURLArgs{}), "/olaris/m/images/local/original/def-456.jpg")
+}
@@ -0,0 +1,210 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/metadata/managers"
+)
+
+// OptimizeJobResolver resolves a request for an optimized version.
+type OptimizeJobResolver struct {
+	r        db.OptimizeJob
+	progress float64
+}
+
+// ID returns the ID of the job.
+func (r *OptimizeJobResolver) ID() int32 {
+	return int32(r.r.ID)
+}
+
+// UserID returns the ID of the user that requested the optimized version.
+func (r *OptimizeJobResolver) UserID() int32 {
+	return int32(r.r.UserID)
+}
+
+// Username returns the name of the user that requested the optimized version.
+func (r *OptimizeJobResolver) Username() string {
+	if user, err := db.FindUser(r.r.UserID); err == nil {
+		return user.Username
+	}
+	return ""
+}
+
+// FileUUID returns the UUID of the file that is optimized.
+func (r *OptimizeJobResolver) FileUUID() string {
+	return r.r.FileUUID
+}
+
+// Profile returns the transcoding profile the file is encoded with.
+func (r *OptimizeJobResolver) Profile() string {
+	return r.r.Profile
+}
+
+// VideoCodec returns the codec the video is encoded to.
+func (r *OptimizeJobResolver) VideoCodec() string {
+	return r.r.VideoCodec
+}
+
+// State returns whether the job is queued, running, done or failed.
+func (r *OptimizeJobResolver) State() string {
+	return r.r.State
+}
+
+// Progress returns the share of the file encoded so far.
+func (r *OptimizeJobResolver) Progress() float64 {
+	return r.progress
+}
+
+// Error returns why the job failed.
+func (r *OptimizeJobResolver) Error() *string {
+	if r.r.Error == "" {
+		return nil
+	}
+	return &r.r.Error
+}
+
+// OptimizedUUID returns the UUID of the created file once the job is done.
+func (r *OptimizeJobResolver) OptimizedUUID() *string {
+	if r.r.OptimizedUUID == "" {
+		return nil
+	}
+	return &r.r.OptimizedUUID
+}
+
+// CreatedAt returns when the job was requested in RFC3339 format.
+func (r *OptimizeJobResolver) CreatedAt() string {
+	return r.r.CreatedAt.Format(time.RFC3339)
+}
+
+// OptimizeJobResponse is returned when optimize jobs or optimized versions are changed.
+type OptimizeJobResponse struct {
+	Error *ErrorResolver
+	Job   *OptimizeJobResolver
+}
+
+// OptimizeJobResponseResolver resolves OptimizeJobResponse.
+type OptimizeJobResponseResolver struct {
+	r OptimizeJobResponse
+}
+
+// Error returns error.
+func (r *OptimizeJobResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Job returns the job.
+func (r *OptimizeJobResponseResolver) Job() *OptimizeJobResolver {
+	return r.r.Job
+}
+
+func optimizeJobErrResponse(err error) *OptimizeJobResponseResolver {
+	return &OptimizeJobResponseResolver{OptimizeJobResponse{Error: CreateErrResolver(err)}}
+}
+
+func (r *Resolver) optimizeJobResolver(job db.OptimizeJob) *OptimizeJobResolver {
+	return &OptimizeJobResolver{r: job, progress: r.optimize.Progress(job.ID)}
+}
+
+// ifCanOptimize allows admins to request optimized versions, and other users of the library
+// if the server allows them to.
+func ifCanOptimize(ctx context.Context, libraryID uint) error {
+	if ifAdmin(ctx) == nil {
+		return nil
+	}
+	if !viper.GetBool("transcoding.usersCanOptimize") {
+		return CreateNoAuthorisationError()
+	}
+	return ifLibraryAccess(ctx, libraryID)
+}
+
+// OptimizeJobs returns the optimize jobs of the user, or of all users for admins.
+func (r *Resolver) OptimizeJobs(ctx context.Context) []*OptimizeJobResolver {
+	userID, _ := auth.UserID(ctx)
+	if ifAdmin(ctx) == nil {
+		userID = 0
+	} else if userID == 0 {
+		return []*OptimizeJobResolver{}
+	}
+
+	jobs := []*OptimizeJobResolver{}
+	for _, job := range db.FindOptimizeJobs(userID) {
+		jobs = append(jobs, r.optimizeJobResolver(job))
+	}
+	return jobs
+}
+
+// CreateOptimizedVersion queues the creation of an optimized version of a movie or episode file.
+func (r *Resolver) CreateOptimizedVersion(ctx context.Context, args struct {
+	UUID       string
+	Profile    *string
+	VideoCodec *string
+}) *OptimizeJobResponseResolver {
+	file := db.FindContentByUUID(args.UUID)
+	if file == nil {
+		return optimizeJobErrResponse(fmt.Errorf("no file found for UUID %s", args.UUID))
+	}
+	if err := ifCanOptimize(ctx, file.GetLibrary().ID); err != nil {
+		return optimizeJobErrResponse(err)
+	}
+
+	var profile, videoCodec string
+	if args.Profile != nil {
+		profile = *args.Profile
+	}
+	if args.VideoCodec != nil {
+		videoCodec = *args.VideoCodec
+	}
+	userID, _ := auth.UserID(ctx)
+	job, err := r.optimize.Request(args.UUID, profile, videoCodec, userID)
+	if err != nil {
+		return optimizeJobErrResponse(err)
+	}
+	if ifAdmin(ctx) == nil {
+		auditAdminMutation(ctx, "createOptimizedVersion", "requested %s version of '%s' with profile '%s'",
+			job.VideoCodec, file.GetFileName(), job.Profile)
+	}
+
+	return &OptimizeJobResponseResolver{OptimizeJobResponse{Job: r.optimizeJobResolver(*job)}}
+}
+
+// CancelOptimizeJob stops an optimize job and removes it.
+func (r *Resolver) CancelOptimizeJob(ctx context.Context, args struct{ ID int32 }) *OptimizeJobResponseResolver {
+	job, err := db.FindOptimizeJob(uint(args.ID))
+	if err != nil {
+		return optimizeJobErrResponse(fmt.Errorf("optimize job could not be found"))
+	}
+	userID, _ := auth.UserID(ctx)
+	if ifAdmin(ctx) != nil && (userID == 0 || job.UserID != userID) {
+		return optimizeJobErrResponse(CreateNoAuthorisationError())
+	}
+
+	if err := r.optimize.Cancel(job.ID); err != nil {
+		return optimizeJobErrResponse(err)
+	}
+	if ifAdmin(ctx) == nil {
+		auditAdminMutation(ctx, "cancelOptimizeJob", "cancelled optimize job %d", job.ID)
+	}
+	return &OptimizeJobResponseResolver{OptimizeJobResponse{Job: &OptimizeJobResolver{r: *job}}}
+}
+
+// DeleteOptimizedVersion removes an optimized version and its file.
+func (r *Resolver) DeleteOptimizedVersion(ctx context.Context, args struct{ UUID string }) *OptimizeJobResponseResolver {
+	if err := ifAdmin(ctx); err != nil {
+		return optimizeJobErrResponse(err)
+	}
+
+	file := db.FindContentByUUID(args.UUID)
+	if file == nil {
+		return optimizeJobErrResponse(fmt.Errorf("no file found for UUID %s", args.UUID))
+	}
+	if err := managers.DeleteOptimizedVersion(args.UUID); err != nil {
+		return optimizeJobErrResponse(err)
+	}
+	auditAdminMutation(ctx, "deleteOptimizedVersion", "deleted optimized version '%s'", file.GetFileName())
+	return &OptimizeJobResponseResolver{}
+}
@@ -39,6 +39,13 @@ func (res *PlayStateResponseResolver) PlayState() *PlayStateResolver {
 func (r *Resolver) CreatePlayState(ctx context.Context, args *playStateArgs) *PlayStateResponseResolver {
 	userID, _ := auth.UserID(ctx)
 
+	if err := ifScope(ctx, db.APIKeyScopePlayStateWrite); err != nil {
+		return &PlayStateResponseResolver{success: false, uuid: args.UUID}
+	}
+	if err := ifMediaItemAccess(ctx, args.UUID); err != nil {
+		return &PlayStateResponseResolver{success: false, uuid: args.UUID}
+	}
+
 	ps := db.PlayState{
 		MediaUUID: args.UUID,
 		UserID:    userID,
@@ -28,16 +28,31 @@ func (a ByUpdatedAt) Less(i, j int) bool { return a[i].UpdatedAtTimeStamp() > a[
 
 // RecentlyAdded returns recently added media content.
 func (r *Resolver) RecentlyAdded(ctx context.Context) *[]*MediaItemResolver {
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return &[]*MediaItemResolver{}
+	}
 	userID, _ := auth.UserID(ctx)
+	libraryIDs := accessibleLibraryIDs(ctx)
 	sortables := []sortable{}
 
 	for _, movie := range db.RecentlyAddedMovies(userID) {
-		sortables = append(sortables, movie)
+		var files []db.MediaItem
+		for _, file := range movie.MovieFiles {
+			files = append(files, file.MediaItem)
+		}
+		if inLibraries(files, libraryIDs) {
+			sortables = append(sortables, movie)
+		}
 	}
 
 	for _, ep := range db.RecentlyAddedEpisodes(userID) {
-		sortables = append(sortables, ep)
-
+		var files []db.MediaItem
+		for _, file := range ep.EpisodeFiles {
+			files = append(files, file.MediaItem)
+		}
+		if inLibraries(files, libraryIDs) {
+			sortables = append(sortables, ep)
+		}
 	}
 	sort.Sort(ByCreationDate(sortables))
 
@@ -54,3 +69,18 @@ func (r *Resolver) RecentlyAdded(ctx context.Context) *[]*MediaItemResolver {
 
 	return &l
 }
+
+// inLibraries returns true if any of the files is in one of the given libraries, or if libraryIDs is nil.
+func inLibraries(files []db.MediaItem, libraryIDs []uint) bool {
+	if libraryIDs == nil {
+		return true
+	}
+	for _, file := range files {
+		for _, id := range libraryIDs {
+			if file.LibraryID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -11,15 +11,20 @@ import (
 
 // Resolver container object for all resolvers.
 type Resolver struct {
-	env  *app.MetadataContext
-	libs map[uint]*managers.LibraryManager
+	env       *app.MetadataContext
+	libs      map[uint]*managers.LibraryManager
+	optimize  *managers.OptimizeManager
+	downloads *managers.DownloadManager
 }
 
 // NewResolver creates a new resolver
 func NewResolver(env *app.MetadataContext) *Resolver {
+	encodeQueue := managers.NewEncodeQueue()
 	r := &Resolver{
-		env:  env,
-		libs: map[uint]*managers.LibraryManager{},
+		env:       env,
+		libs:      map[uint]*managers.LibraryManager{},
+		optimize:  managers.NewOptimizeManager(encodeQueue),
+		downloads: managers.NewDownloadManager(encodeQueue),
 	}
 
 	libs := db.AllLibraries()
@@ -1,6 +1,7 @@
 package resolvers
 
 import (
+	"context"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 )
 
@@ -26,13 +27,17 @@ type searchArgs struct {
 }
 
 // Search searches for media content.
-func (r *Resolver) Search(args *searchArgs) *[]*SearchItemResolver {
+func (r *Resolver) Search(ctx context.Context, args *searchArgs) *[]*SearchItemResolver {
 	var l []*SearchItemResolver
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return &l
+	}
 
-	for _, movie := range db.SearchMovieByTitle(args.Name) {
+	libraryIDs := accessibleLibraryIDs(ctx)
+	for _, movie := range db.SearchMovieByTitle(args.Name, libraryIDs) {
 		l = append(l, &SearchItemResolver{r: &MovieResolver{r: movie}})
 	}
-	for _, serie := range db.SearchSeriesByTitle(args.Name) {
+	for _, serie := range db.SearchSeriesByTitle(args.Name, libraryIDs) {
 		l = append(l, &SearchItemResolver{r: &SeriesResolver{serie}})
 	}
 
@@ -15,9 +15,12 @@ type mustUUIDArgs struct {
 
 // Episode returns episode.
 func (r *Resolver) Episode(ctx context.Context, args *mustUUIDArgs) *EpisodeResolver {
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return &EpisodeResolver{r: db.Episode{}}
+	}
 	episode, err := db.FindEpisodeByUUID(*args.UUID)
 	// TODO(Maran): return an actual error to the client, not just an empty dict
-	if err == nil {
+	if err == nil && db.EpisodeInLibraries(episode, accessibleLibraryIDs(ctx)) {
 		return &EpisodeResolver{r: *episode}
 	}
 	return &EpisodeResolver{r: db.Episode{}}
@@ -26,23 +29,33 @@ func (r *Resolver) Episode(ctx context.Context, args *mustUUIDArgs) *EpisodeReso
 
 // Season returns season.
 func (r *Resolver) Season(ctx context.Context, args *mustUUIDArgs) *SeasonResolver {
-	season, _ := db.FindSeasonByUUID(*args.UUID)
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return &SeasonResolver{r: db.Season{}}
+	}
+	season, err := db.FindSeasonByUUID(*args.UUID)
+	if err != nil || !db.SeriesInLibraries(season.SeriesID, accessibleLibraryIDs(ctx)) {
+		return &SeasonResolver{r: db.Season{}}
+	}
 	return &SeasonResolver{r: *season}
 }
 
 // Series return series.
 func (r *Resolver) Series(ctx context.Context, args *queryArgs) []*SeriesResolver {
 	var series []*db.Series
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return []*SeriesResolver{}
+	}
 
 	if args.UUID != nil {
 		serie, err := db.FindSeriesByUUID(*args.UUID)
-		if err != nil {
+		if err != nil || !db.SeriesInLibraries(serie.ID, accessibleLibraryIDs(ctx)) {
 			series = []*db.Series{}
 		} else {
 			series = []*db.Series{serie}
 		}
 	} else {
 		qd := createQd(args)
+		qd.LibraryIDs = accessibleLibraryIDs(ctx)
 		series, _ = db.FindAllSeries(qd)
 	}
 
@@ -91,12 +104,42 @@ func (r *SeriesResolver) Type() string {
 
 // PosterPath resturn uri to poster.
 func (r *SeriesResolver) PosterPath() string {
-	return r.r.PosterPath
+	return tmdbImagePath(r.r.PosterPath)
 }
 
 // BackdropPath returns uri to backdrop.
 func (r *SeriesResolver) BackdropPath() string {
-	return r.r.BackdropPath
+	return tmdbImagePath(r.r.BackdropPath)
+}
+
+// PosterURL returns poster's URL for the given size
+func (r *SeriesResolver) PosterURL(ctx context.Context, args *posterURLArgs) string {
+	return imageURL(ctx, r.r.PosterPath, args.Width, posterWidths)
+}
+
+// BackdropURL returns backdrop's URL for the given size
+func (r *SeriesResolver) BackdropURL(ctx context.Context, args *posterURLArgs) string {
+	return imageURL(ctx, r.r.BackdropPath, args.Width, backdropWidths)
+}
+
+// PosterBlurHash returns a BlurHash of the poster to show while it loads.
+func (r *SeriesResolver) PosterBlurHash() string {
+	return r.r.PosterBlurHash
+}
+
+// PosterColor returns the dominant colour of the poster.
+func (r *SeriesResolver) PosterColor() string {
+	return r.r.PosterColor
+}
+
+// BackdropBlurHash returns a BlurHash of the backdrop to show while it loads.
+func (r *SeriesResolver) BackdropBlurHash() string {
+	return r.r.BackdropBlurHash
+}
+
+// BackdropColor returns the dominant colour of the backdrop.
+func (r *SeriesResolver) BackdropColor() string {
+	return r.r.BackdropColor
 }
 
 // TmdbID returns tmdb id
@@ -149,7 +192,22 @@ func (r *SeasonResolver) AirDate() string {
 
 // PosterPath resturn uri to poster.
 func (r *SeasonResolver) PosterPath() string {
-	return r.r.PosterPath
+	return tmdbImagePath(r.r.PosterPath)
+}
+
+// PosterURL returns poster's URL for the given size
+func (r *SeasonResolver) PosterURL(ctx context.Context, args *posterURLArgs) string {
+	return imageURL(ctx, r.r.PosterPath, args.Width, posterWidths)
+}
+
+// PosterBlurHash returns a BlurHash of the poster to show while it loads.
+func (r *SeasonResolver) PosterBlurHash() string {
+	return r.r.PosterBlurHash
+}
+
+// PosterColor returns the dominant colour of the poster.
+func (r *SeasonResolver) PosterColor() string {
+	return r.r.PosterColor
 }
 
 // UnwatchedEpisodesCount returns the amount of unwatched episodes for the given season
@@ -224,7 +282,22 @@ func (r *EpisodeResolver) AirDate() string {
 
 // StillPath returns uri to still image.
 func (r *EpisodeResolver) StillPath() string {
-	return r.r.StillPath
+	return tmdbImagePath(r.r.StillPath)
+}
+
+// StillURL returns still image's URL for the given size
+func (r *EpisodeResolver) StillURL(ctx context.Context, args *posterURLArgs) string {
+	return imageURL(ctx, r.r.StillPath, args.Width, stillWidths)
+}
+
+// StillBlurHash returns a BlurHash of the still image to show while it loads.
+func (r *EpisodeResolver) StillBlurHash() string {
+	return r.r.StillBlurHash
+}
+
+// StillColor returns the dominant colour of the still image.
+func (r *EpisodeResolver) StillColor() string {
+	return r.r.StillColor
 }
 
 // TmdbID returns tmdb id.
@@ -320,3 +393,25 @@ func (r *EpisodeFileResolver) Streams() (streams []*StreamResolver) {
 	}
 	return streams
 }
+
+// Chapters returns the chapter markers of the file.
+func (r *EpisodeFileResolver) Chapters() []*ChapterResolver {
+	return chapterResolvers(db.FindChaptersForEpisodeFile(&r.r))
+}
+
+// OptimizedFrom returns the UUID of the file this file is an optimized version of.
+func (r *EpisodeFileResolver) OptimizedFrom() *string {
+	if !r.r.IsOptimizedVersion() {
+		return nil
+	}
+	uuid := r.r.OptimizedFromUUID()
+	return &uuid
+}
+
+// OptimizedProfile returns the transcoding profile the optimized version was created with.
+func (r *EpisodeFileResolver) OptimizedProfile() *string {
+	if !r.r.IsOptimizedVersion() {
+		return nil
+	}
+	return &r.r.OptimizedProfile
+}
@@ -3,6 +3,7 @@ package resolvers
 import (
 	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gitlab.com/olaris/olaris-server/metadata/app"
 	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"gitlab.com/olaris/olaris-server/metadata/db"
@@ -10,10 +11,11 @@ import (
 )
 
 func TestEpisodePlayState(t *testing.T) {
-	const testUserID = 1
-
-	ctx := auth.ContextWithUserID(context.Background(), testUserID)
 	r := NewResolver(app.NewTestingMDContext(nil))
+	user, err := db.CreateUser("testuser", "testtest", false)
+	require.NoError(t, err)
+	testUserID := user.ID
+	ctx := auth.ContextWithUserID(context.Background(), testUserID)
 
 	mi := db.MediaItem{FilePath: "/tmp/test.mkv"}
 	stream := db.Stream{CodecName: "test"}
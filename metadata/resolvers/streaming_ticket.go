@@ -6,7 +6,9 @@ import (
 	"gitlab.com/olaris/olaris-server/helpers"
 	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/trickplay"
 	"path"
+	"time"
 )
 
 // CreateSTResponse  holds new jwt data.
@@ -17,6 +19,10 @@ type CreateSTResponse struct {
 	DASHStreamingPath string
 	HLSStreamingPath  string
 	Streams           []*StreamResolver
+	SessionID         string
+	ExpiresAt         time.Time
+	TrickplayVTTPath  *string
+	TrickplayBIFPath  *string
 }
 
 // CreateSTResponseResolver resolves CreateSTResponse.
@@ -49,15 +55,51 @@ func (r *CreateSTResponseResolver) Jwt() string {
 	return r.r.Jwt
 }
 
+// SessionID returns the playback session the ticket is valid for.
+func (r *CreateSTResponseResolver) SessionID() string {
+	return r.r.SessionID
+}
+
+// ExpiresAt returns the time the ticket expires unless refreshed, in RFC3339 format.
+func (r *CreateSTResponseResolver) ExpiresAt() string {
+	return r.r.ExpiresAt.Format(time.RFC3339)
+}
+
+// TrickplayVTTPath returns the URI of the WebVTT thumbnail track, if thumbnails were generated.
+func (r *CreateSTResponseResolver) TrickplayVTTPath() *string {
+	return r.r.TrickplayVTTPath
+}
+
+// TrickplayBIFPath returns the URI of the BIF file, if thumbnails were generated.
+func (r *CreateSTResponseResolver) TrickplayBIFPath() *string {
+	return r.r.TrickplayBIFPath
+}
+
 // Error returns error.
 func (r *CreateSTResponseResolver) Error() *ErrorResolver {
 	return r.r.Error
 }
 
 // CreateStreamingTicket create a new streaming request for the given content.
-func (r *Resolver) CreateStreamingTicket(ctx context.Context, args *struct{ UUID string }) *CreateSTResponseResolver {
+func (r *Resolver) CreateStreamingTicket(ctx context.Context, args *struct {
+	UUID         string
+	BindClientIP *bool
+}) *CreateSTResponseResolver {
 	userID, _ := auth.UserID(ctx)
+
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return &CreateSTResponseResolver{CreateSTResponse{Error: CreateErrResolver(err)}}
+	}
+
 	mr := db.FindContentByUUID(args.UUID)
+	if mr == nil {
+		return &CreateSTResponseResolver{CreateSTResponse{
+			Error: CreateErrResolver(fmt.Errorf("No file found for UUID %s", args.UUID)),
+		}}
+	}
+	if err := ifLibraryAccess(ctx, mr.GetLibrary().ID); err != nil {
+		return &CreateSTResponseResolver{CreateSTResponse{Error: CreateErrResolver(err)}}
+	}
 
 	filePath := mr.GetFilePath()
 	var streamables []*StreamResolver
@@ -68,7 +110,26 @@ func (r *Resolver) CreateStreamingTicket(ctx context.Context, args *struct{ UUID
 		}}
 	}
 
-	token, err := auth.CreateStreamingJWT(userID, filePath)
+	sessionID, err := helpers.SecureRandAlphaString(16)
+	if err != nil {
+		return &CreateSTResponseResolver{CreateSTResponse{Error: CreateErrResolver(err)}}
+	}
+
+	options := auth.StreamingTicketOptions{
+		UserID:             userID,
+		FilePath:           filePath,
+		SessionID:          sessionID,
+		TranscodingProfile: mr.GetLibrary().TranscodingProfile,
+		Deinterlace:        mr.GetLibrary().Deinterlace,
+		OptimizedVersions:  db.FindOptimizedVersionPaths(filePath),
+	}
+	if user, err := db.FindUser(userID); err == nil && user.TranscodingProfile != "" {
+		options.TranscodingProfile = user.TranscodingProfile
+	}
+	if args.BindClientIP != nil && *args.BindClientIP {
+		options.ClientIP = auth.ClientIPFromContext(ctx)
+	}
+	token, ticket, err := auth.CreateStreamingTicket(options)
 	if err != nil {
 		return &CreateSTResponseResolver{CreateSTResponse{Error: CreateErrResolver(err)}}
 	}
@@ -78,7 +139,6 @@ func (r *Resolver) CreateStreamingTicket(ctx context.Context, args *struct{ UUID
 
 	metadataPath := path.Join(basePath, "metadata.json")
 
-	sessionID := helpers.RandAlphaString(16)
 	HLSStreamingPath := path.Join(
 		basePath, fmt.Sprintf("/session:%s/hls-manifest.m3u8", sessionID))
 	DASHStreamingPath := path.Join(
@@ -94,6 +154,13 @@ func (r *Resolver) CreateStreamingTicket(ctx context.Context, args *struct{ UUID
 
 	}
 
+	var trickplayVTTPath, trickplayBIFPath *string
+	if trickplay.Available(filePath) {
+		vttPath := path.Join(basePath, fmt.Sprintf("/session:%s/trickplay/%s", sessionID, trickplay.WebVTTFile))
+		bifPath := path.Join(basePath, fmt.Sprintf("/session:%s/trickplay/%s", sessionID, trickplay.BIFFile))
+		trickplayVTTPath, trickplayBIFPath = &vttPath, &bifPath
+	}
+
 	return &CreateSTResponseResolver{CreateSTResponse{
 		Error:             nil,
 		Jwt:               token,
@@ -101,5 +168,84 @@ func (r *Resolver) CreateStreamingTicket(ctx context.Context, args *struct{ UUID
 		HLSStreamingPath:  HLSStreamingPath,
 		DASHStreamingPath: DASHStreamingPath,
 		Streams:           streamables,
+		SessionID:         sessionID,
+		ExpiresAt:         ticket.ExpiresAt,
+		TrickplayVTTPath:  trickplayVTTPath,
+		TrickplayBIFPath:  trickplayBIFPath,
 	}}
 }
+
+// StreamingTicketResponse is returned when a streaming ticket is refreshed or revoked.
+type StreamingTicketResponse struct {
+	Error  *ErrorResolver
+	Ticket *db.StreamingTicket
+}
+
+// StreamingTicketResponseResolver resolves StreamingTicketResponse.
+type StreamingTicketResponseResolver struct {
+	r StreamingTicketResponse
+}
+
+// Error returns error.
+func (r *StreamingTicketResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// SessionID returns the playback session of the ticket.
+func (r *StreamingTicketResponseResolver) SessionID() *string {
+	if r.r.Ticket == nil {
+		return nil
+	}
+	return &r.r.Ticket.SessionID
+}
+
+// ExpiresAt returns the time the ticket expires in RFC3339 format.
+func (r *StreamingTicketResponseResolver) ExpiresAt() *string {
+	if r.r.Ticket == nil {
+		return nil
+	}
+	return formatOptionalTime(&r.r.Ticket.ExpiresAt)
+}
+
+// Revoked returns true if the ticket can no longer be used.
+func (r *StreamingTicketResponseResolver) Revoked() bool {
+	return r.r.Ticket != nil && r.r.Ticket.Revoked()
+}
+
+// findOwnStreamingTicket returns the ticket for the playback session if it belongs to the
+// current user. Admins may access all tickets.
+func findOwnStreamingTicket(ctx context.Context, sessionID string) (*db.StreamingTicket, error) {
+	ticket, err := db.FindStreamingTicketBySession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	userID, _ := auth.UserID(ctx)
+	if ticket.UserID != userID && ifAdmin(ctx) != nil {
+		return nil, fmt.Errorf("streaming ticket could not be found")
+	}
+	return ticket, nil
+}
+
+// RefreshStreamingTicket extends the validity of the ticket for the given playback session.
+func (r *Resolver) RefreshStreamingTicket(ctx context.Context, args *struct{ SessionID string }) *StreamingTicketResponseResolver {
+	ticket, err := findOwnStreamingTicket(ctx, args.SessionID)
+	if err == nil {
+		err = auth.RefreshStreamingTicket(ticket)
+	}
+	if err != nil {
+		return &StreamingTicketResponseResolver{StreamingTicketResponse{Error: CreateErrResolver(err)}}
+	}
+	return &StreamingTicketResponseResolver{StreamingTicketResponse{Ticket: ticket}}
+}
+
+// RevokeStreamingTicket stops the ticket for the given playback session from working.
+func (r *Resolver) RevokeStreamingTicket(ctx context.Context, args *struct{ SessionID string }) *StreamingTicketResponseResolver {
+	ticket, err := findOwnStreamingTicket(ctx, args.SessionID)
+	if err == nil {
+		err = auth.RevokeStreamingTicket(ticket)
+	}
+	if err != nil {
+		return &StreamingTicketResponseResolver{StreamingTicketResponse{Error: CreateErrResolver(err)}}
+	}
+	return &StreamingTicketResponseResolver{StreamingTicketResponse{Ticket: ticket}}
+}
@@ -2,6 +2,7 @@ package resolvers
 
 import (
 	"fmt"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 )
 
@@ -68,6 +69,54 @@ func (r *StreamResolver) Title() *string {
 	return &r.r.Title
 }
 
+// Channels returns the number of audio channels, 0 if unknown.
+func (r *StreamResolver) Channels() *int32 {
+	a := int32(r.r.Channels)
+	return &a
+}
+
+// ChannelLayout returns the audio channel layout such as '5.1' if known.
+func (r *StreamResolver) ChannelLayout() *string {
+	return &r.r.ChannelLayout
+}
+
+// VideoRange returns the dynamic range of video streams.
+func (r *StreamResolver) VideoRange() *string {
+	if r.r.StreamType != "video" {
+		return nil
+	}
+	a := ffmpeg.Stream{ColorTransfer: r.r.ColorTransfer}.VideoRange()
+	return &a
+}
+
+// Interlaced returns whether the video stream is interlaced.
+func (r *StreamResolver) Interlaced() *bool {
+	if r.r.StreamType != "video" {
+		return nil
+	}
+	a := ffmpeg.Stream{FieldOrder: r.r.FieldOrder}.IsInterlaced()
+	return &a
+}
+
+// ImageBased returns whether the stream is an image-based subtitle.
+func (r *StreamResolver) ImageBased() *bool {
+	if r.r.StreamType != "subtitle" {
+		return nil
+	}
+	a := ffmpeg.Stream{StreamType: r.r.StreamType, CodecName: r.r.CodecName}.IsImageSubtitle()
+	return &a
+}
+
+// Forced returns whether the subtitle only covers foreign-language parts.
+func (r *StreamResolver) Forced() *bool {
+	return &r.r.Forced
+}
+
+// HearingImpaired returns whether the subtitle is meant for the hearing impaired.
+func (r *StreamResolver) HearingImpaired() *bool {
+	return &r.r.HearingImpaired
+}
+
 // Resolution returns stream resolution if present.
 func (r *StreamResolver) Resolution() *string {
 	if r.r.Width != 0 {
@@ -86,16 +86,62 @@ func (r *Resolver) startMetadataSubscription(
 	return publishCh
 }
 
+// libraryItemFilter filters events of movies or series by the libraries the subscriber can
+// access. Items are only sent to restricted subscribers once they have files in one of their
+// libraries. Deleted items no longer have files telling their library, so it remembers the items
+// the subscriber can see.
+type libraryItemFilter struct {
+	libraryIDs  []uint
+	inLibraries func(id uint, libraryIDs []uint) bool
+	visible     map[uint]bool
+}
+
+func newLibraryItemFilter(
+	ctx context.Context,
+	idsInLibraries func(libraryIDs []uint) []uint,
+	inLibraries func(id uint, libraryIDs []uint) bool) *libraryItemFilter {
+
+	f := &libraryItemFilter{
+		libraryIDs:  accessibleLibraryIDs(ctx),
+		inLibraries: inLibraries,
+		visible:     map[uint]bool{},
+	}
+	if f.libraryIDs != nil {
+		for _, id := range idsInLibraries(f.libraryIDs) {
+			f.visible[id] = true
+		}
+	}
+	return f
+}
+
+func (f *libraryItemFilter) changed(id uint) bool {
+	if f.libraryIDs == nil {
+		return true
+	}
+	f.visible[id] = f.inLibraries(id, f.libraryIDs)
+	return f.visible[id]
+}
+
+func (f *libraryItemFilter) deleted(id uint) bool {
+	if f.libraryIDs == nil {
+		return true
+	}
+	visible := f.visible[id]
+	delete(f.visible, id)
+	return visible
+}
+
 func (r *Resolver) MoviesChanged(ctx context.Context) <-chan *MetadataEventResolver {
 	log.Debugln("Adding subscription to Movies")
+	filter := newLibraryItemFilter(ctx, db.MovieIDsInLibraries, db.MovieInLibraries)
 	return r.startMetadataSubscription(
 		ctx,
 		func(e *metadata.MetadataEvent) bool {
-			if e.EventType == metadata.MetadataEventTypeMovieAdded ||
-				e.EventType == metadata.MetadataEventTypeMovieUpdated ||
-				e.EventType == metadata.MetadataEventTypeMovieDeleted {
-				return true
-
+			switch e.EventType {
+			case metadata.MetadataEventTypeMovieAdded, metadata.MetadataEventTypeMovieUpdated:
+				return filter.changed(e.Payload.(*db.Movie).ID)
+			case metadata.MetadataEventTypeMovieDeleted:
+				return filter.deleted(e.Payload.(*db.Movie).ID)
 			}
 			return false
 		})
@@ -103,14 +149,15 @@ func (r *Resolver) MoviesChanged(ctx context.Context) <-chan *MetadataEventResol
 
 func (r *Resolver) SeriesChanged(ctx context.Context) <-chan *MetadataEventResolver {
 	log.Debugln("Adding subscription to Series")
+	filter := newLibraryItemFilter(ctx, db.SeriesIDsInLibraries, db.SeriesInLibraries)
 	return r.startMetadataSubscription(
 		ctx,
 		func(e *metadata.MetadataEvent) bool {
-			if e.EventType == metadata.MetadataEventTypeSeriesAdded ||
-				e.EventType == metadata.MetadataEventTypeSeriesUpdated ||
-				e.EventType == metadata.MetadataEventTypeSeriesDeleted {
-				return true
-
+			switch e.EventType {
+			case metadata.MetadataEventTypeSeriesAdded, metadata.MetadataEventTypeSeriesUpdated:
+				return filter.changed(e.Payload.(*db.Series).ID)
+			case metadata.MetadataEventTypeSeriesDeleted:
+				return filter.deleted(e.Payload.(*db.Series).ID)
 			}
 			return false
 		})
@@ -133,6 +180,9 @@ func (r *Resolver) SeasonChanged(
 	if err != nil {
 		return nil, errors.Wrap(err, "Failed to find series")
 	}
+	if !db.SeriesInLibraries(series.ID, accessibleLibraryIDs(ctx)) {
+		return nil, CreateNoAuthorisationError()
+	}
 	seriesID := series.ID
 
 	return r.startMetadataSubscription(
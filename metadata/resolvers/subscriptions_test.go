@@ -3,8 +3,10 @@ package resolvers
 import (
 	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gitlab.com/olaris/olaris-server/metadata/agents/agentsfakes"
 	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 	"testing"
 	"time"
@@ -21,7 +23,9 @@ func TestResolver_MoviesChanged_CreateMovie(t *testing.T) {
 	metadataCtx := app.NewTestingMDContext(&tmdbAgent)
 	r := NewResolver(metadataCtx)
 
-	subCh := r.MoviesChanged(context.Background())
+	admin, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	subCh := r.MoviesChanged(auth.ContextWithUserID(context.Background(), admin.ID))
 
 	metadataCtx.MetadataManager.GetOrCreateMovieByTmdbID(1234)
 
@@ -54,7 +58,9 @@ func TestResolver_MoviesChanged_RefreshMovieMetadata(t *testing.T) {
 	}
 	db.SaveMovie(&movie)
 
-	subCh := r.MoviesChanged(context.Background())
+	admin, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	subCh := r.MoviesChanged(auth.ContextWithUserID(context.Background(), admin.ID))
 
 	metadataCtx.MetadataManager.RefreshMovieMetadata(&movie)
 
@@ -78,7 +84,9 @@ func TestResolver_MoviesChanged_GarbageCollectMovie(t *testing.T) {
 	}
 	db.SaveMovie(&movie)
 
-	subCh := r.MoviesChanged(context.Background())
+	admin, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	subCh := r.MoviesChanged(auth.ContextWithUserID(context.Background(), admin.ID))
 
 	metadataCtx.MetadataManager.GarbageCollectMovieIfRequired(movie.ID)
 
@@ -113,8 +121,11 @@ func TestResolver_SeasonDeleted_GarbageCollectEpisode(t *testing.T) {
 	}
 	db.SaveEpisode(episode)
 
-	seriesSubCh := r.SeriesChanged(context.Background())
-	seasonSubCh, _ := r.SeasonChanged(context.Background(),
+	admin, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	ctx := auth.ContextWithUserID(context.Background(), admin.ID)
+	seriesSubCh := r.SeriesChanged(ctx)
+	seasonSubCh, _ := r.SeasonChanged(ctx,
 		&seasonChangedArgs{SeriesUUID: &series.UUID})
 
 	// Episode does not have an EpisodeFile, so the whole tree should be removed
@@ -138,3 +149,39 @@ func TestResolver_SeasonDeleted_GarbageCollectEpisode(t *testing.T) {
 		assert.EqualValues(t, season.UUID, eventResolver.SeasonUUID())
 	}
 }
+
+func TestResolver_MoviesChanged_LibraryGrants(t *testing.T) {
+	metadataCtx := app.NewTestingMDContext(nil)
+	r := NewResolver(metadataCtx)
+
+	visible := db.Movie{Title: "Visible", MovieFiles: []db.MovieFile{
+		{MediaItem: db.MediaItem{FilePath: "local#/movies/visible.mkv", LibraryID: 1}}}}
+	db.SaveMovie(&visible)
+	hidden := db.Movie{Title: "Hidden", MovieFiles: []db.MovieFile{
+		{MediaItem: db.MediaItem{FilePath: "local#/movies/hidden.mkv", LibraryID: 2}}}}
+	db.SaveMovie(&hidden)
+
+	_, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	restricted, err := db.CreateUser("restricted", "testtest", false)
+	require.NoError(t, err)
+	require.NoError(t, db.GrantLibraries(restricted.ID, []uint{1}))
+	subCh := r.MoviesChanged(auth.ContextWithUserID(context.Background(), restricted.ID))
+
+	// Remove the files so the movies get garbage collected
+	for _, movie := range []db.Movie{hidden, visible} {
+		for _, file := range movie.MovieFiles {
+			file.DeleteWithStreams()
+		}
+		metadataCtx.MetadataManager.GarbageCollectMovieIfRequired(movie.ID)
+	}
+
+	select {
+	case <-time.After(time.Second):
+		assert.Fail(t, "Timeout waiting for MovieDeletedEvent")
+	case e := <-subCh:
+		eventResolver, ok := e.ToMovieDeletedEvent()
+		require.True(t, ok)
+		assert.Equal(t, visible.UUID, eventResolver.MovieUUID(), "should only be told about the visible movie")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"github.com/ryanbradynd05/go-tmdb"
 	"gitlab.com/olaris/olaris-server/metadata/agents"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/metadata/images"
 )
 
 type tmdbSearchMoviesArgs struct {
@@ -12,6 +14,9 @@ type tmdbSearchMoviesArgs struct {
 
 func (r *Resolver) TmdbSearchMovies(ctx context.Context,
 	args *tmdbSearchMoviesArgs) ([]*TmdbMovieSearchItemResolver, error) {
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return nil, err
+	}
 
 	searchRes, err := r.env.MetadataRetrievalAgent.TmdbSearchMovie(args.Query, nil)
 	if err != nil {
@@ -20,6 +25,8 @@ func (r *Resolver) TmdbSearchMovies(ctx context.Context,
 
 	var res []*TmdbMovieSearchItemResolver
 	for _, movieResult := range searchRes.Results {
+		images.RememberSearchResult(movieResult.PosterPath)
+		images.RememberSearchResult(movieResult.BackdropPath)
 		res = append(res, &TmdbMovieSearchItemResolver{r: movieResult})
 	}
 	return res, nil
@@ -68,6 +75,9 @@ type tmdbSearchSeriesArgs struct {
 
 func (r *Resolver) TmdbSearchSeries(ctx context.Context,
 	args *tmdbSearchSeriesArgs) ([]*TmdbSeriesSearchItemResolver, error) {
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return nil, err
+	}
 
 	searchRes, err := r.env.MetadataRetrievalAgent.TmdbSearchTv(args.Query, nil)
 	if err != nil {
@@ -76,6 +86,8 @@ func (r *Resolver) TmdbSearchSeries(ctx context.Context,
 
 	var res []*TmdbSeriesSearchItemResolver
 	for _, seriesResult := range searchRes.Results {
+		images.RememberSearchResult(seriesResult.PosterPath)
+		images.RememberSearchResult(seriesResult.BackdropPath)
 		res = append(res, &TmdbSeriesSearchItemResolver{r: seriesResult})
 	}
 	return res, nil
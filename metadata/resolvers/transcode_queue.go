@@ -0,0 +1,75 @@
+package resolvers
+
+import (
+	"context"
+	"time"
+
+	"gitlab.com/olaris/olaris-server/metadata/db"
+	"gitlab.com/olaris/olaris-server/streaming"
+)
+
+// TranscodeJobResolver resolves a running or queued transcode.
+type TranscodeJobResolver struct {
+	r streaming.TranscodeJob
+}
+
+// UserID returns the ID of the user the transcode is for.
+func (r *TranscodeJobResolver) UserID() int32 {
+	return int32(r.r.UserID)
+}
+
+// Username returns the name of the user the transcode is for.
+func (r *TranscodeJobResolver) Username() string {
+	if user, err := db.FindUser(r.r.UserID); err == nil {
+		return user.Username
+	}
+	return ""
+}
+
+// File returns the FileLocator of the transcoded file.
+func (r *TranscodeJobResolver) File() string {
+	return r.r.Stream.FileLocator.String()
+}
+
+// StreamID returns the ID of the transcoded stream in the file.
+func (r *TranscodeJobResolver) StreamID() int32 {
+	return int32(r.r.Stream.StreamId)
+}
+
+// StreamType returns whether a video, audio or subtitle stream is transcoded.
+func (r *TranscodeJobResolver) StreamType() string {
+	return r.r.StreamType
+}
+
+// RepresentationID returns the representation the stream is transcoded to.
+func (r *TranscodeJobResolver) RepresentationID() string {
+	return r.r.RepresentationID
+}
+
+// Weight returns the share of the transcoding capacity the job takes up.
+func (r *TranscodeJobResolver) Weight() float64 {
+	return r.r.Weight
+}
+
+// Queued returns whether the job waits for capacity.
+func (r *TranscodeJobResolver) Queued() bool {
+	return r.r.Queued
+}
+
+// Since returns when the job was queued or started in RFC3339 format.
+func (r *TranscodeJobResolver) Since() string {
+	return r.r.Since.Format(time.RFC3339)
+}
+
+// TranscodeQueue returns the running transcodes followed by the queued ones.
+func (r *Resolver) TranscodeQueue(ctx context.Context) []*TranscodeJobResolver {
+	jobs := []*TranscodeJobResolver{}
+	if err := ifAdmin(ctx); err != nil {
+		return jobs
+	}
+
+	for _, job := range streaming.PBSManager.TranscodeJobs() {
+		jobs = append(jobs, &TranscodeJobResolver{job})
+	}
+	return jobs
+}
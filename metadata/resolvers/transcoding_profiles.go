@@ -0,0 +1,99 @@
+package resolvers
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+)
+
+// TranscodingProfileResolver resolves a transcoding profile.
+type TranscodingProfileResolver struct {
+	r ffmpeg.TranscodingProfile
+}
+
+// Name returns the name used to select the profile.
+func (r *TranscodingProfileResolver) Name() string {
+	return r.r.Name
+}
+
+// VideoEncoder returns the ffmpeg encoder used for H.264 video.
+func (r *TranscodingProfileResolver) VideoEncoder() string {
+	return r.r.VideoEncoder
+}
+
+// VideoCodecs returns the codecs video is transcoded to, most preferred first.
+func (r *TranscodingProfileResolver) VideoCodecs() []string {
+	return r.r.OfferedVideoCodecs()
+}
+
+// RateControl returns whether the profile encodes with a bitrate or a constant quality.
+func (r *TranscodingProfileResolver) RateControl() string {
+	return r.r.RateControl
+}
+
+// MaxFrameRate returns the frame rate transcoded video is limited to, 0 if unlimited.
+func (r *TranscodingProfileResolver) MaxFrameRate() float64 {
+	return r.r.MaxFrameRate
+}
+
+// ToneMapping returns the algorithm used to convert HDR video to SDR.
+func (r *TranscodingProfileResolver) ToneMapping() string {
+	return r.r.ToneMapping
+}
+
+// VideoHeights returns the heights of the video qualities that are offered.
+func (r *TranscodingProfileResolver) VideoHeights() []int32 {
+	heights := []int32{}
+	for _, rung := range r.r.Video {
+		heights = append(heights, int32(rung.Height))
+	}
+	return heights
+}
+
+// VideoBitRates returns the bitrates of the video qualities that are offered.
+func (r *TranscodingProfileResolver) VideoBitRates() []int32 {
+	bitRates := []int32{}
+	for _, rung := range r.r.Video {
+		bitRates = append(bitRates, int32(rung.BitRate))
+	}
+	return bitRates
+}
+
+// AudioBitRates returns the bitrates of the audio qualities that are offered.
+func (r *TranscodingProfileResolver) AudioBitRates() []int32 {
+	bitRates := []int32{}
+	for _, rung := range r.r.Audio {
+		bitRates = append(bitRates, int32(rung.BitRate))
+	}
+	return bitRates
+}
+
+// TranscodingProfiles returns all profiles users and libraries can select.
+func (r *Resolver) TranscodingProfiles() []*TranscodingProfileResolver {
+	profiles := []*TranscodingProfileResolver{}
+	for _, p := range ffmpeg.TranscodingProfiles() {
+		profiles = append(profiles, &TranscodingProfileResolver{p})
+	}
+	return profiles
+}
+
+// validTranscodingProfile checks whether the profile exists, empty means the default profile.
+func validTranscodingProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := ffmpeg.FindTranscodingProfile(name); !ok {
+		return fmt.Errorf("unknown transcoding profile '%s'", name)
+	}
+	return nil
+}
+
+// validDeinterlacing returns an error if the deinterlacing mode is unknown.
+func validDeinterlacing(mode string) error {
+	if !ffmpeg.ValidDeinterlacing(mode) {
+		return fmt.Errorf("unknown deinterlacing mode '%s', must be one of %s",
+			mode, strings.Join(ffmpeg.DeinterlacingModes, ", "))
+	}
+	return nil
+}
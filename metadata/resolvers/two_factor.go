@@ -0,0 +1,165 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+// TOTPEnrollmentResponse holds the data needed to set up an authenticator app.
+type TOTPEnrollmentResponse struct {
+	Error           *ErrorResolver
+	Secret          string
+	ProvisioningURI string
+}
+
+// TOTPEnrollmentResponseResolver resolves TOTPEnrollmentResponse.
+type TOTPEnrollmentResponseResolver struct {
+	r TOTPEnrollmentResponse
+}
+
+// Error returns error.
+func (r *TOTPEnrollmentResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Secret returns the base32-encoded secret for manual entry.
+func (r *TOTPEnrollmentResponseResolver) Secret() string {
+	return r.r.Secret
+}
+
+// ProvisioningURI returns the otpauth:// URI, usually rendered as a QR code.
+func (r *TOTPEnrollmentResponseResolver) ProvisioningURI() string {
+	return r.r.ProvisioningURI
+}
+
+// EnrollTOTP starts two-factor enrollment for the current user.
+func (r *Resolver) EnrollTOTP(ctx context.Context) *TOTPEnrollmentResponseResolver {
+	user, err := currentSessionUser(ctx)
+	if err != nil {
+		return &TOTPEnrollmentResponseResolver{TOTPEnrollmentResponse{Error: CreateErrResolver(err)}}
+	}
+	if user.TOTPEnabled {
+		return &TOTPEnrollmentResponseResolver{TOTPEnrollmentResponse{
+			Error: CreateErrResolver(fmt.Errorf("two-factor authentication is already enabled")),
+		}}
+	}
+
+	secret, err := auth.NewTOTPSecret()
+	if err == nil {
+		err = db.SetPendingTOTPSecret(user.ID, secret)
+	}
+	if err != nil {
+		return &TOTPEnrollmentResponseResolver{TOTPEnrollmentResponse{Error: CreateErrResolver(err)}}
+	}
+
+	return &TOTPEnrollmentResponseResolver{TOTPEnrollmentResponse{
+		Secret:          secret,
+		ProvisioningURI: auth.TOTPProvisioningURI(user.Username, secret),
+	}}
+}
+
+// RecoveryCodesResponse is returned when two-factor authentication gets enabled.
+type RecoveryCodesResponse struct {
+	Error         *ErrorResolver
+	RecoveryCodes []string
+}
+
+// RecoveryCodesResponseResolver resolves RecoveryCodesResponse.
+type RecoveryCodesResponseResolver struct {
+	r RecoveryCodesResponse
+}
+
+// Error returns error.
+func (r *RecoveryCodesResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// RecoveryCodes returns the single-use recovery codes.
+func (r *RecoveryCodesResponseResolver) RecoveryCodes() *[]string {
+	if r.r.RecoveryCodes == nil {
+		return nil
+	}
+	return &r.r.RecoveryCodes
+}
+
+// VerifyTOTP completes enrollment once the user entered a valid code from their authenticator.
+func (r *Resolver) VerifyTOTP(ctx context.Context, args struct{ Code string }) *RecoveryCodesResponseResolver {
+	user, err := currentSessionUser(ctx)
+	if err != nil {
+		return &RecoveryCodesResponseResolver{RecoveryCodesResponse{Error: CreateErrResolver(err)}}
+	}
+	if user.TOTPSecret == "" || user.TOTPEnabled {
+		return &RecoveryCodesResponseResolver{RecoveryCodesResponse{
+			Error: CreateErrResolver(fmt.Errorf("no two-factor enrollment in progress")),
+		}}
+	}
+
+	step, ok := auth.ValidateTOTPCode(user.TOTPSecret, args.Code, time.Now(), user.TOTPLastStep)
+	if !ok {
+		return &RecoveryCodesResponseResolver{RecoveryCodesResponse{
+			Error: CreateErrResolver(fmt.Errorf("invalid two-factor code")),
+		}}
+	}
+
+	codes, err := db.EnableTOTP(user.ID, step)
+	if err != nil {
+		return &RecoveryCodesResponseResolver{RecoveryCodesResponse{Error: CreateErrResolver(err)}}
+	}
+	return &RecoveryCodesResponseResolver{RecoveryCodesResponse{RecoveryCodes: codes}}
+}
+
+// DisableTOTP disables two-factor authentication for the current user. A valid code is required
+// so a stolen session can't be used to weaken the account.
+func (r *Resolver) DisableTOTP(ctx context.Context, args struct{ Code string }) *UserResponseResolver {
+	user, err := currentSessionUser(ctx)
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+
+	if _, ok := auth.ValidateTOTPCode(user.TOTPSecret, args.Code, time.Now(), user.TOTPLastStep); !ok {
+		if err := db.UseRecoveryCode(user.ID, args.Code); err != nil {
+			return &UserResponseResolver{&UserResponse{
+				Error: CreateErrResolver(fmt.Errorf("invalid two-factor code")),
+			}}
+		}
+	}
+
+	return resetTOTP(user.ID)
+}
+
+// ResetUserTOTP allows admins to disable two-factor authentication for a user who lost access
+// to their authenticator and recovery codes.
+func (r *Resolver) ResetUserTOTP(ctx context.Context, args struct{ ID int32 }) *UserResponseResolver {
+	err := ifAdmin(ctx)
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+
+	auditAdminMutation(ctx, "resetUserTOTP", "reset two-factor authentication of user %d", args.ID)
+	return resetTOTP(uint(args.ID))
+}
+
+func resetTOTP(userID uint) *UserResponseResolver {
+	if err := db.ResetTOTP(userID); err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+
+	user, err := db.FindUser(userID)
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+	return &UserResponseResolver{&UserResponse{User: &UserResolver{*user}}}
+}
+
+// currentSessionUser returns the user of an interactive session.
+func currentSessionUser(ctx context.Context) (*db.User, error) {
+	if err := ifUserSession(ctx); err != nil {
+		return nil, err
+	}
+	userID, _ := auth.UserID(ctx)
+	return db.FindUser(userID)
+}
@@ -1,6 +1,7 @@
 package resolvers
 
 import (
+	"context"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 )
 
@@ -11,9 +12,14 @@ type unidentifiedEpisodeFilesArgs struct {
 
 // UnidentifiedEpisodeFiles returns unidentified episode files
 func (r *Resolver) UnidentifiedEpisodeFiles(
+	ctx context.Context,
 	args *unidentifiedEpisodeFilesArgs) []*EpisodeFileResolver {
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return []*EpisodeFileResolver{}
+	}
 
 	qd := buildDatabaseQueryDetails(args.Offset, args.Limit)
+	qd.LibraryIDs = accessibleLibraryIDs(ctx)
 	episodeFiles, err := db.FindAllUnidentifiedEpisodeFiles(&qd)
 	if err != nil {
 		return []*EpisodeFileResolver{}
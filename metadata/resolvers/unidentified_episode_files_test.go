@@ -1,8 +1,12 @@
 package resolvers
 
 import (
+	"context"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 	"testing"
 )
@@ -27,7 +31,10 @@ func TestUnidentifiedEpisodeFiles(t *testing.T) {
 		},
 	})
 
-	response := r.UnidentifiedEpisodeFiles(&unidentifiedEpisodeFilesArgs{})
+	admin, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	ctx := auth.ContextWithUserID(context.Background(), admin.ID)
+	response := r.UnidentifiedEpisodeFiles(ctx, &unidentifiedEpisodeFilesArgs{})
 
 	assert.Len(t, response, 1)
 	filePath, _ := response[0].FilePath()
@@ -35,3 +42,31 @@ func TestUnidentifiedEpisodeFiles(t *testing.T) {
 
 	assert.EqualValues(t, db.BackendLocal, response[0].Library().Backend())
 }
+
+func TestUnidentifiedFiles_LibraryGrants(t *testing.T) {
+	metadataCtx := app.NewTestingMDContext(nil)
+	r := NewResolver(metadataCtx)
+
+	for _, libraryID := range []uint{1, 2} {
+		filePath := fmt.Sprintf("local#/library%d/test.mkv", libraryID)
+		metadataCtx.Db.Create(&db.EpisodeFile{MediaItem: db.MediaItem{FilePath: filePath, LibraryID: libraryID}})
+		metadataCtx.Db.Create(&db.MovieFile{MediaItem: db.MediaItem{FilePath: filePath, LibraryID: libraryID}})
+	}
+
+	_, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	restricted, err := db.CreateUser("restricted", "testtest", false)
+	require.NoError(t, err)
+	require.NoError(t, db.GrantLibraries(restricted.ID, []uint{2}))
+	ctx := auth.ContextWithUserID(context.Background(), restricted.ID)
+
+	episodeFiles := r.UnidentifiedEpisodeFiles(ctx, &unidentifiedEpisodeFilesArgs{})
+	require.Len(t, episodeFiles, 1)
+	filePath, _ := episodeFiles[0].FilePath()
+	assert.Equal(t, "/library2/test.mkv", filePath)
+
+	movieFiles := r.UnidentifiedMovieFiles(ctx, &unidentifiedMovieFilesArgs{})
+	require.Len(t, movieFiles, 1)
+	filePath, _ = movieFiles[0].FilePath()
+	assert.Equal(t, "/library2/test.mkv", filePath)
+}
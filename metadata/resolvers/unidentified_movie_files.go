@@ -14,9 +14,13 @@ type unidentifiedMovieFilesArgs struct {
 func (r *Resolver) UnidentifiedMovieFiles(
 	ctx context.Context,
 	args *unidentifiedMovieFilesArgs) []*MovieFileResolver {
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return []*MovieFileResolver{}
+	}
 
-	movieFiles, err := db.FindAllUnidentifiedMovieFiles(
-		buildDatabaseQueryDetails(args.Offset, args.Limit))
+	qd := buildDatabaseQueryDetails(args.Offset, args.Limit)
+	qd.LibraryIDs = accessibleLibraryIDs(ctx)
+	movieFiles, err := db.FindAllUnidentifiedMovieFiles(qd)
 	if err != nil {
 		return []*MovieFileResolver{}
 	}
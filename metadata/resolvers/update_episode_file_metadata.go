@@ -90,6 +90,9 @@ func (r *Resolver) UpdateEpisodeFileMetadata(
 	//  See https://gitlab.com/olaris/olaris-react/issues/33
 	// updateEpisodeFileMetadataGroup.Wait()
 
+	auditAdminMutation(ctx, "updateEpisodeFileMetadata", "tagging %d episode files as TMDB series %d",
+		len(episodeFiles), args.Input.TmdbID)
+
 	return &UpdateEpisodeFileMetadataPayloadResolver{}
 }
 
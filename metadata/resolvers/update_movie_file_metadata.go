@@ -46,6 +46,8 @@ func (r *Resolver) UpdateMovieFileMetadata(
 
 	movieFile.Movie = *movie
 	db.SaveMovieFile(movieFile)
+	auditAdminMutation(ctx, "updateMovieFileMetadata", "tagged movie file %s as TMDB movie %d",
+		movieFile.UUID, args.Input.TmdbID)
 
 	if oldMovie != nil {
 		r.env.MetadataManager.GarbageCollectMovieIfRequired(oldMovie.ID)
@@ -10,16 +10,23 @@ import (
 
 // UpNext returns episode/movie that could populate a dashboard.
 func (r *Resolver) UpNext(ctx context.Context) *[]*MediaItemResolver {
+	if err := ifScope(ctx, db.APIKeyScopeMetadataRead); err != nil {
+		return &[]*MediaItemResolver{}
+	}
 	userID, _ := auth.UserID(ctx)
+	libraryIDs := accessibleLibraryIDs(ctx)
 	sortables := []sortable{}
 
 	for _, movie := range db.UpNextMovies(userID) {
-		sortables = append(sortables, movie)
+		if db.MovieInLibraries(movie.ID, libraryIDs) {
+			sortables = append(sortables, movie)
+		}
 	}
 
 	for _, ep := range db.UpNextEpisodes(userID) {
-		sortables = append(sortables, ep)
-
+		if db.EpisodeInLibraries(ep, libraryIDs) {
+			sortables = append(sortables, ep)
+		}
 	}
 	sort.Sort(ByUpdatedAt(sortables))
 
@@ -2,6 +2,10 @@ package resolvers
 
 import (
 	"context"
+	"fmt"
+	"time"
+
+	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"gitlab.com/olaris/olaris-server/metadata/db"
 )
 
@@ -25,6 +29,16 @@ func (r *UserResolver) Admin() bool {
 	return r.r.Admin
 }
 
+// TranscodingProfile returns the transcoding profile selected by the user, empty for the one of the library.
+func (r *UserResolver) TranscodingProfile() string {
+	return r.r.TranscodingProfile
+}
+
+// TotpEnabled returns whether the user has two-factor authentication enabled.
+func (r *UserResolver) TotpEnabled() bool {
+	return r.r.TOTPEnabled
+}
+
 // UserResponse holds user information and error if needed.
 type UserResponse struct {
 	Error *ErrorResolver
@@ -70,6 +84,181 @@ func (r *Resolver) DeleteUser(ctx context.Context, args struct{ ID int32 }) *Use
 		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
 	}
 
+	auditEvent(ctx, db.AuditEventUserDeleted, "deleteUser",
+		fmt.Sprintf("deleted user '%s' (%d)", user.Username, user.ID))
+
 	return &UserResponseResolver{&UserResponse{User: &UserResolver{user}}}
 
 }
+
+type changePasswordArgs struct {
+	CurrentPassword string
+	NewPassword     string
+}
+
+// ChangePassword changes the password of the current user.
+func (r *Resolver) ChangePassword(ctx context.Context, args *changePasswordArgs) *UserResponseResolver {
+	user, err := currentSessionUser(ctx)
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(CreateNoAuthorisationError())}}
+	}
+
+	if !user.ValidPassword(args.CurrentPassword) {
+		auditEvent(ctx, db.AuditEventLoginFailed, "changePassword", "invalid current password")
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(fmt.Errorf("current password is invalid"))}}
+	}
+
+	if err := db.UpdatePassword(user.ID, args.NewPassword); err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+	auditEvent(ctx, db.AuditEventPasswordChange, "changePassword", "changed own password")
+
+	return &UserResponseResolver{&UserResponse{User: &UserResolver{*user}}}
+}
+
+type updateProfileArgs struct {
+	Username *string
+}
+
+// UpdateProfile changes the profile of the current user.
+func (r *Resolver) UpdateProfile(ctx context.Context, args *updateProfileArgs) *UserResponseResolver {
+	user, err := currentSessionUser(ctx)
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(CreateNoAuthorisationError())}}
+	}
+
+	if args.Username != nil && *args.Username != user.Username {
+		oldUsername := user.Username
+		user, err = db.RenameUser(user.ID, *args.Username)
+		if err != nil {
+			return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+		}
+		auditEvent(ctx, db.AuditEventUserRenamed, "updateProfile",
+			fmt.Sprintf("renamed from '%s' to '%s'", oldUsername, user.Username))
+	}
+
+	return &UserResponseResolver{&UserResponse{User: &UserResolver{*user}}}
+}
+
+// PasswordResetTokenResponse is returned when creating a password reset token.
+type PasswordResetTokenResponse struct {
+	Error     *ErrorResolver
+	Token     *string
+	ExpiresAt *string
+}
+
+// PasswordResetTokenResponseResolver resolves PasswordResetTokenResponse.
+type PasswordResetTokenResponseResolver struct {
+	r PasswordResetTokenResponse
+}
+
+// Error returns error.
+func (r *PasswordResetTokenResponseResolver) Error() *ErrorResolver {
+	return r.r.Error
+}
+
+// Token returns the reset token, this is the only time it is available.
+func (r *PasswordResetTokenResponseResolver) Token() *string {
+	return r.r.Token
+}
+
+// ExpiresAt returns the time the token expires in RFC3339 format.
+func (r *PasswordResetTokenResponseResolver) ExpiresAt() *string {
+	return r.r.ExpiresAt
+}
+
+type createPasswordResetTokenArgs struct {
+	UserID        int32
+	ValidForHours *int32
+}
+
+// CreatePasswordResetToken creates a one-time token the given user can use to set a new password.
+func (r *Resolver) CreatePasswordResetToken(ctx context.Context, args *createPasswordResetTokenArgs) *PasswordResetTokenResponseResolver {
+	err := ifAdmin(ctx)
+	if err != nil {
+		return &PasswordResetTokenResponseResolver{PasswordResetTokenResponse{Error: CreateErrResolver(err)}}
+	}
+
+	validFor := 24 * time.Hour
+	if args.ValidForHours != nil {
+		if *args.ValidForHours <= 0 {
+			return &PasswordResetTokenResponseResolver{PasswordResetTokenResponse{
+				Error: CreateErrResolver(fmt.Errorf("validForHours should be positive")),
+			}}
+		}
+		validFor = time.Duration(*args.ValidForHours) * time.Hour
+	}
+
+	createdByID, _ := auth.UserID(ctx)
+	reset, token, err := db.CreatePasswordResetToken(uint(args.UserID), createdByID, validFor)
+	if err != nil {
+		return &PasswordResetTokenResponseResolver{PasswordResetTokenResponse{Error: CreateErrResolver(err)}}
+	}
+	auditAdminMutation(ctx, "createPasswordResetToken", "created password reset token for user %d", reset.UserID)
+
+	return &PasswordResetTokenResponseResolver{PasswordResetTokenResponse{
+		Token:     &token,
+		ExpiresAt: formatOptionalTime(&reset.ExpiresAt),
+	}}
+}
+
+type updateUserAdminArgs struct {
+	ID    int32
+	Admin bool
+}
+
+// UpdateUserAdmin promotes a user to admin or demotes them.
+func (r *Resolver) UpdateUserAdmin(ctx context.Context, args *updateUserAdminArgs) *UserResponseResolver {
+	err := ifAdmin(ctx)
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+
+	user, err := db.SetUserAdmin(uint(args.ID), args.Admin)
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+	auditAdminMutation(ctx, "updateUserAdmin", "set admin of user '%s' (%d) to %t", user.Username, user.ID, user.Admin)
+
+	return &UserResponseResolver{&UserResponse{User: &UserResolver{*user}}}
+}
+
+type updateUserTranscodingProfileArgs struct {
+	Profile string
+	UserID  *int32
+}
+
+// UpdateUserTranscodingProfile selects the transcoding profile of the current user, admins can
+// select it for other users too.
+func (r *Resolver) UpdateUserTranscodingProfile(ctx context.Context, args *updateUserTranscodingProfileArgs) *UserResponseResolver {
+	currentUserID, ok := auth.UserID(ctx)
+	if !ok {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(CreateNoAuthorisationError())}}
+	}
+
+	userID := currentUserID
+	if args.UserID != nil && uint(*args.UserID) != currentUserID {
+		if err := ifAdmin(ctx); err != nil {
+			return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+		}
+		userID = uint(*args.UserID)
+	} else if err := ifUserSession(ctx); err != nil {
+		// Users change their own settings themselves, not through API keys
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+
+	if err := validTranscodingProfile(args.Profile); err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+
+	user, err := db.SetUserTranscodingProfile(userID, args.Profile)
+	if err != nil {
+		return &UserResponseResolver{&UserResponse{Error: CreateErrResolver(err)}}
+	}
+	if userID != currentUserID {
+		auditAdminMutation(ctx, "updateUserTranscodingProfile", "set transcoding profile of user '%s' (%d) to '%s'",
+			user.Username, user.ID, user.TranscodingProfile)
+	}
+
+	return &UserResponseResolver{&UserResponse{User: &UserResolver{*user}}}
+}
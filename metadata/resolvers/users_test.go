@@ -0,0 +1,93 @@
+package resolvers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/metadata/app"
+	"gitlab.com/olaris/olaris-server/metadata/auth"
+	"gitlab.com/olaris/olaris-server/metadata/db"
+)
+
+func TestDemotedAdminLosesAdminRights(t *testing.T) {
+	r := NewResolver(app.NewTestingMDContext(nil))
+	_, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	coadmin, err := db.CreateUser("coadmin", "testtest", true)
+	require.NoError(t, err)
+	token, err := auth.CreateMetadataJWT(&coadmin, auth.DefaultLoginTokenValidity)
+	require.NoError(t, err)
+
+	var users []*UserResolver
+	handler := auth.MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		users = r.Users(req.Context())
+	}))
+	request := func() {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	request()
+	assert.Len(t, users, 2)
+
+	_, err = db.SetUserAdmin(coadmin.ID, false)
+	require.NoError(t, err)
+	request()
+	assert.Empty(t, users, "the token still claims admin, but the user isn't one anymore")
+}
+
+func TestUpdateProfile(t *testing.T) {
+	r := NewResolver(app.NewTestingMDContext(nil))
+	_, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	user, err := db.CreateUser("user", "testtest", false)
+	require.NoError(t, err)
+	ctx := auth.ContextWithUserID(context.Background(), user.ID)
+
+	taken := "admin"
+	res := r.UpdateProfile(ctx, &updateProfileArgs{Username: &taken})
+	require.NotNil(t, res.Error())
+	assert.Nil(t, res.User())
+
+	renamed := "renamed"
+	res = r.UpdateProfile(ctx, &updateProfileArgs{Username: &renamed})
+	require.Nil(t, res.Error())
+	assert.Equal(t, "renamed", res.User().Username())
+	found, err := db.FindUser(user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "renamed", found.Username)
+
+	res = r.UpdateProfile(context.Background(), &updateProfileArgs{Username: &renamed})
+	assert.NotNil(t, res.Error(), "requires a logged in user")
+}
+
+func TestUpdateUserTranscodingProfile_APIKey(t *testing.T) {
+	r := NewResolver(app.NewTestingMDContext(nil))
+	_, err := db.CreateUser("admin", "testtest", true)
+	require.NoError(t, err)
+	user, err := db.CreateUser("user", "testtest", false)
+	require.NoError(t, err)
+	_, apiKey, err := db.CreateAPIKey("read only", user.ID, 0, []string{db.APIKeyScopeMetadataRead})
+	require.NoError(t, err)
+
+	var res *UserResponseResolver
+	handler := auth.MiddleWare(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		res = r.UpdateUserTranscodingProfile(req.Context(), &updateUserTranscodingProfileArgs{Profile: "default"})
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Add(auth.APIKeyHeader, apiKey)
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	require.NotNil(t, res)
+	assert.NotNil(t, res.Error(), "API keys can't change the settings of their user")
+
+	ctx := auth.ContextWithUserID(context.Background(), user.ID)
+	res = r.UpdateUserTranscodingProfile(ctx, &updateUserTranscodingProfileArgs{Profile: "default"})
+	require.Nil(t, res.Error())
+	assert.Equal(t, "default", res.User().TranscodingProfile())
+}
@@ -5,7 +5,6 @@ import (
 	"github.com/gorilla/mux"
 	"gitlab.com/olaris/olaris-server/dash"
 	"gitlab.com/olaris/olaris-server/ffmpeg"
-	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"net/http"
 )
 
@@ -16,10 +15,9 @@ func serveDASHManifest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	playableCodecs := r.URL.Query()["playableCodecs"]
-	capabilities := ffmpeg.ClientCodecCapabilities{
-		PlayableCodecs: playableCodecs,
-	}
+	capabilities := getClientCapabilities(r)
+
+	profile := getTranscodingProfile(r)
 
 	streams, err := ffmpeg.GetStreams(fileLocator)
 	if err != nil {
@@ -27,21 +25,40 @@ func serveDASHManifest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	burnInSubtitle, err := getBurnInSubtitle(r, streams)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Optimized versions don't have the image subtitles of the file to burn in
+	if burnInSubtitle == nil && redirectToOptimizedVersion(w, r, streams, capabilities, profile) {
+		return
+	}
+	format := profile.VideoCodecFor(streams.GetVideoStream(), capabilities)
+
 	videoStream := dash.StreamRepresentations{Stream: streams.GetVideoStream()}
 	// Get transmuxed or similar transcoded representation
-	fullQualityRepresentation, _ := ffmpeg.GetTransmuxedOrTranscodedRepresentation(streams.GetVideoStream(), capabilities)
+	fullQualityRepresentation, _ := ffmpeg.GetTransmuxedOrTranscodedRepresentation(
+		streams.GetVideoStream(), capabilities, profile)
+	if burnInSubtitle != nil {
+		fullQualityRepresentation = ffmpeg.GetBurnInRepresentation(
+			fullQualityRepresentation, *burnInSubtitle, profile, format)
+	}
 	videoStream.Representations = append(videoStream.Representations, fullQualityRepresentation)
 
-	lowQualityRepresentations := ffmpeg.GetStandardPresetVideoRepresentations(streams.GetVideoStream())
+	lowQualityRepresentations := profile.VideoRepresentations(streams.GetVideoStream(), format)
 	for _, r := range lowQualityRepresentations {
 		if r.Representation.BitRate < fullQualityRepresentation.Representation.BitRate {
+			if burnInSubtitle != nil {
+				r = ffmpeg.GetBurnInRepresentation(r, *burnInSubtitle, profile, format)
+			}
 			videoStream.Representations = append(videoStream.Representations, r)
 		}
 	}
 
 	audioStreams := []dash.StreamRepresentations{}
 	for _, s := range streams.AudioStreams {
-		r, err := ffmpeg.GetTransmuxedOrTranscodedRepresentation(s, capabilities)
+		r, err := ffmpeg.GetTransmuxedOrTranscodedRepresentation(s, capabilities, profile)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -56,11 +73,9 @@ func serveDASHManifest(w http.ResponseWriter, r *http.Request) {
 	subtitleStreams := []dash.SubtitleStreamRepresentation{}
 	subtitleRepresentations := ffmpeg.GetSubtitleStreamRepresentations(streams.SubtitleStreams)
 	for _, s := range subtitleRepresentations {
-		// NOTE(Leon Handreke): Because we'd have to propagate the UserID here through
-		// context or something like that and it's not used anyway, just use 0 here.
 		// We need to use s.Stream.FileLocator here because the subtitle file may be external
 		// next to the video file.
-		jwt, err := auth.CreateStreamingJWT(0, s.Stream.FileLocator.String())
+		fileLocatorPath, err := fileLocatorURLPath(r, s.Stream.FileLocator)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -68,8 +83,8 @@ func serveDASHManifest(w http.ResponseWriter, r *http.Request) {
 		subtitleStreams = append(subtitleStreams, dash.SubtitleStreamRepresentation{
 			StreamRepresentation: s,
 			// TODO(Maran) It would be better to somehow pass routing information along and not hard-code this in place.
-			URI: fmt.Sprintf("/olaris/s/files/jwt/%s/%s/%d/%s/0.vtt",
-				jwt,
+			URI: fmt.Sprintf("/olaris/s/files/%s/%s/%d/%s/0.vtt",
+				fileLocatorPath,
 				mux.Vars(r)["sessionID"],
 				s.Stream.StreamId,
 				s.Representation.RepresentationId),
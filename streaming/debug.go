@@ -46,6 +46,29 @@ const transcodingSessionsDebugPageTemplate = `
 			{{ end }}
 			</tbody>
 		</table>
+		<table style="border: 1px solid black;">
+			<caption>Transcode queue</caption>
+			<thead><tr>
+				<th>User</th>
+				<th>Source</th>
+				<th>Target Representation</th>
+				<th>Weight</th>
+				<th>Status</th>
+				<th>Since</th>
+			</tr></thead>
+			<tbody>
+			{{ range .jobs }}
+				<tr>
+					<td>{{ .UserID }}</td>
+					<td>{{ .Stream.FileLocator }}:{{ .Stream.StreamId }} ({{ .StreamType }})</td>
+					<td style="width: 200px;">{{ .RepresentationID }}</td>
+					<td>{{ printf "%.2f" .Weight }}</td>
+					<td>{{if .Queued }}Queued{{else}}Running{{end}}</td>
+					<td>{{ .Since.Format "15:04:05" }}</td>
+				</tr>
+			{{ end }}
+			</tbody>
+		</table>
 	</body>
 </html>
 `
@@ -65,6 +88,7 @@ func servePlaybackSessionDebugPage(w http.ResponseWriter, r *http.Request) {
 
 	templateData := map[string]interface{}{
 		"sessions": playbackSessions,
+		"jobs":     PBSManager.TranscodeJobs(),
 	}
 
 	t := template.Must(template.New("manifest").Parse(transcodingSessionsDebugPageTemplate))
@@ -16,15 +16,19 @@ func AddM3U8Header(next http.Handler) http.Handler {
 
 // RegisterRoutes registers streaming routes to an existing router
 func RegisterRoutes(router *mux.Router) {
+	router.Use(validateStreamingTicket)
+
 	router.Handle("/files/{fileLocator:.*}/{sessionID}/hls-transmuxing-manifest.m3u8", AddM3U8Header(http.HandlerFunc(serveHlsTransmuxingMasterPlaylist)))
 	router.Handle("/files/{fileLocator:.*}/{sessionID}/hls-transcoding-manifest.m3u8", AddM3U8Header(http.HandlerFunc(serveHlsTranscodingMasterPlaylist)))
 	router.HandleFunc("/files/{fileLocator:.*}/metadata.json", serveMetadata)
+	router.HandleFunc("/files/{fileLocator:.*}/{streamId:[0-9]+}/subtitles.ass", serveASSSubtitle)
 	router.Handle("/files/{fileLocator:.*}/{sessionID}/hls-manifest.m3u8", AddM3U8Header(http.HandlerFunc(serveHlsMasterPlaylist)))
 	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/dash-manifest.mpd", serveDASHManifest)
 	router.Handle("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/media.m3u8", AddM3U8Header(http.HandlerFunc(serveHlsTranscodingMediaPlaylist)))
 	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/{segmentId:[0-9]+}.m4s", serveMediaSegment)
 	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/{segmentId:[0-9]+}.vtt", serveSubtitleSegment)
 	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/{streamId}/{representationId}/init.mp4", serveInit)
+	router.HandleFunc("/files/{fileLocator:.*}/{sessionID}/trickplay/{file:(?:sprite|chapter)-[0-9]+\\.jpg|thumbnails\\.vtt|index\\.bif|images\\.m3u8}", serveTrickplay)
 
 	// This handler just serves up the file for downloading. This is also used
 	// internally by ffmpeg to access rclone files.
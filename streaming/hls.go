@@ -5,9 +5,9 @@ import (
 	"github.com/gorilla/mux"
 	"gitlab.com/olaris/olaris-server/ffmpeg"
 	"gitlab.com/olaris/olaris-server/hls"
-	"gitlab.com/olaris/olaris-server/metadata/auth"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 func serveHlsMasterPlaylist(w http.ResponseWriter, r *http.Request) {
@@ -17,10 +17,9 @@ func serveHlsMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	playableCodecs := r.URL.Query()["playableCodecs"]
-	capabilities := ffmpeg.ClientCodecCapabilities{
-		PlayableCodecs: playableCodecs,
-	}
+	capabilities := getClientCapabilities(r)
+
+	profile := getTranscodingProfile(r)
 
 	streams, err := ffmpeg.GetStreams(fileLocator)
 	if err != nil {
@@ -28,8 +27,24 @@ func serveHlsMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	burnInSubtitle, err := getBurnInSubtitle(r, streams)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Optimized versions don't have the image subtitles of the file to burn in
+	if burnInSubtitle == nil && redirectToOptimizedVersion(w, r, streams, capabilities, profile) {
+		return
+	}
+	format := profile.VideoCodecFor(streams.GetVideoStream(), capabilities)
+
 	// Get transmuxed or similar transcoded representation
-	fullQualityRepresentation, _ := ffmpeg.GetTransmuxedOrTranscodedRepresentation(streams.GetVideoStream(), capabilities)
+	fullQualityRepresentation, _ := ffmpeg.GetTransmuxedOrTranscodedRepresentation(
+		streams.GetVideoStream(), capabilities, profile)
+	if burnInSubtitle != nil {
+		fullQualityRepresentation = ffmpeg.GetBurnInRepresentation(
+			fullQualityRepresentation, *burnInSubtitle, profile, format)
+	}
 	videoRepresentations := []ffmpeg.StreamRepresentation{fullQualityRepresentation}
 
 	// TODO(Leon Handreke): I've observed issues with switching from transmuxed representations to transcoded
@@ -37,10 +52,11 @@ func serveHlsMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 	// https://gitlab.com/olaris/olaris-server/issues/48
 	if fullQualityRepresentation.Representation.Transcoded {
 		// Build lower-quality transcoded versions
-		for _, preset := range []string{"preset:480-1000k-video", "preset:720-5000k-video", "preset:1080-10000k-video"} {
-			r, _ := ffmpeg.StreamRepresentationFromRepresentationId(
-				streams.GetVideoStream(), preset)
+		for _, r := range profile.VideoRepresentations(streams.GetVideoStream(), format) {
 			if r.Representation.BitRate < fullQualityRepresentation.Representation.BitRate {
+				if burnInSubtitle != nil {
+					r = ffmpeg.GetBurnInRepresentation(r, *burnInSubtitle, profile, format)
+				}
 				videoRepresentations = append(videoRepresentations, r)
 			}
 		}
@@ -48,7 +64,7 @@ func serveHlsMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 
 	audioStreamRepresentations := []ffmpeg.StreamRepresentation{}
 	for _, s := range streams.AudioStreams {
-		r, err := ffmpeg.GetTransmuxedOrTranscodedRepresentation(s, capabilities)
+		r, err := ffmpeg.GetTransmuxedOrTranscodedRepresentation(s, capabilities, profile)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -62,15 +78,15 @@ func serveHlsMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 			VideoStream:    v,
 			AudioStreams:   audioStreamRepresentations,
 			AudioGroupName: "audio",
-			// TODO(Leon Handreke): Is just using the first one always correct?
-			AudioCodecs: audioStreamRepresentations[0].Representation.Codecs,
+			AudioCodecs:    joinAudioCodecs(audioStreamRepresentations),
 		})
 	}
 
 	subtitleRepresentations := ffmpeg.GetSubtitleStreamRepresentations(streams.SubtitleStreams)
-	subtitlePlaylistItems := buildSubtitlePlaylistItems(subtitleRepresentations, mux.Vars(r)["sessionID"])
+	subtitlePlaylistItems := buildSubtitlePlaylistItems(r, subtitleRepresentations)
 
-	manifest := hls.BuildMasterPlaylistFromFile(combinations, subtitlePlaylistItems)
+	manifest := hls.BuildMasterPlaylistFromFile(
+		combinations, subtitlePlaylistItems, buildImagePlaylistItems(fileLocator.String()))
 	w.Write([]byte(manifest))
 }
 
@@ -95,7 +111,7 @@ func serveHlsTransmuxingMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 	}
 
 	subtitleRepresentations := ffmpeg.GetSubtitleStreamRepresentations(streams.SubtitleStreams)
-	subtitlePlaylistItems := buildSubtitlePlaylistItems(subtitleRepresentations, mux.Vars(r)["sessionID"])
+	subtitlePlaylistItems := buildSubtitlePlaylistItems(r, subtitleRepresentations)
 
 	manifest := hls.BuildMasterPlaylistFromFile(
 		[]hls.RepresentationCombination{
@@ -103,11 +119,11 @@ func serveHlsTransmuxingMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 				VideoStream:    transmuxedVideoStream,
 				AudioStreams:   audioStreamRepresentations,
 				AudioGroupName: "transmuxed",
-				// TODO(Leon Handreke): Fill this from the audio codecs.
-				AudioCodecs: "mp4a.40.2",
+				AudioCodecs:    joinAudioCodecs(audioStreamRepresentations),
 			},
 		},
-		subtitlePlaylistItems)
+		subtitlePlaylistItems,
+		buildImagePlaylistItems(fileLocator.String()))
 	w.Write([]byte(manifest))
 }
 
@@ -123,16 +139,12 @@ func serveHlsTranscodingMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	videoRepresentation1, _ := ffmpeg.StreamRepresentationFromRepresentationId(
-		streams.GetVideoStream(), "preset:480-1000k-video")
-	videoRepresentation2, _ := ffmpeg.StreamRepresentationFromRepresentationId(
-		streams.GetVideoStream(), "preset:720-5000k-video")
-	videoRepresentations := []ffmpeg.StreamRepresentation{
-		videoRepresentation1, videoRepresentation2}
+	profile := getTranscodingProfile(r)
+	audioPresets := profile.AudioPresets()
 
 	representationCombinations := []hls.RepresentationCombination{}
 
-	for i, r := range videoRepresentations {
+	for i, r := range profile.VideoRepresentations(streams.GetVideoStream(), ffmpeg.VideoCodecH264) {
 		// NOTE(Leon Handreke): This will lead to multiple identical audio groups but whatevs
 		audioGroupName := "audio-group-" + strconv.Itoa(i)
 		c := hls.RepresentationCombination{
@@ -140,25 +152,23 @@ func serveHlsTranscodingMasterPlaylist(w http.ResponseWriter, r *http.Request) {
 			AudioGroupName: audioGroupName,
 			AudioCodecs:    "mp4a.40.2",
 		}
+		// Pair the lowest video qualities with the lowest audio qualities, the rest get the best audio.
+		audioPreset := audioPresets[len(audioPresets)-1]
+		if i < len(audioPresets) {
+			audioPreset = audioPresets[i]
+		}
 		for _, s := range streams.AudioStreams {
-			var audioRepresentation ffmpeg.StreamRepresentation
-			if i == 0 {
-				audioRepresentation, _ = ffmpeg.StreamRepresentationFromRepresentationId(
-					s, "preset:64k-audio")
-			} else {
-				audioRepresentation, _ = ffmpeg.StreamRepresentationFromRepresentationId(
-					s, "preset:128k-audio")
-			}
+			audioRepresentation, _ := ffmpeg.StreamRepresentationFromRepresentationId(s, audioPreset)
 			c.AudioStreams = append(c.AudioStreams, audioRepresentation)
 		}
 		representationCombinations = append(representationCombinations, c)
 	}
 
 	subtitleRepresentations := ffmpeg.GetSubtitleStreamRepresentations(streams.SubtitleStreams)
-	subtitlePlaylistItems := buildSubtitlePlaylistItems(subtitleRepresentations, mux.Vars(r)["sessionID"])
+	subtitlePlaylistItems := buildSubtitlePlaylistItems(r, subtitleRepresentations)
 
 	manifest := hls.BuildMasterPlaylistFromFile(
-		representationCombinations, subtitlePlaylistItems)
+		representationCombinations, subtitlePlaylistItems, buildImagePlaylistItems(mediaFileURL.String()))
 	w.Write([]byte(manifest))
 }
 
@@ -184,19 +194,31 @@ func serveHlsTranscodingMediaPlaylist(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(manifest))
 }
 
-func buildSubtitlePlaylistItems(representations []ffmpeg.StreamRepresentation, sessionID string) []hls.SubtitlePlaylistItem {
+// joinAudioCodecs returns the codecs of all audio representations for the CODECS attribute of
+// a variant stream, which has to list every codec a client may need.
+func joinAudioCodecs(representations []ffmpeg.StreamRepresentation) string {
+	codecs := []string{}
+	seen := map[string]bool{}
+	for _, r := range representations {
+		if !seen[r.Representation.Codecs] {
+			seen[r.Representation.Codecs] = true
+			codecs = append(codecs, r.Representation.Codecs)
+		}
+	}
+	return strings.Join(codecs, ",")
+}
+
+func buildSubtitlePlaylistItems(r *http.Request, representations []ffmpeg.StreamRepresentation) []hls.SubtitlePlaylistItem {
 	// Subtitles may be in another file, so we need to list their absolute URI.
 	subtitlePlaylistItems := []hls.SubtitlePlaylistItem{}
 	for _, s := range representations {
-		// NOTE(Leon Handreke): Because we'd have to propagate the UserID here through
-		// context or something like that and it's not used anyway, just use 0 here.
-		jwt, _ := auth.CreateStreamingJWT(0, s.Stream.FileLocator.String())
+		fileLocatorPath, _ := fileLocatorURLPath(r, s.Stream.FileLocator)
 		subtitlePlaylistItems = append(subtitlePlaylistItems,
 			hls.SubtitlePlaylistItem{
 				StreamRepresentation: s,
-				URI: fmt.Sprintf("/olaris/s/files/jwt/%s/%s/%d/%s/media.m3u8",
-					jwt,
-					sessionID,
+				URI: fmt.Sprintf("/olaris/s/files/%s/%s/%d/%s/media.m3u8",
+					fileLocatorPath,
+					mux.Vars(r)["sessionID"],
 					s.Stream.StreamId,
 					s.Representation.RepresentationId),
 			})
@@ -2,12 +2,41 @@ package streaming
 
 import (
 	"encoding/json"
+	"fmt"
 	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+	"gitlab.com/olaris/olaris-server/trickplay"
 	"net/http"
+	"path"
 )
 
 type metadataResponse struct {
-	CheckCodecs []string `json:"checkCodecs"`
+	CheckCodecs []string           `json:"checkCodecs"`
+	Chapters    []chapterMetadata  `json:"chapters"`
+	Subtitles   []subtitleMetadata `json:"subtitles"`
+}
+
+type subtitleMetadata struct {
+	StreamID        int64  `json:"streamId"`
+	Codec           string `json:"codec"`
+	Language        string `json:"language"`
+	Title           string `json:"title"`
+	Forced          bool   `json:"forced"`
+	HearingImpaired bool   `json:"hearingImpaired"`
+	External        bool   `json:"external"`
+	// ImageBased subtitles are not offered as WebVTT, they can only be burned in.
+	ImageBased bool `json:"imageBased"`
+	// ASSPath is only set for ASS/SSA subtitles, which clients can render with their styling.
+	ASSPath string `json:"assPath,omitempty"`
+}
+
+type chapterMetadata struct {
+	// Start and End are in seconds.
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Title string  `json:"title,omitempty"`
+	// ThumbnailPath is only set if trickplay images were generated.
+	ThumbnailPath string `json:"thumbnailPath,omitempty"`
 }
 
 // serveMetadata generates a list of possible codecs that we could possibly serve and returns
@@ -31,34 +60,108 @@ func serveMetadata(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	profile := getTranscodingProfile(r)
 	checkCodecs := []string{}
 
 	transmuxedVideo := ffmpeg.GetTransmuxedRepresentation(streams.GetVideoStream())
-	transcodedVideo := ffmpeg.GetSimilarTranscodedRepresentation(streams.GetVideoStream())
+	checkCodecs = append(checkCodecs, transmuxedVideo.Representation.Codecs)
 
-	checkCodecs = append(checkCodecs,
-		transmuxedVideo.Representation.Codecs,
-		transcodedVideo.Representation.Codecs)
+	// Clients get HEVC or AV1 only if they report being able to play these codecs, and HDR only in
+	// 10-bit HEVC or AV1
+	for _, format := range profile.OfferedVideoFormats(streams.GetVideoStream()) {
+		transcodedVideo := ffmpeg.GetSimilarTranscodedVideoRepresentation(streams.GetVideoStream(), profile, format)
+		checkCodecs = append(checkCodecs, transcodedVideo.Representation.Codecs)
 
-	lowQualityRepresentations := ffmpeg.GetStandardPresetVideoRepresentations(
-		streams.GetVideoStream())
-	for _, r := range lowQualityRepresentations {
-		checkCodecs = append(checkCodecs, r.Representation.Codecs)
+		lowQualityRepresentations := profile.VideoRepresentations(streams.GetVideoStream(), format)
+		for _, r := range lowQualityRepresentations {
+			checkCodecs = append(checkCodecs, r.Representation.Codecs)
+		}
 	}
 
 	for _, s := range streams.AudioStreams {
-		transmuxedAudio := ffmpeg.GetTransmuxedRepresentation(streams.GetVideoStream())
-		transcodedAudio := ffmpeg.GetSimilarTranscodedRepresentation(streams.GetVideoStream())
-		lowQualityAudio, _ := ffmpeg.StreamRepresentationFromRepresentationId(
-			s, "preset:128k-audio")
-
-		checkCodecs = append(checkCodecs,
-			transmuxedAudio.Representation.Codecs,
-			transcodedAudio.Representation.Codecs,
-			lowQualityAudio.Representation.Codecs)
+		transmuxedAudio := ffmpeg.GetTransmuxedRepresentation(s)
+		checkCodecs = append(checkCodecs, transmuxedAudio.Representation.Codecs)
+
+		// Surround audio is only kept for clients that report being able to play AC-3 or E-AC-3
+		for _, codec := range ffmpeg.OfferedAudioCodecs(s) {
+			transcodedAudio := ffmpeg.GetSimilarTranscodedAudioRepresentation(s, profile, codec)
+			checkCodecs = append(checkCodecs, transcodedAudio.Representation.Codecs)
+		}
+
+		for _, preset := range profile.AudioPresets() {
+			lowQualityAudio, _ := ffmpeg.StreamRepresentationFromRepresentationId(s, preset)
+			checkCodecs = append(checkCodecs, lowQualityAudio.Representation.Codecs)
+		}
+	}
+
+	chapters, err := buildChapterMetadata(r, fileLocator)
+	if err != nil {
+		http.Error(w, "Failed to get chapters: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	subtitles, err := buildSubtitleMetadata(r, fileLocator, streams.SubtitleStreams)
+	if err != nil {
+		http.Error(w, "Failed to list subtitles: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(metadataResponse{CheckCodecs: checkCodecs})
+	json.NewEncoder(w).Encode(metadataResponse{CheckCodecs: checkCodecs, Chapters: chapters, Subtitles: subtitles})
+}
+
+func buildSubtitleMetadata(
+	r *http.Request,
+	fileLocator filesystem.FileLocator,
+	streams []ffmpeg.Stream) ([]subtitleMetadata, error) {
+
+	metadata := []subtitleMetadata{}
+	for _, s := range streams {
+		subtitle := subtitleMetadata{
+			StreamID:        s.StreamId,
+			Codec:           s.CodecName,
+			Language:        s.Language,
+			Title:           s.Title,
+			Forced:          s.Forced,
+			HearingImpaired: s.HearingImpaired,
+			External:        s.FileLocator != fileLocator,
+			ImageBased:      s.IsImageSubtitle(),
+		}
+		if s.IsStyledSubtitle() {
+			// External subtitles need a URL of their own file
+			fileLocatorPath, err := fileLocatorURLPath(r, s.FileLocator)
+			if err != nil {
+				return nil, err
+			}
+			subtitle.ASSPath = fmt.Sprintf("/olaris/s/files/%s/%d/subtitles.ass", fileLocatorPath, s.StreamId)
+		}
+		metadata = append(metadata, subtitle)
+	}
+	return metadata, nil
+}
+
+func buildChapterMetadata(r *http.Request, fileLocator filesystem.FileLocator) ([]chapterMetadata, error) {
+	chapters, err := ffmpeg.GetChapters(fileLocator)
+	if err != nil {
+		return nil, err
+	}
+
+	// Thumbnails are served per playback session, so they need the session of the ticket.
+	var thumbnailDir string
+	if claims, err := getStreamingClaims(r); err == nil {
+		if m, err := trickplay.Load(fileLocator.String()); err == nil && m.Chapters == len(chapters) {
+			thumbnailDir = path.Join(path.Dir(r.URL.Path), fmt.Sprintf("session:%s/trickplay", claims.SessionID))
+		}
+	}
+
+	metadata := []chapterMetadata{}
+	for i, c := range chapters {
+		chapter := chapterMetadata{Start: c.Start.Seconds(), End: c.End.Seconds(), Title: c.Title}
+		if thumbnailDir != "" {
+			chapter.ThumbnailPath = path.Join(thumbnailDir, trickplay.ChapterFile(i))
+		}
+		metadata = append(metadata, chapter)
+	}
+	return metadata, nil
 }
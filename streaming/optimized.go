@@ -0,0 +1,103 @@
+package streaming
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+)
+
+// playableWithoutTranscoding returns whether the client can play the video and all audio streams
+// as they are.
+func playableWithoutTranscoding(
+	streams *ffmpeg.Streams,
+	capabilities ffmpeg.ClientCodecCapabilities,
+	profile ffmpeg.TranscodingProfile) bool {
+
+	if len(streams.VideoStreams) == 0 {
+		return false
+	}
+	for _, s := range append([]ffmpeg.Stream{streams.GetVideoStream()}, streams.AudioStreams...) {
+		r, err := ffmpeg.GetTransmuxedOrTranscodedRepresentation(s, capabilities, profile)
+		if err != nil || !r.Representation.Transmuxed {
+			return false
+		}
+	}
+	return true
+}
+
+// findOptimizedVersion returns the first optimized version of the file of the streaming ticket
+// that the client can play without transcoding. Optimized versions are only used if the video of
+// the file itself would have to be transcoded, the original is better quality otherwise.
+func findOptimizedVersion(
+	r *http.Request,
+	streams *ffmpeg.Streams,
+	capabilities ffmpeg.ClientCodecCapabilities,
+	profile ffmpeg.TranscodingProfile) (filesystem.FileLocator, bool) {
+
+	claims, err := getStreamingClaims(r)
+	if err != nil || len(claims.OptimizedVersions) == 0 {
+		return filesystem.FileLocator{}, false
+	}
+	video, err := ffmpeg.GetTransmuxedOrTranscodedRepresentation(streams.GetVideoStream(), capabilities, profile)
+	if err != nil || video.Representation.Transmuxed {
+		return filesystem.FileLocator{}, false
+	}
+
+	for _, filePath := range claims.OptimizedVersions {
+		fileLocator, err := filesystem.ParseFileLocator(filePath)
+		if err != nil {
+			continue
+		}
+		optimizedStreams, err := ffmpeg.GetStreams(fileLocator)
+		if err != nil {
+			log.WithError(err).WithField("filePath", filePath).Warnln("Failed to probe optimized version")
+			continue
+		}
+		if playableWithoutTranscoding(optimizedStreams, capabilities, profile) {
+			return fileLocator, true
+		}
+	}
+	return filesystem.FileLocator{}, false
+}
+
+// replaceFileLocator returns the URL of the request with the file locator replaced by the given
+// file locator URL path, keeping the rest of the path and the query.
+func replaceFileLocator(r *http.Request, fileLocatorPath string) string {
+	urlFileLocator := mux.Vars(r)["fileLocator"]
+	i := strings.Index(r.URL.Path, urlFileLocator)
+	u := url.URL{
+		Path:     r.URL.Path[:i] + fileLocatorPath + r.URL.Path[i+len(urlFileLocator):],
+		RawQuery: r.URL.RawQuery,
+	}
+	return u.String()
+}
+
+// redirectToOptimizedVersion redirects manifest requests to the same manifest of an optimized
+// version of the file if the client can play it without transcoding. It returns true if the
+// request was redirected.
+func redirectToOptimizedVersion(
+	w http.ResponseWriter,
+	r *http.Request,
+	streams *ffmpeg.Streams,
+	capabilities ffmpeg.ClientCodecCapabilities,
+	profile ffmpeg.TranscodingProfile) bool {
+
+	fileLocator, ok := findOptimizedVersion(r, streams, capabilities, profile)
+	if !ok {
+		return false
+	}
+	fileLocatorPath, err := fileLocatorURLPath(r, fileLocator)
+	if err != nil {
+		log.WithError(err).Warnln("Failed to create URL for optimized version")
+		return false
+	}
+
+	log.WithField("filePath", fileLocator.String()).Debugln("Serving optimized version")
+	http.Redirect(w, r, replaceFileLocator(r, fileLocatorPath), http.StatusFound)
+	return true
+}
@@ -0,0 +1,35 @@
+package streaming
+
+import (
+	"math/big"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+)
+
+func TestPlayableWithoutTranscoding(t *testing.T) {
+	profile := ffmpeg.GetTranscodingProfile(ffmpeg.DefaultTranscodingProfile)
+	streams := &ffmpeg.Streams{
+		VideoStreams: []ffmpeg.Stream{{StreamType: "video", Codecs: "avc1.640028", Width: 1920, Height: 1080,
+			BitRate: 8000000, FrameRate: big.NewRat(24, 1)}},
+		AudioStreams: []ffmpeg.Stream{{StreamType: "audio", Codecs: "mp4a.40.2", Channels: 2}},
+	}
+
+	assert.True(t, playableWithoutTranscoding(streams, ffmpeg.ClientCodecCapabilities{
+		PlayableCodecs: []string{"avc1.640028", "mp4a.40.2"}}, profile))
+	assert.False(t, playableWithoutTranscoding(streams, ffmpeg.ClientCodecCapabilities{
+		PlayableCodecs: []string{"avc1.640028"}}, profile), "audio would have to be transcoded")
+	assert.False(t, playableWithoutTranscoding(&ffmpeg.Streams{}, ffmpeg.ClientCodecCapabilities{}, profile))
+}
+
+func TestReplaceFileLocator(t *testing.T) {
+	r := httptest.NewRequest("GET",
+		"/olaris/s/files/jwt/original/session:abc/hls-manifest.m3u8?playableCodecs=avc1.640028", nil)
+	r = mux.SetURLVars(r, map[string]string{"fileLocator": "jwt/original", "sessionID": "session:abc"})
+
+	assert.Equal(t, "/olaris/s/files/jwt/optimized/session:abc/hls-manifest.m3u8?playableCodecs=avc1.640028",
+		replaceFileLocator(r, "jwt/optimized"))
+}
@@ -0,0 +1,304 @@
+package streaming
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+)
+
+// Weights of the different kinds of ffmpeg processes used if they aren't configured. A 1080p video
+// transcode weighs 1, other resolutions are weighted by their number of pixels.
+const (
+	defaultVideoTranscodeWeight    = 1.0
+	defaultAudioTranscodeWeight    = 0.1
+	defaultSubtitleTranscodeWeight = 0.05
+	defaultTransmuxWeight          = 0.0
+)
+
+const defaultTranscodeQueueTimeout = 30 * time.Second
+
+const (
+	// whenBusyQueue makes new transcodes wait until enough running ones have finished.
+	whenBusyQueue = "queue"
+	// whenBusyFallback starts video in a lower quality of the profile that fits within the
+	// limits instead of waiting, and queues if none does.
+	whenBusyFallback = "fallback"
+)
+
+// errTranscodeQueueTimeout is returned if a transcode waited longer than the queue timeout.
+var errTranscodeQueueTimeout = errors.New("timed out waiting for a free transcoding slot")
+
+// TranscodeJob is an ffmpeg process that is running or waiting for capacity.
+type TranscodeJob struct {
+	UserID           uint
+	Stream           ffmpeg.StreamKey
+	StreamType       string
+	RepresentationID string
+	Weight           float64
+	// Queued is true while the job waits for capacity.
+	Queued bool
+	// Since is when the job was queued or started.
+	Since time.Time
+
+	session *ffmpeg.TranscodingSession
+}
+
+// active returns whether the job takes up capacity. Jobs whose ffmpeg process has exited
+// don't, even if their playback session is still around to serve segments.
+func (j *TranscodeJob) active() bool {
+	return j.session == nil || j.session.State != ffmpeg.SessionStateExited
+}
+
+// transcodeLimits are the "transcoding.limits" settings.
+type transcodeLimits struct {
+	// maxWeight and maxWeightPerUser are unlimited if zero.
+	maxWeight        float64
+	maxWeightPerUser float64
+
+	videoWeight    float64
+	audioWeight    float64
+	subtitleWeight float64
+	transmuxWeight float64
+
+	whenBusy     string
+	queueTimeout time.Duration
+}
+
+func getTranscodeLimits() transcodeLimits {
+	l := transcodeLimits{
+		maxWeight:        viper.GetFloat64("transcoding.limits.maxWeight"),
+		maxWeightPerUser: viper.GetFloat64("transcoding.limits.maxWeightPerUser"),
+		videoWeight:      defaultVideoTranscodeWeight,
+		audioWeight:      defaultAudioTranscodeWeight,
+		subtitleWeight:   defaultSubtitleTranscodeWeight,
+		transmuxWeight:   defaultTransmuxWeight,
+		whenBusy:         viper.GetString("transcoding.limits.whenBusy"),
+		queueTimeout:     defaultTranscodeQueueTimeout,
+	}
+	if viper.IsSet("transcoding.limits.videoWeight") {
+		l.videoWeight = viper.GetFloat64("transcoding.limits.videoWeight")
+	}
+	if viper.IsSet("transcoding.limits.audioWeight") {
+		l.audioWeight = viper.GetFloat64("transcoding.limits.audioWeight")
+	}
+	if viper.IsSet("transcoding.limits.subtitleWeight") {
+		l.subtitleWeight = viper.GetFloat64("transcoding.limits.subtitleWeight")
+	}
+	if viper.IsSet("transcoding.limits.transmuxWeight") {
+		l.transmuxWeight = viper.GetFloat64("transcoding.limits.transmuxWeight")
+	}
+	if seconds := viper.GetInt("transcoding.limits.queueTimeout"); seconds > 0 {
+		l.queueTimeout = time.Duration(seconds) * time.Second
+	}
+	if l.whenBusy != whenBusyFallback {
+		l.whenBusy = whenBusyQueue
+	}
+	return l
+}
+
+// weight returns how much of the capacity transcoding the representation takes up.
+func (l transcodeLimits) weight(sr ffmpeg.StreamRepresentation) float64 {
+	switch {
+	case sr.Stream.StreamType == "subtitle":
+		return l.subtitleWeight
+	case !sr.Representation.Transcoded:
+		return l.transmuxWeight
+	case sr.Stream.StreamType == "audio":
+		return l.audioWeight
+	}
+
+	// Transcoded representation IDs don't carry the resolution, it's the one of the source
+	height := sr.Representation.Height
+	if height <= 0 {
+		height = sr.Stream.Height
+	}
+	if height <= 0 {
+		return l.videoWeight
+	}
+	return l.videoWeight * float64(height*height) / (1080 * 1080)
+}
+
+// transcodeScheduler keeps the ffmpeg processes within the configured limits. Jobs that don't fit
+// wait in a queue and are started in order once enough capacity is released.
+type transcodeScheduler struct {
+	mtx     sync.Mutex
+	running []*TranscodeJob
+	queue   []*TranscodeJob
+	// released is closed and replaced whenever capacity is released to wake up waiting jobs.
+	released chan struct{}
+}
+
+func newTranscodeScheduler() *transcodeScheduler {
+	return &transcodeScheduler{released: make(chan struct{})}
+}
+
+// acquire reserves capacity for transcoding the representation, waiting in the queue until it
+// fits within the limits. With whenBusyFallback, the first of the fallbacks that fits is used
+// instead of waiting. The returned job must be released once its ffmpeg process is gone.
+func (s *transcodeScheduler) acquire(
+	userID uint,
+	sr ffmpeg.StreamRepresentation,
+	fallbacks []ffmpeg.StreamRepresentation) (ffmpeg.StreamRepresentation, *TranscodeJob, error) {
+
+	l := getTranscodeLimits()
+	job := newTranscodeJob(userID, sr, l)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.queue = append(s.queue, job)
+	timeout := time.After(l.queueTimeout)
+	for {
+		if s.nextJob(l) == job {
+			s.start(job)
+			// Jobs queued behind this one may fit as well
+			s.wake()
+			return sr, job, nil
+		}
+
+		if l.whenBusy == whenBusyFallback {
+			for _, fallback := range fallbacks {
+				fallbackJob := newTranscodeJob(userID, fallback, l)
+				if s.fits(l, fallbackJob) {
+					log.WithFields(log.Fields{
+						"requested": sr.Representation.RepresentationId,
+						"fallback":  fallback.Representation.RepresentationId,
+					}).Infoln("Transcoding limit reached, falling back to lower quality")
+					s.dequeue(job)
+					s.start(fallbackJob)
+					return fallback, fallbackJob, nil
+				}
+			}
+		}
+
+		released := s.released
+		s.mtx.Unlock()
+		select {
+		case <-released:
+		// ffmpeg processes exit on their own when they reach the end, so check regularly
+		case <-time.After(time.Second):
+		case <-timeout:
+			s.mtx.Lock()
+			s.dequeue(job)
+			s.wake()
+			return sr, nil, errTranscodeQueueTimeout
+		}
+		s.mtx.Lock()
+	}
+}
+
+// started records the ffmpeg process of the job so that it stops counting once it has exited.
+func (s *transcodeScheduler) started(job *TranscodeJob, session *ffmpeg.TranscodingSession) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	job.session = session
+}
+
+// release frees the capacity of the job. Releasing a job more than once is harmless.
+func (s *transcodeScheduler) release(job *TranscodeJob) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for i, j := range s.running {
+		if j == job {
+			s.running = append(s.running[:i], s.running[i+1:]...)
+			s.wake()
+			return
+		}
+	}
+}
+
+// jobs returns copies of the running jobs followed by the queued ones in order.
+func (s *transcodeScheduler) jobs() []TranscodeJob {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	jobs := []TranscodeJob{}
+	for _, j := range s.running {
+		if j.active() {
+			jobs = append(jobs, *j)
+		}
+	}
+	for _, j := range s.queue {
+		jobs = append(jobs, *j)
+	}
+	return jobs
+}
+
+func newTranscodeJob(userID uint, sr ffmpeg.StreamRepresentation, l transcodeLimits) *TranscodeJob {
+	return &TranscodeJob{
+		UserID:           userID,
+		Stream:           sr.Stream.StreamKey,
+		StreamType:       sr.Stream.StreamType,
+		RepresentationID: sr.Representation.RepresentationId,
+		Weight:           l.weight(sr),
+		Queued:           true,
+		Since:            time.Now(),
+	}
+}
+
+// nextJob returns the first queued job that fits within the limits, if any. s.mtx must be held.
+func (s *transcodeScheduler) nextJob(l transcodeLimits) *TranscodeJob {
+	for _, j := range s.queue {
+		if s.fits(l, j) {
+			return j
+		}
+	}
+	return nil
+}
+
+// fits returns whether the job can be started without exceeding the limits. A job that exceeds a
+// limit on its own, e.g. a 4K transcode, can still be started if nothing else is running.
+// s.mtx must be held.
+func (s *transcodeScheduler) fits(l transcodeLimits, job *TranscodeJob) bool {
+	if job.Weight <= 0 {
+		return true
+	}
+
+	var total, user float64
+	for _, j := range s.running {
+		if !j.active() {
+			continue
+		}
+		total += j.Weight
+		if j.UserID == job.UserID {
+			user += j.Weight
+		}
+	}
+
+	if l.maxWeight > 0 && total > 0 && total+job.Weight > l.maxWeight {
+		return false
+	}
+	if l.maxWeightPerUser > 0 && user > 0 && user+job.Weight > l.maxWeightPerUser {
+		return false
+	}
+	return true
+}
+
+// start moves the job to the running ones. s.mtx must be held.
+func (s *transcodeScheduler) start(job *TranscodeJob) {
+	s.dequeue(job)
+	job.Queued = false
+	job.Since = time.Now()
+	s.running = append(s.running, job)
+}
+
+// dequeue removes the job from the queue if it is queued. s.mtx must be held.
+func (s *transcodeScheduler) dequeue(job *TranscodeJob) {
+	for i, j := range s.queue {
+		if j == job {
+			s.queue = append(s.queue[:i], s.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// wake wakes up all waiting jobs so they check whether they fit now. s.mtx must be held.
+func (s *transcodeScheduler) wake() {
+	close(s.released)
+	s.released = make(chan struct{})
+}
@@ -0,0 +1,104 @@
+package streaming
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+)
+
+func setTestTranscodeLimits(t *testing.T, limits map[string]interface{}) {
+	for key, value := range limits {
+		viper.Set("transcoding.limits."+key, value)
+	}
+	t.Cleanup(func() {
+		for key := range limits {
+			viper.Set("transcoding.limits."+key, nil)
+		}
+	})
+}
+
+func testVideo(height int) ffmpeg.StreamRepresentation {
+	stream := ffmpeg.Stream{StreamType: "video", Codecs: "avc1.640028", Width: height * 16 / 9, Height: height,
+		BitRate: 8000000, FrameRate: big.NewRat(24, 1)}
+	return ffmpeg.GetSimilarTranscodedVideoRepresentation(
+		stream, ffmpeg.GetTranscodingProfile(ffmpeg.DefaultTranscodingProfile), ffmpeg.VideoCodecH264)
+}
+
+func TestTranscodeWeight(t *testing.T) {
+	l := getTranscodeLimits()
+	assert.Equal(t, 1.0, l.weight(testVideo(1080)))
+	assert.Equal(t, 4.0, l.weight(testVideo(2160)))
+	assert.Equal(t, 0.0, l.weight(ffmpeg.GetTransmuxedRepresentation(testVideo(1080).Stream)))
+	assert.Equal(t, defaultSubtitleTranscodeWeight,
+		l.weight(ffmpeg.GetSubtitleStreamRepresentation(ffmpeg.Stream{StreamType: "subtitle"})))
+}
+
+func TestTranscodeSchedulerQueue(t *testing.T) {
+	setTestTranscodeLimits(t, map[string]interface{}{"maxWeight": 2, "maxWeightPerUser": 1})
+	s := newTranscodeScheduler()
+
+	_, first, err := s.acquire(1, testVideo(1080), nil)
+	require.NoError(t, err)
+	_, _, err = s.acquire(2, testVideo(1080), nil)
+	require.NoError(t, err)
+
+	// A 4K transcode exceeds the limit on its own but may run once nothing else does
+	started := make(chan *TranscodeJob)
+	go func() {
+		_, job, err := s.acquire(3, testVideo(2160), nil)
+		assert.NoError(t, err)
+		started <- job
+	}()
+	require.Eventually(t, func() bool { return len(s.jobs()) == 3 }, time.Second, 10*time.Millisecond)
+	assert.True(t, s.jobs()[2].Queued)
+
+	s.release(first)
+	select {
+	case <-started:
+		t.Fatal("queued job started while the limit is reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+	for _, j := range s.running {
+		s.release(j)
+	}
+	select {
+	case job := <-started:
+		assert.False(t, job.Queued)
+	case <-time.After(time.Second):
+		t.Fatal("queued job didn't start after capacity was released")
+	}
+}
+
+func TestTranscodeSchedulerPerUserLimit(t *testing.T) {
+	setTestTranscodeLimits(t, map[string]interface{}{"maxWeightPerUser": 1, "queueTimeout": 1})
+	s := newTranscodeScheduler()
+
+	_, _, err := s.acquire(1, testVideo(1080), nil)
+	require.NoError(t, err)
+	_, _, err = s.acquire(2, testVideo(1080), nil)
+	require.NoError(t, err)
+	_, _, err = s.acquire(1, testVideo(720), nil)
+	assert.Equal(t, errTranscodeQueueTimeout, err)
+	assert.Len(t, s.jobs(), 2)
+}
+
+func TestTranscodeSchedulerFallback(t *testing.T) {
+	setTestTranscodeLimits(t, map[string]interface{}{"maxWeight": 1.3, "whenBusy": whenBusyFallback})
+	s := newTranscodeScheduler()
+
+	_, _, err := s.acquire(1, testVideo(1080), nil)
+	require.NoError(t, err)
+
+	requested := testVideo(1080)
+	fallbacks := ffmpeg.LowerQualityRepresentations(requested)
+	require.Len(t, fallbacks, 2)
+	r, job, err := s.acquire(2, requested, fallbacks)
+	require.NoError(t, err)
+	assert.Equal(t, "preset:default:480-1000k-video", r.Representation.RepresentationId)
+	assert.Equal(t, r.Representation.RepresentationId, job.RepresentationID)
+}
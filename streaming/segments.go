@@ -31,7 +31,7 @@ func serveInit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := getStreamingClaims(mux.Vars(r)["fileLocator"])
+	claims, err := getStreamingClaims(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -42,8 +42,13 @@ func serveInit(w http.ResponseWriter, r *http.Request) {
 			StreamKey:        streamKey,
 			sessionID:        sessionID,
 			representationID: representationId,
-			userID:           claims.UserID},
+			userID:           claims.UserID,
+			deinterlace:      claims.Deinterlace},
 		InitSegmentIdx)
+	if err != nil {
+		servePlaybackSessionError(w, err)
+		return
+	}
 	defer playbackSession.Release()
 
 	for {
@@ -92,7 +97,7 @@ func serveSegment(w http.ResponseWriter, r *http.Request, mimeType string) {
 		return
 	}
 
-	claims, err := getStreamingClaims(mux.Vars(r)["fileLocator"])
+	claims, err := getStreamingClaims(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -100,12 +105,17 @@ func serveSegment(w http.ResponseWriter, r *http.Request, mimeType string) {
 
 	playbackSession, err := PBSManager.GetPlaybackSession(
 		PlaybackSessionKey{
-			streamKey,
-			sessionID,
-			representationId,
-			claims.UserID,
+			StreamKey:        streamKey,
+			sessionID:        sessionID,
+			representationID: representationId,
+			userID:           claims.UserID,
+			deinterlace:      claims.Deinterlace,
 		},
 		segmentIdx)
+	if err != nil {
+		servePlaybackSessionError(w, err)
+		return
+	}
 	defer playbackSession.Release()
 
 	for {
@@ -159,3 +169,13 @@ func serveMediaSegment(w http.ResponseWriter, r *http.Request) {
 func serveSubtitleSegment(w http.ResponseWriter, r *http.Request) {
 	serveSegment(w, r, "text/vtt")
 }
+
+// servePlaybackSessionError responds to a request for which no playback session could be started.
+func servePlaybackSessionError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errTranscodeQueueTimeout) {
+		// The client may retry once the server is less busy
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
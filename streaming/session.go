@@ -24,6 +24,7 @@ func NewPlaybackSessionManager() (m *PlaybackSessionManager, cleanup func()) {
 		mtx:               sync.Mutex{},
 		canCreateSessions: true,
 		sessions:          make(map[PlaybackSessionKey]*PlaybackSession),
+		scheduler:         newTranscodeScheduler(),
 	}
 
 	return m, m.CleanupSessions
@@ -79,6 +80,9 @@ type PlaybackSessionManager struct {
 	canCreateSessions bool
 
 	sessions map[PlaybackSessionKey]*PlaybackSession
+
+	// Keeps the number of ffmpeg processes within the configured limits
+	scheduler *transcodeScheduler
 }
 
 type PlaybackSessionKey struct {
@@ -91,6 +95,9 @@ type PlaybackSessionKey struct {
 	representationID string
 
 	userID uint
+
+	// Mode used if interlaced video is transcoded, see ffmpeg.DeinterlacingModes
+	deinterlace string
 }
 
 type PlaybackSession struct {
@@ -113,21 +120,23 @@ type PlaybackSession struct {
 	referenceCount int
 
 	lastAccessed time.Time
+
+	// release frees the transcoding capacity taken up by the session.
+	release func()
 }
 
-func NewPlaybackSession(playbackSessionKey PlaybackSessionKey, segmentIdx int, m *PlaybackSessionManager) (*PlaybackSession, error) {
+// NewPlaybackSession starts transcoding the representation of the stream identified by the key.
+// The representation may be a lower quality one if the transcoding limits were reached.
+func NewPlaybackSession(
+	playbackSessionKey PlaybackSessionKey,
+	streamRepresentation ffmpeg.StreamRepresentation,
+	segmentIdx int,
+	m *PlaybackSessionManager) (*PlaybackSession, error) {
+
 	if m.canCreateSessions == false {
 		return nil, errors.New("cannot create new playback sessions for this manager")
 	}
 
-	stream, err := ffmpeg.GetStream(playbackSessionKey.StreamKey)
-	if err != nil {
-		return nil, err
-	}
-
-	streamRepresentation, err := ffmpeg.StreamRepresentationFromRepresentationId(
-		stream, playbackSessionKey.representationID)
-
 	transcodingSession, err := ffmpeg.NewTranscodingSession(streamRepresentation, segmentIdx)
 	if err != nil {
 		return nil, err
@@ -151,39 +160,71 @@ func NewPlaybackSession(playbackSessionKey PlaybackSessionKey, segmentIdx int, m
 // representationID). This is useful to get  a session to serve the init segment from because
 // it doesn't matter where ffmpeg seeked to, the init segment will
 // always be the same.
+// New sessions wait for transcoding capacity if the configured limits are reached, see
+// transcodeScheduler.
 // The returned PlaybackSession must be released after use by calling ReleasePlaybackSession.
 func (m *PlaybackSessionManager) GetPlaybackSession(
 	playbackSessionKey PlaybackSessionKey,
 	segmentIdx int) (*PlaybackSession, error) {
 
 	m.mtx.Lock()
-	defer m.mtx.Unlock()
+	if s := m.reusablePlaybackSession(playbackSessionKey, segmentIdx); s != nil {
+		m.mtx.Unlock()
+		return s, nil
+	}
+	// We are seeking, so the new session has to continue in the representation of the existing
+	// one, which may be a lower quality than requested. Stop the existing session first so that
+	// its capacity can be used for the new one.
+	existing := m.sessions[playbackSessionKey]
+	if existing != nil {
+		delete(m.sessions, playbackSessionKey)
+		existing.referenceCount--
+		existing.CleanupIfRequired()
+	}
+	m.mtx.Unlock()
 
-	s := m.sessions[playbackSessionKey]
+	var streamRepresentation ffmpeg.StreamRepresentation
+	var fallbacks []ffmpeg.StreamRepresentation
+	if existing != nil {
+		streamRepresentation = existing.TranscodingSession.Stream
+	} else {
+		stream, err := ffmpeg.GetStream(playbackSessionKey.StreamKey)
+		if err != nil {
+			return nil, err
+		}
+		streamRepresentation, err = ffmpeg.StreamRepresentationFromRepresentationId(
+			stream, playbackSessionKey.representationID)
+		if err != nil {
+			return nil, err
+		}
+		streamRepresentation = ffmpeg.WithDeinterlacing(streamRepresentation, playbackSessionKey.deinterlace)
 
-	// If requesting the init segment, it doesn't matter where the existing session started,
-	// we can just deliver it directly. Init segments are always the same, regardless of the
-	// seek location passed to ffmpeg.
-	if segmentIdx == InitSegmentIdx && s != nil {
-		s.referenceCount++
-		return s, nil
+		// Falling back to a lower quality is only possible when playback starts, segments have
+		// to match the init segment the client already has.
+		if segmentIdx == InitSegmentIdx {
+			fallbacks = ffmpeg.LowerQualityRepresentations(streamRepresentation)
+		}
 	}
 
-	// If the request is for the next couple of segments, i.e. not seeking
-	// Note: This is a really crude heuristic. VideoJS will skip requesting a
-	// segment if the previous segment already covers the whole duration of that
-	// segment. E.g. if the playlist has 5s segment lengths but a segment is 15s
-	// long, the next two won't be requested. This heuristic allows "skipping"
-	// at most 4 segments.
-	// TODO(Leon Handreke): Maybe do something more intelligent here by
-	// analyzing the duration of the previous delivered segment?
-	if s != nil && segmentIdx >= s.TranscodingSession.SegmentStartIndex && segmentIdx < s.lastServedSegmentIdx+5 {
-		s.referenceCount++
+	// Waiting for capacity may take a while, so don't hold the lock
+	streamRepresentation, job, err := m.scheduler.acquire(
+		playbackSessionKey.userID, streamRepresentation, fallbacks)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	// Another request may have started the session in the meantime
+	if s := m.reusablePlaybackSession(playbackSessionKey, segmentIdx); s != nil {
+		m.scheduler.release(job)
 		return s, nil
 	}
 
-	// We are either seeking or no session exists yet. Destroy any existing session and
-	// start a new one
+	// A concurrent request started a session at a different position. Destroy it and start a
+	// new one
+	s := m.sessions[playbackSessionKey]
 	if s != nil {
 		s.referenceCount--
 		s.CleanupIfRequired()
@@ -197,10 +238,13 @@ func (m *PlaybackSessionManager) GetPlaybackSession(
 		startAtSegmentIdx = segmentIdx
 	}
 
-	s, err := NewPlaybackSession(playbackSessionKey, startAtSegmentIdx, m)
+	s, err = NewPlaybackSession(playbackSessionKey, streamRepresentation, startAtSegmentIdx, m)
 	if err != nil {
+		m.scheduler.release(job)
 		return nil, err
 	}
+	m.scheduler.started(job, s.TranscodingSession)
+	s.release = func() { m.scheduler.release(job) }
 
 	m.sessions[playbackSessionKey] = s
 
@@ -209,6 +253,38 @@ func (m *PlaybackSessionManager) GetPlaybackSession(
 	return s, nil
 }
 
+// reusablePlaybackSession returns the existing session for the key if it can serve the segment,
+// with its reference count incremented. m.mtx must be held.
+func (m *PlaybackSessionManager) reusablePlaybackSession(
+	playbackSessionKey PlaybackSessionKey,
+	segmentIdx int) *PlaybackSession {
+
+	s := m.sessions[playbackSessionKey]
+
+	// If requesting the init segment, it doesn't matter where the existing session started,
+	// we can just deliver it directly. Init segments are always the same, regardless of the
+	// seek location passed to ffmpeg.
+	if segmentIdx == InitSegmentIdx && s != nil {
+		s.referenceCount++
+		return s
+	}
+
+	// If the request is for the next couple of segments, i.e. not seeking
+	// Note: This is a really crude heuristic. VideoJS will skip requesting a
+	// segment if the previous segment already covers the whole duration of that
+	// segment. E.g. if the playlist has 5s segment lengths but a segment is 15s
+	// long, the next two won't be requested. This heuristic allows "skipping"
+	// at most 4 segments.
+	// TODO(Leon Handreke): Maybe do something more intelligent here by
+	// analyzing the duration of the previous delivered segment?
+	if s != nil && segmentIdx >= s.TranscodingSession.SegmentStartIndex && segmentIdx < s.lastServedSegmentIdx+5 {
+		s.referenceCount++
+		return s
+	}
+
+	return nil
+}
+
 func (m *PlaybackSessionManager) garbageCollectPlaybackSessions() {
 	// Clean up streams after a user has switched representations, or after they have started a
 	// new playback session for the same stream (e.g. by reloading the page)
@@ -266,6 +342,9 @@ func (s *PlaybackSession) CleanupIfRequired() {
 		if err != nil {
 			log.WithField("error", err).Warnln("received an error while cleaning up transcoding folder")
 		}
+		if s.release != nil {
+			s.release()
+		}
 	}()
 }
 
@@ -335,6 +414,11 @@ func (m *PlaybackSessionManager) CleanupSessions() {
 	log.Println("Cleaned up all streaming context")
 }
 
+// TranscodeJobs returns the running ffmpeg processes followed by the ones waiting for capacity.
+func (m *PlaybackSessionManager) TranscodeJobs() []TranscodeJob {
+	return m.scheduler.jobs()
+}
+
 func (m *PlaybackSessionManager) GetPlaybackSessions() map[PlaybackSessionKey]*PlaybackSession {
 	m.mtx.Lock()
 
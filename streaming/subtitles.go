@@ -0,0 +1,45 @@
+package streaming
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+)
+
+// serveASSSubtitle serves an ASS/SSA subtitle stream as is, so that clients that can render it
+// keep the styling that is lost in the WebVTT conversion.
+func serveASSSubtitle(w http.ResponseWriter, r *http.Request) {
+	fileLocator, statusErr := getFileLocatorOrFail(r)
+	if statusErr != nil {
+		http.Error(w, statusErr.Error(), statusErr.Status())
+		return
+	}
+
+	streamKey, err := getStreamKey(fileLocator, mux.Vars(r)["streamId"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	stream, err := ffmpeg.GetStream(streamKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if !stream.IsStyledSubtitle() {
+		http.Error(w, "Stream is not an ASS/SSA subtitle", http.StatusNotFound)
+		return
+	}
+
+	// Subtitles are small, buffer them so that we can still report errors
+	b := bytes.Buffer{}
+	if err := ffmpeg.ExtractASSSubtitle(r.Context(), stream, &b); err != nil {
+		log.WithError(err).WithField("fileLocator", fileLocator.String()).Warnln("Failed to extract ASS subtitle")
+		http.Error(w, "Failed to extract subtitle", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/x-ssa; charset=utf-8")
+	w.Write(b.Bytes())
+}
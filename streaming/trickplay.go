@@ -0,0 +1,72 @@
+package streaming
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/gorilla/mux"
+	"gitlab.com/olaris/olaris-server/hls"
+	"gitlab.com/olaris/olaris-server/trickplay"
+)
+
+// trickplayImagePlaylist is served next to the sprite sheets so their URIs can be relative.
+const trickplayImagePlaylist = "images.m3u8"
+
+// buildImagePlaylistItems returns the trickplay image playlist for the HLS master playlist, if
+// trickplay images have been generated for the file.
+func buildImagePlaylistItems(fileLocator string) []hls.ImagePlaylistItem {
+	m, err := trickplay.Load(fileLocator)
+	if err != nil {
+		return nil
+	}
+	return []hls.ImagePlaylistItem{{
+		Bandwidth: m.Bandwidth,
+		Width:     m.Width,
+		Height:    m.Height,
+		URI:       path.Join("trickplay", trickplayImagePlaylist),
+	}}
+}
+
+func serveTrickplay(w http.ResponseWriter, r *http.Request) {
+	fileLocator, statusErr := getFileLocatorOrFail(r)
+	if statusErr != nil {
+		http.Error(w, statusErr.Error(), statusErr.Status())
+		return
+	}
+
+	m, err := trickplay.Load(fileLocator.String())
+	if err != nil {
+		http.Error(w, "No trickplay images available for this file", http.StatusNotFound)
+		return
+	}
+
+	fileName := mux.Vars(r)["file"]
+	if fileName == trickplayImagePlaylist {
+		p := hls.ImagePlaylist{
+			Width:        m.Width,
+			Height:       m.Height,
+			Columns:      m.Columns,
+			Rows:         m.Rows,
+			TileDuration: float64(m.Interval),
+		}
+		for i := 0; i < m.Sprites(); i++ {
+			p.Segments = append(p.Segments, hls.ImageSegment{
+				Duration: float64(m.SpriteThumbnails(i) * m.Interval),
+				URI:      trickplay.SpriteFile(i),
+			})
+		}
+		w.Header().Set("Content-Type", "application/x-mpegURL")
+		w.Write([]byte(hls.BuildImageMediaPlaylist(p)))
+		return
+	}
+
+	switch path.Ext(fileName) {
+	case ".vtt":
+		w.Header().Set("Content-Type", "text/vtt")
+	case ".bif":
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	// The images only change when they are regenerated, which is rare.
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	http.ServeFile(w, r, path.Join(trickplay.Dir(fileLocator.String()), fileName))
+}
@@ -1,7 +1,9 @@
 package streaming
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,68 +16,174 @@ import (
 	"gitlab.com/olaris/olaris-server/metadata/auth"
 )
 
-// getNode parses the file that the client is trying to access from a string.
-// The passed string may either be in the form of "jwt/<streaming JWT>
-// or simply directly an absolute path.
-//
-// This function also checks whether the user is allowed to access this file, i.e. whether
-// the passed JWT is valid or whether accessing paths directly is allowed (controlled by a flag)
-func getFileLocator(urlFileLocatorStr string) (filesystem.FileLocator, error) {
-	return _getFileLocator(urlFileLocatorStr, false)
+type contextKey string
 
-}
+const contextKeyStreamingClaims = contextKey("streaming claims")
 
-func getStreamingClaims(urlFileLocator string) (*auth.StreamingClaims, error) {
+// jwtFromFileLocator returns the streaming JWT if the file locator from the URL is in the form
+// of "jwt/<streaming JWT>".
+func jwtFromFileLocator(urlFileLocator string) (string, bool) {
 	// Allow both with and without leading slash, but canonical version is without
-	if urlFileLocator[0] == '/' {
-		urlFileLocator = urlFileLocator[1:]
+	parts := strings.SplitN(strings.TrimPrefix(urlFileLocator, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] != "jwt" {
+		return "", false
 	}
+	return parts[1], true
+}
 
-	parts := strings.SplitN(urlFileLocator, "/", 2)
-
-	if len(parts) != 2 {
-		return nil,
-			fmt.Errorf("Failed to split urlFileLocator \"%s\"", urlFileLocator)
-	}
+// validateStreamingTicket checks the streaming JWT of requests that carry one and makes its
+// claims available to the handlers. Tickets are only valid for the playback session they were
+// created for.
+func validateStreamingTicket(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		token, ok := jwtFromFileLocator(vars["fileLocator"])
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
 
-	if parts[0] == "jwt" {
-		claims, err := auth.ValidateStreamingJWT(parts[1])
+		claims, err := auth.ValidateStreamingJWT(token, auth.ClientIP(r))
 		if err != nil {
-			return nil, fmt.Errorf("Failed to validate JWT: %s", err.Error())
+			http.Error(w, fmt.Sprintf("Failed to validate JWT: %s", err.Error()), http.StatusUnauthorized)
+			return
 		}
+		if sessionID, ok := vars["sessionID"]; ok && sessionID != "session:"+claims.SessionID {
+			http.Error(w, "Streaming ticket is not valid for this playback session", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyStreamingClaims, claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getStreamingClaims(r *http.Request) (*auth.StreamingClaims, error) {
+	if claims, ok := r.Context().Value(contextKeyStreamingClaims).(*auth.StreamingClaims); ok {
 		return claims, nil
 	}
 	return nil, fmt.Errorf("No JWT in file locator")
 }
 
-func _getFileLocator(urlFileLocator string, allowDirectFileAccess bool) (filesystem.FileLocator, error) {
-	// Allow both with and without leading slash, but canonical version is without
-	if urlFileLocator[0] == '/' {
-		urlFileLocator = urlFileLocator[1:]
+// getTranscodingProfile returns the transcoding profile selected for the streaming ticket of the
+// request, or the default profile for requests without a ticket.
+func getTranscodingProfile(r *http.Request) ffmpeg.TranscodingProfile {
+	name := ""
+	if claims, err := getStreamingClaims(r); err == nil {
+		name = claims.TranscodingProfile
+	}
+	return ffmpeg.GetTranscodingProfile(name)
+}
+
+// getDeinterlacing returns the deinterlacing mode selected for the streaming ticket of the
+// request, empty for the default one.
+func getDeinterlacing(r *http.Request) string {
+	if claims, err := getStreamingClaims(r); err == nil {
+		return claims.Deinterlace
+	}
+	return ""
+}
+
+// getClientCapabilities returns what the client can play as passed in the query parameters
+// playableCodecs, videoRanges and canDeinterlace.
+func getClientCapabilities(r *http.Request) ffmpeg.ClientCodecCapabilities {
+	capabilities := ffmpeg.ClientCodecCapabilities{
+		PlayableCodecs: r.URL.Query()["playableCodecs"],
+		VideoRanges:    r.URL.Query()["videoRanges"],
+		CanDeinterlace: r.URL.Query().Get("canDeinterlace") == "true",
+	}
+	// Interlaced video is served as is if the library doesn't want it deinterlaced
+	if getDeinterlacing(r) == ffmpeg.DeinterlaceOff {
+		capabilities.CanDeinterlace = true
 	}
+	return capabilities
+}
 
+// getBurnInSubtitle returns the image subtitle stream the client asked to have burned into the
+// video with the burnInSubtitle query parameter, nil if it didn't ask for one.
+func getBurnInSubtitle(r *http.Request, streams *ffmpeg.Streams) (*ffmpeg.Stream, error) {
+	streamIdStr := r.URL.Query().Get("burnInSubtitle")
+	if streamIdStr == "" {
+		return nil, nil
+	}
+	streamId, err := strconv.ParseInt(streamIdStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid burnInSubtitle \"%s\"", streamIdStr)
+	}
+	// External VobSub files can't be burned in, ffmpeg only overlays streams of the video file
+	video := streams.GetVideoStream()
+	for _, s := range streams.SubtitleStreams {
+		if s.StreamId == streamId && s.IsImageSubtitle() && s.FileLocator == video.FileLocator {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("no image subtitle stream %d to burn in", streamId)
+}
+
+// isInternalRequest returns true if the request was made by a process on this host, e.g. ffmpeg,
+// and not passed on by a reverse proxy.
+func isInternalRequest(r *http.Request) bool {
+	if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("Forwarded") != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// getFileLocator parses the file that the client is trying to access from the request.
+// The file locator in the URL may either be in the form of "jwt/<streaming JWT>",
+// "internal/<internal file token>" or simply directly an absolute path.
+//
+// This function also checks whether the user is allowed to access this file, i.e. whether
+// the passed JWT is valid or whether accessing paths directly is allowed (controlled by a flag)
+func getFileLocator(r *http.Request) (filesystem.FileLocator, error) {
+	urlFileLocator := strings.TrimPrefix(mux.Vars(r)["fileLocator"], "/")
 	parts := strings.SplitN(urlFileLocator, "/", 2)
-	if parts[0] == "jwt" {
-		claims, err := auth.ValidateStreamingJWT(parts[1])
+
+	switch parts[0] {
+	case "jwt":
+		claims, err := getStreamingClaims(r)
 		if err != nil {
+			return filesystem.FileLocator{}, err
+		}
+		return filesystem.ParseFileLocator(claims.FilePath)
+	case "internal":
+		if len(parts) != 2 || !isInternalRequest(r) {
 			return filesystem.FileLocator{},
-				fmt.Errorf("Failed to validate JWT: %s", err.Error())
+				errors.New("internal file access is only allowed from the local host")
 		}
-		// Set authenticated to
-		return _getFileLocator(claims.FilePath, true)
+		fileLocator, err := auth.ValidateInternalFileToken(parts[1])
+		if err != nil {
+			return filesystem.FileLocator{}, err
+		}
+		return filesystem.ParseFileLocator(fileLocator)
 	}
 
-	if !(allowDirectFileAccess || viper.GetBool("server.directFileAccess")) {
+	if !viper.GetBool("server.directFileAccess") {
 		return filesystem.FileLocator{},
 			errors.New("direct file access is not allowed")
 	}
 
-	fileLocator, err := filesystem.ParseFileLocator(urlFileLocator)
-	if err != nil {
-		return filesystem.FileLocator{}, err
+	return filesystem.ParseFileLocator(urlFileLocator)
+}
 
+// fileLocatorURLPath returns the file locator part of a URL for another file, e.g. an external
+// subtitle, that the client may access with the ticket of the current request.
+func fileLocatorURLPath(r *http.Request, fileLocator filesystem.FileLocator) (string, error) {
+	claims, err := getStreamingClaims(r)
+	if err != nil {
+		// Direct file access
+		return strings.TrimPrefix(fileLocator.String(), "/"), nil
 	}
-	return fileLocator, nil
+	token, err := auth.DeriveStreamingJWT(claims, fileLocator.String())
+	if err != nil {
+		return "", err
+	}
+	return "jwt/" + token, nil
 }
 
 func getStreamKey(fileLocator filesystem.FileLocator, streamIdStr string) (ffmpeg.StreamKey, error) {
@@ -92,7 +200,7 @@ func getStreamKey(fileLocator filesystem.FileLocator, streamIdStr string) (ffmpe
 
 func getFileLocatorOrFail(r *http.Request) (filesystem.FileLocator, Error) {
 	fileLocatorStr := mux.Vars(r)["fileLocator"]
-	fileLocator, err := getFileLocator(fileLocatorStr)
+	fileLocator, err := getFileLocator(r)
 	if err != nil {
 		return filesystem.FileLocator{}, StatusError{
 			Err: errors.Wrap(err,
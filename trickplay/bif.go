@@ -0,0 +1,48 @@
+package trickplay
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// bifMagic identifies a Roku BIF file.
+var bifMagic = []byte{0x89, 0x42, 0x49, 0x46, 0x0d, 0x0a, 0x1a, 0x0a}
+
+const bifHeaderSize = 64
+
+// WriteBIF writes the JPEG images as a BIF archive, one image per interval. See
+// https://developer.roku.com/docs/developer-program/media-playback/trick-mode/bif-file-creation.md
+func WriteBIF(w io.Writer, interval time.Duration, images [][]byte) error {
+	header := make([]byte, bifHeaderSize)
+	copy(header, bifMagic)
+	// Version 0
+	binary.LittleEndian.PutUint32(header[8:], 0)
+	binary.LittleEndian.PutUint32(header[12:], uint32(len(images)))
+	// Timestamps in the index are multiples of this, in milliseconds.
+	binary.LittleEndian.PutUint32(header[16:], uint32(interval.Milliseconds()))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	// The index has an entry for every image plus one marking the end of the last image.
+	index := make([]byte, 8*(len(images)+1))
+	offset := uint32(bifHeaderSize + len(index))
+	for i, img := range images {
+		binary.LittleEndian.PutUint32(index[8*i:], uint32(i))
+		binary.LittleEndian.PutUint32(index[8*i+4:], offset)
+		offset += uint32(len(img))
+	}
+	binary.LittleEndian.PutUint32(index[8*len(images):], 0xffffffff)
+	binary.LittleEndian.PutUint32(index[8*len(images)+4:], offset)
+	if _, err := w.Write(index); err != nil {
+		return err
+	}
+
+	for _, img := range images {
+		if _, err := w.Write(img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
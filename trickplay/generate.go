@@ -0,0 +1,211 @@
+package trickplay
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+	"gitlab.com/olaris/olaris-server/filesystem"
+)
+
+// spriteQuality is the JPEG quality of the sprite sheets. Thumbnails are small and only shown
+// briefly, so this keeps the sheets light.
+const spriteQuality = 75
+
+// generateMutex makes sure only one file is processed at a time, decoding a whole video is
+// expensive enough.
+var generateMutex sync.Mutex
+
+// Generate extracts the thumbnails of the given file and stores the sprite sheets, WebVTT track
+// and BIF file. Existing images for the file are replaced.
+func Generate(fileLocator filesystem.FileLocator) error {
+	generateMutex.Lock()
+	defer generateMutex.Unlock()
+
+	if err := os.MkdirAll(rootDir(), 0755); err != nil {
+		return err
+	}
+	// Build everything in a temporary directory so clients never see a half generated set.
+	tmpDir, err := ioutil.TempDir(rootDir(), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	thumbDir := path.Join(tmpDir, "thumbnails")
+	if err := os.Mkdir(thumbDir, 0755); err != nil {
+		return err
+	}
+	st := time.Now()
+	if err := ffmpeg.ExtractThumbnails(fileLocator, Interval*time.Second, ThumbnailWidth, thumbDir); err != nil {
+		return err
+	}
+	thumbnails, err := readThumbnails(thumbDir)
+	if err != nil {
+		return err
+	}
+	os.RemoveAll(thumbDir)
+
+	chapters, err := ffmpeg.GetChapters(fileLocator)
+	if err != nil {
+		return err
+	}
+	m, err := writeTrickplay(tmpDir, thumbnails, chapters)
+	if err != nil {
+		return err
+	}
+
+	dir := Dir(fileLocator.String())
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpDir, dir); err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"fileLocator": fileLocator.String(),
+		"thumbnails":  m.Thumbnails,
+		"duration":    time.Since(st).Seconds(),
+	}).Infoln("Generated trickplay images")
+	return nil
+}
+
+func readThumbnails(dir string) ([][]byte, error) {
+	files, err := filepath.Glob(path.Join(dir, "thumb-*.jpg"))
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no thumbnails were extracted")
+	}
+	// The names are zero-padded, so this is chronological order.
+	sort.Strings(files)
+
+	thumbnails := make([][]byte, 0, len(files))
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		thumbnails = append(thumbnails, data)
+	}
+	return thumbnails, nil
+}
+
+// writeTrickplay writes all trickplay files for the JPEG thumbnails to dir. The manifest is
+// written last so its presence means the set is complete.
+func writeTrickplay(dir string, thumbnails [][]byte, chapters []ffmpeg.Chapter) (*Manifest, error) {
+	first, err := jpeg.DecodeConfig(bytes.NewReader(thumbnails[0]))
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{
+		Interval:   Interval,
+		Width:      first.Width,
+		Height:     first.Height,
+		Columns:    Columns,
+		Rows:       Rows,
+		Thumbnails: len(thumbnails),
+		Chapters:   len(chapters),
+	}
+
+	perSprite := m.Columns * m.Rows
+	for i := 0; i < m.Sprites(); i++ {
+		end := (i + 1) * perSprite
+		if end > len(thumbnails) {
+			end = len(thumbnails)
+		}
+		sprite, err := composeSprite(thumbnails[i*perSprite:end], m)
+		if err != nil {
+			return nil, err
+		}
+		if err := ioutil.WriteFile(path.Join(dir, SpriteFile(i)), sprite, 0644); err != nil {
+			return nil, err
+		}
+		bandwidth := len(sprite) * 8 / (m.SpriteThumbnails(i) * m.Interval)
+		if bandwidth > m.Bandwidth {
+			m.Bandwidth = bandwidth
+		}
+	}
+
+	if err := ioutil.WriteFile(path.Join(dir, WebVTTFile), []byte(m.WebVTT()), 0644); err != nil {
+		return nil, err
+	}
+
+	for i, c := range chapters {
+		thumbnail := thumbnails[chapterThumbnail(c, m)]
+		if err := ioutil.WriteFile(path.Join(dir, ChapterFile(i)), thumbnail, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	bif, err := os.Create(path.Join(dir, BIFFile))
+	if err != nil {
+		return nil, err
+	}
+	err = WriteBIF(bif, time.Duration(m.Interval)*time.Second, thumbnails)
+	if closeErr := bif.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, ioutil.WriteFile(path.Join(dir, manifestFile), data, 0644)
+}
+
+// chapterThumbnail returns the index of the thumbnail to use for the chapter. The first one
+// after the chapter start is preferred, chapters often start with a fade from black.
+func chapterThumbnail(c ffmpeg.Chapter, m *Manifest) int {
+	interval := time.Duration(m.Interval) * time.Second
+	index := int(c.Start/interval) + 1
+	if time.Duration(index)*interval >= c.End {
+		index--
+	}
+	if index >= m.Thumbnails {
+		index = m.Thumbnails - 1
+	}
+	return index
+}
+
+// composeSprite tiles the thumbnails row by row into a single JPEG image. Sheets that aren't
+// full only get as many rows as they need.
+func composeSprite(thumbnails [][]byte, m *Manifest) ([]byte, error) {
+	rows := (len(thumbnails) + m.Columns - 1) / m.Columns
+	columns := m.Columns
+	if len(thumbnails) < columns {
+		columns = len(thumbnails)
+	}
+	sprite := image.NewRGBA(image.Rect(0, 0, columns*m.Width, rows*m.Height))
+
+	for i, data := range thumbnails {
+		thumb, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		x, y := (i%m.Columns)*m.Width, (i/m.Columns)*m.Height
+		draw.Draw(sprite, image.Rect(x, y, x+m.Width, y+m.Height), thumb, thumb.Bounds().Min, draw.Src)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, sprite, &jpeg.Options{Quality: spriteQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,112 @@
+// Package trickplay generates and stores the thumbnails clients show while seeking.
+//
+// For every media file a thumbnail is taken at a fixed interval. The thumbnails are tiled into
+// JPEG sprite sheets that are described by a WebVTT thumbnail track and an HLS image playlist.
+// Roku clients get the same thumbnails as BIF file instead. Every chapter also gets a thumbnail.
+package trickplay
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// Interval is the time between two thumbnails in seconds.
+	Interval = 10
+	// ThumbnailWidth is the width of a thumbnail in pixels, the height depends on the aspect ratio.
+	ThumbnailWidth = 320
+	// Columns and Rows define how many thumbnails are tiled into one sprite sheet.
+	Columns = 10
+	Rows    = 10
+)
+
+// Files that are stored for every media file.
+const (
+	manifestFile = "manifest.json"
+	// WebVTTFile is the thumbnail track referencing the sprite sheets.
+	WebVTTFile = "thumbnails.vtt"
+	// BIFFile holds the individual thumbnails for Roku clients.
+	BIFFile = "index.bif"
+)
+
+// Manifest describes the trickplay images generated for a file.
+type Manifest struct {
+	// Interval is the time between two thumbnails in seconds.
+	Interval   int `json:"interval"`
+	Width      int `json:"width"`
+	Height     int `json:"height"`
+	Columns    int `json:"columns"`
+	Rows       int `json:"rows"`
+	Thumbnails int `json:"thumbnails"`
+	// Chapters is the number of chapter thumbnails.
+	Chapters int `json:"chapters"`
+	// Bandwidth is the peak bitrate of the sprite sheets, for the HLS master playlist.
+	Bandwidth int `json:"bandwidth"`
+}
+
+// Sprites returns the number of sprite sheets.
+func (m *Manifest) Sprites() int {
+	perSprite := m.Columns * m.Rows
+	return (m.Thumbnails + perSprite - 1) / perSprite
+}
+
+// SpriteFile returns the file name of the sprite sheet with the given index.
+func SpriteFile(index int) string {
+	return fmt.Sprintf("sprite-%d.jpg", index)
+}
+
+// ChapterFile returns the file name of the thumbnail for the chapter with the given index.
+func ChapterFile(index int) string {
+	return fmt.Sprintf("chapter-%d.jpg", index)
+}
+
+// SpriteThumbnails returns how many thumbnails the sprite sheet with the given index holds.
+func (m *Manifest) SpriteThumbnails(index int) int {
+	perSprite := m.Columns * m.Rows
+	if remaining := m.Thumbnails - index*perSprite; remaining < perSprite {
+		return remaining
+	}
+	return perSprite
+}
+
+func rootDir() string {
+	return path.Join(viper.GetString("server.cacheDir"), "trickplay")
+}
+
+// Dir returns the directory the trickplay images of the given file are stored in.
+func Dir(fileLocator string) string {
+	sum := sha1.Sum([]byte(fileLocator))
+	return path.Join(rootDir(), hex.EncodeToString(sum[:]))
+}
+
+// Load returns the manifest of the trickplay images for the given file. It fails if no images
+// have been generated yet.
+func Load(fileLocator string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(path.Join(Dir(fileLocator), manifestFile))
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Available returns true if trickplay images have been generated for the given file.
+func Available(fileLocator string) bool {
+	_, err := os.Stat(path.Join(Dir(fileLocator), manifestFile))
+	return err == nil
+}
+
+// Remove deletes the trickplay images of the given file.
+func Remove(fileLocator string) error {
+	return os.RemoveAll(Dir(fileLocator))
+}
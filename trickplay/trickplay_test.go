@@ -0,0 +1,113 @@
+package trickplay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/olaris/olaris-server/ffmpeg"
+)
+
+func testThumbnail(t *testing.T, c color.Color) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 32, 18))
+	for y := 0; y < 18; y++ {
+		for x := 0; x < 32; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, jpeg.Encode(&buf, img, nil))
+	return buf.Bytes()
+}
+
+func TestManifest_WebVTT(t *testing.T) {
+	m := Manifest{Interval: 10, Width: 320, Height: 180, Columns: 2, Rows: 2, Thumbnails: 5}
+
+	assert.Equal(t, 2, m.Sprites())
+	assert.Equal(t, 4, m.SpriteThumbnails(0))
+	assert.Equal(t, 1, m.SpriteThumbnails(1))
+
+	vtt := m.WebVTT()
+	assert.True(t, strings.HasPrefix(vtt, "WEBVTT\n"))
+	assert.Contains(t, vtt, "00:00:00.000 --> 00:00:10.000\nsprite-0.jpg#xywh=0,0,320,180\n")
+	assert.Contains(t, vtt, "00:00:30.000 --> 00:00:40.000\nsprite-0.jpg#xywh=320,180,320,180\n")
+	assert.Contains(t, vtt, "00:00:40.000 --> 00:00:50.000\nsprite-1.jpg#xywh=0,0,320,180\n")
+}
+
+func TestWriteBIF(t *testing.T) {
+	images := [][]byte{[]byte("first"), []byte("second")}
+	var buf bytes.Buffer
+	require.NoError(t, WriteBIF(&buf, 10*time.Second, images))
+	bif := buf.Bytes()
+
+	assert.Equal(t, bifMagic, bif[:8])
+	assert.Equal(t, uint32(2), binary.LittleEndian.Uint32(bif[12:]))
+	assert.Equal(t, uint32(10000), binary.LittleEndian.Uint32(bif[16:]))
+
+	index := bif[bifHeaderSize:]
+	firstOffset := binary.LittleEndian.Uint32(index[4:])
+	secondOffset := binary.LittleEndian.Uint32(index[12:])
+	assert.Equal(t, uint32(1), binary.LittleEndian.Uint32(index[8:]))
+	assert.Equal(t, uint32(0xffffffff), binary.LittleEndian.Uint32(index[16:]))
+	assert.Equal(t, uint32(len(bif)), binary.LittleEndian.Uint32(index[20:]))
+	assert.Equal(t, "first", string(bif[firstOffset:secondOffset]))
+	assert.Equal(t, "second", string(bif[secondOffset:]))
+}
+
+func TestWriteTrickplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trickplay")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var thumbnails [][]byte
+	for i := 0; i < Columns*Rows+3; i++ {
+		thumbnails = append(thumbnails, testThumbnail(t, color.RGBA{R: uint8(i), A: 255}))
+	}
+	chapters := []ffmpeg.Chapter{
+		{Start: 0, End: 95 * time.Second},
+		{Start: 95 * time.Second, End: 100 * time.Second},
+		{Start: 2000 * time.Second, End: time.Hour},
+	}
+	m, err := writeTrickplay(dir, thumbnails, chapters)
+	require.NoError(t, err)
+	assert.Equal(t, 32, m.Width)
+	assert.Equal(t, 18, m.Height)
+	assert.Equal(t, 2, m.Sprites())
+	assert.True(t, m.Bandwidth > 0)
+
+	f, err := os.Open(path.Join(dir, SpriteFile(0)))
+	require.NoError(t, err)
+	full, err := jpeg.DecodeConfig(f)
+	f.Close()
+	require.NoError(t, err)
+	assert.Equal(t, Columns*32, full.Width)
+	assert.Equal(t, Rows*18, full.Height)
+
+	f, err = os.Open(path.Join(dir, SpriteFile(1)))
+	require.NoError(t, err)
+	last, err := jpeg.DecodeConfig(f)
+	f.Close()
+	require.NoError(t, err)
+	assert.Equal(t, 3*32, last.Width)
+	assert.Equal(t, 18, last.Height)
+
+	assert.Equal(t, 3, m.Chapters)
+	assert.Equal(t, 1, chapterThumbnail(chapters[0], m))
+	assert.Equal(t, 9, chapterThumbnail(chapters[1], m))
+	assert.Equal(t, Columns*Rows+2, chapterThumbnail(chapters[2], m))
+
+	for _, name := range []string{WebVTTFile, BIFFile, manifestFile, ChapterFile(0), ChapterFile(2)} {
+		_, err := os.Stat(path.Join(dir, name))
+		assert.NoError(t, err, name)
+	}
+}
@@ -0,0 +1,30 @@
+package trickplay
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WebVTT returns a thumbnail track that maps every interval to its tile in a sprite sheet, using
+// media fragments as understood by most web players.
+func (m *Manifest) WebVTT() string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+
+	perSprite := m.Columns * m.Rows
+	for i := 0; i < m.Thumbnails; i++ {
+		tile := i % perSprite
+		start := time.Duration(i*m.Interval) * time.Second
+		end := start + time.Duration(m.Interval)*time.Second
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			formatTimestamp(start), formatTimestamp(end), SpriteFile(i/perSprite),
+			(tile%m.Columns)*m.Width, (tile/m.Columns)*m.Height, m.Width, m.Height)
+	}
+	return b.String()
+}
+
+func formatTimestamp(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d",
+		int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60, d.Milliseconds()%1000)
+}